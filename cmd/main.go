@@ -2,23 +2,34 @@ package main
 
 import (
 	"context"
+	"crypto/rsa"
+	"database/sql"
 	"errors"
 	stdhttp "net/http"
 
 	"os"
 	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"testovoe_again/docs"
 	"testovoe_again/internal/config"
 	deliveryhttp "testovoe_again/internal/delivery/http"
+	apimw "testovoe_again/internal/delivery/http/middleware"
 	"testovoe_again/internal/logger"
+	"testovoe_again/internal/outbox"
+	"testovoe_again/internal/policy"
+	"testovoe_again/internal/ratelimit"
+	"testovoe_again/internal/rates"
 	"testovoe_again/internal/repository"
 	"testovoe_again/internal/service"
+	"testovoe_again/internal/users"
+	"testovoe_again/internal/webhook"
+	"testovoe_again/internal/worker"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"go.uber.org/zap"
@@ -29,6 +40,16 @@ import (
 // @description     сервис для управления подписками
 // @host            localhost:8080
 // @BasePath        /api/v1
+//
+// @securityDefinitions.apikey  BearerAuth
+// @in                          header
+// @name                        Authorization
+// @description                 JWT в формате "Bearer <token>"
+//
+// @securityDefinitions.apikey  ApiKeyAuth
+// @in                          header
+// @name                        X-API-Key
+// @description                 ключ для вызовов сервис-сервис
 func main() {
 	cfg, err := config.Load()
 	if err != nil {
@@ -44,7 +65,7 @@ func main() {
 
 	e := echo.New()
 
-	e.Validator = &deliveryhttp.Validator{Validater: validator.New()}
+	e.Validator = deliveryhttp.NewValidator()
 
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
@@ -60,13 +81,146 @@ func main() {
 	db.SetConnMaxLifetime(time.Duration(cfg.DB.ConnMaxLifetimeMin) * time.Minute)
 
 	repo := repository.NewPostgresRepo(db, log)
-	svc := service.NewSubscriptionService(log, repo)
-	handler := deliveryhttp.NewHandler(log, svc)
 
-	handler.Routing(e)
+	var ratesProvider rates.Provider
+	switch cfg.Rates.Source {
+	case "db":
+		ratesProvider = repository.NewExchangeRateRepo(db, log)
+	default:
+		ratesProvider, err = rates.NewFileProvider(cfg.Rates.File)
+		if err != nil {
+			log.Fatal("не удалось загрузить курсы валют", zap.Error(err))
+		}
+	}
+
+	catalog := service.NewCatalog(log, repository.NewCatalogRepo(db, log), ratesProvider, service.CatalogRules{
+		Required:     cfg.Catalog.Required,
+		EnforcePrice: cfg.Catalog.EnforcePrice,
+	})
+
+	// user_id подписок проверяется по таблице users или по внешнему справочнику с копией в ту же таблицу
+	userRepo := repository.NewUserRepo(db, log)
+	var directory users.Directory = userRepo
+	if cfg.Users.Directory == "stub" {
+		stub, err := users.NewStubDirectory(cfg.Users.StubFile)
+		if err != nil {
+			log.Fatal("не удалось загрузить справочник пользователей", zap.Error(err))
+		}
+		directory = users.NewMirror(stub, userRepo)
+	}
+	svc := service.NewSubscriptionService(log, repo, repository.NewTxManager(db, log), ratesProvider, cfg.Overlap.Policy, catalog, directory)
+
+	// политика доступа встаёт между хендлерами и сервисом, фоновые задачи ходят в сервис напрямую
+	policies, err := policy.NewStore(log, cfg.Policy.File)
+	if err != nil {
+		log.Fatal("не удалось загрузить политику доступа", zap.Error(err))
+	}
+
+	// события изменённых подписок пишутся в outbox вместе с изменением, релей отдаёт их публикатору
+	// и в очередь вебхуков, её разбирает воркер доставки
+	webhooks := service.NewWebhooks(log, repository.NewWebhookRepo(db, log), webhook.NewHTTPSender(cfg.WebhookTimeout(), cfg.Webhook.AllowPrivate), service.WebhookRules{
+		MaxAttempts: cfg.Webhook.MaxAttempts,
+		BackoffBase: cfg.WebhookBackoffBase(),
+		BackoffMax:  cfg.WebhookBackoffMax(),
+		Timeout:     cfg.WebhookTimeout(),
+		BatchSize:   cfg.Webhook.BatchSize,
+	})
+	publisher, err := newEventPublisher(cfg.Outbox, log)
+	if err != nil {
+		log.Fatal("не удалось настроить публикацию событий", zap.Error(err))
+	}
+	relay := service.NewOutboxRelay(log, repository.NewOutboxRepo(db, log), outbox.Fanout{publisher, webhooks},
+		cfg.Outbox.BatchSize, cfg.OutboxRetention())
+
+	handler := deliveryhttp.NewHandler(log, policy.NewService(svc, policies),
+		policy.NewCatalogService(catalog, policies), policy.NewUserService(service.NewUsers(log, userRepo), policies),
+		policy.NewWebhookService(webhooks, policies))
+
+	// все ошибки хендлеров и самого echo отдаются как application/problem+json
+	e.HTTPErrorHandler = handler.ErrorHandler
+	auth, err := newAuth(cfg.Auth, log, policies)
+	if err != nil {
+		log.Fatal("не удалось настроить аутентификацию", zap.Error(err))
+	}
+
+	// лимиты запросов по группам маршрутов, бакеты в памяти или в postgres
+	limit := func(string) echo.MiddlewareFunc { return passthrough }
+	var limitSweeper *worker.RateLimitSweeper
+	if cfg.Limits.Enabled {
+		limits, err := ratelimit.ParseLimits(cfg.Limits.Limits)
+		if err != nil {
+			log.Fatal("невалидные лимиты запросов", zap.Error(err))
+		}
+		store := newRateLimitStore(cfg.Limits, log, db)
+		limit = apimw.NewRateLimiter(log, store, limits).Group
+		limitSweeper = worker.NewRateLimitSweeper(log, store, limits)
+	}
+
+	idempotency := repository.NewIdempotencyRepo(db, log)
+	handler.Routing(e, deliveryhttp.RouteMiddleware{
+		Auth:        auth,
+		Limit:       limit,
+		Idempotency: apimw.Idempotency(log, idempotency, cfg.IdempotencyTTL()),
+	})
 	docs.SwaggerInfo.Host = cfg.Swagger.Host
 	docs.SwaggerInfo.BasePath = cfg.Swagger.BasePath
 
+	// фоновые задачи живут до сигнала на выключение
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+
+	purger := worker.NewTrashPurger(log, svc, cfg.TrashRetention(), cfg.TrashPurgeInterval())
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		purger.Run(workerCtx)
+	}()
+
+	expiry := worker.NewExpiryWorker(log, svc, cfg.ExpiryInterval(), cfg.AutoRenewLead(), cfg.Expiry.BatchSize)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		expiry.Run(workerCtx)
+	}()
+
+	outboxWorker := worker.NewOutboxWorker(log, relay, cfg.OutboxRelayInterval())
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		outboxWorker.Run(workerCtx)
+	}()
+
+	webhookWorker := worker.NewWebhookWorker(log, webhooks, cfg.WebhookInterval())
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		webhookWorker.Run(workerCtx)
+	}()
+
+	idempotencyPurger := worker.NewIdempotencyPurger(log, idempotency)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		idempotencyPurger.Run(workerCtx)
+	}()
+
+	if limitSweeper != nil {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			limitSweeper.Run(workerCtx)
+		}()
+	}
+
+	// kill -HUP перечитывает политику без рестарта
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		policies.ReloadOn(workerCtx, hup)
+	}()
+
 	go func() {
 		if err := e.Start(":" + cfg.HTTP.AppPort); err != nil && !errors.Is(err, stdhttp.ErrServerClosed) {
 			log.Fatal("выключение сервера...", zap.Error(err))
@@ -77,9 +231,63 @@ func main() {
 	signal.Notify(quit, os.Interrupt)
 	<-quit
 
+	stopWorkers()
+	workers.Wait()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := e.Shutdown(ctx); err != nil {
 		log.Fatal(err.Error())
 	}
 }
+
+// newAuth собирает middleware аутентификации из конфига. При выключенной аутентификации
+// запросы проходят без принципала, и проверки владения в хендлерах пропускаются
+func newAuth(cfg config.AuthConfig, log *zap.Logger, grants apimw.Grants) (echo.MiddlewareFunc, error) {
+	if !cfg.Enabled {
+		log.Warn("аутентификация выключена, API открыт всем")
+		return passthrough, nil
+	}
+
+	var verifier *apimw.JWTVerifier
+	if cfg.JWTSecret != "" || cfg.JWTPublicKeyFile != "" {
+		var publicKey *rsa.PublicKey
+		if cfg.JWTPublicKeyFile != "" {
+			key, err := apimw.LoadRSAPublicKey(cfg.JWTPublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			publicKey = key
+		}
+		verifier = apimw.NewJWTVerifier([]byte(cfg.JWTSecret), publicKey, cfg.JWTIssuer, cfg.JWTAudience)
+	}
+
+	keys, err := apimw.ParseAPIKeys(cfg.APIKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return apimw.NewAuthenticator(log, verifier, keys, grants).Middleware(), nil
+}
+
+// newRateLimitStore выбирает хранилище бакетов: память инстанса или общая для реплик таблица в postgres
+func newRateLimitStore(cfg config.RateLimitConfig, log *zap.Logger, db *sql.DB) ratelimit.Store {
+	if cfg.Store == "db" {
+		return repository.NewRateLimitRepo(db, log)
+	}
+	return ratelimit.NewMemoryStore()
+}
+
+func newEventPublisher(cfg config.OutboxConfig, log *zap.Logger) (outbox.EventPublisher, error) {
+	switch cfg.Publisher {
+	case "file":
+		return outbox.NewFilePublisher(cfg.File)
+	case "memory":
+		return outbox.NewMemoryPublisher(cfg.MemoryCapacity), nil
+	}
+	return outbox.NewLogPublisher(log), nil
+}
+
+func passthrough(next echo.HandlerFunc) echo.HandlerFunc {
+	return next
+}
@@ -32,61 +32,61 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/stats": {
-            "post": {
-                "description": "возвращает суммарную стоимость подписок по конкретному сервису за указанный период",
-                "consumes": [
-                    "application/json"
+        "/api/v1/services": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "все сервисы каталога с алиасами и ценами. Подписки на любое написание сервиса сохраняются под его каноническим названием",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "analytics"
-                ],
-                "summary": "рассчитать сумму затрат",
-                "parameters": [
-                    {
-                        "description": "параметры фильтрации (UserID, ServiceName, Dates)",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/http.GetStatsRequest"
-                        }
-                    }
+                    "services"
                 ],
+                "summary": "каталог сервисов",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/http.StatsResponse"
+                            "$ref": "#/definitions/http.CatalogResponse"
                         }
                     },
-                    "400": {
-                        "description": "невалидный айди пользователя",
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
                         }
                     },
                     "500": {
-                        "description": "ошибка расчёта суммы",
+                        "description": "ошибка сервера",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/http.Problem"
                         }
                     }
                 }
-            }
-        },
-        "/api/v1/subscriptions": {
+            },
             "post": {
-                "description": "создает новую запись о подписке и возвращает её тело",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "заводит сервис с каноническим названием и алиасами. Уже существующие подписки на любое из написаний привязываются к сервису. Цены в минорных единицах currency, min_price и max_price - необязательные границы цены подписки",
                 "consumes": [
                     "application/json"
                 ],
@@ -94,17 +94,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "services"
                 ],
-                "summary": "создать подписку",
+                "summary": "добавить сервис в каталог",
                 "parameters": [
                     {
-                        "description": "данные новой подписки",
+                        "description": "сервис",
                         "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/http.CreateSubscriptionRequest"
+                            "$ref": "#/definitions/http.CatalogServiceRequest"
                         }
                     }
                 ],
@@ -112,94 +112,69 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/http.CreateSubscriptionResponse"
+                            "$ref": "#/definitions/domain.CatalogEntry"
                         }
                     },
                     "400": {
-                        "description": "не удалось обработать запрос",
+                        "description": "невалидный сервис",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/http.Problem"
                         }
                     },
-                    "500": {
-                        "description": "ошибка сервера",
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/http.Problem"
                         }
-                    }
-                }
-            }
-        },
-        "/api/v1/subscriptions/list/{user_id}": {
-            "get": {
-                "description": "возвращает все активные подписки конкретного пользователя по его UUID",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "subscriptions"
-                ],
-                "summary": "список подписок пользователя",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "UUID пользователя",
-                        "name": "user_id",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/http.CreateSubscriptionResponse"
-                            }
+                            "$ref": "#/definitions/http.Problem"
                         }
                     },
-                    "400": {
-                        "description": "невалидный айди пользователя",
+                    "409": {
+                        "description": "название или алиас заняты другим сервисом",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
                         }
                     },
                     "500": {
-                        "description": "не удалось получить подписки",
+                        "description": "ошибка сервера",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/http.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/v1/subscriptions/{id}": {
+        "/api/v1/services/{id}": {
             "get": {
-                "description": "возвращает данные конкретной подписки по её уникальному идентификатору",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "services"
                 ],
-                "summary": "получить подписку по ID",
+                "summary": "сервис каталога",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "ID подписки",
+                        "description": "ID сервиса",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -209,40 +184,51 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/http.CreateSubscriptionResponse"
+                            "$ref": "#/definitions/domain.CatalogEntry"
                         }
                     },
                     "400": {
-                        "description": "невалидный ID",
+                        "description": "невалидный id",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
                         }
                     },
                     "404": {
-                        "description": "подписка не найдена",
+                        "description": "сервиса нет в каталоге",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
                         }
                     },
                     "500": {
                         "description": "ошибка сервера",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/http.Problem"
                         }
                     }
                 }
             },
             "put": {
-                "description": "обновляет данные существующей подписки по её ID и требует полное тело запроса",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "перезаписывает сервис целиком, включая набор алиасов. Подписки хранят название, под которым были созданы, в отчётах сервис идёт под новым каноническим названием",
                 "consumes": [
                     "application/json"
                 ],
@@ -250,70 +236,96 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "services"
                 ],
-                "summary": "обновить подписку",
+                "summary": "обновить сервис каталога",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "ID подписки",
+                        "description": "ID сервиса",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "новые данные подписки",
+                        "description": "сервис",
                         "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/http.CreateSubscriptionRequest"
+                            "$ref": "#/definitions/http.CatalogServiceRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.CatalogEntry"
+                        }
                     },
                     "400": {
-                        "description": "невалидный ID или тело запроса",
+                        "description": "невалидный id или сервис",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
                         }
                     },
                     "404": {
-                        "description": "подписка не найдена",
+                        "description": "сервиса нет в каталоге",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "название или алиас заняты другим сервисом",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
                         }
                     },
                     "500": {
                         "description": "ошибка сервера",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/http.Problem"
                         }
                     }
                 }
             },
             "delete": {
-                "description": "удаляет запись о подписке из базы данных по её ID",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "подписки на сервис остаются со своим названием, но теряют привязку к каталогу",
                 "tags": [
-                    "subscriptions"
+                    "services"
                 ],
-                "summary": "удалить подписку",
+                "summary": "удалить сервис из каталога",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "id подписки",
+                        "description": "ID сервиса",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -326,60 +338,3507 @@ const docTemplate = `{
                     "400": {
                         "description": "невалидный id",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "сервиса нет в каталоге",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
                         }
                     },
                     "500": {
-                        "description": "ошибка удаления",
+                        "description": "ошибка сервера",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/http.Problem"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "http.CreateSubscriptionRequest": {
-            "type": "object",
-            "required": [
-                "price",
-                "service_name",
-                "start_date",
-                "user_id"
-            ],
-            "properties": {
-                "end_date": {
-                    "type": "string",
-                    "example": "08-2025"
-                },
-                "price": {
-                    "type": "integer",
-                    "example": 400
-                },
-                "service_name": {
-                    "type": "string",
-                    "example": "Yandex Plus"
-                },
-                "start_date": {
-                    "type": "string",
-                    "example": "07-2025"
-                },
-                "user_id": {
-                    "type": "string",
-                    "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+        },
+        "/api/v1/stats": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "возвращает суммарную стоимость подписок по конкретному сервису за указанный период (границы включительно, MM-YYYY или YYYY-MM-DD): от каждого расчётного периода подписки в сумму идёт доля цены, пропорциональная дням внутри периода. Итог пересчитывается в currency (по умолчанию RUB): total_sum в целых единицах валюты, total_sum_minor - в минорных",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "analytics"
+                ],
+                "summary": "рассчитать сумму затрат",
+                "parameters": [
+                    {
+                        "description": "параметры фильтрации (UserID, ServiceName, Dates)",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.GetStatsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.StatsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный айди пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "подписки другого пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка расчёта суммы",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stats/monthly": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "возвращает сумму трат по каждому месяцу периода, опционально для одного пользователя и/или сервиса",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "analytics"
+                ],
+                "summary": "помесячные траты",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "название сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "начало периода (MM-YYYY или YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "конец периода включительно (MM-YYYY или YYYY-MM-DD)",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "валюта отчёта (по умолчанию RUB)",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/http.SpendRowResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный запрос",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "подписки другого пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка расчёта статистики",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stats/services": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "возвращает сумму трат за период по каждому сервису, от самого дорогого",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "analytics"
+                ],
+                "summary": "траты в разрезе сервисов",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "название сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "начало периода (MM-YYYY или YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "конец периода включительно (MM-YYYY или YYYY-MM-DD)",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "валюта отчёта (по умолчанию RUB)",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/http.SpendRowResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный запрос",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "подписки другого пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка расчёта статистики",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stats/top": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "возвращает N сервисов с наибольшими тратами за период среди всех пользователей",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "analytics"
+                ],
+                "summary": "топ сервисов по тратам",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "название сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "начало периода (MM-YYYY или YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "конец периода включительно (MM-YYYY или YYYY-MM-DD)",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "валюта отчёта (по умолчанию RUB)",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "размер топа (по умолчанию 10, максимум 100)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/http.SpendRowResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный запрос",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "нужен доступ ко всем пользователям или операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка расчёта статистики",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "возвращает страницу подписок по фильтрам, отсортированную по ключу sort; следующая страница запрашивается по next_cursor",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "листинг подписок с фильтрами и пагинацией",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "название сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "валюта подписки (RUB, USD, EUR)",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "минимальная цена в целых единицах валюты",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "максимальная цена в целых единицах валюты",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "подписка активна на дату (MM-YYYY или YYYY-MM-DD)",
+                        "name": "active_at",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "start_date не раньше (MM-YYYY или YYYY-MM-DD)",
+                        "name": "start_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "start_date не позже (MM-YYYY или YYYY-MM-DD)",
+                        "name": "start_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "end_date не раньше (MM-YYYY или YYYY-MM-DD)",
+                        "name": "end_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "end_date не позже (MM-YYYY или YYYY-MM-DD)",
+                        "name": "end_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "id | service_name | price | start_date, префикс - для убывания",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "размер страницы (по умолчанию 50, максимум 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor из предыдущего ответа",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.ListSubscriptionsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "невалидные параметры запроса",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "подписки другого пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "не удалось получить подписки",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "создает новую запись о подписке и возвращает её тело. Цена - price в целых единицах валюты или price_minor в минорных, в ответе есть оба поля. Название сервиса сверяется с каталогом: любое написание из каталога сохраняется под каноническим названием с service_id. user_id должен быть в справочнике пользователей, иначе 400 invalid_user_id. Пересечение по датам с другой подпиской пользователя на тот же сервис обрабатывается по политике OVERLAP_POLICY: reject - 409, warn - подписка создаётся, id пересечений приходят в overlaps, merge - новые данные ложатся в существующую подписку с объединённым периодом (200 вместо 201), поглощённые подписки уходят в корзину и перечислены в merged_ids. С заголовком Idempotency-Key повтор запроса с тем же телом отдаёт исходный ответ (с Idempotent-Replayed: true) и не создаёт дубликат",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "создать подписку",
+                "parameters": [
+                    {
+                        "description": "данные новой подписки",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateSubscriptionRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ключ идемпотентности, до 255 символов",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "подписка слита с существующей",
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateSubscriptionResponse"
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateSubscriptionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "не удалось обработать запрос или пользователя нет в справочнике",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "подписка другого пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "пересечение с другой подпиской сервиса или запрос с этим Idempotency-Key ещё выполняется",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "сервиса нет в каталоге (CATALOG_REQUIRED) или Idempotency-Key уже использован с другим телом",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/conflicts": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "пары живых подписок одного пользователя на один сервис, периоды которых пересекаются, с границами пересечения (from/to включительно, to пустой у бессрочного пересечения). Такие пары остаются после политики warn или от данных, записанных до появления проверки",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "пересечения подписок",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.ConflictsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный user_id",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "подписки другого пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/export": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "отдаёт все подписки под фильтрами листинга одним потоком (chunked), без пагинации: csv с заголовком или ndjson - по объекту подписки на строку. price в целых единицах валюты, как в GetByID, точная цена в price_minor. Если выгрузка оборвалась на середине, соединение закрывается без завершающего chunk'а, чтобы обрыв нельзя было принять за конец данных",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "выгрузка подписок",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "csv | ndjson",
+                        "name": "format",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "название сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "валюта подписки (RUB, USD, EUR)",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "минимальная цена в целых единицах валюты",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "максимальная цена в целых единицах валюты",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "подписка активна на дату (MM-YYYY или YYYY-MM-DD)",
+                        "name": "active_at",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "start_date не раньше (MM-YYYY или YYYY-MM-DD)",
+                        "name": "start_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "start_date не позже (MM-YYYY или YYYY-MM-DD)",
+                        "name": "start_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "end_date не раньше (MM-YYYY или YYYY-MM-DD)",
+                        "name": "end_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "end_date не позже (MM-YYYY или YYYY-MM-DD)",
+                        "name": "end_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "id | service_name | price | start_date, префикс - для убывания",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "поток строк выгрузки",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "невалидные параметры запроса",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "подписки другого пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "не удалось выгрузить подписки",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/import": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "multipart-форма: необязательное поле mapping (JSON \"поле подписки\": \"колонка файла\") и поле file с .csv или .xlsx. Поле mapping должно идти до file. Первая строка файла - заголовок, без маппинга колонки ищутся по именам полей (service_name, price, price_minor, currency, user_id, start_date, end_date, billing_period, billing_period_days). Цена - price в целых единицах валюты или price_minor в минорных, как в теле создания. Каждая строка проходит те же проверки, что и при создании подписки, включая политику пересечений; dry_run=true только проверяет файл",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "импорт подписок из CSV или XLSX",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "только проверить файл, ничего не сохраняя",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "JSON с маппингом полей на колонки файла",
+                        "name": "mapping",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "file",
+                        "description": "CSV или XLSX файл",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.ImportReport"
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный запрос или файл",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "нужен доступ ко всем пользователям или операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "файл слишком большой",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/list/{user_id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "возвращает все активные подписки конкретного пользователя по его UUID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "список подписок пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/http.CreateSubscriptionResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный айди пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "подписки другого пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "не удалось получить подписки",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/trash": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "возвращает страницу мягко удалённых подписок; фильтры, сортировка и пагинация те же, что у листинга",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "корзина подписок",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "название сервиса",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "валюта подписки (RUB, USD, EUR)",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "id | service_name | price | start_date, префикс - для убывания",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "размер страницы (по умолчанию 50, максимум 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor из предыдущего ответа",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.ListSubscriptionsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "невалидные параметры запроса",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "подписки другого пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "не удалось получить корзину",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "возвращает данные конкретной подписки по её уникальному идентификатору",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "получить подписку по ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag закэшированной версии",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateSubscriptionResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "версия подписки"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "400": {
+                        "description": "невалидный ID",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "обновляет данные существующей подписки по её ID и требует полное тело запроса. status из тела не применяется, для смены статуса есть pause/resume/cancel/renew",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "обновить подписку",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "новые данные подписки",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateSubscriptionRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag версии, которую редактирует клиент",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content",
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный ID или тело запроса",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "user_id другого пользователя",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "пересечение с другой подпиской сервиса (политика reject)",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "подписка изменилась с момента чтения",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "мягко удаляет подписку по её ID: запись уходит в корзину и пропадает из чтений и статистики, восстановить её можно до очистки корзины",
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "удалить подписку",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "id подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag версии, которую удаляет клиент",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "невалидный id",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "подписка изменилась с момента чтения",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка удаления",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "принимает JSON Merge Patch (RFC 7396): отсутствующие ключи не меняются, null очищает end_date и billing_period_days. Цена - price в целых единицах валюты или price_minor в минорных",
+                "consumes": [
+                    "application/merge-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "частично обновить подписку",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "изменяемые поля",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.PatchSubscriptionRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag версии, которую редактирует клиент",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateSubscriptionResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный ID или документ",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "пересечение с другой подпиской сервиса (политика reject)",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "подписка изменилась с момента чтения",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "неподдерживаемый Content-Type",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}/cancel": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "trial, active или paused -\u003e cancelled. Текущий расчётный период остаётся оплаченным: end_date становится сегодняшним днём, если была позже или не была задана, и auto_renew выключается. Ещё не начавшуюся подписку отменить нельзя (409) - её удаляют",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "отменить подписку",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag версии, которую видел клиент",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateSubscriptionResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный id",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "действие недоступно в текущем статусе или подписка ещё не началась",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "подписка изменилась с момента чтения",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}/history": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "возвращает все изменения подписки от старых к новым: кто, когда, какая операция и снимки до/после. Журнал доступен и для удалённых подписок",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "журнал изменений подписки",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/http.AuditEntryResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный id",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}/pause": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "active -\u003e paused с сегодняшнего дня. Дни на паузе не входят в траты подписки",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "поставить подписку на паузу",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag версии, которую видел клиент",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateSubscriptionResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный id",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "действие недоступно в текущем статусе",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "подписка изменилась с момента чтения",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}/renew": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "trial, active или cancelled -\u003e active, end_date сдвигается на один расчётный период. trial без end_date просто становится платной, закончившуюся (expired) подписку не продлить",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "продлить подписку",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag версии, которую видел клиент",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateSubscriptionResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный id",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "действие недоступно в текущем статусе или продление пересекается с другой подпиской сервиса",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "подписка изменилась с момента чтения",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "возвращает мягко удалённую подписку обратно, пока корзина не очищена",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "восстановить подписку из корзины",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateSubscriptionResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный id",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "подписки нет в корзине",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "период подписки уже занят другой подпиской сервиса",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions/{id}/resume": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "paused -\u003e active с сегодняшнего дня",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "снять подписку с паузы",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag версии, которую видел клиент",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateSubscriptionResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный id",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "подписка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "действие недоступно в текущем статусе",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "подписка изменилась с момента чтения",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/subscriptions:batch": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "выполняет пакет create/update/delete (до 1000 операций) за один запрос. atomic - всё или ничего: при любой ошибке пакет откатывается и отдаётся 422 с результатами по каждой операции; best_effort - каждая операция выполняется сама по себе, итог по каждой в results",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "пакетные операции над подписками",
+                "parameters": [
+                    {
+                        "description": "пакет операций",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.BatchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.BatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный запрос",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "нужен доступ ко всем пользователям или операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "atomic пакет откатился",
+                        "schema": {
+                            "$ref": "#/definitions/http.BatchResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "страница пользователей по возрастанию id, следующая страница запрашивается по next_cursor. Доступно только принципалам с доступом ко всем пользователям",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "справочник пользователей",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "размер страницы (по умолчанию 50, максимум 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor из предыдущего ответа",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.ListUsersResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "невалидные параметры запроса",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "нет доступа ко всем пользователям",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "заводит пользователя в справочник, только после этого на него можно создавать подписки. id задаёт клиент - это тот же UUID, что в токене и user_id подписок",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "добавить пользователя",
+                "parameters": [
+                    {
+                        "description": "пользователь",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/domain.User"
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный пользователь",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "пользователь с таким id или email уже есть",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "пользователь справочника",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.User"
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный uuid",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "другой пользователь",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "пользователь не найден",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "перезаписывает email и имя пользователя, email без значения стирается",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "обновить пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "пользователь",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.UpdateUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.User"
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный uuid или пользователь",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "пользователь не найден",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "email занят другим пользователем",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "удаляет пользователя без подписок. Подписки в корзине тоже считаются: сначала их нужно очистить",
+                "tags": [
+                    "users"
+                ],
+                "summary": "удалить пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UUID пользователя",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "невалидный uuid",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "пользователь не найден",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "у пользователя есть подписки",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/webhooks": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "все вебхуки на события подписок, без секретов",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "вебхуки",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.WebhooksResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "после создания, обновления и удаления подписки на url POST-ом уходит событие {id, type, occurred_at, actor, subscription}. Тело подписано: X-Webhook-Signature = \"sha256=\" + hex(HMAC-SHA256(secret, X-Webhook-Timestamp + \".\" + тело)). id события в X-Webhook-Id не меняется между повторами. Ответ не 2xx или таймаут - повтор с экспоненциальной задержкой, после последней попытки доставка уходит в dead-letter. Секрет возвращается только в ответе на создание",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "добавить вебхук",
+                "parameters": [
+                    {
+                        "description": "вебхук",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.WebhookRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/domain.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный вебхук",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/webhooks/dead-letters": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "доставки, исчерпавшие попытки, от новых к старым. Следующая страница запрашивается по next_cursor",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "dead-letter список",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "только доставки этого вебхука",
+                        "name": "webhook_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "размер страницы (по умолчанию 50, максимум 500)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "next_cursor из предыдущего ответа",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/http.DeadLettersResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "невалидные параметры запроса",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/webhooks/deliveries/{id}/replay": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "возвращает доставку из dead-letter списка в очередь с обнулённым счётчиком попыток, воркер отправит её на ближайшем проходе с тем же id события",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "повторить доставку",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID доставки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/domain.WebhookDelivery"
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный id",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "доставка не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "доставка не в dead-letter списке",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/webhooks/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "вебхук",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID вебхука",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный id",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "вебхук не найден",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "перезаписывает адрес, события и активность вебхука, без secret секрет остаётся прежним. Доставки выключенного вебхука ждут в очереди, пока его не включат",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "обновить вебхук",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID вебхука",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "вебхук",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.WebhookRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/domain.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "невалидный id или вебхук",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "вебхук не найден",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    },
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "удаляет вебхук вместе с очередью и dead-letter списком его доставок",
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "удалить вебхук",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID вебхука",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "невалидный id",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "нет или невалидные учётные данные",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "операция запрещена роли",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "вебхук не найден",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "превышен лимит запросов, см. Retry-After",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/http.Problem"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "domain.CatalogEntry": {
+            "type": "object",
+            "properties": {
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "yandex plus",
+                        "Яндекс Плюс"
+                    ]
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2025-08-01T12:00:00Z"
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "default_price": {
+                    "type": "integer",
+                    "example": 39900
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "max_price": {
+                    "type": "integer",
+                    "example": 99900
+                },
+                "min_price": {
+                    "type": "integer",
+                    "example": 19900
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Yandex Plus"
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2025-08-01T12:00:00Z"
+                }
+            }
+        },
+        "domain.Conflict": {
+            "type": "object",
+            "properties": {
+                "first_id": {
+                    "type": "integer"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "second_id": {
+                    "type": "integer"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "domain.ImportReport": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer",
+                    "example": 118
+                },
+                "dry_run": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.ImportRowError"
+                    }
+                },
+                "errors_truncated": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "invalid": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 120
+                },
+                "valid": {
+                    "type": "integer",
+                    "example": 118
+                }
+            }
+        },
+        "domain.ImportRowError": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "invalid_price"
+                },
+                "error": {
+                    "type": "string",
+                    "example": "указана невалидная цена"
+                },
+                "field": {
+                    "type": "string",
+                    "example": "price"
+                },
+                "row": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "domain.User": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string",
+                    "example": "2025-08-01T12:00:00Z"
+                },
+                "email": {
+                    "type": "string",
+                    "example": "user@example.com"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Иван Иванов"
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2025-08-01T12:00:00Z"
+                }
+            }
+        },
+        "domain.Webhook": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2025-08-01T12:00:00Z"
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "subscription.created",
+                        "subscription.deleted"
+                    ]
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "secret": {
+                    "type": "string",
+                    "example": "whsec_3f9a..."
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2025-08-01T12:00:00Z"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://billing.example.com/hooks/subscriptions"
+                }
+            }
+        },
+        "domain.WebhookDelivery": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer",
+                    "example": 8
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "delivered_at": {
+                    "type": "string"
+                },
+                "event": {
+                    "type": "string",
+                    "example": "subscription.created"
+                },
+                "event_id": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "last_error": {
+                    "type": "string",
+                    "example": "unexpected status 503"
+                },
+                "last_status": {
+                    "type": "integer",
+                    "example": 503
+                },
+                "next_attempt_at": {
+                    "type": "string"
+                },
+                "payload": {
+                    "type": "object"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "dead"
+                },
+                "webhook_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "http.AuditEntryResponse": {
+            "type": "object",
+            "properties": {
+                "actor": {
+                    "type": "string",
+                    "example": "anonymous"
+                },
+                "after": {
+                    "type": "object"
+                },
+                "before": {
+                    "type": "object"
+                },
+                "changed_at": {
+                    "type": "string",
+                    "example": "2025-08-01T12:00:00Z"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "operation": {
+                    "type": "string",
+                    "example": "update"
+                },
+                "subscription_id": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "http.BatchItemResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "code и field - те же, что отдал бы отдельный запрос в problem+json",
+                    "type": "string",
+                    "example": "invalid_price"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "field": {
+                    "type": "string",
+                    "example": "price"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "index": {
+                    "type": "integer",
+                    "example": 0
+                },
+                "op": {
+                    "type": "string",
+                    "example": "create"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 201
+                },
+                "version": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "http.BatchOperationRequest": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "op": {
+                    "type": "string",
+                    "example": "create"
+                },
+                "subscription": {
+                    "$ref": "#/definitions/http.CreateSubscriptionRequest"
+                },
+                "version": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "http.BatchRequest": {
+            "type": "object",
+            "required": [
+                "operations"
+            ],
+            "properties": {
+                "mode": {
+                    "type": "string",
+                    "enum": [
+                        "atomic",
+                        "best_effort"
+                    ],
+                    "example": "atomic"
+                },
+                "operations": {
+                    "type": "array",
+                    "maxItems": 1000,
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/http.BatchOperationRequest"
+                    }
+                }
+            }
+        },
+        "http.BatchResponse": {
+            "type": "object",
+            "properties": {
+                "committed": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "mode": {
+                    "type": "string",
+                    "example": "atomic"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/http.BatchItemResponse"
+                    }
+                }
+            }
+        },
+        "http.CatalogResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.CatalogEntry"
+                    }
+                }
+            }
+        },
+        "http.CatalogServiceRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "Яндекс Плюс"
+                    ]
+                },
+                "currency": {
+                    "type": "string",
+                    "enum": [
+                        "RUB",
+                        "USD",
+                        "EUR"
+                    ],
+                    "example": "RUB"
+                },
+                "default_price": {
+                    "type": "integer",
+                    "minimum": 0,
+                    "example": 39900
+                },
+                "max_price": {
+                    "type": "integer",
+                    "minimum": 0,
+                    "example": 99900
+                },
+                "min_price": {
+                    "type": "integer",
+                    "minimum": 0,
+                    "example": 19900
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Yandex Plus"
+                }
+            }
+        },
+        "http.ConflictsResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.Conflict"
+                    }
+                }
+            }
+        },
+        "http.CreateSubscriptionRequest": {
+            "type": "object",
+            "required": [
+                "service_name",
+                "start_date",
+                "user_id"
+            ],
+            "properties": {
+                "auto_renew": {
+                    "description": "AutoRenew - продлевать подписку на расчётный период по прошествии end_date",
+                    "type": "boolean",
+                    "example": false
+                },
+                "billing_period": {
+                    "type": "string",
+                    "enum": [
+                        "weekly",
+                        "monthly",
+                        "quarterly",
+                        "yearly",
+                        "custom"
+                    ],
+                    "example": "monthly"
+                },
+                "billing_period_days": {
+                    "type": "integer",
+                    "example": 30
+                },
+                "currency": {
+                    "type": "string",
+                    "enum": [
+                        "RUB",
+                        "USD",
+                        "EUR"
+                    ],
+                    "example": "RUB"
+                },
+                "end_date": {
+                    "type": "string",
+                    "example": "08-2025"
+                },
+                "price": {
+                    "type": "integer",
+                    "example": 399
+                },
+                "price_minor": {
+                    "type": "integer",
+                    "example": 39900
+                },
+                "service_name": {
+                    "type": "string",
+                    "example": "Yandex Plus"
+                },
+                "start_date": {
+                    "type": "string",
+                    "example": "07-2025"
+                },
+                "status": {
+                    "description": "Status - только при создании, дальше статус меняется действиями pause/resume/cancel/renew",
+                    "type": "string",
+                    "enum": [
+                        "trial",
+                        "active"
+                    ],
+                    "example": "active"
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba"
                 }
             }
         },
         "http.CreateSubscriptionResponse": {
             "type": "object",
             "properties": {
+                "auto_renew": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "billing_period": {
+                    "type": "string",
+                    "example": "monthly"
+                },
+                "billing_period_days": {
+                    "type": "integer",
+                    "example": 30
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "deleted_at": {
+                    "description": "DeletedAt заполнен только у подписок из корзины",
+                    "type": "string",
+                    "example": "2025-08-01T12:00:00Z"
+                },
                 "end_date": {
                     "type": "string",
                     "example": "08-2025"
@@ -388,9 +3847,44 @@ const docTemplate = `{
                     "type": "integer",
                     "example": 1
                 },
+                "merged_ids": {
+                    "description": "MergedIDs - id подписок, поглощённых при слиянии (политика merge) и ушедших в корзину",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        5
+                    ]
+                },
+                "overlap_allowed": {
+                    "description": "OverlapAllowed - пересечение с другими подписками сервиса сохранено осознанно (политика warn)",
+                    "type": "boolean",
+                    "example": false
+                },
+                "overlaps": {
+                    "description": "Overlaps - id пересекающихся подписок, только в ответе на запись с политикой warn",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        3,
+                        7
+                    ]
+                },
                 "price": {
                     "type": "integer",
-                    "example": 400
+                    "example": 399
+                },
+                "price_minor": {
+                    "type": "integer",
+                    "example": 39900
+                },
+                "service_id": {
+                    "description": "ServiceID - сервис каталога, с которым сопоставлено название",
+                    "type": "integer",
+                    "example": 1
                 },
                 "service_name": {
                     "type": "string",
@@ -400,9 +3894,66 @@ const docTemplate = `{
                     "type": "string",
                     "example": "07-2025"
                 },
+                "status": {
+                    "description": "Status - trial, active, paused, cancelled или expired",
+                    "type": "string",
+                    "example": "active"
+                },
                 "user_id": {
                     "type": "string",
                     "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+                },
+                "version": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "http.CreateUserRequest": {
+            "type": "object",
+            "required": [
+                "id"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "user@example.com"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Иван Иванов"
+                }
+            }
+        },
+        "http.DeadLettersResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.WebhookDelivery"
+                    }
+                },
+                "next_cursor": {
+                    "type": "integer",
+                    "example": 42
+                }
+            }
+        },
+        "http.FieldProblem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "required"
+                },
+                "field": {
+                    "type": "string",
+                    "example": "service_name"
                 }
             }
         },
@@ -415,6 +3966,15 @@ const docTemplate = `{
                 "user_id"
             ],
             "properties": {
+                "currency": {
+                    "type": "string",
+                    "enum": [
+                        "RUB",
+                        "USD",
+                        "EUR"
+                    ],
+                    "example": "RUB"
+                },
                 "first_date": {
                     "type": "string",
                     "example": "01-2025"
@@ -431,17 +3991,225 @@ const docTemplate = `{
                 }
             }
         },
+        "http.ListSubscriptionsResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/http.CreateSubscriptionResponse"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string",
+                    "example": "eyJzIjoiaWQiLCJkIjpmYWxzZSwidiI6IjUwIiwiaWQiOjUwfQ"
+                }
+            }
+        },
+        "http.ListUsersResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.User"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string",
+                    "example": "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+                }
+            }
+        },
+        "http.PatchSubscriptionRequest": {
+            "type": "object",
+            "properties": {
+                "billing_period": {
+                    "type": "string",
+                    "example": "monthly"
+                },
+                "billing_period_days": {
+                    "type": "integer",
+                    "example": 30
+                },
+                "currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "end_date": {
+                    "type": "string",
+                    "example": "08-2025"
+                },
+                "price": {
+                    "type": "integer",
+                    "example": 499
+                },
+                "price_minor": {
+                    "type": "integer",
+                    "example": 49900
+                },
+                "service_name": {
+                    "type": "string",
+                    "example": "Yandex Plus"
+                },
+                "start_date": {
+                    "type": "string",
+                    "example": "07-2025"
+                }
+            }
+        },
+        "http.Problem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "invalid_price"
+                },
+                "detail": {
+                    "type": "string",
+                    "example": "указана невалидная цена"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/http.FieldProblem"
+                    }
+                },
+                "field": {
+                    "type": "string",
+                    "example": "price"
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/api/v1/subscriptions"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 400
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Bad Request"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "urn:subscriptions:problem:invalid_price"
+                }
+            }
+        },
+        "http.SpendRowResponse": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
+                "period": {
+                    "type": "string",
+                    "example": "07-2025"
+                },
+                "service_name": {
+                    "type": "string",
+                    "example": "Yandex Plus"
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 1200
+                },
+                "total_minor": {
+                    "type": "integer",
+                    "example": 120000
+                }
+            }
+        },
         "http.StatsResponse": {
             "type": "object",
             "properties": {
+                "currency": {
+                    "type": "string",
+                    "example": "RUB"
+                },
                 "total_sum": {
                     "type": "integer",
                     "example": 1200
                 },
+                "total_sum_minor": {
+                    "type": "integer",
+                    "example": 120000
+                },
                 "user_id": {
                     "type": "string"
                 }
             }
+        },
+        "http.UpdateUserRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "user@example.com"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Иван Иванов"
+                }
+            }
+        },
+        "http.WebhookRequest": {
+            "type": "object",
+            "required": [
+                "url"
+            ],
+            "properties": {
+                "active": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "subscription.created",
+                        "subscription.deleted"
+                    ]
+                },
+                "secret": {
+                    "type": "string",
+                    "minLength": 16,
+                    "example": "whsec_3f9a..."
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://billing.example.com/hooks/subscriptions"
+                }
+            }
+        },
+        "http.WebhooksResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.Webhook"
+                    }
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "description": "ключ для вызовов сервис-сервис",
+            "type": "apiKey",
+            "name": "X-API-Key",
+            "in": "header"
+        },
+        "BearerAuth": {
+            "description": "JWT в формате \"Bearer \u003ctoken\u003e\"",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
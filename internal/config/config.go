@@ -32,11 +32,117 @@ type SwaggerConfig struct {
 	BasePath string `env:"SWAGGER_BASE_PATH" envDefault:"/api/v1"`
 }
 
+// RatesConfig - откуда брать курсы валют: file - json-файл, db - таблица exchange_rates
+type RatesConfig struct {
+	Source string `env:"RATES_SOURCE" envDefault:"file"`
+	File   string `env:"RATES_FILE" envDefault:"configs/rates.json"`
+}
+
+// TrashConfig - сколько мягко удалённые подписки лежат в корзине и как часто корзина чистится
+type TrashConfig struct {
+	RetentionHours   int `env:"TRASH_RETENTION_HOURS" envDefault:"720"`
+	PurgeIntervalMin int `env:"TRASH_PURGE_INTERVAL_MIN" envDefault:"60"`
+}
+
+// ExpiryConfig - воркер истечения подписок: как часто он проходит по подпискам, сколько берёт за одну транзакцию
+// и за сколько дней до end_date продлевает подписки с auto_renew
+type ExpiryConfig struct {
+	IntervalMin   int `env:"EXPIRY_INTERVAL_MIN" envDefault:"60"`
+	BatchSize     int `env:"EXPIRY_BATCH_SIZE" envDefault:"100"`
+	RenewLeadDays int `env:"AUTO_RENEW_LEAD_DAYS" envDefault:"1"`
+}
+
+// WebhookConfig - доставка вебхуков: как часто воркер смотрит в очередь, таймаут запроса, число попыток
+// и экспоненциальная задержка между ними от BackoffBaseSec до BackoffMaxMin
+type WebhookConfig struct {
+	IntervalSec    int `env:"WEBHOOK_INTERVAL_SEC" envDefault:"5"`
+	TimeoutSec     int `env:"WEBHOOK_TIMEOUT_SEC" envDefault:"10"`
+	MaxAttempts    int `env:"WEBHOOK_MAX_ATTEMPTS" envDefault:"8"`
+	BackoffBaseSec int `env:"WEBHOOK_BACKOFF_BASE_SEC" envDefault:"30"`
+	BackoffMaxMin  int `env:"WEBHOOK_BACKOFF_MAX_MIN" envDefault:"360"`
+	BatchSize      int `env:"WEBHOOK_BATCH_SIZE" envDefault:"50"`
+	// AllowPrivate - разрешить вебхуки на внутренние адреса (localhost, частные сети), только для локального запуска
+	AllowPrivate bool `env:"WEBHOOK_ALLOW_PRIVATE" envDefault:"false"`
+}
+
+// OutboxConfig - релей outbox: куда публиковать события (log, memory или file), как часто и сколько за раз,
+// и сколько часов хранить уже опубликованные. Вебхуки получают события при любом публикаторе
+type OutboxConfig struct {
+	Publisher        string `env:"OUTBOX_PUBLISHER" envDefault:"log"`
+	File             string `env:"OUTBOX_FILE" envDefault:"outbox.jsonl"`
+	MemoryCapacity   int    `env:"OUTBOX_MEMORY_CAPACITY" envDefault:"1000"`
+	RelayIntervalSec int    `env:"OUTBOX_RELAY_INTERVAL_SEC" envDefault:"1"`
+	BatchSize        int    `env:"OUTBOX_BATCH_SIZE" envDefault:"100"`
+	RetentionHours   int    `env:"OUTBOX_RETENTION_HOURS" envDefault:"168"`
+}
+
+// AuthConfig - аутентификация API: JWT (HS256 по секрету и/или RS256 по публичному ключу) и API-ключи
+// для вызовов сервис-сервис в формате name:key через запятую. Выключать стоит только локально
+type AuthConfig struct {
+	Enabled          bool     `env:"AUTH_ENABLED" envDefault:"true"`
+	JWTSecret        string   `env:"AUTH_JWT_SECRET"`
+	JWTPublicKeyFile string   `env:"AUTH_JWT_PUBLIC_KEY_FILE"`
+	JWTIssuer        string   `env:"AUTH_JWT_ISSUER"`
+	JWTAudience      string   `env:"AUTH_JWT_AUDIENCE"`
+	APIKeys          []string `env:"AUTH_API_KEYS" envSeparator:","`
+}
+
+// PolicyConfig - файл с ролями и разрешёнными им операциями, перечитывается по SIGHUP
+type PolicyConfig struct {
+	File string `env:"POLICY_FILE" envDefault:"configs/policy.json"`
+}
+
+// RateLimitConfig - лимиты запросов по группам маршрутов (subscriptions, batch, import, export, stats и default
+// для остальных) в формате group=N/s|m|h[:burst]. Бакеты живут в памяти инстанса (memory) или в postgres (db), общем для всех реплик
+type RateLimitConfig struct {
+	Enabled bool              `env:"RATE_LIMIT_ENABLED" envDefault:"true"`
+	Store   string            `env:"RATE_LIMIT_STORE" envDefault:"memory"`
+	Limits  map[string]string `env:"RATE_LIMITS" envSeparator:"," envKeyValSeparator:"=" envDefault:"default=20/s:40,stats=5/s:10,batch=1/s:2,import=6/m:2,export=6/m:2"`
+}
+
+// IdempotencyConfig - сколько хранится ответ на запрос с Idempotency-Key
+type IdempotencyConfig struct {
+	TTLHours int `env:"IDEMPOTENCY_TTL_HOURS" envDefault:"24"`
+}
+
+// OverlapConfig - что делать с подпиской, период которой пересекается с другой подпиской пользователя
+// на тот же сервис: reject - отказать, merge - слить в одну, warn - сохранить и вернуть пересечения в ответе
+type OverlapConfig struct {
+	Policy string `env:"OVERLAP_POLICY" envDefault:"reject"`
+}
+
+// CatalogConfig - насколько строго подписки сверяются с каталогом сервисов. Required - подписку на сервис
+// вне каталога создать нельзя, EnforcePrice - цена проверяется по границам сервиса вместо общего потолка
+type CatalogConfig struct {
+	Required     bool `env:"CATALOG_REQUIRED" envDefault:"false"`
+	EnforcePrice bool `env:"CATALOG_ENFORCE_PRICE" envDefault:"false"`
+}
+
+// UsersConfig - справочник, по которому проверяется user_id подписок: таблица users (db) или заглушка
+// identity-сервиса из json-файла (stub), найденные в заглушке пользователи заводятся и в таблице
+type UsersConfig struct {
+	Directory string `env:"USER_DIRECTORY" envDefault:"db"`
+	StubFile  string `env:"USER_DIRECTORY_STUB_FILE" envDefault:"configs/users.json"`
+}
+
 type Config struct {
 	HTTP    HTTPConfig
 	DB      DBConfig
 	Logger  LoggerConfig
 	Swagger SwaggerConfig
+	Rates   RatesConfig
+	Trash   TrashConfig
+	Expiry  ExpiryConfig
+	Webhook WebhookConfig
+	Outbox  OutboxConfig
+	Auth    AuthConfig
+	Policy  PolicyConfig
+	Limits  RateLimitConfig
+
+	Idempotency IdempotencyConfig
+	Overlap     OverlapConfig
+	Catalog     CatalogConfig
+	Users       UsersConfig
 }
 
 func Load() (Config, error) {
@@ -55,6 +161,52 @@ func Load() (Config, error) {
 	if err := env.Parse(&cfg.Swagger); err != nil {
 		return Config{}, fmt.Errorf("ошибка парсинга сконфигурации сваггера: %w", err)
 	}
+	if err := env.Parse(&cfg.Rates); err != nil {
+		return Config{}, fmt.Errorf("ошибка парсинга конфигурации курсов валют: %w", err)
+	}
+	if err := env.Parse(&cfg.Trash); err != nil {
+		return Config{}, fmt.Errorf("ошибка парсинга конфигурации корзины: %w", err)
+	}
+
+	if err := env.Parse(&cfg.Expiry); err != nil {
+		return Config{}, fmt.Errorf("ошибка парсинга конфигурации истечения подписок: %w", err)
+	}
+
+	if err := env.Parse(&cfg.Webhook); err != nil {
+		return Config{}, fmt.Errorf("ошибка парсинга конфигурации вебхуков: %w", err)
+	}
+
+	if err := env.Parse(&cfg.Outbox); err != nil {
+		return Config{}, fmt.Errorf("ошибка парсинга конфигурации outbox: %w", err)
+	}
+
+	if err := env.Parse(&cfg.Auth); err != nil {
+		return Config{}, fmt.Errorf("ошибка парсинга конфигурации аутентификации: %w", err)
+	}
+
+	if err := env.Parse(&cfg.Policy); err != nil {
+		return Config{}, fmt.Errorf("ошибка парсинга конфигурации политики доступа: %w", err)
+	}
+
+	if err := env.Parse(&cfg.Limits); err != nil {
+		return Config{}, fmt.Errorf("ошибка парсинга конфигурации rate limit: %w", err)
+	}
+
+	if err := env.Parse(&cfg.Idempotency); err != nil {
+		return Config{}, fmt.Errorf("ошибка парсинга конфигурации идемпотентности: %w", err)
+	}
+
+	if err := env.Parse(&cfg.Overlap); err != nil {
+		return Config{}, fmt.Errorf("ошибка парсинга конфигурации пересечений подписок: %w", err)
+	}
+
+	if err := env.Parse(&cfg.Catalog); err != nil {
+		return Config{}, fmt.Errorf("ошибка парсинга конфигурации каталога сервисов: %w", err)
+	}
+
+	if err := env.Parse(&cfg.Users); err != nil {
+		return Config{}, fmt.Errorf("ошибка парсинга конфигурации справочника пользователей: %w", err)
+	}
 
 	if err := cfg.validate(); err != nil {
 		return Config{}, fmt.Errorf("ошибка валидации конфига: %w", err)
@@ -98,6 +250,96 @@ func (c *Config) validate() error {
 	if c.Swagger.BasePath == "" {
 		c.Swagger.BasePath = "/api/v1"
 	}
+	if c.Rates.Source == "" {
+		c.Rates.Source = "file"
+	}
+	if c.Rates.Source != "file" && c.Rates.Source != "db" {
+		return errors.New("RATES_SOURCE должен быть file или db")
+	}
+	if c.Rates.Source == "file" && c.Rates.File == "" {
+		c.Rates.File = "configs/rates.json"
+	}
+	if c.Limits.Store == "" {
+		c.Limits.Store = "memory"
+	}
+	if c.Limits.Store != "memory" && c.Limits.Store != "db" {
+		return errors.New("RATE_LIMIT_STORE должен быть memory или db")
+	}
+	if c.Idempotency.TTLHours <= 0 {
+		c.Idempotency.TTLHours = 24
+	}
+	if c.Overlap.Policy == "" {
+		c.Overlap.Policy = "reject"
+	}
+	if c.Overlap.Policy != "reject" && c.Overlap.Policy != "merge" && c.Overlap.Policy != "warn" {
+		return errors.New("OVERLAP_POLICY должен быть reject, merge или warn")
+	}
+	if c.Users.Directory == "" {
+		c.Users.Directory = "db"
+	}
+	if c.Users.Directory != "db" && c.Users.Directory != "stub" {
+		return errors.New("USER_DIRECTORY должен быть db или stub")
+	}
+	if c.Users.Directory == "stub" && c.Users.StubFile == "" {
+		c.Users.StubFile = "configs/users.json"
+	}
+	if c.Trash.RetentionHours <= 0 {
+		c.Trash.RetentionHours = 720
+	}
+	if c.Trash.PurgeIntervalMin <= 0 {
+		c.Trash.PurgeIntervalMin = 60
+	}
+	if c.Expiry.IntervalMin <= 0 {
+		c.Expiry.IntervalMin = 60
+	}
+	if c.Expiry.BatchSize <= 0 {
+		c.Expiry.BatchSize = 100
+	}
+	if c.Expiry.RenewLeadDays < 0 {
+		return errors.New("AUTO_RENEW_LEAD_DAYS не может быть отрицательным")
+	}
+	if c.Webhook.IntervalSec <= 0 {
+		c.Webhook.IntervalSec = 5
+	}
+	if c.Webhook.TimeoutSec <= 0 {
+		c.Webhook.TimeoutSec = 10
+	}
+	if c.Webhook.MaxAttempts <= 0 {
+		c.Webhook.MaxAttempts = 8
+	}
+	if c.Webhook.BackoffBaseSec <= 0 {
+		c.Webhook.BackoffBaseSec = 30
+	}
+	if c.Webhook.BackoffMaxMin <= 0 {
+		c.Webhook.BackoffMaxMin = 360
+	}
+	if c.Webhook.BatchSize <= 0 {
+		c.Webhook.BatchSize = 50
+	}
+	if c.Outbox.Publisher == "" {
+		c.Outbox.Publisher = "log"
+	}
+	if c.Outbox.Publisher != "log" && c.Outbox.Publisher != "memory" && c.Outbox.Publisher != "file" {
+		return errors.New("OUTBOX_PUBLISHER должен быть log, memory или file")
+	}
+	if c.Outbox.Publisher == "file" && c.Outbox.File == "" {
+		c.Outbox.File = "outbox.jsonl"
+	}
+	if c.Outbox.MemoryCapacity <= 0 {
+		c.Outbox.MemoryCapacity = 1000
+	}
+	if c.Outbox.RelayIntervalSec <= 0 {
+		c.Outbox.RelayIntervalSec = 1
+	}
+	if c.Outbox.BatchSize <= 0 {
+		c.Outbox.BatchSize = 100
+	}
+	if c.Outbox.RetentionHours <= 0 {
+		c.Outbox.RetentionHours = 168
+	}
+	if c.Auth.Enabled && c.Auth.JWTSecret == "" && c.Auth.JWTPublicKeyFile == "" && len(c.Auth.APIKeys) == 0 {
+		return errors.New("при AUTH_ENABLED нужен AUTH_JWT_SECRET, AUTH_JWT_PUBLIC_KEY_FILE или AUTH_API_KEYS")
+	}
 	return nil
 }
 
@@ -108,3 +350,47 @@ func (c *Config) HTTPReadTimeout() time.Duration {
 func (c *Config) HTTPWriteTimeout() time.Duration {
 	return time.Duration(c.HTTP.WriteTimeoutSec) * time.Second
 }
+
+func (c *Config) IdempotencyTTL() time.Duration {
+	return time.Duration(c.Idempotency.TTLHours) * time.Hour
+}
+
+func (c *Config) TrashRetention() time.Duration {
+	return time.Duration(c.Trash.RetentionHours) * time.Hour
+}
+
+func (c *Config) TrashPurgeInterval() time.Duration {
+	return time.Duration(c.Trash.PurgeIntervalMin) * time.Minute
+}
+
+func (c *Config) ExpiryInterval() time.Duration {
+	return time.Duration(c.Expiry.IntervalMin) * time.Minute
+}
+
+func (c *Config) AutoRenewLead() time.Duration {
+	return time.Duration(c.Expiry.RenewLeadDays) * 24 * time.Hour
+}
+
+func (c *Config) WebhookInterval() time.Duration {
+	return time.Duration(c.Webhook.IntervalSec) * time.Second
+}
+
+func (c *Config) WebhookTimeout() time.Duration {
+	return time.Duration(c.Webhook.TimeoutSec) * time.Second
+}
+
+func (c *Config) WebhookBackoffBase() time.Duration {
+	return time.Duration(c.Webhook.BackoffBaseSec) * time.Second
+}
+
+func (c *Config) WebhookBackoffMax() time.Duration {
+	return time.Duration(c.Webhook.BackoffMaxMin) * time.Minute
+}
+
+func (c *Config) OutboxRelayInterval() time.Duration {
+	return time.Duration(c.Outbox.RelayIntervalSec) * time.Second
+}
+
+func (c *Config) OutboxRetention() time.Duration {
+	return time.Duration(c.Outbox.RetentionHours) * time.Hour
+}
@@ -0,0 +1,143 @@
+package http
+
+import (
+	"context"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/service"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// MonthlySpend godoc
+// @Summary      помесячные траты
+// @Description  возвращает сумму трат по каждому месяцу периода, опционально для одного пользователя и/или сервиса
+// @Tags         analytics
+// @Produce      json
+// @Param        user_id       query     string  false  "UUID пользователя"
+// @Param        service_name  query     string  false  "название сервиса"
+// @Param        from          query     string  true   "начало периода (MM-YYYY или YYYY-MM-DD)"
+// @Param        to            query     string  true   "конец периода включительно (MM-YYYY или YYYY-MM-DD)"
+// @Param        currency      query     string  false  "валюта отчёта (по умолчанию RUB)"
+// @Success      200           {array}   SpendRowResponse
+// @Failure      400           {object}  Problem "невалидный запрос"
+// @Failure      401           {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403           {object}  Problem "подписки другого пользователя"
+// @Failure      429           {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500           {object}  Problem "ошибка расчёта статистики"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/stats/monthly [get]
+func (h *Handler) MonthlySpend(c echo.Context) error {
+	return h.spendReport(c, h.service.MonthlySpend)
+}
+
+// SpendByService godoc
+// @Summary      траты в разрезе сервисов
+// @Description  возвращает сумму трат за период по каждому сервису, от самого дорогого
+// @Tags         analytics
+// @Produce      json
+// @Param        user_id       query     string  false  "UUID пользователя"
+// @Param        service_name  query     string  false  "название сервиса"
+// @Param        from          query     string  true   "начало периода (MM-YYYY или YYYY-MM-DD)"
+// @Param        to            query     string  true   "конец периода включительно (MM-YYYY или YYYY-MM-DD)"
+// @Param        currency      query     string  false  "валюта отчёта (по умолчанию RUB)"
+// @Success      200           {array}   SpendRowResponse
+// @Failure      400           {object}  Problem "невалидный запрос"
+// @Failure      401           {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403           {object}  Problem "подписки другого пользователя"
+// @Failure      429           {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500           {object}  Problem "ошибка расчёта статистики"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/stats/services [get]
+func (h *Handler) SpendByService(c echo.Context) error {
+	return h.spendReport(c, h.service.SpendByService)
+}
+
+// TopServices godoc
+// @Summary      топ сервисов по тратам
+// @Description  возвращает N сервисов с наибольшими тратами за период среди всех пользователей
+// @Tags         analytics
+// @Produce      json
+// @Param        service_name  query     string  false  "название сервиса"
+// @Param        from          query     string  true   "начало периода (MM-YYYY или YYYY-MM-DD)"
+// @Param        to            query     string  true   "конец периода включительно (MM-YYYY или YYYY-MM-DD)"
+// @Param        currency      query     string  false  "валюта отчёта (по умолчанию RUB)"
+// @Param        limit         query     int     false  "размер топа (по умолчанию 10, максимум 100)"
+// @Success      200           {array}   SpendRowResponse
+// @Failure      400           {object}  Problem "невалидный запрос"
+// @Failure      401           {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403           {object}  Problem "нужен доступ ко всем пользователям или операция запрещена роли"
+// @Failure      429           {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500           {object}  Problem "ошибка расчёта статистики"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/stats/top [get]
+func (h *Handler) TopServices(c echo.Context) error {
+	// топ считается по всем пользователям, поэтому только для ролей с доступом ко всем
+	if err := requireAnyUser(c); err != nil {
+		return err
+	}
+	return h.spendReport(c, h.service.TopServices)
+}
+
+// spendReport - общая часть аналитических ручек: разбор query, вызов нужного метода сервиса и маппинг ошибок
+func (h *Handler) spendReport(c echo.Context, report func(context.Context, domain.AnalyticsFilter) ([]domain.SpendRow, error)) error {
+	var request AnalyticsRequest
+	if err := c.Bind(&request); err != nil {
+		h.logger.Warn("не удалось обработать параметры аналитики", zap.Error(err))
+		return err
+	}
+	if err := c.Validate(&request); err != nil {
+		return err
+	}
+
+	filter, err := h.ToAnalyticsFilter(request)
+	if err != nil {
+		return invalid(err)
+	}
+	if err := scopeUserFilter(c, &filter.UserID); err != nil {
+		return err
+	}
+
+	rows, err := report(c.Request().Context(), filter)
+	if err != nil {
+		h.logger.Warn("ошибка расчёта статистики", zap.Error(err))
+		return err
+	}
+
+	response := make([]SpendRowResponse, 0, len(rows))
+	for _, row := range rows {
+		response = append(response, SpendRowResponse{Period: row.Period, ServiceName: row.ServiceName,
+			Currency: row.Currency, Total: domain.WholeUnits(row.Total), TotalMinor: row.Total})
+	}
+	return c.JSON(200, response)
+}
+
+func (h *Handler) ToAnalyticsFilter(input AnalyticsRequest) (domain.AnalyticsFilter, error) {
+	// from и to в запросе включительные, в фильтре - полуинтервал [from, to)
+	from, _, err := service.ValidateDateRange(input.From)
+	if err != nil {
+		return domain.AnalyticsFilter{}, err
+	}
+	_, to, err := service.ValidateDateRange(input.To)
+	if err != nil {
+		return domain.AnalyticsFilter{}, err
+	}
+
+	filter := domain.AnalyticsFilter{From: from, To: to, Limit: input.Limit, Currency: input.Currency}
+	if input.UserID != "" {
+		uid, err := uuid.Parse(input.UserID)
+		if err != nil {
+			h.logger.Warn("невалидный uuid", zap.String("id", input.UserID))
+			return domain.AnalyticsFilter{}, err
+		}
+		filter.UserID = &uid
+	}
+	if input.ServiceName != "" {
+		filter.ServiceName = &input.ServiceName
+	}
+	return filter, nil
+}
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"encoding/json"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// Проверки владения. Принципала в контексте нет только при выключенной аутентификации - тогда можно всё.
+// Админский скоуп (и API-ключи сервисов) и роли, которым политика разрешила всех пользователей,
+// работают с подписками любого пользователя, остальные - только со своими, где свой значит sub токена == user_id подписки.
+// Какие операции вообще разрешены роли, проверяет уже политика доступа поверх сервиса
+
+// authorizeUser - 403, если принципал не может работать с подписками пользователя userID
+func authorizeUser(c echo.Context, userID uuid.UUID) error {
+	p, ok := domain.PrincipalFromContext(c.Request().Context())
+	if !ok || p.Owns(userID) {
+		return nil
+	}
+	return errors.ErrForbidden.WithField("user_id")
+}
+
+// requireAnyUser - для ручек, которые по своей природе работают с подписками разных пользователей
+func requireAnyUser(c echo.Context) error {
+	p, ok := domain.PrincipalFromContext(c.Request().Context())
+	if !ok || p.AnyUser() {
+		return nil
+	}
+	return errors.ErrForbidden
+}
+
+// scopeUserFilter сужает фильтр по пользователю до самого принципала: без user_id в запросе
+// принципал без доступа ко всем пользователям видит только свои подписки, с чужим user_id получает 403
+func scopeUserFilter(c echo.Context, userID **uuid.UUID) error {
+	p, ok := domain.PrincipalFromContext(c.Request().Context())
+	if !ok || p.AnyUser() {
+		return nil
+	}
+	if p.UserID == nil {
+		return errors.ErrForbidden
+	}
+	if *userID != nil && **userID != *p.UserID {
+		return errors.ErrForbidden.WithField("user_id")
+	}
+	*userID = p.UserID
+	return nil
+}
+
+// ownsSubscription - может ли принципал видеть уже прочитанную подписку
+func ownsSubscription(c echo.Context, sub domain.Subscription) bool {
+	p, ok := domain.PrincipalFromContext(c.Request().Context())
+	return !ok || p.Owns(sub.UserID)
+}
+
+// authorizeSubscription читает подписку и проверяет владельца. Чужая подписка для клиента выглядит
+// как несуществующая: 404, а не 403, чтобы перебором id нельзя было узнать, какие подписки есть у других
+func (h *Handler) authorizeSubscription(c echo.Context, id int) error {
+	if _, ok := domain.PrincipalFromContext(c.Request().Context()); !ok {
+		return nil
+	}
+	sub, err := h.service.Read(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+	if !ownsSubscription(c, sub) {
+		return errors.ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// authorizeHistory - та же проверка для подписок, которых уже нет среди живых (корзина, журнал).
+// Журнал переживает удаление, владельца берём из последнего снимка
+func authorizeHistory(c echo.Context, entries []domain.AuditEntry) error {
+	p, ok := domain.PrincipalFromContext(c.Request().Context())
+	if !ok || p.AnyUser() {
+		return nil
+	}
+	if len(entries) == 0 {
+		return errors.ErrSubscriptionNotFound
+	}
+
+	last := entries[len(entries)-1]
+	raw := last.After
+	if len(raw) == 0 {
+		raw = last.Before
+	}
+	var sub domain.Subscription
+	if err := json.Unmarshal(raw, &sub); err != nil || !p.Owns(sub.UserID) {
+		return errors.ErrSubscriptionNotFound
+	}
+	return nil
+}
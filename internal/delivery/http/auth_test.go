@@ -0,0 +1,92 @@
+package http
+
+import (
+	"encoding/json"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+func principalContext(p *domain.Principal) echo.Context {
+	req := httptest.NewRequest(stdhttp.MethodGet, "/api/v1/subscriptions", nil)
+	if p != nil {
+		req = req.WithContext(domain.WithPrincipal(req.Context(), *p))
+	}
+	return echo.New().NewContext(req, httptest.NewRecorder())
+}
+
+func TestScopeUserFilter(t *testing.T) {
+	owner, other := uuid.New(), uuid.New()
+	cases := []struct {
+		name      string
+		principal *domain.Principal
+		userID    *uuid.UUID
+		want      *uuid.UUID
+		wantErr   bool
+	}{
+		{name: "без аутентификации", userID: &other, want: &other},
+		{name: "админ видит всех", principal: &domain.Principal{Scopes: []string{domain.ScopeAdmin}}},
+		{name: "роль со всеми пользователями", principal: &domain.Principal{UserID: &owner, AllUsers: true}, userID: &other, want: &other},
+		{name: "без user_id - свои", principal: &domain.Principal{UserID: &owner}, want: &owner},
+		{name: "свой user_id", principal: &domain.Principal{UserID: &owner}, userID: &owner, want: &owner},
+		{name: "чужой user_id", principal: &domain.Principal{UserID: &owner}, userID: &other, wantErr: true},
+		{name: "сервис без пользователя", principal: &domain.Principal{Subject: "billing"}, wantErr: true},
+	}
+	for _, c := range cases {
+		userID := c.userID
+		err := scopeUserFilter(principalContext(c.principal), &userID)
+		if c.wantErr {
+			if !errors.Is(err, errors.ErrForbidden) {
+				t.Errorf("%s: ошибка %v, ожидалась ErrForbidden", c.name, err)
+			}
+			continue
+		}
+		if err != nil || (userID == nil) != (c.want == nil) || (userID != nil && *userID != *c.want) {
+			t.Errorf("%s: user_id %v, %v, ожидался %v", c.name, userID, err, c.want)
+		}
+	}
+}
+
+func TestAuthorizeHistory(t *testing.T) {
+	owner, other := uuid.New(), uuid.New()
+	snapshot := func(userID uuid.UUID) json.RawMessage {
+		raw, _ := json.Marshal(domain.Subscription{ID: 7, UserID: userID})
+		return raw
+	}
+	created := domain.AuditEntry{Operation: domain.AuditCreate, After: snapshot(owner)}
+	deleted := domain.AuditEntry{Operation: domain.AuditDelete, Before: snapshot(owner)}
+	user := &domain.Principal{UserID: &owner}
+
+	cases := []struct {
+		name      string
+		principal *domain.Principal
+		entries   []domain.AuditEntry
+		wantErr   bool
+	}{
+		{name: "без аутентификации", entries: nil},
+		{name: "админ", principal: &domain.Principal{Scopes: []string{domain.ScopeAdmin}}, entries: []domain.AuditEntry{created}},
+		{name: "владелец", principal: user, entries: []domain.AuditEntry{created}},
+		{name: "после удаления владелец по снимку до", principal: user, entries: []domain.AuditEntry{created, deleted}},
+		{name: "чужая подписка", principal: &domain.Principal{UserID: &other}, entries: []domain.AuditEntry{created}, wantErr: true},
+		{name: "пустой журнал", principal: user, wantErr: true},
+		{name: "битый снимок", principal: user, entries: []domain.AuditEntry{{After: json.RawMessage(`{`)}}, wantErr: true},
+	}
+	for _, c := range cases {
+		err := authorizeHistory(principalContext(c.principal), c.entries)
+		if c.wantErr {
+			// чужая подписка выглядит как несуществующая
+			if !errors.Is(err, errors.ErrSubscriptionNotFound) {
+				t.Errorf("%s: ошибка %v, ожидалась ErrSubscriptionNotFound", c.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+	}
+}
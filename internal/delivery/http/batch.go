@@ -0,0 +1,134 @@
+package http
+
+import (
+	"fmt"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// Batch godoc
+// @Summary      пакетные операции над подписками
+// @Description  выполняет пакет create/update/delete (до 1000 операций) за один запрос. atomic - всё или ничего: при любой ошибке пакет откатывается и отдаётся 422 с результатами по каждой операции; best_effort - каждая операция выполняется сама по себе, итог по каждой в results
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        input body BatchRequest true "пакет операций"
+// @Success      200  {object}  BatchResponse
+// @Failure      400  {object}  Problem "невалидный запрос"
+// @Failure      401  {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403  {object}  Problem "нужен доступ ко всем пользователям или операция запрещена роли"
+// @Failure      422  {object}  BatchResponse "atomic пакет откатился"
+// @Failure      429  {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500  {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/subscriptions:batch [post]
+func (h *Handler) Batch(c echo.Context) error {
+	// операции пакета могут касаться подписок разных пользователей, поэтому пакет - только для ролей с доступом ко всем
+	if err := requireAnyUser(c); err != nil {
+		return err
+	}
+
+	var request BatchRequest
+	if err := c.Bind(&request); err != nil {
+		h.logger.Warn("не удалось обработать пакет", zap.Error(err))
+		return err
+	}
+	if err := c.Validate(request); err != nil {
+		return err
+	}
+	if request.Mode == "" {
+		request.Mode = domain.BatchAtomic
+	}
+
+	ops := make([]domain.BatchOp, 0, len(request.Operations))
+	for _, item := range request.Operations {
+		ops = append(ops, h.ToBatchOp(c, item))
+	}
+
+	results, err := h.service.Batch(c.Request().Context(), ops, request.Mode == domain.BatchAtomic)
+	if err != nil && !errors.Is(err, errors.ErrBatchAborted) {
+		h.logger.Warn("ошибка выполнения пакета", zap.Error(err))
+		return err
+	}
+
+	response := BatchResponse{
+		Mode:      request.Mode,
+		Committed: err == nil,
+		Results:   make([]BatchItemResponse, 0, len(results)),
+	}
+	for _, r := range results {
+		response.Results = append(response.Results, ToBatchItemResponse(r))
+	}
+
+	if !response.Committed {
+		return c.JSON(422, response)
+	}
+	return c.JSON(200, response)
+}
+
+// ToBatchOp разбирает операцию из запроса. Ошибка разбора не валит весь пакет, а ложится в op.Err
+func (h *Handler) ToBatchOp(c echo.Context, item BatchOperationRequest) domain.BatchOp {
+	op := domain.BatchOp{Op: item.Op, ID: item.ID, Version: item.Version}
+
+	switch item.Op {
+	case domain.BatchCreate, domain.BatchUpdate:
+		if item.Subscription == nil {
+			op.Err = errors.Wrap(errors.ErrInvalidBatch.WithField("subscription"), fmt.Errorf("нет subscription"))
+			return op
+		}
+		if err := c.Validate(item.Subscription); err != nil {
+			op.Err = errors.Wrap(errors.ErrInvalidBatch, err)
+			return op
+		}
+		sub, err := h.ToDomain(*item.Subscription)
+		if err != nil {
+			op.Err = err
+			return op
+		}
+		sub.ID = item.ID
+		op.Subscription = sub
+	case domain.BatchDelete:
+	default:
+		op.Err = errors.Wrap(errors.ErrInvalidBatch.WithField("op"), fmt.Errorf("неизвестная операция %q", item.Op))
+	}
+	return op
+}
+
+// ToBatchItemResponse переводит итог операции в тот HTTP-код, который отдал бы отдельный запрос
+func ToBatchItemResponse(r domain.BatchResult) BatchItemResponse {
+	item := BatchItemResponse{Index: r.Index, Op: r.Op, ID: r.ID, Version: r.Version}
+
+	switch {
+	case r.Err == nil:
+		switch r.Op {
+		case domain.BatchCreate:
+			item.Status = 201
+		case domain.BatchDelete:
+			item.Status = 204
+		default:
+			item.Status = 200
+		}
+		return item
+	case errors.Is(r.Err, errors.ErrBatchRolledBack):
+		item.Status = 424
+		item.Code = errors.ErrBatchRolledBack.Code
+	default:
+		var typed *errors.Error
+		if !errors.As(r.Err, &typed) || typed.Kind == errors.KindInternal {
+			// наружу текст ошибки базы не отдаём, как и в одиночных ручках
+			item.Status = 500
+			item.Code = errors.ErrInternal.Code
+			item.Error = errors.ErrInternal.Message
+			return item
+		}
+		item.Status = StatusFor(typed.Kind)
+		item.Code = typed.Code
+		item.Field = typed.Field
+	}
+	item.Error = r.Err.Error()
+	return item
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"fmt"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+)
+
+func TestToBatchItemResponse(t *testing.T) {
+	cases := []struct {
+		name      string
+		result    domain.BatchResult
+		status    int
+		code      string
+		field     string
+		hideError bool
+	}{
+		{name: "создание", result: domain.BatchResult{Op: domain.BatchCreate, ID: 1, Version: 1}, status: 201},
+		{name: "обновление", result: domain.BatchResult{Op: domain.BatchUpdate, ID: 1, Version: 2}, status: 200},
+		{name: "удаление", result: domain.BatchResult{Op: domain.BatchDelete, ID: 1}, status: 204},
+		{name: "откат вместе с пакетом", result: domain.BatchResult{Op: domain.BatchCreate, Err: errors.ErrBatchRolledBack},
+			status: 424, code: errors.ErrBatchRolledBack.Code},
+		{name: "невалидная цена", result: domain.BatchResult{Op: domain.BatchCreate, Err: errors.ErrInvalidPrice},
+			status: 400, code: errors.ErrInvalidPrice.Code, field: "price"},
+		{name: "нет подписки", result: domain.BatchResult{Op: domain.BatchDelete, ID: 9, Err: errors.ErrSubscriptionNotFound},
+			status: 404, code: errors.ErrSubscriptionNotFound.Code},
+		{name: "конфликт версий", result: domain.BatchResult{Op: domain.BatchUpdate, ID: 9, Err: errors.ErrVersionConflict},
+			status: 412, code: errors.ErrVersionConflict.Code},
+		{name: "пересечение", result: domain.BatchResult{Op: domain.BatchCreate, Err: errors.ErrSubscriptionOverlap},
+			status: 409, code: errors.ErrSubscriptionOverlap.Code, field: "start_date"},
+		{name: "ошибка базы не уходит наружу", result: domain.BatchResult{Op: domain.BatchCreate,
+			Err: fmt.Errorf("pq: relation \"subscriptions\" does not exist")}, status: 500, code: errors.ErrInternal.Code, hideError: true},
+	}
+
+	for _, c := range cases {
+		item := ToBatchItemResponse(c.result)
+		if item.Status != c.status || item.Code != c.code || item.Field != c.field {
+			t.Errorf("%s: %d %q %q, ожидалось %d %q %q", c.name, item.Status, item.Code, item.Field, c.status, c.code, c.field)
+		}
+		if c.hideError && item.Error != errors.ErrInternal.Message {
+			t.Errorf("%s: наружу ушло %q", c.name, item.Error)
+		}
+	}
+}
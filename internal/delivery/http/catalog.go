@@ -0,0 +1,161 @@
+package http
+
+import (
+	"strconv"
+	"testovoe_again/internal/errors"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// ListServices godoc
+// @Summary      каталог сервисов
+// @Description  все сервисы каталога с алиасами и ценами. Подписки на любое написание сервиса сохраняются под его каноническим названием
+// @Tags         services
+// @Produce      json
+// @Success      200  {object}  CatalogResponse
+// @Failure      401  {object}  Problem "нет или невалидные учётные данные"
+// @Failure      429  {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500  {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/services [get]
+func (h *Handler) ListServices(c echo.Context) error {
+	entries, err := h.catalog.List(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	return c.JSON(200, CatalogResponse{Items: entries})
+}
+
+// GetService godoc
+// @Summary      сервис каталога
+// @Tags         services
+// @Produce      json
+// @Param        id   path      int  true  "ID сервиса"
+// @Success      200  {object}  domain.CatalogEntry
+// @Failure      400  {object}  Problem "невалидный id"
+// @Failure      401  {object}  Problem "нет или невалидные учётные данные"
+// @Failure      404  {object}  Problem "сервиса нет в каталоге"
+// @Failure      429  {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500  {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/services/{id} [get]
+func (h *Handler) GetService(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Warn("невалидный id", zap.String("id", c.Param("id")))
+		return errors.ErrInvalidID
+	}
+	entry, err := h.catalog.Get(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(200, entry)
+}
+
+// CreateService godoc
+// @Summary      добавить сервис в каталог
+// @Description  заводит сервис с каноническим названием и алиасами. Уже существующие подписки на любое из написаний привязываются к сервису. Цены в минорных единицах currency, min_price и max_price - необязательные границы цены подписки
+// @Tags         services
+// @Accept       json
+// @Produce      json
+// @Param        input body      CatalogServiceRequest  true  "сервис"
+// @Success      201   {object}  domain.CatalogEntry
+// @Failure      400   {object}  Problem "невалидный сервис"
+// @Failure      401   {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403   {object}  Problem "операция запрещена роли"
+// @Failure      409   {object}  Problem "название или алиас заняты другим сервисом"
+// @Failure      429   {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500   {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/services [post]
+func (h *Handler) CreateService(c echo.Context) error {
+	var request CatalogServiceRequest
+	if err := c.Bind(&request); err != nil {
+		h.logger.Warn("не удалось обработать запрос", zap.Error(err))
+		return err
+	}
+	if err := c.Validate(request); err != nil {
+		return err
+	}
+
+	entry, err := h.catalog.Create(c.Request().Context(), request.ToDomain())
+	if err != nil {
+		return err
+	}
+	return c.JSON(201, entry)
+}
+
+// UpdateService godoc
+// @Summary      обновить сервис каталога
+// @Description  перезаписывает сервис целиком, включая набор алиасов. Подписки хранят название, под которым были созданы, в отчётах сервис идёт под новым каноническим названием
+// @Tags         services
+// @Accept       json
+// @Produce      json
+// @Param        id    path      int                    true  "ID сервиса"
+// @Param        input body      CatalogServiceRequest  true  "сервис"
+// @Success      200   {object}  domain.CatalogEntry
+// @Failure      400   {object}  Problem "невалидный id или сервис"
+// @Failure      401   {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403   {object}  Problem "операция запрещена роли"
+// @Failure      404   {object}  Problem "сервиса нет в каталоге"
+// @Failure      409   {object}  Problem "название или алиас заняты другим сервисом"
+// @Failure      429   {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500   {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/services/{id} [put]
+func (h *Handler) UpdateService(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Warn("невалидный id", zap.String("id", c.Param("id")))
+		return errors.ErrInvalidID
+	}
+
+	var request CatalogServiceRequest
+	if err := c.Bind(&request); err != nil {
+		h.logger.Warn("не удалось обработать запрос", zap.Error(err))
+		return err
+	}
+	if err := c.Validate(request); err != nil {
+		return err
+	}
+
+	entry := request.ToDomain()
+	entry.ID = id
+	entry, err = h.catalog.Update(c.Request().Context(), entry)
+	if err != nil {
+		return err
+	}
+	return c.JSON(200, entry)
+}
+
+// DeleteService godoc
+// @Summary      удалить сервис из каталога
+// @Description  подписки на сервис остаются со своим названием, но теряют привязку к каталогу
+// @Tags         services
+// @Param        id   path      int  true  "ID сервиса"
+// @Success      204  "No Content"
+// @Failure      400  {object}  Problem "невалидный id"
+// @Failure      401  {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403  {object}  Problem "операция запрещена роли"
+// @Failure      404  {object}  Problem "сервиса нет в каталоге"
+// @Failure      429  {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500  {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/services/{id} [delete]
+func (h *Handler) DeleteService(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Warn("невалидный id", zap.String("id", c.Param("id")))
+		return errors.ErrInvalidID
+	}
+	if err := h.catalog.Delete(c.Request().Context(), id); err != nil {
+		return err
+	}
+	return c.NoContent(204)
+}
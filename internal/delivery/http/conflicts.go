@@ -0,0 +1,49 @@
+package http
+
+import (
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// ConflictsResponse - пары пересекающихся подписок
+type ConflictsResponse struct {
+	Items []domain.Conflict `json:"items"`
+}
+
+// Conflicts godoc
+// @Summary      пересечения подписок
+// @Description  пары живых подписок одного пользователя на один сервис, периоды которых пересекаются, с границами пересечения (from/to включительно, to пустой у бессрочного пересечения). Такие пары остаются после политики warn или от данных, записанных до появления проверки
+// @Tags         subscriptions
+// @Produce      json
+// @Param        user_id  query     string  false  "UUID пользователя"
+// @Success      200      {object}  ConflictsResponse
+// @Failure      400      {object}  Problem "невалидный user_id"
+// @Failure      401      {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403      {object}  Problem "подписки другого пользователя"
+// @Failure      429      {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500      {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/subscriptions/conflicts [get]
+func (h *Handler) Conflicts(c echo.Context) error {
+	var userID *uuid.UUID
+	if raw := c.QueryParam("user_id"); raw != "" {
+		uid, err := uuid.Parse(raw)
+		if err != nil {
+			return errors.Wrap(errors.ErrInvalidUUID.WithField("user_id"), err)
+		}
+		userID = &uid
+	}
+	if err := scopeUserFilter(c, &userID); err != nil {
+		return err
+	}
+
+	conflicts, err := h.service.Conflicts(c.Request().Context(), userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(200, ConflictsResponse{Items: conflicts})
+}
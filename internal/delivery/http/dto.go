@@ -1,7 +1,13 @@
 // Здесь будут описываться тела сообщений (как в gRPC контракте)
 package http
 
-import "github.com/google/uuid"
+import (
+	"encoding/json"
+	"testovoe_again/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 // ТЕГИ:
 // json: название заголовка
@@ -12,22 +18,64 @@ import "github.com/google/uuid"
 // 3.gt=0 значит, что число должно быть больше нуля
 //
 // example: теги для сваггера
+//
+// Даты принимаются в формате MM-YYYY (первое число месяца) или полной датой YYYY-MM-DD,
+// первое число месяца в ответах всегда отдаётся как MM-YYYY
+
+// Цены и суммы в /api/v1 с самого начала были в целых единицах валюты (рублях), и price, total_sum и total
+// такими и остались. С появлением валют база хранит минорные единицы: точные значения отдаются рядом
+// в полях с суффиксом _minor (39900 в RUB это 399 рублей 00 копеек), в целых единицах дробная часть отбрасывается.
+// В запросе цену можно передать любым из полей, price_minor точнее. Если пришли оба, они должны совпадать,
+// чтобы тело из GET можно было отправить обратно в PUT
 
 type CreateSubscriptionRequest struct {
 	ServiceName string  `json:"service_name" validate:"required" example:"Yandex Plus"`
-	Price       int     `json:"price" validate:"required" example:"400"`
+	Price       int64   `json:"price,omitempty" example:"399"`
+	PriceMinor  *int64  `json:"price_minor,omitempty" validate:"omitempty,gt=0" example:"39900"`
+	Currency    string  `json:"currency,omitempty" validate:"omitempty,oneof=RUB USD EUR" example:"RUB"`
 	UserID      string  `json:"user_id" validate:"required,uuid" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
 	StartDate   string  `json:"start_date" validate:"required" example:"07-2025"`
 	EndDate     *string `json:"end_date,omitempty" example:"08-2025"`
+
+	BillingPeriod     string `json:"billing_period,omitempty" validate:"omitempty,oneof=weekly monthly quarterly yearly custom" example:"monthly"`
+	BillingPeriodDays *int   `json:"billing_period_days,omitempty" validate:"omitempty,gt=0" example:"30"`
+
+	// Status - только при создании, дальше статус меняется действиями pause/resume/cancel/renew
+	Status string `json:"status,omitempty" validate:"omitempty,oneof=trial active" example:"active"`
+	// AutoRenew - продлевать подписку на расчётный период по прошествии end_date
+	AutoRenew bool `json:"auto_renew,omitempty" example:"false"`
 }
 
 type CreateSubscriptionResponse struct {
 	ID          int     `json:"id" example:"1"`
 	ServiceName string  `json:"service_name" example:"Yandex Plus"`
-	Price       int     `json:"price" example:"400"`
+	Price       int64   `json:"price" example:"399"`
+	PriceMinor  int64   `json:"price_minor" example:"39900"`
+	Currency    string  `json:"currency" example:"RUB"`
 	UserID      string  `json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
 	StartDate   string  `json:"start_date" example:"07-2025"`
 	EndDate     *string `json:"end_date,omitempty" example:"08-2025"`
+
+	BillingPeriod     string `json:"billing_period" example:"monthly"`
+	BillingPeriodDays *int   `json:"billing_period_days,omitempty" example:"30"`
+
+	// Status - trial, active, paused, cancelled или expired
+	Status    string `json:"status" example:"active"`
+	AutoRenew bool   `json:"auto_renew" example:"false"`
+
+	// ServiceID - сервис каталога, с которым сопоставлено название
+	ServiceID *int `json:"service_id,omitempty" example:"1"`
+
+	Version int `json:"version" example:"1"`
+	// DeletedAt заполнен только у подписок из корзины
+	DeletedAt *time.Time `json:"deleted_at,omitempty" example:"2025-08-01T12:00:00Z"`
+
+	// OverlapAllowed - пересечение с другими подписками сервиса сохранено осознанно (политика warn)
+	OverlapAllowed bool `json:"overlap_allowed" example:"false"`
+	// Overlaps - id пересекающихся подписок, только в ответе на запись с политикой warn
+	Overlaps []int `json:"overlaps,omitempty" example:"3,7"`
+	// MergedIDs - id подписок, поглощённых при слиянии (политика merge) и ушедших в корзину
+	MergedIDs []int `json:"merged_ids,omitempty" example:"5"`
 }
 
 type GetStatsRequest struct {
@@ -35,9 +83,201 @@ type GetStatsRequest struct {
 	ServiceName string `json:"service_name" validate:"required"`
 	FirstDate   string `json:"first_date" validate:"required" example:"01-2025"`
 	LastDate    string `json:"last_date" validate:"required" example:"12-2025"`
+	Currency    string `json:"currency,omitempty" validate:"omitempty,oneof=RUB USD EUR" example:"RUB"`
 }
 
 type StatsResponse struct {
-	UserID   uuid.UUID `json:"user_id"`
-	TotalSum int       `json:"total_sum" example:"1200"`
+	UserID        uuid.UUID `json:"user_id"`
+	TotalSum      int64     `json:"total_sum" example:"1200"`
+	TotalSumMinor int64     `json:"total_sum_minor" example:"120000"`
+	Currency      string    `json:"currency" example:"RUB"`
+}
+
+// ListSubscriptionsRequest - query-параметры листинга, всё опционально.
+// даты в тех же форматах, что и в теле подписки, start_to и end_to включительные (MM-YYYY - до конца месяца)
+// sort: id | service_name | price | start_date, минус впереди - сортировка по убыванию (например -price).
+// min_price и max_price в целых единицах, как price в ответе
+type ListSubscriptionsRequest struct {
+	UserID      string `query:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	ServiceName string `query:"service_name" example:"Yandex Plus"`
+	Currency    string `query:"currency" example:"RUB"`
+	MinPrice    string `query:"min_price" example:"100"`
+	MaxPrice    string `query:"max_price" example:"1000"`
+	ActiveAt    string `query:"active_at" example:"07-2025"`
+	StartFrom   string `query:"start_from" example:"01-2025"`
+	StartTo     string `query:"start_to" example:"12-2025"`
+	EndFrom     string `query:"end_from" example:"01-2025"`
+	EndTo       string `query:"end_to" example:"12-2025"`
+	Sort        string `query:"sort" example:"-start_date"`
+	Limit       string `query:"limit" example:"50"`
+	Cursor      string `query:"cursor"`
+}
+
+type ListSubscriptionsResponse struct {
+	Items      []CreateSubscriptionResponse `json:"items"`
+	NextCursor string                       `json:"next_cursor,omitempty" example:"eyJzIjoiaWQiLCJkIjpmYWxzZSwidiI6IjUwIiwiaWQiOjUwfQ"`
+}
+
+// AnalyticsRequest - query-параметры аналитических ручек, from и to обязательны и включительны
+type AnalyticsRequest struct {
+	UserID      string `query:"user_id" validate:"omitempty,uuid" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	ServiceName string `query:"service_name" example:"Yandex Plus"`
+	From        string `query:"from" validate:"required" example:"01-2025"`
+	To          string `query:"to" validate:"required" example:"12-2025"`
+	Limit       int    `query:"limit" validate:"gte=0" example:"10"`
+	Currency    string `query:"currency" validate:"omitempty,oneof=RUB USD EUR" example:"RUB"`
+}
+
+// SpendRowResponse - строка аналитического отчёта, см. domain.SpendRow
+type SpendRowResponse struct {
+	Period      string `json:"period" example:"07-2025"`
+	ServiceName string `json:"service_name" example:"Yandex Plus"`
+	Currency    string `json:"currency" example:"RUB"`
+	Total       int64  `json:"total" example:"1200"`
+	TotalMinor  int64  `json:"total_minor" example:"120000"`
+}
+
+// PatchSubscriptionRequest - только для сваггера: реальный разбор идёт по ключам документа в ToPatch,
+// потому что в структуре не отличить отсутствующий ключ от явного null.
+// null допустим только для end_date и billing_period_days
+type PatchSubscriptionRequest struct {
+	ServiceName       *string `json:"service_name,omitempty" example:"Yandex Plus"`
+	Price             *int64  `json:"price,omitempty" example:"499"`
+	PriceMinor        *int64  `json:"price_minor,omitempty" example:"49900"`
+	Currency          *string `json:"currency,omitempty" example:"RUB"`
+	StartDate         *string `json:"start_date,omitempty" example:"07-2025"`
+	EndDate           *string `json:"end_date,omitempty" example:"08-2025"`
+	BillingPeriod     *string `json:"billing_period,omitempty" example:"monthly"`
+	BillingPeriodDays *int    `json:"billing_period_days,omitempty" example:"30"`
+}
+
+// AuditEntryResponse - запись журнала изменений, before и after - снимки подписки в том виде, в каком она
+// хранится: price в них в минорных единицах, как в базе, а не в целых, как в GetByID
+type AuditEntryResponse struct {
+	ID             int64           `json:"id" example:"1"`
+	SubscriptionID int             `json:"subscription_id" example:"1"`
+	Actor          string          `json:"actor" example:"anonymous"`
+	Operation      string          `json:"operation" example:"update"`
+	ChangedAt      time.Time       `json:"changed_at" example:"2025-08-01T12:00:00Z"`
+	Before         json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After          json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+}
+
+// BatchRequest - пакет операций. mode: atomic (по умолчанию) - всё или ничего, best_effort - каждая операция сама по себе
+type BatchRequest struct {
+	Mode       string                  `json:"mode,omitempty" validate:"omitempty,oneof=atomic best_effort" example:"atomic"`
+	Operations []BatchOperationRequest `json:"operations" validate:"required,min=1,max=1000"`
+}
+
+// BatchOperationRequest - одна операция пакета: для create нужна subscription, для update - id и subscription,
+// для delete - только id. version - ожидаемая версия, как в If-Match, 0 или пусто - без проверки
+type BatchOperationRequest struct {
+	Op           string                     `json:"op" example:"create"`
+	ID           int                        `json:"id,omitempty" example:"1"`
+	Version      int                        `json:"version,omitempty" example:"1"`
+	Subscription *CreateSubscriptionRequest `json:"subscription,omitempty"`
+}
+
+// BatchItemResponse - итог операции: status - HTTP-код, который вернул бы отдельный запрос,
+// 424 - операция сама по себе была в порядке, но откатилась вместе с пакетом
+type BatchItemResponse struct {
+	Index   int    `json:"index" example:"0"`
+	Op      string `json:"op" example:"create"`
+	Status  int    `json:"status" example:"201"`
+	ID      int    `json:"id,omitempty" example:"1"`
+	Version int    `json:"version,omitempty" example:"1"`
+	// code и field - те же, что отдал бы отдельный запрос в problem+json
+	Code  string `json:"code,omitempty" example:"invalid_price"`
+	Field string `json:"field,omitempty" example:"price"`
+	Error string `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Mode      string              `json:"mode" example:"atomic"`
+	Committed bool                `json:"committed" example:"true"`
+	Results   []BatchItemResponse `json:"results"`
+}
+
+// CatalogServiceRequest - сервис каталога. Алиасы - другие написания, под которыми сервис присылают клиенты,
+// регистр и лишние пробелы и так не учитываются
+type CatalogServiceRequest struct {
+	Name         string   `json:"name" validate:"required" example:"Yandex Plus"`
+	Aliases      []string `json:"aliases,omitempty" example:"Яндекс Плюс"`
+	DefaultPrice int64    `json:"default_price" validate:"gte=0" example:"39900"`
+	Currency     string   `json:"currency,omitempty" validate:"omitempty,oneof=RUB USD EUR" example:"RUB"`
+	MinPrice     *int64   `json:"min_price,omitempty" validate:"omitempty,gte=0" example:"19900"`
+	MaxPrice     *int64   `json:"max_price,omitempty" validate:"omitempty,gte=0" example:"99900"`
+}
+
+func (r CatalogServiceRequest) ToDomain() domain.CatalogEntry {
+	return domain.CatalogEntry{
+		Name:         r.Name,
+		Aliases:      r.Aliases,
+		DefaultPrice: r.DefaultPrice,
+		Currency:     r.Currency,
+		MinPrice:     r.MinPrice,
+		MaxPrice:     r.MaxPrice,
+	}
+}
+
+// CatalogResponse - весь каталог
+type CatalogResponse struct {
+	Items []domain.CatalogEntry `json:"items"`
+}
+
+// CreateUserRequest - пользователь справочника. id задаёт клиент: это тот же UUID, что sub в токене и user_id в подписках
+type CreateUserRequest struct {
+	ID    string  `json:"id" validate:"required,uuid" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	Email *string `json:"email,omitempty" example:"user@example.com"`
+	Name  string  `json:"name" example:"Иван Иванов"`
+}
+
+// UpdateUserRequest - перезапись пользователя целиком, email без значения стирается
+type UpdateUserRequest struct {
+	Email *string `json:"email,omitempty" example:"user@example.com"`
+	Name  string  `json:"name" example:"Иван Иванов"`
+}
+
+// ListUsersRequest - keyset-пагинация справочника, cursor - next_cursor предыдущей страницы
+type ListUsersRequest struct {
+	Limit  int    `query:"limit" validate:"gte=0" example:"50"`
+	Cursor string `query:"cursor" validate:"omitempty,uuid"`
+}
+
+type ListUsersResponse struct {
+	Items      []domain.User `json:"items"`
+	NextCursor *uuid.UUID    `json:"next_cursor,omitempty" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+}
+
+// WebhookRequest - вебхук. Пустой events - все события, пустой secret при создании генерируется, при обновлении не меняется
+type WebhookRequest struct {
+	URL    string   `json:"url" validate:"required,url" example:"https://billing.example.com/hooks/subscriptions"`
+	Secret string   `json:"secret,omitempty" validate:"omitempty,min=16" example:"whsec_3f9a..."`
+	Events []string `json:"events,omitempty" validate:"dive,oneof=subscription.created subscription.updated subscription.deleted subscription.purged" example:"subscription.created,subscription.deleted"`
+	Active *bool    `json:"active,omitempty" example:"true"`
+}
+
+// ToDomain - без active вебхук включён
+func (r WebhookRequest) ToDomain() domain.Webhook {
+	active := true
+	if r.Active != nil {
+		active = *r.Active
+	}
+	return domain.Webhook{URL: r.URL, Secret: r.Secret, Events: r.Events, Active: active}
+}
+
+type WebhooksResponse struct {
+	Items []domain.Webhook `json:"items"`
+}
+
+// DeadLettersRequest - dead-letter список от новых доставок к старым, cursor - next_cursor предыдущей страницы
+type DeadLettersRequest struct {
+	WebhookID *int   `query:"webhook_id" validate:"omitempty,gt=0" example:"1"`
+	Limit     int    `query:"limit" validate:"gte=0" example:"50"`
+	Cursor    *int64 `query:"cursor" validate:"omitempty,gt=0"`
+}
+
+type DeadLettersResponse struct {
+	Items      []domain.WebhookDelivery `json:"items"`
+	NextCursor *int64                   `json:"next_cursor,omitempty" example:"42"`
 }
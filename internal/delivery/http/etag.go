@@ -0,0 +1,54 @@
+package http
+
+import (
+	"strconv"
+	"strings"
+	"testovoe_again/internal/errors"
+)
+
+// в echo нет констант под условные заголовки
+const (
+	HeaderETag        = "ETag"
+	HeaderIfMatch     = "If-Match"
+	HeaderIfNoneMatch = "If-None-Match"
+)
+
+// ETag подписки - её версия. Тег сильный: версия меняется на любое изменение записи
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ParseIfMatch достаёт ожидаемую версию из If-Match.
+// Пустой заголовок и * означают "без проверки" и дают 0, как и в репозитории.
+// Слабый префикс W/ принимаем, хоть по RFC 9110 If-Match сравнивает сильно - версия у нас одна на оба случая
+func ParseIfMatch(header string) (int, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	if strings.Contains(header, ",") {
+		return 0, errors.ErrInvalidPrecondition
+	}
+
+	tag := strings.TrimPrefix(header, "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, errors.ErrInvalidPrecondition
+	}
+	version, err := strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil || version <= 0 {
+		return 0, errors.ErrInvalidPrecondition
+	}
+	return version, nil
+}
+
+// matchesIfNoneMatch - клиент уже держит текущую версию и можно отдать 304
+func matchesIfNoneMatch(header string, version int) bool {
+	current := ETag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package http
+
+import (
+	"testing"
+	"testovoe_again/internal/errors"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	cases := []struct {
+		header string
+		want   int
+		ok     bool
+	}{
+		{"", 0, true},
+		{"*", 0, true},
+		{` "3" `, 3, true},
+		{`W/"3"`, 3, true},
+		{ETag(42), 42, true},
+		{`3`, 0, false},
+		{`"3`, 0, false},
+		{`""`, 0, false},
+		{`"0"`, 0, false},
+		{`"-1"`, 0, false},
+		{`"abc"`, 0, false},
+		{`"3", "4"`, 0, false},
+		{`w/"3"`, 0, false},
+	}
+	for _, c := range cases {
+		got, err := ParseIfMatch(c.header)
+		if !c.ok {
+			if !errors.Is(err, errors.ErrInvalidPrecondition) {
+				t.Errorf("ParseIfMatch(%q): ошибка %v, ожидалась ErrInvalidPrecondition", c.header, err)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("ParseIfMatch(%q) = %d, %v, ожидалось %d", c.header, got, err, c.want)
+		}
+	}
+}
+
+func TestMatchesIfNoneMatch(t *testing.T) {
+	cases := []struct {
+		header string
+		match  bool
+	}{
+		{`"3"`, true},
+		{`W/"3"`, true},
+		{`*`, true},
+		{`"1", "3"`, true},
+		{`"1",W/"3"`, true},
+		{`"4"`, false},
+		{`"33"`, false},
+		{``, false},
+	}
+	for _, c := range cases {
+		if got := matchesIfNoneMatch(c.header, 3); got != c.match {
+			t.Errorf("matchesIfNoneMatch(%q, 3) = %v, ожидалось %v", c.header, got, c.match)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package http
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	stdhttp "net/http"
+	"strconv"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// Форматы выгрузки
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+
+	MIMENDJSON = "application/x-ndjson"
+)
+
+// exportFlushEvery - раз в сколько строк выталкиваем накопленное клиенту очередным chunk'ом
+const exportFlushEvery = 500
+
+var exportCSVHeader = []string{
+	"id", "service_name", "price", "price_minor", "currency", "user_id", "start_date", "end_date",
+	"billing_period", "billing_period_days", "version", "status", "auto_renew",
+}
+
+// Export godoc
+// @Summary      выгрузка подписок
+// @Description  отдаёт все подписки под фильтрами листинга одним потоком (chunked), без пагинации: csv с заголовком или ndjson - по объекту подписки на строку. price в целых единицах валюты, как в GetByID, точная цена в price_minor. Если выгрузка оборвалась на середине, соединение закрывается без завершающего chunk'а, чтобы обрыв нельзя было принять за конец данных
+// @Tags         subscriptions
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Param        format        query     string  true   "csv | ndjson"
+// @Param        user_id       query     string  false  "UUID пользователя"
+// @Param        service_name  query     string  false  "название сервиса"
+// @Param        currency      query     string  false  "валюта подписки (RUB, USD, EUR)"
+// @Param        min_price     query     int     false  "минимальная цена в целых единицах валюты"
+// @Param        max_price     query     int     false  "максимальная цена в целых единицах валюты"
+// @Param        active_at     query     string  false  "подписка активна на дату (MM-YYYY или YYYY-MM-DD)"
+// @Param        start_from    query     string  false  "start_date не раньше (MM-YYYY или YYYY-MM-DD)"
+// @Param        start_to      query     string  false  "start_date не позже (MM-YYYY или YYYY-MM-DD)"
+// @Param        end_from      query     string  false  "end_date не раньше (MM-YYYY или YYYY-MM-DD)"
+// @Param        end_to        query     string  false  "end_date не позже (MM-YYYY или YYYY-MM-DD)"
+// @Param        sort          query     string  false  "id | service_name | price | start_date, префикс - для убывания"
+// @Success      200           {string}  string "поток строк выгрузки"
+// @Failure      400           {object}  Problem "невалидные параметры запроса"
+// @Failure      401           {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403           {object}  Problem "подписки другого пользователя"
+// @Failure      429           {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500           {object}  Problem "не удалось выгрузить подписки"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/subscriptions/export [get]
+func (h *Handler) Export(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format != ExportCSV && format != ExportNDJSON {
+		return errors.Wrap(errors.ErrInvalidRequest.WithField("format"), fmt.Errorf("format должен быть csv или ndjson"))
+	}
+
+	var request ListSubscriptionsRequest
+	if err := c.Bind(&request); err != nil {
+		h.logger.Warn("не удалось обработать параметры выгрузки", zap.Error(err))
+		return err
+	}
+	filter, err := h.ToListFilter(request)
+	if err != nil {
+		return err
+	}
+	if err := scopeUserFilter(c, &filter.UserID); err != nil {
+		return err
+	}
+
+	res := c.Response()
+	// выгрузка миллионов строк не укладывается в общий HTTP_WRITE_TIMEOUT_SEC, снимаем дедлайн для этого ответа
+	_ = stdhttp.NewResponseController(res).SetWriteDeadline(time.Time{})
+
+	var out exportWriter
+	switch format {
+	case ExportCSV:
+		res.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+		out = &csvExport{w: csv.NewWriter(res)}
+	case ExportNDJSON:
+		res.Header().Set(echo.HeaderContentType, MIMENDJSON)
+		out = &ndjsonExport{enc: json.NewEncoder(res)}
+	}
+	res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="subscriptions.`+format+`"`)
+
+	// статус и заголовок файла пишем только на первой строке: пока ничего не ушло, ошибку ещё можно отдать нормальным ответом
+	start := func() error {
+		if res.Committed {
+			return nil
+		}
+		res.WriteHeader(200)
+		return out.Begin()
+	}
+
+	written := 0
+	err = h.service.Export(c.Request().Context(), filter, func(sub domain.Subscription) error {
+		if err := start(); err != nil {
+			return err
+		}
+		if err := out.Write(sub); err != nil {
+			return err
+		}
+		written++
+		if written%exportFlushEvery == 0 {
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			res.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		// пустая выгрузка - всё равно 200 и для csv строка заголовка
+		if err = start(); err == nil {
+			err = out.Flush()
+		}
+	}
+	if err != nil {
+		if !res.Committed {
+			return err
+		}
+		// заголовки уже ушли, поменять статус нельзя - рвём соединение, чтобы клиент не принял обрывок за полную выгрузку
+		h.logger.Error("выгрузка оборвалась", zap.Error(err), zap.Int("written", written))
+		panic(stdhttp.ErrAbortHandler)
+	}
+	return nil
+}
+
+// exportWriter - формат выгрузки: Begin пишет то, что идёт до первой строки, Flush выталкивает буфер формата
+type exportWriter interface {
+	Begin() error
+	Write(sub domain.Subscription) error
+	Flush() error
+}
+
+type csvExport struct {
+	w *csv.Writer
+}
+
+func (e *csvExport) Begin() error {
+	return e.w.Write(exportCSVHeader)
+}
+
+func (e *csvExport) Write(sub domain.Subscription) error {
+	return e.w.Write(csvRecord(sub))
+}
+
+// csv.Writer буферизует сам, без Flush строки не дойдут до ответа
+func (e *csvExport) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonExport struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonExport) Begin() error { return nil }
+
+func (e *ndjsonExport) Write(sub domain.Subscription) error {
+	return e.enc.Encode(ToResponse(sub))
+}
+
+func (e *ndjsonExport) Flush() error { return nil }
+
+func csvRecord(sub domain.Subscription) []string {
+	var endDate, periodDays string
+	if sub.EndDate != nil {
+		endDate = *sub.EndDate
+	}
+	if sub.BillingPeriodDays != nil {
+		periodDays = strconv.Itoa(*sub.BillingPeriodDays)
+	}
+	return []string{
+		strconv.Itoa(sub.ID),
+		sub.ServiceName,
+		strconv.FormatInt(domain.WholeUnits(sub.Price), 10),
+		strconv.FormatInt(sub.Price, 10),
+		sub.Currency,
+		sub.UserID.String(),
+		sub.StartDate,
+		endDate,
+		sub.BillingPeriod,
+		periodDays,
+		strconv.Itoa(sub.Version),
+		sub.Status,
+		strconv.FormatBool(sub.AutoRenew),
+	}
+}
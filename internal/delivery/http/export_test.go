@@ -0,0 +1,182 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"testovoe_again/internal/service"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// exportService отдаёт subs по одной, а на failAt-й подписке (с единицы) возвращает ошибку
+type exportService struct {
+	service.SubService
+	subs   []domain.Subscription
+	failAt int
+	filter domain.ListFilter
+}
+
+func (s *exportService) Export(ctx context.Context, filter domain.ListFilter, fn func(domain.Subscription) error) error {
+	s.filter = filter
+	for i, sub := range s.subs {
+		if i+1 == s.failAt {
+			return stderrors.New("conn reset")
+		}
+		if err := fn(sub); err != nil {
+			return err
+		}
+	}
+	if s.failAt > len(s.subs) {
+		return stderrors.New("conn reset")
+	}
+	return nil
+}
+
+func exportSubs() []domain.Subscription {
+	end, days := "12-2025", 45
+	return []domain.Subscription{
+		{ID: 1, ServiceName: "Yandex Plus", Price: 39950, Currency: "RUB", UserID: uuid.MustParse("60601fee-2bf1-4721-ae6f-7636e79a0cba"),
+			StartDate: "07-2025", BillingPeriod: domain.BillingMonthly, Version: 1, Status: domain.StatusActive, AutoRenew: true},
+		{ID: 2, ServiceName: "Кинопоиск, \"HD\"", Price: 100, Currency: "USD", UserID: uuid.MustParse("60601fee-2bf1-4721-ae6f-7636e79a0cba"),
+			StartDate: "08-2025", EndDate: &end, BillingPeriod: domain.BillingCustom, BillingPeriodDays: &days, Version: 3, Status: domain.StatusPaused},
+	}
+}
+
+func TestCSVRecord(t *testing.T) {
+	subs := exportSubs()
+	want := [][]string{
+		{"1", "Yandex Plus", "399", "39950", "RUB", "60601fee-2bf1-4721-ae6f-7636e79a0cba", "07-2025", "", domain.BillingMonthly, "", "1", domain.StatusActive, "true"},
+		{"2", "Кинопоиск, \"HD\"", "1", "100", "USD", "60601fee-2bf1-4721-ae6f-7636e79a0cba", "08-2025", "12-2025", domain.BillingCustom, "45", "3", domain.StatusPaused, "false"},
+	}
+	for i, sub := range subs {
+		got := csvRecord(sub)
+		if len(got) != len(exportCSVHeader) {
+			t.Errorf("подписка %d: %d колонок при %d в заголовке", sub.ID, len(got), len(exportCSVHeader))
+		}
+		if strings.Join(got, "|") != strings.Join(want[i], "|") {
+			t.Errorf("подписка %d: %q, ожидалось %q", sub.ID, got, want[i])
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	owner := uuid.MustParse("60601fee-2bf1-4721-ae6f-7636e79a0cba")
+	cases := []struct {
+		name      string
+		query     string
+		principal *domain.Principal
+		subs      []domain.Subscription
+		failAt    int
+		wantErr   *errors.Error
+		wantAbort bool
+		wantType  string
+		wantLines int
+	}{
+		{name: "csv", query: "format=csv", subs: exportSubs(), wantType: "text/csv; charset=utf-8", wantLines: 3},
+		{name: "ndjson", query: "format=ndjson", subs: exportSubs(), wantType: MIMENDJSON, wantLines: 2},
+		{name: "пустая csv - только заголовок", query: "format=csv", wantType: "text/csv; charset=utf-8", wantLines: 1},
+		{name: "пустая ndjson", query: "format=ndjson", wantType: MIMENDJSON},
+		{name: "без формата", query: "", wantErr: errors.ErrInvalidRequest},
+		{name: "неизвестный формат", query: "format=xml", wantErr: errors.ErrInvalidRequest},
+		{name: "кривой фильтр", query: "format=csv&user_id=nope", wantErr: errors.ErrInvalidUUID},
+		{name: "чужой user_id", query: "format=csv&user_id=" + uuid.NewString(), principal: &domain.Principal{UserID: &owner},
+			wantErr: errors.ErrForbidden},
+		{name: "ошибка до первой строки - обычный ответ", query: "format=csv", subs: exportSubs(), failAt: 1},
+		{name: "обрыв после первой строки", query: "format=ndjson", subs: exportSubs(), failAt: 2, wantAbort: true},
+	}
+
+	for _, c := range cases {
+		svc := &exportService{subs: c.subs, failAt: c.failAt}
+		h := NewHandler(zap.NewNop(), svc, nil, nil, nil)
+
+		req := httptest.NewRequest(stdhttp.MethodGet, "/api/v1/subscriptions/export?"+c.query, nil)
+		if c.principal != nil {
+			req = req.WithContext(domain.WithPrincipal(req.Context(), *c.principal))
+		}
+		rec := httptest.NewRecorder()
+		ctx := echo.New().NewContext(req, rec)
+
+		var (
+			err     error
+			aborted bool
+		)
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					aborted = r == stdhttp.ErrAbortHandler
+					if !aborted {
+						panic(r)
+					}
+				}
+			}()
+			err = h.Export(ctx)
+		}()
+
+		if aborted != c.wantAbort {
+			t.Errorf("%s: обрыв соединения %v, ожидался %v", c.name, aborted, c.wantAbort)
+			continue
+		}
+		if c.wantAbort {
+			continue
+		}
+		if c.wantErr != nil {
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, c.wantErr)
+			}
+			continue
+		}
+		if c.failAt > 0 {
+			if err == nil || ctx.Response().Committed {
+				t.Errorf("%s: ошибка %v, ответ начат %v - ожидалась ошибка без начатого ответа", c.name, err, ctx.Response().Committed)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+
+		if got := rec.Header().Get(echo.HeaderContentType); got != c.wantType {
+			t.Errorf("%s: Content-Type %q, ожидался %q", c.name, got, c.wantType)
+		}
+		body := strings.TrimSuffix(rec.Body.String(), "\n")
+		var lines []string
+		if body != "" {
+			lines = strings.Split(body, "\n")
+		}
+		if len(lines) != c.wantLines {
+			t.Errorf("%s: %d строк, ожидалось %d:\n%s", c.name, len(lines), c.wantLines, rec.Body.String())
+			continue
+		}
+		if strings.Contains(c.query, "ndjson") && len(lines) > 0 {
+			var sub CreateSubscriptionResponse
+			if err := json.Unmarshal([]byte(lines[0]), &sub); err != nil || sub.Price != 399 || sub.PriceMinor != 39950 {
+				t.Errorf("%s: первая строка %s, %v", c.name, lines[0], err)
+			}
+		}
+	}
+}
+
+func TestExportScopesOwnSubscriptions(t *testing.T) {
+	owner := uuid.MustParse("60601fee-2bf1-4721-ae6f-7636e79a0cba")
+	svc := &exportService{}
+	h := NewHandler(zap.NewNop(), svc, nil, nil, nil)
+
+	req := httptest.NewRequest(stdhttp.MethodGet, "/api/v1/subscriptions/export?format=csv", nil)
+	req = req.WithContext(domain.WithPrincipal(req.Context(), domain.Principal{UserID: &owner}))
+	if err := h.Export(echo.New().NewContext(req, httptest.NewRecorder())); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if svc.filter.UserID == nil || *svc.filter.UserID != owner {
+		t.Errorf("выгрузка без user_id не ограничена подписками клиента: %v", svc.filter.UserID)
+	}
+}
@@ -1,10 +1,13 @@
 package http
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
 	"testovoe_again/internal/domain"
 	"testovoe_again/internal/errors"
 	"testovoe_again/internal/service"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -12,23 +15,36 @@ import (
 )
 
 type Handler struct {
-	logger  *zap.Logger
-	service service.SubService
+	logger   *zap.Logger
+	service  service.SubService
+	catalog  service.CatalogService
+	users    service.UserService
+	webhooks service.WebhookService
 }
 
-func NewHandler(logger *zap.Logger, service service.SubService) *Handler {
-	return &Handler{logger: logger, service: service}
+func NewHandler(logger *zap.Logger, service service.SubService, catalog service.CatalogService, users service.UserService,
+	webhooks service.WebhookService) *Handler {
+	return &Handler{logger: logger, service: service, catalog: catalog, users: users, webhooks: webhooks}
 }
 
 // @Summary      создать подписку
-// @Description  создает новую запись о подписке и возвращает её тело
+// @Description  создает новую запись о подписке и возвращает её тело. Цена - price в целых единицах валюты или price_minor в минорных, в ответе есть оба поля. Название сервиса сверяется с каталогом: любое написание из каталога сохраняется под каноническим названием с service_id. user_id должен быть в справочнике пользователей, иначе 400 invalid_user_id. Пересечение по датам с другой подпиской пользователя на тот же сервис обрабатывается по политике OVERLAP_POLICY: reject - 409, warn - подписка создаётся, id пересечений приходят в overlaps, merge - новые данные ложатся в существующую подписку с объединённым периодом (200 вместо 201), поглощённые подписки уходят в корзину и перечислены в merged_ids. С заголовком Idempotency-Key повтор запроса с тем же телом отдаёт исходный ответ (с Idempotent-Replayed: true) и не создаёт дубликат
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
 // @Param        input body CreateSubscriptionRequest true "данные новой подписки"
+// @Param        Idempotency-Key header string false "ключ идемпотентности, до 255 символов"
 // @Success      201 {object} CreateSubscriptionResponse
-// @Failure      400 {object} map[string]string "не удалось обработать запрос"
-// @Failure      500 {object} map[string]string "ошибка сервера"
+// @Success      200 {object} CreateSubscriptionResponse "подписка слита с существующей"
+// @Failure      400 {object} Problem "не удалось обработать запрос или пользователя нет в справочнике"
+// @Failure      401 {object} Problem "нет или невалидные учётные данные"
+// @Failure      403 {object} Problem "подписка другого пользователя"
+// @Failure      409 {object} Problem "пересечение с другой подпиской сервиса или запрос с этим Idempotency-Key ещё выполняется"
+// @Failure      422 {object} Problem "сервиса нет в каталоге (CATALOG_REQUIRED) или Idempotency-Key уже использован с другим телом"
+// @Failure      429 {object} Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500 {object} Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Router       /api/v1/subscriptions [post]
 func (h *Handler) Create(c echo.Context) error {
 	// создаём переменную для DTO_шки, куда будем записывать результат для похода в сервис
@@ -37,155 +53,259 @@ func (h *Handler) Create(c echo.Context) error {
 	// с помощью Bind метода раскидываем поля в структуру, если ошибка - отдаём 400
 	if err := c.Bind(&request); err != nil {
 		h.logger.Warn("не удалось обработать запрос", zap.Error(err))
-		return echo.NewHTTPError(400, err.Error())
+		return err
 	}
 
 	// с помощью Validate проверяем полученные поля и если получаем не состыковку с тегами - кидаем ошибку(400)
 	if err := c.Validate(request); err != nil {
-		return echo.NewHTTPError(400, err.Error())
+		return err
 	}
 
 	// т.к. DTO != domain - перекладываем поля в требуемую для метода структуру
 	sub, err := h.ToDomain(request)
 	if err != nil {
-		return echo.NewHTTPError(400, err.Error())
+		return err
+	}
+
+	// создать подписку можно только себе, если роль не даёт доступа ко всем пользователям
+	if err := authorizeUser(c, sub.UserID); err != nil {
+		return err
 	}
 
 	// если всё ок на этом уровне - вызываем сервис
-	id, err := h.service.Create(c.Request().Context(), sub)
+	// ошибки сервиса типизированные, статус и код по ним подберёт ErrorHandler: невалидная цена - 400, база упала - 500
+	sub, err = h.service.Create(c.Request().Context(), sub)
 	if err != nil {
-		// осознанно(!!!) распаковываю ошибку в рамках контексте тестового задания, понимаю что бест практис - вернуть кастом
-		return echo.NewHTTPError(500, err.Error())
+		return err
 	}
 
 	// если всё сработало - возвращаем 201(created) и структуру ответа из DTO
-	return c.JSON(201, CreateSubscriptionResponse{
-		ID:          id,
-		ServiceName: request.ServiceName,
-		Price:       request.Price,
-		UserID:      request.UserID,
-		StartDate:   request.StartDate,
-		EndDate:     request.EndDate,
-	})
+	// при слиянии новой записи не появилось, изменилась существующая - тогда 200
+	status := 201
+	if sub.Merged {
+		status = 200
+	}
+	c.Response().Header().Set(HeaderETag, ETag(sub.Version))
+	return c.JSON(status, ToResponse(sub))
 }
 
 func (h *Handler) ToDomain(input CreateSubscriptionRequest) (domain.Subscription, error) {
 	uid, err := uuid.Parse(input.UserID)
 	if err != nil {
 		h.logger.Warn("не удалось обработать UUID пользователя", zap.String("uuid", input.UserID))
+		return domain.Subscription{}, errors.Wrap(errors.ErrInvalidUUID.WithField("user_id"), err)
+	}
+
+	// price 0 в теле не отличить от отсутствующей, а нулевая цена и раньше не проходила validate:"required"
+	var whole *int64
+	if input.Price != 0 {
+		whole = &input.Price
+	}
+	price, err := minorPrice(whole, input.PriceMinor)
+	if err != nil {
 		return domain.Subscription{}, err
 	}
 
+	// валюта опциональна в запросе, по умолчанию подписка рублёвая
+	currency := input.Currency
+	if currency == "" {
+		currency = domain.DefaultCurrency
+	}
+
 	return domain.Subscription{
 		ServiceName: input.ServiceName,
-		Price:       input.Price,
+		Price:       price,
+		Currency:    currency,
 		UserID:      uid,
 		StartDate:   input.StartDate,
 		EndDate:     input.EndDate,
+
+		BillingPeriod:     input.BillingPeriod,
+		BillingPeriodDays: input.BillingPeriodDays,
+
+		Status:    input.Status,
+		AutoRenew: input.AutoRenew,
 	}, nil
 }
 
+// minorPrice - цена в минорных единицах из пары полей price (целые единицы) и price_minor.
+// Нет ни одного - ErrInvalidPrice, оба расходятся - тоже
+func minorPrice(price, priceMinor *int64) (int64, error) {
+	if priceMinor != nil {
+		if price != nil && *price != domain.WholeUnits(*priceMinor) {
+			return 0, errors.Wrap(errors.ErrInvalidPrice, fmt.Errorf("price %d не совпадает с price_minor %d", *price, *priceMinor))
+		}
+		return *priceMinor, nil
+	}
+	if price == nil {
+		return 0, errors.ErrInvalidPrice
+	}
+	minor, ok := domain.MinorUnits(*price)
+	if !ok {
+		return 0, errors.Wrap(errors.ErrInvalidPrice, fmt.Errorf("price %d вне допустимого диапазона", *price))
+	}
+	return minor, nil
+}
+
 // GetByID godoc
 // @Summary      получить подписку по ID
 // @Description  возвращает данные конкретной подписки по её уникальному идентификатору
 // @Tags         subscriptions
 // @Produce      json
 // @Param        id   path      int  true  "ID подписки"
+// @Param        If-None-Match  header  string  false  "ETag закэшированной версии"
 // @Success      200  {object}  CreateSubscriptionResponse
-// @Failure      400  {object}  map[string]string "невалидный ID"
-// @Failure      404  {object}  map[string]string "подписка не найдена"
-// @Failure      500  {object}  map[string]string "ошибка сервера"
+// @Header       200  {string}  ETag  "версия подписки"
+// @Success      304  "Not Modified"
+// @Failure      400  {object}  Problem "невалидный ID"
+// @Failure      401  {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403  {object}  Problem "операция запрещена роли"
+// @Failure      404  {object}  Problem "подписка не найдена"
+// @Failure      429  {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500  {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Router       /api/v1/subscriptions/{id} [get]
 func (h *Handler) GetByID(c echo.Context) error {
 	//читаем из query айдишник, если ошибка - отдаём 400
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		h.logger.Warn("невалидный id", zap.Int("id", id))
-		return echo.NewHTTPError(400, "невалидный id")
+		h.logger.Warn("невалидный id", zap.String("id", c.Param("id")))
+		return errors.ErrInvalidID
 	}
 
 	// если айди валидный - вызываем сервис
 	sub, err := h.service.Read(c.Request().Context(), id)
 	if err != nil {
-		if err == errors.ErrSubscriptionNotFound {
-			h.logger.Warn("пользователь не найден", zap.Int("id", id))
-			return c.JSON(404, err.Error())
+		if !errors.Is(err, errors.ErrSubscriptionNotFound) {
+			h.logger.Error("не удалось найти подписку", zap.Error(err))
 		}
-		h.logger.Error("не удалось найти пользователя", zap.Error(err))
-		return echo.NewHTTPError(500, "ошибка сервера")
+		return err
 	}
 
-	return c.JSON(200, sub)
+	// чужую подписку не отдаём и не подтверждаем, что она существует
+	if !ownsSubscription(c, sub) {
+		return errors.ErrSubscriptionNotFound
+	}
+
+	c.Response().Header().Set(HeaderETag, ETag(sub.Version))
+	if inm := c.Request().Header.Get(HeaderIfNoneMatch); inm != "" && matchesIfNoneMatch(inm, sub.Version) {
+		return c.NoContent(304)
+	}
+
+	return c.JSON(200, ToResponse(sub))
 }
 
 // Update godoc
 // @Summary      обновить подписку
-// @Description  обновляет данные существующей подписки по её ID и требует полное тело запроса
+// @Description  обновляет данные существующей подписки по её ID и требует полное тело запроса. status из тела не применяется, для смены статуса есть pause/resume/cancel/renew
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
 // @Param        id    path    int                        true  "ID подписки"
 // @Param        input body    CreateSubscriptionRequest  true  "новые данные подписки"
+// @Param        If-Match  header  string  false  "ETag версии, которую редактирует клиент"
 // @Success      204   "No Content"
-// @Failure      400   {object} map[string]string "невалидный ID или тело запроса"
-// @Failure      404   {object} map[string]string "подписка не найдена"
-// @Failure      500   {object} map[string]string "ошибка сервера"
+// @Header       204   {string}  ETag  "новая версия подписки"
+// @Failure      400   {object} Problem "невалидный ID или тело запроса"
+// @Failure      401   {object} Problem "нет или невалидные учётные данные"
+// @Failure      403   {object} Problem "user_id другого пользователя"
+// @Failure      404   {object} Problem "подписка не найдена"
+// @Failure      409   {object} Problem "пересечение с другой подпиской сервиса (политика reject)"
+// @Failure      412   {object} Problem "подписка изменилась с момента чтения"
+// @Failure      429   {object} Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500   {object} Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Router       /api/v1/subscriptions/{id} [put]
 func (h *Handler) Update(c echo.Context) error {
 	//читаем айди
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		h.logger.Warn("невалидный id", zap.Int("id", id))
-		return c.JSON(400, err.Error())
+		h.logger.Warn("невалидный id", zap.String("id", c.Param("id")))
+		return errors.ErrInvalidID
 	}
 
 	// переменная с телом запроса
 	var request CreateSubscriptionRequest
 	if err := c.Bind(&request); err != nil {
 		h.logger.Warn("невалидное тело для обновления", zap.Error(err))
-		return echo.NewHTTPError(400, err.Error())
+		return err
 	}
 
 	// переводим всё в необходимую структуру
 	result, err := h.ToDomain(request)
 	if err != nil {
 		//не валидировал здесь, т.к. логер реализовал внутри метода
-		return echo.NewHTTPError(400, err.Error())
+		return err
 	}
 
 	//прокидываем ID, т.к. метод ToDomain не работает с ID
 	result.ID = id
 
-	//вызываем сервис
-	err = h.service.Update(c.Request().Context(), result)
+	// обновлять можно только свою подписку и передать её другому пользователю тоже нельзя
+	if err := h.authorizeSubscription(c, id); err != nil {
+		return err
+	}
+	if err := authorizeUser(c, result.UserID); err != nil {
+		return err
+	}
+
+	// ожидаемая версия из If-Match, без заголовка обновляем безусловно
+	result.Version, err = ParseIfMatch(c.Request().Header.Get(HeaderIfMatch))
+	if err != nil {
+		return err
+	}
+
+	//вызываем сервис, нет подписки - 404, устаревший If-Match - 412
+	updated, err := h.service.Update(c.Request().Context(), result)
 	if err != nil {
 		h.logger.Warn("ошибка обработки запроса обновления", zap.Error(err))
-		return echo.NewHTTPError(500, err.Error())
+		return err
 	}
 
-	//если всё ок - отдаём 204, т.к. метод Update ничего не возвращает
+	//если всё ок - отдаём 204 и новую версию в ETag
+	c.Response().Header().Set(HeaderETag, ETag(updated.Version))
 	return c.NoContent(204)
 }
 
 // Delete godoc
 // @Summary      удалить подписку
-// @Description  удаляет запись о подписке из базы данных по её ID
+// @Description  мягко удаляет подписку по её ID: запись уходит в корзину и пропадает из чтений и статистики, восстановить её можно до очистки корзины
 // @Tags         subscriptions
 // @Param        id   path      int  true  "id подписки"
+// @Param        If-Match  header  string  false  "ETag версии, которую удаляет клиент"
 // @Success      204  "No Content"
-// @Failure      400  {object}  map[string]string "невалидный id"
-// @Failure      500  {object}  map[string]string "ошибка удаления"
+// @Failure      400  {object}  Problem "невалидный id"
+// @Failure      401  {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403  {object}  Problem "операция запрещена роли"
+// @Failure      404  {object}  Problem "подписка не найдена"
+// @Failure      412  {object}  Problem "подписка изменилась с момента чтения"
+// @Failure      429  {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500  {object}  Problem "ошибка удаления"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Router       /api/v1/subscriptions/{id} [delete]
 func (h *Handler) Delete(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		h.logger.Warn("невалидный id", zap.Int("id", id))
-		return echo.NewHTTPError(400, "невалидный id")
+		h.logger.Warn("невалидный id", zap.String("id", c.Param("id")))
+		return errors.ErrInvalidID
+	}
+
+	version, err := ParseIfMatch(c.Request().Header.Get(HeaderIfMatch))
+	if err != nil {
+		return err
+	}
+
+	if err := h.authorizeSubscription(c, id); err != nil {
+		return err
 	}
 
-	if err := h.service.Delete(c.Request().Context(), id); err != nil {
+	// удалять нечего - 404, а не 204: клиент должен узнать, что id не тот
+	if err := h.service.Delete(c.Request().Context(), id, version); err != nil {
 		h.logger.Warn("не удалось удалить подписку", zap.Error(err))
-		return echo.NewHTTPError(500, "ошибка удаления")
+		return err
 	}
 
 	return c.NoContent(204)
@@ -198,8 +318,13 @@ func (h *Handler) Delete(c echo.Context) error {
 // @Produce      json
 // @Param        user_id  path      string  true  "UUID пользователя"
 // @Success      200      {array}   CreateSubscriptionResponse
-// @Failure      400      {object}  map[string]string "невалидный айди пользователя"
-// @Failure      500      {object}  map[string]string "не удалось получить подписки"
+// @Failure      400      {object}  Problem "невалидный айди пользователя"
+// @Failure      401      {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403      {object}  Problem "подписки другого пользователя"
+// @Failure      429      {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500      {object}  Problem "не удалось получить подписки"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Router       /api/v1/subscriptions/list/{user_id} [get]
 func (h *Handler) List(c echo.Context) error {
 	id := c.Param("user_id")
@@ -207,46 +332,255 @@ func (h *Handler) List(c echo.Context) error {
 	uid, err := uuid.Parse(id)
 	if err != nil {
 		h.logger.Warn("невалидный uuid", zap.String("id", id))
-		return echo.NewHTTPError(400, "невалидный айди пользователя")
+		return errors.Wrap(errors.ErrInvalidUUID.WithField("user_id"), err)
+	}
+
+	if err := authorizeUser(c, uid); err != nil {
+		return err
 	}
 
 	subscriptions, err := h.service.GetListByUserID(c.Request().Context(), uid)
 	if err != nil {
 		h.logger.Error("ошибка получения списка подписок", zap.Error(err))
-		return echo.NewHTTPError(500, "не удалось получить подписки")
+		return err
 	}
 
-	return c.JSON(200, subscriptions)
+	response := make([]CreateSubscriptionResponse, 0, len(subscriptions))
+	for _, sub := range subscriptions {
+		response = append(response, ToResponse(sub))
+	}
+	return c.JSON(200, response)
+}
+
+// ListSubscriptions godoc
+// @Summary      листинг подписок с фильтрами и пагинацией
+// @Description  возвращает страницу подписок по фильтрам, отсортированную по ключу sort; следующая страница запрашивается по next_cursor
+// @Tags         subscriptions
+// @Produce      json
+// @Param        user_id       query     string  false  "UUID пользователя"
+// @Param        service_name  query     string  false  "название сервиса"
+// @Param        currency      query     string  false  "валюта подписки (RUB, USD, EUR)"
+// @Param        min_price     query     int     false  "минимальная цена в целых единицах валюты"
+// @Param        max_price     query     int     false  "максимальная цена в целых единицах валюты"
+// @Param        active_at     query     string  false  "подписка активна на дату (MM-YYYY или YYYY-MM-DD)"
+// @Param        start_from    query     string  false  "start_date не раньше (MM-YYYY или YYYY-MM-DD)"
+// @Param        start_to      query     string  false  "start_date не позже (MM-YYYY или YYYY-MM-DD)"
+// @Param        end_from      query     string  false  "end_date не раньше (MM-YYYY или YYYY-MM-DD)"
+// @Param        end_to        query     string  false  "end_date не позже (MM-YYYY или YYYY-MM-DD)"
+// @Param        sort          query     string  false  "id | service_name | price | start_date, префикс - для убывания"
+// @Param        limit         query     int     false  "размер страницы (по умолчанию 50, максимум 500)"
+// @Param        cursor        query     string  false  "next_cursor из предыдущего ответа"
+// @Success      200           {object}  ListSubscriptionsResponse
+// @Failure      400           {object}  Problem "невалидные параметры запроса"
+// @Failure      401           {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403           {object}  Problem "подписки другого пользователя"
+// @Failure      429           {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500           {object}  Problem "не удалось получить подписки"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/subscriptions [get]
+func (h *Handler) ListSubscriptions(c echo.Context) error {
+	var request ListSubscriptionsRequest
+	if err := c.Bind(&request); err != nil {
+		h.logger.Warn("не удалось обработать параметры листинга", zap.Error(err))
+		return err
+	}
+
+	filter, err := h.ToListFilter(request)
+	if err != nil {
+		return err
+	}
+	if err := scopeUserFilter(c, &filter.UserID); err != nil {
+		return err
+	}
+
+	page, err := h.service.List(c.Request().Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(200, ToListResponse(page))
+}
+
+// ToListResponse перекладывает страницу листинга в DTO ответа
+func ToListResponse(page domain.SubscriptionPage) ListSubscriptionsResponse {
+	response := ListSubscriptionsResponse{
+		Items:      make([]CreateSubscriptionResponse, 0, len(page.Items)),
+		NextCursor: page.NextCursor,
+	}
+	for _, sub := range page.Items {
+		response.Items = append(response.Items, ToResponse(sub))
+	}
+	return response
+}
+
+// ToListFilter переводит строковые query-параметры в доменный фильтр, пустая строка - фильтр не задан
+func (h *Handler) ToListFilter(input ListSubscriptionsRequest) (domain.ListFilter, error) {
+	var filter domain.ListFilter
+
+	if input.UserID != "" {
+		uid, err := uuid.Parse(input.UserID)
+		if err != nil {
+			h.logger.Warn("невалидный uuid", zap.String("id", input.UserID))
+			return domain.ListFilter{}, errors.Wrap(errors.ErrInvalidUUID.WithField("user_id"), err)
+		}
+		filter.UserID = &uid
+	}
+	if input.ServiceName != "" {
+		filter.ServiceName = &input.ServiceName
+	}
+	if input.Currency != "" {
+		filter.Currency = &input.Currency
+	}
+
+	// границы в целых единицах, а сравниваются с ценой в минорных. Верхняя граница включает всю
+	// последнюю единицу: подписка за 399.50 отдаётся с price 399 и должна попасть под max_price=399
+	prices := []struct {
+		field string
+		raw   string
+		dst   **int64
+		upper bool
+	}{
+		{"min_price", input.MinPrice, &filter.MinPrice, false},
+		{"max_price", input.MaxPrice, &filter.MaxPrice, true},
+	}
+	for _, p := range prices {
+		if p.raw == "" {
+			continue
+		}
+		v, err := strconv.ParseInt(p.raw, 10, 64)
+		if err != nil {
+			return domain.ListFilter{}, errors.Wrap(errors.ErrInvalidFilter.WithField(p.field), err)
+		}
+		if p.upper {
+			v++
+		}
+		minor, ok := domain.MinorUnits(v)
+		if !ok {
+			return domain.ListFilter{}, errors.Wrap(errors.ErrInvalidFilter.WithField(p.field), fmt.Errorf("%s вне допустимого диапазона", p.field))
+		}
+		if p.upper {
+			minor--
+		}
+		*p.dst = &minor
+	}
+
+	// для верхних границ берём последний день: start_to=12-2025 должен захватить весь декабрь
+	dates := []struct {
+		field     string
+		raw       string
+		dst       **time.Time
+		inclusive bool
+	}{
+		{"active_at", input.ActiveAt, &filter.ActiveAt, false},
+		{"start_from", input.StartFrom, &filter.StartFrom, false},
+		{"start_to", input.StartTo, &filter.StartTo, true},
+		{"end_from", input.EndFrom, &filter.EndFrom, false},
+		{"end_to", input.EndTo, &filter.EndTo, true},
+	}
+	for _, d := range dates {
+		if d.raw == "" {
+			continue
+		}
+		start, end, err := service.ValidateDateRange(d.raw)
+		if err != nil {
+			return domain.ListFilter{}, errors.ErrInvalidDateFormat.WithField(d.field)
+		}
+		t := start
+		if d.inclusive {
+			t = end.AddDate(0, 0, -1)
+		}
+		*d.dst = &t
+	}
+
+	filter.SortBy = strings.TrimPrefix(input.Sort, "-")
+	filter.SortDesc = strings.HasPrefix(input.Sort, "-")
+
+	if input.Limit != "" {
+		limit, err := strconv.Atoi(input.Limit)
+		if err != nil || limit < 0 {
+			return domain.ListFilter{}, errors.ErrInvalidFilter.WithField("limit")
+		}
+		filter.Limit = limit
+	}
+
+	if input.Cursor != "" {
+		cursor, err := service.DecodeCursor(input.Cursor)
+		if err != nil {
+			return domain.ListFilter{}, err
+		}
+		filter.Cursor = &cursor
+	}
+
+	return filter, nil
+}
+
+// ToResponse - обратное к ToDomain перекладывание доменной подписки в DTO ответа
+func ToResponse(sub domain.Subscription) CreateSubscriptionResponse {
+	return CreateSubscriptionResponse{
+		ID:          sub.ID,
+		ServiceName: sub.ServiceName,
+		Price:       domain.WholeUnits(sub.Price),
+		PriceMinor:  sub.Price,
+		Currency:    sub.Currency,
+		UserID:      sub.UserID.String(),
+		StartDate:   sub.StartDate,
+		EndDate:     sub.EndDate,
+
+		BillingPeriod:     sub.BillingPeriod,
+		BillingPeriodDays: sub.BillingPeriodDays,
+
+		Status:    sub.Status,
+		AutoRenew: sub.AutoRenew,
+
+		ServiceID: sub.ServiceID,
+
+		Version:   sub.Version,
+		DeletedAt: sub.DeletedAt,
+
+		OverlapAllowed: sub.OverlapAllowed,
+		Overlaps:       sub.Overlaps,
+		MergedIDs:      sub.MergedIDs,
+	}
 }
 
 // GetSum godoc
 // @Summary      рассчитать сумму затрат
-// @Description  возвращает суммарную стоимость подписок по конкретному сервису за указанный период
+// @Description  возвращает суммарную стоимость подписок по конкретному сервису за указанный период (границы включительно, MM-YYYY или YYYY-MM-DD): от каждого расчётного периода подписки в сумму идёт доля цены, пропорциональная дням внутри периода. Итог пересчитывается в currency (по умолчанию RUB): total_sum в целых единицах валюты, total_sum_minor - в минорных
 // @Tags         analytics
 // @Accept       json
 // @Produce      json
 // @Param        request  body      GetStatsRequest  true  "параметры фильтрации (UserID, ServiceName, Dates)"
 // @Success      200      {object}  StatsResponse
-// @Failure      400      {object}  map[string]string "невалидный запрос"
-// @Failure      400      {object}  map[string]string "невалидный айди пользователя"
-// @Failure      500      {object}  map[string]string "ошибка расчёта суммы"
+// @Failure      400      {object}  Problem "невалидный запрос"
+// @Failure      400      {object}  Problem "невалидный айди пользователя"
+// @Failure      401      {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403      {object}  Problem "подписки другого пользователя"
+// @Failure      429      {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500      {object}  Problem "ошибка расчёта суммы"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Router       /api/v1/stats [post]
 func (h *Handler) GetSum(c echo.Context) error {
 	var request GetStatsRequest
 
 	if err := c.Bind(&request); err != nil {
 		h.logger.Warn("не удалось распарсить тело запроса статистики", zap.Error(err))
-		return echo.NewHTTPError(400, "невалидный запрос")
+		return err
 	}
 
 	if err := c.Validate(&request); err != nil {
-		return echo.NewHTTPError(400, err.Error())
+		return err
 	}
 
 	uid, err := uuid.Parse(request.UserID)
 	if err != nil {
 		h.logger.Warn("невалидный uuid в запросе суммы", zap.String("id", request.UserID))
-		return echo.NewHTTPError(400, "невалидный айди пользователя")
+		return errors.Wrap(errors.ErrInvalidUUID.WithField("user_id"), err)
+	}
+
+	if err := authorizeUser(c, uid); err != nil {
+		return err
 	}
 
 	result, err := h.service.CalculateTotal(
@@ -255,15 +589,23 @@ func (h *Handler) GetSum(c echo.Context) error {
 		request.ServiceName,
 		request.FirstDate,
 		request.LastDate,
+		request.Currency,
 	)
 	if err != nil {
-		h.logger.Error("ошибка расчета суммы", zap.Error(err))
-		return c.JSON(500, map[string]string{"error": err.Error()})
+		h.logger.Warn("ошибка расчета суммы", zap.Error(err))
+		return err
+	}
+
+	currency := request.Currency
+	if currency == "" {
+		currency = domain.DefaultCurrency
 	}
 
 	return c.JSON(200, StatsResponse{
-		UserID:   uid,
-		TotalSum: result,
+		UserID:        uid,
+		TotalSum:      domain.WholeUnits(result),
+		TotalSumMinor: result,
+		Currency:      currency,
 	})
 }
 
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"strconv"
+	"testovoe_again/internal/errors"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// History godoc
+// @Summary      журнал изменений подписки
+// @Description  возвращает все изменения подписки от старых к новым: кто, когда, какая операция и снимки до/после. Журнал доступен и для удалённых подписок
+// @Tags         subscriptions
+// @Produce      json
+// @Param        id   path      int  true  "ID подписки"
+// @Success      200  {array}   AuditEntryResponse
+// @Failure      400  {object}  Problem "невалидный id"
+// @Failure      401  {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403  {object}  Problem "операция запрещена роли"
+// @Failure      404  {object}  Problem "подписка не найдена"
+// @Failure      429  {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500  {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/subscriptions/{id}/history [get]
+func (h *Handler) History(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Warn("невалидный id", zap.String("id", c.Param("id")))
+		return errors.ErrInvalidID
+	}
+
+	entries, err := h.service.History(c.Request().Context(), id)
+	if err != nil {
+		h.logger.Warn("ошибка получения журнала изменений", zap.Error(err))
+		return err
+	}
+	if err := authorizeHistory(c, entries); err != nil {
+		return err
+	}
+
+	response := make([]AuditEntryResponse, 0, len(entries))
+	for _, entry := range entries {
+		response = append(response, AuditEntryResponse(entry))
+	}
+	return c.JSON(200, response)
+}
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testovoe_again/internal/errors"
+	"testovoe_again/internal/importer"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// MaxImportFileSize - XLSX приходится сначала сложить во временный файл (zip читается с конца),
+// поэтому размер ограничен. CSV читается прямо из тела запроса
+const MaxImportFileSize = 100 << 20
+
+// Import godoc
+// @Summary      импорт подписок из CSV или XLSX
+// @Description  multipart-форма: необязательное поле mapping (JSON "поле подписки": "колонка файла") и поле file с .csv или .xlsx. Поле mapping должно идти до file. Первая строка файла - заголовок, без маппинга колонки ищутся по именам полей (service_name, price, price_minor, currency, user_id, start_date, end_date, billing_period, billing_period_days). Цена - price в целых единицах валюты или price_minor в минорных, как в теле создания. Каждая строка проходит те же проверки, что и при создании подписки, включая политику пересечений; dry_run=true только проверяет файл
+// @Tags         subscriptions
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        dry_run  query     bool    false  "только проверить файл, ничего не сохраняя"
+// @Param        mapping  formData  string  false  "JSON с маппингом полей на колонки файла"
+// @Param        file     formData  file    true   "CSV или XLSX файл"
+// @Success      200      {object}  domain.ImportReport
+// @Failure      400      {object}  Problem "невалидный запрос или файл"
+// @Failure      401      {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403      {object}  Problem "нужен доступ ко всем пользователям или операция запрещена роли"
+// @Failure      413      {object}  Problem "файл слишком большой"
+// @Failure      429      {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500      {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/subscriptions/import [post]
+func (h *Handler) Import(c echo.Context) error {
+	// в файле могут быть подписки любых пользователей
+	if err := requireAnyUser(c); err != nil {
+		return err
+	}
+
+	dryRun := false
+	if raw := c.QueryParam("dry_run"); raw != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(raw)
+		if err != nil {
+			return errors.Wrap(errors.ErrInvalidRequest.WithField("dry_run"), err)
+		}
+	}
+
+	// форму читаем по частям, а не через FormFile: тот складывает весь файл в память или на диск до начала разбора
+	mr, err := c.Request().MultipartReader()
+	if err != nil {
+		return echo.NewHTTPError(415, "ожидается multipart/form-data")
+	}
+
+	var mapping importer.Mapping
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return errors.Wrap(errors.ErrInvalidImport.WithField("file"), fmt.Errorf("в форме нет файла"))
+		}
+		if err != nil {
+			h.logger.Warn("не удалось прочитать форму импорта", zap.Error(err))
+			return invalid(err)
+		}
+
+		switch part.FormName() {
+		case "mapping":
+			if err := json.NewDecoder(io.LimitReader(part, 64<<10)).Decode(&mapping); err != nil {
+				return errors.Wrap(errors.ErrInvalidImport.WithField("mapping"), err)
+			}
+		case "file":
+			return h.importFile(c, part, part.FileName(), mapping, dryRun)
+		}
+		part.Close()
+	}
+}
+
+func (h *Handler) importFile(c echo.Context, file io.Reader, name string, mapping importer.Mapping, dryRun bool) error {
+	var rows importer.Reader
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		rows = importer.NewCSVReader(file)
+	case ".xlsx":
+		tmp, err := os.CreateTemp("", "import-*.xlsx")
+		if err != nil {
+			h.logger.Error("не удалось создать временный файл", zap.Error(err))
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		size, err := io.Copy(tmp, io.LimitReader(file, MaxImportFileSize+1))
+		if err != nil {
+			h.logger.Warn("не удалось принять файл импорта", zap.Error(err))
+			return invalid(err)
+		}
+		if size > MaxImportFileSize {
+			return echo.NewHTTPError(413, "файл слишком большой")
+		}
+
+		xlsx, err := importer.NewXLSXReader(tmp, size)
+		if err != nil {
+			return err
+		}
+		defer xlsx.Close()
+		rows = xlsx
+	default:
+		return errors.Wrap(errors.ErrInvalidImport.WithField("file"), fmt.Errorf("поддерживаются только .csv и .xlsx"))
+	}
+
+	report, err := h.service.Import(c.Request().Context(), rows, mapping, dryRun)
+	if err != nil {
+		h.logger.Warn("ошибка импорта подписок", zap.Error(err))
+		return err
+	}
+	return c.JSON(200, report)
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// HeaderAPIKey - заголовок с ключом для вызовов сервис-сервис
+const HeaderAPIKey = "X-API-Key"
+
+// APIKey - ключ для вызовов сервис-сервис. Сервисы работают с подписками всех пользователей,
+// поэтому у ключа есть админский скоуп
+type APIKey struct {
+	Name string
+	Key  string
+}
+
+// ParseAPIKeys разбирает ключи из конфига в формате name:key
+func ParseAPIKeys(raw []string) ([]APIKey, error) {
+	keys := make([]APIKey, 0, len(raw))
+	for i, entry := range raw {
+		name, key, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || name == "" || key == "" {
+			// сам ключ в ошибку не кладём, она уйдёт в лог
+			return nil, fmt.Errorf("невалидный API-ключ №%d, ожидается name:key", i+1)
+		}
+		keys = append(keys, APIKey{Name: name, Key: key})
+	}
+	return keys, nil
+}
+
+// Grants - права по ролям, которые хендлерам нужно знать до вызова сервиса (реализует policy.Store)
+type Grants interface {
+	AllUsers(p domain.Principal) bool
+}
+
+// Authenticator пускает запрос по bearer-токену или API-ключу и кладёт в контекст принципала.
+// Автором изменений для журнала становится субъект токена или имя ключа, X-Actor после этого не действует
+type Authenticator struct {
+	logger *zap.Logger
+	jwt    *JWTVerifier
+	keys   []APIKey
+	grants Grants
+}
+
+// NewAuthenticator - grants может быть nil, тогда доступ ко всем пользователям даёт только админский скоуп
+func NewAuthenticator(logger *zap.Logger, jwt *JWTVerifier, keys []APIKey, grants Grants) *Authenticator {
+	return &Authenticator{logger: logger, jwt: jwt, keys: keys, grants: grants}
+}
+
+func (a *Authenticator) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal, err := a.authenticate(c)
+			if err != nil {
+				a.logger.Warn("запрос не прошёл аутентификацию", zap.Error(err), zap.String("path", c.Request().URL.Path))
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer realm="subscriptions"`)
+				return err
+			}
+			if a.grants != nil {
+				principal.AllUsers = a.grants.AllUsers(principal)
+			}
+
+			ctx := domain.WithPrincipal(c.Request().Context(), principal)
+			ctx = domain.WithActor(ctx, principal.Subject)
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+func (a *Authenticator) authenticate(c echo.Context) (domain.Principal, error) {
+	if key := c.Request().Header.Get(HeaderAPIKey); key != "" {
+		return a.byAPIKey(key)
+	}
+
+	header := c.Request().Header.Get(echo.HeaderAuthorization)
+	if header == "" {
+		return domain.Principal{}, errors.ErrUnauthorized
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return domain.Principal{}, unauthorized("ожидается Authorization: Bearer <token>")
+	}
+	if a.jwt == nil {
+		return domain.Principal{}, unauthorized("аутентификация по токену не настроена")
+	}
+	return a.jwt.Verify(strings.TrimSpace(token))
+}
+
+// byAPIKey сравнивает ключ со всеми настроенными за постоянное время, чтобы по времени ответа нельзя было подбирать ключ
+func (a *Authenticator) byAPIKey(key string) (domain.Principal, error) {
+	var found *APIKey
+	for i := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(a.keys[i].Key), []byte(key)) == 1 {
+			found = &a.keys[i]
+		}
+	}
+	if found == nil {
+		return domain.Principal{}, unauthorized("неизвестный API-ключ")
+	}
+	return domain.Principal{
+		Subject: "service:" + found.Name,
+		Scopes:  []string{domain.ScopeAdmin},
+		Method:  domain.AuthAPIKey,
+	}, nil
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+func TestParseAPIKeys(t *testing.T) {
+	cases := []struct {
+		raw     []string
+		want    []APIKey
+		wantErr bool
+	}{
+		{raw: nil, want: []APIKey{}},
+		{raw: []string{"billing:s3cr3t", " reports:k:with:colons "}, want: []APIKey{{"billing", "s3cr3t"}, {"reports", "k:with:colons"}}},
+		{raw: []string{"billing"}, wantErr: true},
+		{raw: []string{":s3cr3t"}, wantErr: true},
+		{raw: []string{"billing:"}, wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := ParseAPIKeys(c.raw)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%q: ключи разобраны, ожидалась ошибка", c.raw)
+			}
+			continue
+		}
+		if err != nil || len(got) != len(c.want) {
+			t.Errorf("%q: %v, %v, ожидалось %v", c.raw, got, err, c.want)
+			continue
+		}
+		for i := range c.want {
+			if got[i] != c.want[i] {
+				t.Errorf("%q: ключ %d %v, ожидался %v", c.raw, i, got[i], c.want[i])
+			}
+		}
+	}
+}
+
+type allUsersGrants struct{ role string }
+
+func (g allUsersGrants) AllUsers(p domain.Principal) bool {
+	for _, r := range p.Roles {
+		if r == g.role {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAuthenticator(t *testing.T) {
+	verifier := NewJWTVerifier(testSecret, nil, "", "")
+	keys := []APIKey{{Name: "billing", Key: "s3cr3t"}}
+	user := claimsWith(nil)["sub"].(string)
+
+	cases := []struct {
+		name          string
+		jwt           *JWTVerifier
+		authorization string
+		apiKey        string
+		wantErr       bool
+		subject       string
+		method        string
+		anyUser       bool
+	}{
+		{name: "bearer", jwt: verifier, authorization: "Bearer " + signHS256(t, testSecret, claimsWith(nil)), subject: user, method: domain.AuthJWT},
+		{name: "схема без учёта регистра", jwt: verifier, authorization: "bearer " + signHS256(t, testSecret, claimsWith(nil)), subject: user, method: domain.AuthJWT},
+		{name: "роль со всеми пользователями", jwt: verifier, subject: user, method: domain.AuthJWT, anyUser: true,
+			authorization: "Bearer " + signHS256(t, testSecret, claimsWith(map[string]any{"roles": "support"}))},
+		{name: "API-ключ важнее токена", jwt: verifier, apiKey: "s3cr3t", authorization: "Bearer garbage",
+			subject: "service:billing", method: domain.AuthAPIKey, anyUser: true},
+		{name: "неизвестный API-ключ", jwt: verifier, apiKey: "guess", wantErr: true},
+		{name: "без учётных данных", jwt: verifier, wantErr: true},
+		{name: "Basic вместо Bearer", jwt: verifier, authorization: "Basic dXNlcjpwYXNz", wantErr: true},
+		{name: "Bearer без токена", jwt: verifier, authorization: "Bearer ", wantErr: true},
+		{name: "токены не настроены", authorization: "Bearer " + signHS256(t, testSecret, claimsWith(nil)), wantErr: true},
+		{name: "невалидный токен", jwt: verifier, authorization: "Bearer " + signHS256(t, []byte("wrong"), claimsWith(nil)), wantErr: true},
+	}
+
+	for _, c := range cases {
+		auth := NewAuthenticator(zap.NewNop(), c.jwt, keys, allUsersGrants{role: "support"})
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/subscriptions", nil)
+		if c.authorization != "" {
+			req.Header.Set(echo.HeaderAuthorization, c.authorization)
+		}
+		if c.apiKey != "" {
+			req.Header.Set(HeaderAPIKey, c.apiKey)
+		}
+		rec := httptest.NewRecorder()
+		ctx := echo.New().NewContext(req, rec)
+
+		var (
+			principal domain.Principal
+			actor     string
+			called    bool
+		)
+		err := auth.Middleware()(func(c echo.Context) error {
+			called = true
+			principal, _ = domain.PrincipalFromContext(c.Request().Context())
+			actor = domain.ActorFromContext(c.Request().Context())
+			return nil
+		})(ctx)
+
+		if c.wantErr {
+			if !errors.Is(err, errors.ErrUnauthorized) || called {
+				t.Errorf("%s: ошибка %v, хендлер вызван %v - ожидался 401", c.name, err, called)
+			}
+			if rec.Header().Get(echo.HeaderWWWAuthenticate) == "" {
+				t.Errorf("%s: нет WWW-Authenticate", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if principal.Subject != c.subject || principal.Method != c.method || principal.AnyUser() != c.anyUser {
+			t.Errorf("%s: принципал %+v, ожидался %s %s, все пользователи %v", c.name, principal, c.subject, c.method, c.anyUser)
+		}
+		if actor != c.subject {
+			t.Errorf("%s: автор изменений %q, ожидался %q", c.name, actor, c.subject)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	stdhttp "net/http"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed - ответ взят из сохранённого, а не выполнен заново
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// replayHeaders - заголовки ответа, которые сохраняются вместе с телом и отдаются при повторе
+var replayHeaders = []string{echo.HeaderContentType, echo.HeaderLocation, "ETag"}
+
+// IdempotencyStore - хранилище ключей идемпотентности, реализуется repository.IdempotencyRepo
+type IdempotencyStore interface {
+	Reserve(ctx context.Context, rec domain.IdempotencyRecord) (domain.IdempotencyRecord, bool, error)
+	Complete(ctx context.Context, rec domain.IdempotencyRecord) error
+	Release(ctx context.Context, owner, key string) error
+}
+
+// Idempotency - повтор запроса с тем же Idempotency-Key и тем же телом отдаёт сохранённый успешный ответ
+// вместо повторного выполнения, тот же ключ с другим телом - 422, пока первый запрос выполняется - 409.
+// Сохраняются только успешные ответы: после ошибки ключ освобождается, и запрос можно повторить.
+// Запросы без заголовка проходят как обычно
+func Idempotency(logger *zap.Logger, store IdempotencyStore, ttl time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(HeaderIdempotencyKey)
+			if key == "" {
+				return next(c)
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				return errors.ErrInvalidIdempotencyKey
+			}
+
+			body, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				return errors.Wrap(errors.ErrInvalidRequest, err)
+			}
+			c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+			ctx := c.Request().Context()
+			rec := domain.IdempotencyRecord{
+				Owner:       domain.ActorFromContext(ctx),
+				Key:         key,
+				RequestHash: requestHash(c.Request(), body),
+				ExpiresAt:   time.Now().Add(ttl),
+			}
+
+			existing, reserved, err := store.Reserve(ctx, rec)
+			if err != nil {
+				return err
+			}
+			if !reserved {
+				return replay(c, existing, rec.RequestHash)
+			}
+
+			recorder := &bodyRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+
+			// ответ сохраняем даже если клиент уже отвалился - ради его ретрая всё и затевается
+			saveCtx := context.WithoutCancel(ctx)
+			err = next(c)
+			status := c.Response().Status
+			if err != nil || status < 200 || status >= 300 {
+				if releaseErr := store.Release(saveCtx, rec.Owner, rec.Key); releaseErr != nil {
+					logger.Error("не удалось освободить ключ идемпотентности", zap.String("key", key), zap.Error(releaseErr))
+				}
+				return err
+			}
+
+			rec.Status = status
+			rec.Body = recorder.body.Bytes()
+			rec.Headers = make(map[string]string)
+			for _, name := range replayHeaders {
+				if v := c.Response().Header().Get(name); v != "" {
+					rec.Headers[name] = v
+				}
+			}
+			if err := store.Complete(saveCtx, rec); err != nil {
+				// ответ уже ушёл клиенту, портить его поздно; ключ протухнет по TTL
+				logger.Error("не удалось сохранить ответ по ключу идемпотентности", zap.String("key", key), zap.Error(err))
+			}
+			return nil
+		}
+	}
+}
+
+func replay(c echo.Context, rec domain.IdempotencyRecord, hash string) error {
+	if rec.RequestHash != hash {
+		return errors.ErrIdempotencyKeyReused
+	}
+	if rec.Status == 0 {
+		return errors.ErrIdempotencyKeyInProgress
+	}
+
+	header := c.Response().Header()
+	for name, v := range rec.Headers {
+		header.Set(name, v)
+	}
+	header.Set(HeaderIdempotentReplayed, "true")
+	return c.Blob(rec.Status, rec.Headers[echo.HeaderContentType], rec.Body)
+}
+
+// requestHash - хэш метода, пути и тела: тот же ключ на другой ручке тоже считается другим запросом
+func requestHash(r *stdhttp.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// bodyRecorder пишет ответ клиенту и параллельно копит его для сохранения
+type bodyRecorder struct {
+	stdhttp.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *bodyRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// memIdempotencyStore - ключи в памяти с той же семантикой, что у repository.IdempotencyRepo
+type memIdempotencyStore struct {
+	records map[string]domain.IdempotencyRecord
+}
+
+func (s *memIdempotencyStore) Reserve(_ context.Context, rec domain.IdempotencyRecord) (domain.IdempotencyRecord, bool, error) {
+	if existing, ok := s.records[rec.Owner+"/"+rec.Key]; ok {
+		return existing, false, nil
+	}
+	s.records[rec.Owner+"/"+rec.Key] = rec
+	return rec, true, nil
+}
+
+func (s *memIdempotencyStore) Complete(_ context.Context, rec domain.IdempotencyRecord) error {
+	s.records[rec.Owner+"/"+rec.Key] = rec
+	return nil
+}
+
+func (s *memIdempotencyStore) Release(_ context.Context, owner, key string) error {
+	delete(s.records, owner+"/"+key)
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	const created = `{"id":7,"service_name":"Netflix"}`
+	store := &memIdempotencyStore{records: make(map[string]domain.IdempotencyRecord)}
+	// запрос, который уже выполняется: ключ занят, ответа ещё нет
+	store.records["alice/in-flight"] = domain.IdempotencyRecord{Owner: "alice", Key: "in-flight",
+		RequestHash: requestHash(httptest.NewRequest(http.MethodPost, "/api/v1/subscriptions", nil), []byte(`{}`))}
+
+	// шаги идут по порядку над одним хранилищем
+	cases := []struct {
+		name     string
+		actor    string
+		key      string
+		path     string
+		body     string
+		respond  int // статус, которым ответит хендлер; 0 - хендлер вернёт ошибку
+		called   bool
+		wantErr  *errors.Error
+		status   int
+		replayed bool
+	}{
+		{name: "без ключа", body: `{}`, respond: 201, called: true, status: 201},
+		{name: "слишком длинный ключ", key: strings.Repeat("k", maxIdempotencyKeyLength+1), body: `{}`, wantErr: errors.ErrInvalidIdempotencyKey},
+		{name: "первый запрос", key: "k1", body: `{"service_name":"Netflix"}`, respond: 201, called: true, status: 201},
+		{name: "повтор отдаёт сохранённый ответ", key: "k1", body: `{"service_name":"Netflix"}`, status: 201, replayed: true},
+		{name: "тот же ключ с другим телом", key: "k1", body: `{"service_name":"Okko"}`, wantErr: errors.ErrIdempotencyKeyReused},
+		{name: "тот же ключ на другой ручке", key: "k1", path: "/api/v1/subscriptions/batch", body: `{"service_name":"Netflix"}`,
+			wantErr: errors.ErrIdempotencyKeyReused},
+		{name: "тот же ключ у другого автора", actor: "bob", key: "k1", body: `{"service_name":"Okko"}`, respond: 201, called: true, status: 201},
+		{name: "первый запрос ещё выполняется", key: "in-flight", body: `{}`, wantErr: errors.ErrIdempotencyKeyInProgress},
+		{name: "ошибка освобождает ключ", key: "k2", body: `{}`, respond: 0, called: true, wantErr: errors.ErrSubscriptionOverlap},
+		{name: "после ошибки запрос выполняется заново", key: "k2", body: `{}`, respond: 400, called: true, status: 400},
+		{name: "и после неуспешного ответа тоже", key: "k2", body: `{}`, respond: 201, called: true, status: 201},
+	}
+
+	for _, c := range cases {
+		actor, path := c.actor, c.path
+		if actor == "" {
+			actor = "alice"
+		}
+		if path == "" {
+			path = "/api/v1/subscriptions"
+		}
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(c.body))
+		req = req.WithContext(domain.WithActor(req.Context(), actor))
+		if c.key != "" {
+			req.Header.Set(HeaderIdempotencyKey, c.key)
+		}
+		rec := httptest.NewRecorder()
+
+		called := false
+		err := Idempotency(zap.NewNop(), store, time.Hour)(func(ctx echo.Context) error {
+			called = true
+			// хендлер должен видеть тело целиком, хотя middleware его уже прочитал
+			body, err := io.ReadAll(ctx.Request().Body)
+			if err != nil || string(body) != c.body {
+				t.Errorf("%s: хендлер получил тело %q, %v", c.name, body, err)
+			}
+			if c.respond == 0 {
+				return errors.ErrSubscriptionOverlap
+			}
+			ctx.Response().Header().Set(echo.HeaderLocation, "/api/v1/subscriptions/7")
+			return ctx.JSONBlob(c.respond, []byte(created))
+		})(echo.New().NewContext(req, rec))
+
+		if called != c.called {
+			t.Errorf("%s: хендлер вызван %v, ожидалось %v", c.name, called, c.called)
+		}
+		if c.wantErr != nil {
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, c.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if rec.Code != c.status || rec.Body.String() != created {
+			t.Errorf("%s: %d %s, ожидалось %d %s", c.name, rec.Code, rec.Body.String(), c.status, created)
+		}
+		if got := rec.Header().Get(HeaderIdempotentReplayed) == "true"; got != c.replayed {
+			t.Errorf("%s: Idempotent-Replayed %v, ожидалось %v", c.name, got, c.replayed)
+		}
+		if c.replayed {
+			if rec.Header().Get(echo.HeaderLocation) != "/api/v1/subscriptions/7" || rec.Header().Get(echo.HeaderContentType) != echo.MIMEApplicationJSON {
+				t.Errorf("%s: заголовки повтора %v", c.name, rec.Header())
+			}
+		}
+	}
+}
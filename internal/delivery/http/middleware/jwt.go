@@ -0,0 +1,235 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JWT проверяем сами: нужны только HS256 и RS256 и проверка пары стандартных claims,
+// ради этого библиотека не нужна. alg из заголовка токена принимается только тот, для которого
+// в конфиге есть ключ - так не пройдёт ни "none", ни подмена RS256 на HS256 с публичным ключом вместо секрета
+
+// clockSkew - допуск на расхождение часов при проверке exp и nbf
+const clockSkew = 30 * time.Second
+
+type JWTVerifier struct {
+	secret    []byte
+	publicKey *rsa.PublicKey
+	issuer    string
+	audience  string
+}
+
+// NewJWTVerifier - secret для HS256, publicKey для RS256, хотя бы один обязателен.
+// issuer и audience проверяются, только если заданы
+func NewJWTVerifier(secret []byte, publicKey *rsa.PublicKey, issuer, audience string) *JWTVerifier {
+	return &JWTVerifier{secret: secret, publicKey: publicKey, issuer: issuer, audience: audience}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+}
+
+type jwtClaims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *float64 `json:"exp"`
+	NotBefore *float64 `json:"nbf"`
+	// скоупы принимаем и в OAuth-виде (строка через пробел), и массивом
+	Scope  string   `json:"scope"`
+	Scopes []string `json:"scopes"`
+	// роли для политики доступа, так же строкой через пробел или массивом
+	Roles roles `json:"roles"`
+}
+
+type roles []string
+
+func (r *roles) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*r = strings.Fields(single)
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*r = many
+	return nil
+}
+
+// audience - aud по RFC 7519 бывает и строкой, и массивом строк
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// Verify проверяет подпись и claims токена и отдаёт принципала
+func (v *JWTVerifier) Verify(token string) (domain.Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return domain.Principal{}, unauthorized("токен не в формате JWT")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return domain.Principal{}, unauthorized("невалидный заголовок токена")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return domain.Principal{}, unauthorized("невалидная подпись токена")
+	}
+	if err := v.verifySignature(header.Alg, parts[0]+"."+parts[1], signature); err != nil {
+		return domain.Principal{}, err
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return domain.Principal{}, unauthorized("невалидное тело токена")
+	}
+	if err := v.verifyClaims(claims, time.Now()); err != nil {
+		return domain.Principal{}, err
+	}
+
+	principal := domain.Principal{
+		Subject: claims.Subject,
+		Scopes:  append(strings.Fields(claims.Scope), claims.Scopes...),
+		Roles:   claims.Roles,
+		Method:  domain.AuthJWT,
+	}
+	if uid, err := uuid.Parse(claims.Subject); err == nil {
+		principal.UserID = &uid
+	}
+	return principal, nil
+}
+
+func (v *JWTVerifier) verifySignature(alg, signed string, signature []byte) error {
+	switch alg {
+	case "HS256":
+		if len(v.secret) == 0 {
+			return unauthorized("HS256 не настроен")
+		}
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return unauthorized("подпись токена не сходится")
+		}
+		return nil
+	case "RS256":
+		if v.publicKey == nil {
+			return unauthorized("RS256 не настроен")
+		}
+		sum := sha256.Sum256([]byte(signed))
+		if err := rsa.VerifyPKCS1v15(v.publicKey, crypto.SHA256, sum[:], signature); err != nil {
+			return unauthorized("подпись токена не сходится")
+		}
+		return nil
+	}
+	return unauthorized(fmt.Sprintf("неподдерживаемый алгоритм подписи %q", alg))
+}
+
+func (v *JWTVerifier) verifyClaims(claims jwtClaims, now time.Time) error {
+	if claims.Subject == "" {
+		return unauthorized("в токене нет sub")
+	}
+	if claims.ExpiresAt == nil {
+		return unauthorized("в токене нет exp")
+	}
+	if now.Add(-clockSkew).After(numericDate(*claims.ExpiresAt)) {
+		return unauthorized("токен просрочен")
+	}
+	if claims.NotBefore != nil && now.Add(clockSkew).Before(numericDate(*claims.NotBefore)) {
+		return unauthorized("токен ещё не действует")
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return unauthorized("чужой издатель токена")
+	}
+	if v.audience != "" && !contains(claims.Audience, v.audience) {
+		return unauthorized("токен выпущен не для этого сервиса")
+	}
+	return nil
+}
+
+// LoadRSAPublicKey читает публичный ключ для RS256 из PEM: PKIX, PKCS#1 или сертификат
+func LoadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения публичного ключа: %w", err)
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("публичный ключ не в формате PEM")
+	}
+
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if key, ok := cert.PublicKey.(*rsa.PublicKey); ok {
+			return key, nil
+		}
+	default:
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if key, ok := key.(*rsa.PublicKey); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("ключ не RSA")
+}
+
+func decodeSegment(segment string, v any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func numericDate(v float64) time.Time {
+	return time.Unix(0, int64(v*float64(time.Second)))
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func unauthorized(reason string) error {
+	return errors.Wrap(errors.ErrUnauthorized, fmt.Errorf("%s", reason))
+}
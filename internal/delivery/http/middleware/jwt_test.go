@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testovoe_again/internal/errors"
+	"time"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func segment(t *testing.T, v any) string {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func signHS256(t *testing.T, secret []byte, claims map[string]any) string {
+	t.Helper()
+	signed := segment(t, map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + segment(t, claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, claims map[string]any) string {
+	t.Helper()
+	signed := segment(t, map[string]string{"alg": "RS256", "typ": "JWT"}) + "." + segment(t, claims)
+	sum := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatalf("rsa: %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func claimsWith(overrides map[string]any) map[string]any {
+	claims := map[string]any{
+		"sub": "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iss": "auth.example.com",
+		"aud": "subscriptions",
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(claims, k)
+			continue
+		}
+		claims[k] = v
+	}
+	return claims
+}
+
+func TestJWTVerifier(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa: %v", err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa: %v", err)
+	}
+	publicDER := x509.MarshalPKCS1PublicKey(&key.PublicKey)
+	now := time.Now()
+
+	both := NewJWTVerifier(testSecret, &key.PublicKey, "auth.example.com", "subscriptions")
+	onlyRSA := NewJWTVerifier(nil, &key.PublicKey, "", "")
+	onlyHMAC := NewJWTVerifier(testSecret, nil, "", "")
+
+	cases := []struct {
+		name     string
+		verifier *JWTVerifier
+		token    string
+		wantErr  bool
+		wantUser bool
+		scopes   []string
+		roles    []string
+	}{
+		{name: "HS256", verifier: both, token: signHS256(t, testSecret, claimsWith(nil)), wantUser: true},
+		{name: "RS256", verifier: both, token: signRS256(t, key, claimsWith(nil)), wantUser: true},
+		{name: "sub не uuid - принципал без пользователя", verifier: both,
+			token: signHS256(t, testSecret, claimsWith(map[string]any{"sub": "billing-service"}))},
+		{name: "скоупы строкой и массивом, роли строкой", verifier: both, wantUser: true,
+			token:  signHS256(t, testSecret, claimsWith(map[string]any{"scope": "read write", "scopes": []string{"admin"}, "roles": "support auditor"})),
+			scopes: []string{"read", "write", "admin"}, roles: []string{"support", "auditor"}},
+		{name: "роли массивом", verifier: both, wantUser: true,
+			token: signHS256(t, testSecret, claimsWith(map[string]any{"roles": []string{"support"}})), roles: []string{"support"}},
+		{name: "aud массивом", verifier: both, wantUser: true,
+			token: signHS256(t, testSecret, claimsWith(map[string]any{"aud": []string{"billing", "subscriptions"}}))},
+		{name: "exp в пределах допуска часов", verifier: both, wantUser: true,
+			token: signHS256(t, testSecret, claimsWith(map[string]any{"exp": now.Add(-clockSkew / 2).Unix()}))},
+		{name: "nbf в пределах допуска часов", verifier: both, wantUser: true,
+			token: signHS256(t, testSecret, claimsWith(map[string]any{"nbf": now.Add(clockSkew / 2).Unix()}))},
+
+		{name: "чужой секрет", verifier: both, token: signHS256(t, []byte("wrong"), claimsWith(nil)), wantErr: true},
+		{name: "чужой ключ RSA", verifier: both, token: signRS256(t, otherKey, claimsWith(nil)), wantErr: true},
+		{name: "подмена RS256 на HS256 с публичным ключом", verifier: onlyRSA, token: signHS256(t, publicDER, claimsWith(nil)), wantErr: true},
+		{name: "RS256 без ключа", verifier: onlyHMAC, token: signRS256(t, key, claimsWith(nil)), wantErr: true},
+		{name: "alg none", verifier: both, wantErr: true,
+			token: segment(t, map[string]string{"alg": "none"}) + "." + segment(t, claimsWith(nil)) + "."},
+		{name: "подменённое тело", verifier: both, wantErr: true, token: func() string {
+			parts := strings.Split(signHS256(t, testSecret, claimsWith(nil)), ".")
+			parts[1] = segment(t, claimsWith(map[string]any{"scope": "admin"}))
+			return strings.Join(parts, ".")
+		}()},
+		{name: "просрочен", verifier: both, wantErr: true,
+			token: signHS256(t, testSecret, claimsWith(map[string]any{"exp": now.Add(-time.Hour).Unix()}))},
+		{name: "ещё не действует", verifier: both, wantErr: true,
+			token: signHS256(t, testSecret, claimsWith(map[string]any{"nbf": now.Add(time.Hour).Unix()}))},
+		{name: "без exp", verifier: both, wantErr: true, token: signHS256(t, testSecret, claimsWith(map[string]any{"exp": nil}))},
+		{name: "без sub", verifier: both, wantErr: true, token: signHS256(t, testSecret, claimsWith(map[string]any{"sub": nil}))},
+		{name: "чужой издатель", verifier: both, wantErr: true,
+			token: signHS256(t, testSecret, claimsWith(map[string]any{"iss": "evil.example.com"}))},
+		{name: "чужая аудитория", verifier: both, wantErr: true,
+			token: signHS256(t, testSecret, claimsWith(map[string]any{"aud": []string{"billing"}}))},
+		{name: "не JWT", verifier: both, token: "abc.def", wantErr: true},
+		{name: "подпись не base64url", verifier: both, wantErr: true,
+			token: strings.Join(strings.Split(signHS256(t, testSecret, claimsWith(nil)), ".")[:2], ".") + ".!!!"},
+		{name: "заголовок не JSON", verifier: both, token: "bm90LWpzb24." + segment(t, claimsWith(nil)) + ".c2ln", wantErr: true},
+	}
+
+	for _, c := range cases {
+		p, err := c.verifier.Verify(c.token)
+		if c.wantErr {
+			if !errors.Is(err, errors.ErrUnauthorized) {
+				t.Errorf("%s: ошибка %v, ожидалась ErrUnauthorized", c.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if (p.UserID != nil) != c.wantUser {
+			t.Errorf("%s: user_id %v, ожидался %v", c.name, p.UserID, c.wantUser)
+		}
+		if c.scopes != nil && strings.Join(p.Scopes, " ") != strings.Join(c.scopes, " ") {
+			t.Errorf("%s: скоупы %v, ожидались %v", c.name, p.Scopes, c.scopes)
+		}
+		if c.roles != nil && strings.Join(p.Roles, " ") != strings.Join(c.roles, " ") {
+			t.Errorf("%s: роли %v, ожидались %v", c.name, p.Roles, c.roles)
+		}
+	}
+}
+
+func TestLoadRSAPublicKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa: %v", err)
+	}
+	pkix509, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("pkix: %v", err)
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "auth"},
+		NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("cert: %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		content []byte
+		wantErr bool
+	}{
+		{name: "PKIX", content: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix509})},
+		{name: "PKCS#1", content: pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)})},
+		{name: "сертификат", content: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})},
+		{name: "не PEM", content: []byte("ssh-rsa AAAA"), wantErr: true},
+		{name: "мусор внутри PEM", content: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("garbage")}), wantErr: true},
+	}
+
+	dir := t.TempDir()
+	for i, c := range cases {
+		path := filepath.Join(dir, string(rune('a'+i))+".pem")
+		if err := os.WriteFile(path, c.content, 0o600); err != nil {
+			t.Fatalf("запись ключа: %v", err)
+		}
+		got, err := LoadRSAPublicKey(path)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%s: ключ прочитан, ожидалась ошибка", c.name)
+			}
+			continue
+		}
+		if err != nil || !got.Equal(&key.PublicKey) {
+			t.Errorf("%s: %v", c.name, err)
+		}
+	}
+
+	if _, err := LoadRSAPublicKey(filepath.Join(dir, "missing.pem")); err == nil {
+		t.Errorf("несуществующий файл прочитан без ошибки")
+	}
+}
@@ -1 +1,24 @@
 package middleware
+
+import (
+	"testovoe_again/internal/domain"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HeaderActor - кто делает запрос, если аутентификация выключена
+const HeaderActor = "X-Actor"
+
+// Actor кладёт автора запроса в контекст, откуда его забирает журнал изменений подписок.
+// Authenticator идёт после него и перезаписывает автора принципалом, так что с аутентификацией заголовок ничего не решает
+func Actor() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if actor := c.Request().Header.Get(HeaderActor); actor != "" {
+				ctx := domain.WithActor(c.Request().Context(), actor)
+				c.SetRequest(c.Request().WithContext(ctx))
+			}
+			return next(c)
+		}
+	}
+}
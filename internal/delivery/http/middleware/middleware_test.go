@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe_again/internal/domain"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestActor(t *testing.T) {
+	cases := []struct {
+		header string
+		want   string
+	}{
+		{"", domain.AnonymousActor},
+		{"support@example.com", "support@example.com"},
+	}
+
+	for _, c := range cases {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/subscriptions", nil)
+		if c.header != "" {
+			req.Header.Set(HeaderActor, c.header)
+		}
+		ctx := e.NewContext(req, httptest.NewRecorder())
+
+		var got string
+		err := Actor()(func(c echo.Context) error {
+			got = domain.ActorFromContext(c.Request().Context())
+			return nil
+		})(ctx)
+		if err != nil {
+			t.Fatalf("Actor: %v", err)
+		}
+		if got != c.want {
+			t.Errorf("X-Actor %q: автор %q, ожидалось %q", c.header, got, c.want)
+		}
+	}
+}
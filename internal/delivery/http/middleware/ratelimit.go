@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"testovoe_again/internal/ratelimit"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// Заголовки по черновику IETF RateLimit header fields
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+)
+
+// DefaultLimitGroup - группа, лимит которой действует на группы маршрутов без собственного
+const DefaultLimitGroup = "default"
+
+// RateLimiter раздаёт middleware лимитов по группам маршрутов. Бакет - группа + клиент,
+// клиент - принципал (API-ключ или sub токена), а без аутентификации - IP
+type RateLimiter struct {
+	logger *zap.Logger
+	store  ratelimit.Store
+	limits map[string]ratelimit.Limit
+}
+
+func NewRateLimiter(logger *zap.Logger, store ratelimit.Store, limits map[string]ratelimit.Limit) *RateLimiter {
+	return &RateLimiter{logger: logger, store: store, limits: limits}
+}
+
+// Group - middleware для группы маршрутов. Без своего лимита и без default группа не ограничивается.
+// Ставится после аутентификации, иначе все клиенты за одним NAT делят один бакет
+func (l *RateLimiter) Group(group string) echo.MiddlewareFunc {
+	limit, ok := l.limits[group]
+	if !ok {
+		limit, ok = l.limits[DefaultLimitGroup]
+	}
+	if !ok {
+		return func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			client := clientKey(c)
+			res, err := l.store.Take(c.Request().Context(), group+":"+client, limit)
+			if err != nil {
+				// хранилище лимитов недоступно - пропускаем запрос: лучше временно без лимита, чем без API
+				l.logger.Error("rate limit недоступен, запрос пропущен без лимита", zap.Error(err))
+				return next(c)
+			}
+
+			header := c.Response().Header()
+			header.Set(HeaderRateLimitLimit, strconv.Itoa(res.Limit))
+			header.Set(HeaderRateLimitRemaining, strconv.Itoa(res.Remaining))
+			header.Set(HeaderRateLimitReset, ceilSeconds(res.Reset))
+
+			if !res.Allowed {
+				l.logger.Warn("превышен лимит запросов", zap.String("group", group), zap.String("client", client))
+				header.Set(echo.HeaderRetryAfter, ceilSeconds(res.RetryAfter))
+				return errors.ErrRateLimited
+			}
+			return next(c)
+		}
+	}
+}
+
+func clientKey(c echo.Context) string {
+	if p, ok := domain.PrincipalFromContext(c.Request().Context()); ok {
+		return p.Method + ":" + p.Subject
+	}
+	return "ip:" + c.RealIP()
+}
+
+// ceilSeconds - секунды для заголовков округляем вверх, чтобы клиент не пришёл за токеном раньше времени
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"testovoe_again/internal/ratelimit"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// fixedStore отвечает заданным результатом и запоминает ключ бакета
+type fixedStore struct {
+	res ratelimit.Result
+	err error
+	key string
+}
+
+func (s *fixedStore) Take(_ context.Context, key string, _ ratelimit.Limit) (ratelimit.Result, error) {
+	s.key = key
+	return s.res, s.err
+}
+
+func (s *fixedStore) Sweep(context.Context, time.Duration) (int64, error) { return 0, nil }
+
+func TestRateLimiter(t *testing.T) {
+	limits := map[string]ratelimit.Limit{
+		DefaultLimitGroup: {Rate: 1, Burst: 60},
+		"export":          {Rate: 0.1, Burst: 2},
+	}
+	cases := []struct {
+		name      string
+		group     string
+		limits    map[string]ratelimit.Limit
+		principal *domain.Principal
+		res       ratelimit.Result
+		storeErr  error
+		wantErr   bool
+		key       string
+		headers   map[string]string
+	}{
+		{name: "пропущен", group: "export", res: ratelimit.Result{Allowed: true, Limit: 2, Remaining: 1, Reset: 5500 * time.Millisecond},
+			key: "export:ip:192.0.2.1", headers: map[string]string{HeaderRateLimitLimit: "2", HeaderRateLimitRemaining: "1", HeaderRateLimitReset: "6"}},
+		{name: "отказ с Retry-After вверх", group: "export", wantErr: true,
+			res: ratelimit.Result{Limit: 2, RetryAfter: 1200 * time.Millisecond, Reset: 20 * time.Second}, key: "export:ip:192.0.2.1",
+			headers: map[string]string{HeaderRateLimitRemaining: "0", echo.HeaderRetryAfter: "2", HeaderRateLimitReset: "20"}},
+		{name: "бакет по принципалу", group: "subscriptions", principal: &domain.Principal{Subject: "billing", Method: domain.AuthAPIKey},
+			res: ratelimit.Result{Allowed: true, Limit: 60}, key: "subscriptions:api_key:billing"},
+		{name: "хранилище недоступно - без лимита", group: "export", storeErr: stderrors.New("conn refused"), key: "export:ip:192.0.2.1",
+			headers: map[string]string{HeaderRateLimitLimit: ""}},
+		{name: "группа без лимита и без default", group: "export", limits: map[string]ratelimit.Limit{"import": {Rate: 1, Burst: 1}},
+			headers: map[string]string{HeaderRateLimitLimit: ""}},
+	}
+
+	for _, c := range cases {
+		store := &fixedStore{res: c.res, err: c.storeErr}
+		groupLimits := limits
+		if c.limits != nil {
+			groupLimits = c.limits
+		}
+		limiter := NewRateLimiter(zap.NewNop(), store, groupLimits)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/subscriptions/export", nil)
+		req.RemoteAddr = "192.0.2.1:51234"
+		if c.principal != nil {
+			req = req.WithContext(domain.WithPrincipal(req.Context(), *c.principal))
+		}
+		rec := httptest.NewRecorder()
+
+		called := false
+		err := limiter.Group(c.group)(func(echo.Context) error {
+			called = true
+			return nil
+		})(echo.New().NewContext(req, rec))
+
+		if c.wantErr {
+			if !errors.Is(err, errors.ErrRateLimited) || called {
+				t.Errorf("%s: ошибка %v, хендлер вызван %v - ожидался 429", c.name, err, called)
+			}
+		} else if err != nil || !called {
+			t.Errorf("%s: ошибка %v, хендлер вызван %v", c.name, err, called)
+		}
+		if store.key != c.key {
+			t.Errorf("%s: бакет %q, ожидался %q", c.name, store.key, c.key)
+		}
+		for header, want := range c.headers {
+			if got := rec.Header().Get(header); got != want {
+				t.Errorf("%s: %s = %q, ожидалось %q", c.name, header, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const MIMEMergePatch = "application/merge-patch+json"
+
+// Patch godoc
+// @Summary      частично обновить подписку
+// @Description  принимает JSON Merge Patch (RFC 7396): отсутствующие ключи не меняются, null очищает end_date и billing_period_days. Цена - price в целых единицах валюты или price_minor в минорных
+// @Tags         subscriptions
+// @Accept       application/merge-patch+json
+// @Produce      json
+// @Param        id    path    int                       true  "ID подписки"
+// @Param        input body    PatchSubscriptionRequest  true  "изменяемые поля"
+// @Param        If-Match  header  string  false  "ETag версии, которую редактирует клиент"
+// @Success      200   {object} CreateSubscriptionResponse
+// @Header       200   {string}  ETag  "новая версия подписки"
+// @Failure      400   {object} Problem "невалидный ID или документ"
+// @Failure      401   {object} Problem "нет или невалидные учётные данные"
+// @Failure      403   {object} Problem "операция запрещена роли"
+// @Failure      404   {object} Problem "подписка не найдена"
+// @Failure      409   {object} Problem "пересечение с другой подпиской сервиса (политика reject)"
+// @Failure      412   {object} Problem "подписка изменилась с момента чтения"
+// @Failure      415   {object} Problem "неподдерживаемый Content-Type"
+// @Failure      429   {object} Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500   {object} Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/subscriptions/{id} [patch]
+func (h *Handler) Patch(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Warn("невалидный id", zap.String("id", c.Param("id")))
+		return errors.ErrInvalidID
+	}
+
+	// обычный application/json тоже принимаем - многие клиенты не умеют выставлять merge-patch тип
+	mediaType, _, err := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if err != nil || (mediaType != MIMEMergePatch && mediaType != echo.MIMEApplicationJSON) {
+		return echo.NewHTTPError(415, "ожидается "+MIMEMergePatch)
+	}
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		h.logger.Warn("не удалось прочитать тело патча", zap.Error(err))
+		return invalid(err)
+	}
+
+	patch, err := ToPatch(body)
+	if err != nil {
+		h.logger.Warn("невалидный merge-patch документ", zap.Error(err))
+		return err
+	}
+
+	version, err := ParseIfMatch(c.Request().Header.Get(HeaderIfMatch))
+	if err != nil {
+		return err
+	}
+
+	if err := h.authorizeSubscription(c, id); err != nil {
+		return err
+	}
+
+	sub, err := h.service.Patch(c.Request().Context(), id, patch, version)
+	if err != nil {
+		h.logger.Warn("ошибка обработки запроса частичного обновления", zap.Error(err))
+		return err
+	}
+
+	c.Response().Header().Set(HeaderETag, ETag(sub.Version))
+	return c.JSON(200, ToResponse(sub))
+}
+
+// ToPatch разбирает merge-patch документ по ключам: так отличаем отсутствующий ключ от явного null.
+// Неизвестные ключи (в том числе id и user_id, которые менять нельзя) - ошибка, а не молчаливый игнор
+func ToPatch(body []byte) (domain.SubscriptionPatch, error) {
+	var (
+		doc               map[string]json.RawMessage
+		patch             domain.SubscriptionPatch
+		price, priceMinor *int64
+	)
+
+	if err := json.Unmarshal(body, &doc); err != nil || doc == nil {
+		return domain.SubscriptionPatch{}, errors.ErrInvalidPatch
+	}
+
+	for key, raw := range doc {
+		isNull := bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+
+		var err error
+		switch key {
+		case "service_name":
+			patch.ServiceName, err = decodeRequired[string](key, raw, isNull)
+		case "price":
+			price, err = decodeRequired[int64](key, raw, isNull)
+		case "price_minor":
+			priceMinor, err = decodeRequired[int64](key, raw, isNull)
+		case "currency":
+			patch.Currency, err = decodeRequired[string](key, raw, isNull)
+		case "start_date":
+			patch.StartDate, err = decodeRequired[string](key, raw, isNull)
+		case "billing_period":
+			patch.BillingPeriod, err = decodeRequired[string](key, raw, isNull)
+		case "end_date":
+			if isNull {
+				patch.ClearEndDate = true
+				continue
+			}
+			patch.EndDate, err = decodeRequired[string](key, raw, false)
+		case "billing_period_days":
+			if isNull {
+				patch.ClearBillingPeriodDays = true
+				continue
+			}
+			patch.BillingPeriodDays, err = decodeRequired[int](key, raw, false)
+		case "auto_renew":
+			patch.AutoRenew, err = decodeRequired[bool](key, raw, isNull)
+		default:
+			err = errors.Wrap(errors.ErrInvalidPatch.WithField(key), fmt.Errorf("поле %s нельзя изменить", key))
+		}
+		if err != nil {
+			return domain.SubscriptionPatch{}, err
+		}
+	}
+
+	// price и price_minor - два написания одной цены, как и в теле POST и PUT
+	if price != nil || priceMinor != nil {
+		minor, err := minorPrice(price, priceMinor)
+		if err != nil {
+			return domain.SubscriptionPatch{}, err
+		}
+		patch.Price = &minor
+	}
+
+	return patch, nil
+}
+
+// decodeRequired декодирует значение non-nullable поля, null для такого поля - ошибка
+func decodeRequired[T any](key string, raw json.RawMessage, isNull bool) (*T, error) {
+	if isNull {
+		return nil, errors.Wrap(errors.ErrInvalidPatch.WithField(key), fmt.Errorf("поле %s не может быть null", key))
+	}
+	var v T
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, errors.Wrap(errors.ErrInvalidPatch.WithField(key), fmt.Errorf("поле %s: %v", key, err))
+	}
+	return &v, nil
+}
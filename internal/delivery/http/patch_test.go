@@ -0,0 +1,53 @@
+package http
+
+import (
+	"testing"
+	"testovoe_again/internal/errors"
+)
+
+func TestToPatch(t *testing.T) {
+	cases := []struct {
+		body      string
+		wantField string
+	}{
+		{body: `{}`},
+		{body: `{"service_name": "Kinopoisk", "currency": "USD", "start_date": "08-2025"}`},
+		{body: `{"end_date": null, "billing_period_days": null}`},
+		{body: `{"end_date": "12-2025", "billing_period": "custom", "billing_period_days": 30, "auto_renew": true}`},
+		{body: `{"service_name": null}`, wantField: "service_name"},
+		{body: `{"auto_renew": null}`, wantField: "auto_renew"},
+		{body: `{"billing_period_days": "30"}`, wantField: "billing_period_days"},
+		{body: `{"id": 5}`, wantField: "id"},
+		{body: `{"user_id": "60601fee-2bf1-4721-ae6f-7636e79a0cba"}`, wantField: "user_id"},
+		{body: `{"status": "paused"}`, wantField: "status"},
+		{body: `[]`, wantField: "-"},
+		{body: `null`, wantField: "-"},
+		{body: `{`, wantField: "-"},
+	}
+
+	for _, c := range cases {
+		_, err := ToPatch([]byte(c.body))
+		if c.wantField == "" {
+			if err != nil {
+				t.Errorf("%s: %v", c.body, err)
+			}
+			continue
+		}
+		if !errors.Is(err, errors.ErrInvalidPatch) {
+			t.Errorf("%s: ошибка %v, ожидалась ErrInvalidPatch", c.body, err)
+			continue
+		}
+		var e *errors.Error
+		if c.wantField != "-" && (!errors.As(err, &e) || e.Field != c.wantField) {
+			t.Errorf("%s: ошибка %v, ожидалось поле %s", c.body, err, c.wantField)
+		}
+	}
+
+	patch, err := ToPatch([]byte(`{"end_date": null, "billing_period_days": 14}`))
+	if err != nil {
+		t.Fatalf("ToPatch: %v", err)
+	}
+	if !patch.ClearEndDate || patch.EndDate != nil || patch.BillingPeriodDays == nil || *patch.BillingPeriodDays != 14 {
+		t.Errorf("null и значение разобраны неверно: %+v", patch)
+	}
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func TestMinorPrice(t *testing.T) {
+	ptr := func(v int64) *int64 { return &v }
+	cases := []struct {
+		name       string
+		price      *int64
+		priceMinor *int64
+		want       int64
+		wantErr    bool
+	}{
+		{name: "только price в целых единицах", price: ptr(399), want: 39900},
+		{name: "только price_minor", priceMinor: ptr(39950), want: 39950},
+		{name: "оба совпадают", price: ptr(399), priceMinor: ptr(39950), want: 39950},
+		{name: "оба расходятся", price: ptr(39950), priceMinor: ptr(39950), wantErr: true},
+		{name: "ничего не передано", wantErr: true},
+		{name: "переполнение при переводе в минорные", price: ptr(1 << 62), wantErr: true},
+	}
+
+	for _, c := range cases {
+		got, err := minorPrice(c.price, c.priceMinor)
+		if c.wantErr {
+			if !errors.Is(err, errors.ErrInvalidPrice) {
+				t.Errorf("%s: ошибка %v, ожидалась ErrInvalidPrice", c.name, err)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("%s: %d, %v, ожидалось %d", c.name, got, err, c.want)
+		}
+	}
+}
+
+func TestPriceRoundTrip(t *testing.T) {
+	h := NewHandler(zap.NewNop(), nil, nil, nil, nil)
+	sub := domain.Subscription{ID: 1, ServiceName: "Yandex Plus", Price: 39950, Currency: domain.CurrencyRUB,
+		UserID: uuid.New(), StartDate: "07-2025"}
+
+	resp := ToResponse(sub)
+	if resp.Price != 399 || resp.PriceMinor != 39950 {
+		t.Fatalf("price %d, price_minor %d, ожидалось 399 и 39950", resp.Price, resp.PriceMinor)
+	}
+
+	// тело из GET уходит обратно в PUT без правок
+	back, err := h.ToDomain(CreateSubscriptionRequest{ServiceName: resp.ServiceName, Price: resp.Price, PriceMinor: &resp.PriceMinor,
+		Currency: resp.Currency, UserID: resp.UserID, StartDate: resp.StartDate})
+	if err != nil {
+		t.Fatalf("ToDomain: %v", err)
+	}
+	if back.Price != sub.Price {
+		t.Errorf("цена после круга %d, ожидалась %d", back.Price, sub.Price)
+	}
+
+	// старый клиент шлёт рубли
+	old, err := h.ToDomain(CreateSubscriptionRequest{ServiceName: "Yandex Plus", Price: 400, UserID: sub.UserID.String(), StartDate: "07-2025"})
+	if err != nil || old.Price != 40000 {
+		t.Errorf("price 400 - %d, %v, ожидалось 40000 копеек", old.Price, err)
+	}
+}
+
+func TestToPatchPrice(t *testing.T) {
+	cases := []struct {
+		body    string
+		want    int64
+		wantErr bool
+	}{
+		{body: `{"price": 499}`, want: 49900},
+		{body: `{"price_minor": 49950}`, want: 49950},
+		{body: `{"price": 499, "price_minor": 49950}`, want: 49950},
+		{body: `{"price": 500, "price_minor": 49950}`, wantErr: true},
+		{body: `{"price": null}`, wantErr: true},
+	}
+
+	for _, c := range cases {
+		patch, err := ToPatch([]byte(c.body))
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%s: ожидалась ошибка", c.body)
+			}
+			continue
+		}
+		if err != nil || patch.Price == nil || *patch.Price != c.want {
+			t.Errorf("%s: %v, %v, ожидалось %d", c.body, patch.Price, err, c.want)
+		}
+	}
+
+	patch, err := ToPatch([]byte(`{"service_name": "Kinopoisk"}`))
+	if err != nil || patch.Price != nil {
+		t.Errorf("без цены в патче цена не должна меняться: %v, %v", patch.Price, err)
+	}
+}
+
+func TestToListFilterPriceBounds(t *testing.T) {
+	h := NewHandler(zap.NewNop(), nil, nil, nil, nil)
+	filter, err := h.ToListFilter(ListSubscriptionsRequest{MinPrice: "100", MaxPrice: "399"})
+	if err != nil {
+		t.Fatalf("ToListFilter: %v", err)
+	}
+	if *filter.MinPrice != 10000 {
+		t.Errorf("min_price=100 - %d, ожидалось 10000", *filter.MinPrice)
+	}
+	// 399.99 отдаётся с price 399 и должна попасть под max_price=399
+	if *filter.MaxPrice != 39999 {
+		t.Errorf("max_price=399 - %d, ожидалось 39999", *filter.MaxPrice)
+	}
+
+	for _, raw := range []string{"abc", "9223372036854775807"} {
+		if _, err := h.ToListFilter(ListSubscriptionsRequest{MaxPrice: raw}); !errors.Is(err, errors.ErrInvalidFilter) {
+			t.Errorf("max_price=%s: ошибка %v, ожидалась ErrInvalidFilter", raw, err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package http
+
+import (
+	stdhttp "net/http"
+	"strings"
+	"testovoe_again/internal/errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// MIMEProblemJSON - RFC 7807, все ошибки API отдаются в этом формате
+const MIMEProblemJSON = "application/problem+json"
+
+// Problem - тело ошибки по RFC 7807. Клиентам стоит опираться на code: он стабилен,
+// а title и detail - человекочитаемые и могут меняться
+type Problem struct {
+	Type     string         `json:"type" example:"urn:subscriptions:problem:invalid_price"`
+	Title    string         `json:"title" example:"Bad Request"`
+	Status   int            `json:"status" example:"400"`
+	Detail   string         `json:"detail,omitempty" example:"указана невалидная цена"`
+	Instance string         `json:"instance,omitempty" example:"/api/v1/subscriptions"`
+	Code     string         `json:"code" example:"invalid_price"`
+	Field    string         `json:"field,omitempty" example:"price"`
+	Errors   []FieldProblem `json:"errors,omitempty"`
+}
+
+// FieldProblem - ошибка валидации одного поля тела запроса
+type FieldProblem struct {
+	Field string `json:"field" example:"service_name"`
+	Code  string `json:"code" example:"required"`
+}
+
+// problemTypePrefix - type у всех проблем это URN с кодом, отдельной страницы документации под каждую нет
+const problemTypePrefix = "urn:subscriptions:problem:"
+
+// StatusFor - HTTP-статус для класса типизированной ошибки
+func StatusFor(kind errors.Kind) int {
+	switch kind {
+	case errors.KindInvalid:
+		return stdhttp.StatusBadRequest
+	case errors.KindUnauthorized:
+		return stdhttp.StatusUnauthorized
+	case errors.KindForbidden:
+		return stdhttp.StatusForbidden
+	case errors.KindNotFound:
+		return stdhttp.StatusNotFound
+	case errors.KindConflict:
+		return stdhttp.StatusConflict
+	case errors.KindPrecondition:
+		return stdhttp.StatusPreconditionFailed
+	case errors.KindUnprocessable:
+		return stdhttp.StatusUnprocessableEntity
+	case errors.KindRateLimited:
+		return stdhttp.StatusTooManyRequests
+	}
+	return stdhttp.StatusInternalServerError
+}
+
+// ErrorHandler - центральный echo.HTTPErrorHandler: любая ошибка из хендлера превращается в problem+json.
+// Хендлерам достаточно вернуть типизированную ошибку из internal/errors, статус и код подберутся здесь
+func (h *Handler) ErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	problem := h.toProblem(err)
+	problem.Instance = c.Request().URL.Path
+
+	if problem.Status >= 500 {
+		h.logger.Error("ошибка обработки запроса", zap.Error(err), zap.String("path", problem.Instance))
+	}
+
+	var writeErr error
+	if c.Request().Method == stdhttp.MethodHead {
+		writeErr = c.NoContent(problem.Status)
+	} else {
+		// c.JSON не перетирает уже выставленный Content-Type
+		c.Response().Header().Set(echo.HeaderContentType, MIMEProblemJSON)
+		writeErr = c.JSON(problem.Status, problem)
+	}
+	if writeErr != nil {
+		h.logger.Warn("не удалось отдать ошибку клиенту", zap.Error(writeErr))
+	}
+}
+
+func (h *Handler) toProblem(err error) Problem {
+	var typed *errors.Error
+	// ошибку echo проверяем первой: HTTPError отдаёт Internal через Unwrap, и As по типизированной ошибке
+	// нашёл бы причину в обход явно выставленного статуса
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		if he.Internal != nil && errors.As(he.Internal, &typed) {
+			return newProblem(he.Code, typed.Code, typed.Field, detailFor(typed, he.Internal))
+		}
+		detail, _ := he.Message.(string)
+		if he.Code >= 500 {
+			detail = errors.ErrInternal.Message
+		}
+		return newProblem(he.Code, codeForStatus(he.Code), "", detail)
+	}
+
+	if errors.As(err, &typed) {
+		return newProblem(StatusFor(typed.Kind), typed.Code, typed.Field, detailFor(typed, err))
+	}
+
+	var validation validator.ValidationErrors
+	if errors.As(err, &validation) {
+		p := newProblem(stdhttp.StatusBadRequest, "validation_failed", "", "тело запроса не прошло валидацию")
+		for _, fe := range validation {
+			p.Errors = append(p.Errors, FieldProblem{Field: fe.Field(), Code: fe.Tag()})
+		}
+		if len(p.Errors) == 1 {
+			p.Field = p.Errors[0].Field
+		}
+		return p
+	}
+
+	return newProblem(stdhttp.StatusInternalServerError, errors.ErrInternal.Code, "", errors.ErrInternal.Message)
+}
+
+// detailFor не отдаёт наружу текст внутренних ошибок - там может быть что угодно из базы
+func detailFor(typed *errors.Error, err error) string {
+	if typed.Kind == errors.KindInternal {
+		return typed.Message
+	}
+	return err.Error()
+}
+
+func newProblem(status int, code, field, detail string) Problem {
+	return Problem{
+		Type:   problemTypePrefix + code,
+		Title:  stdhttp.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+		Field:  field,
+	}
+}
+
+// codeForStatus - код для ошибок самого echo (нет маршрута, не тот метод, кривое тело): snake_case от текста статуса
+func codeForStatus(status int) string {
+	text := stdhttp.StatusText(status)
+	if text == "" {
+		return "http_error"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
+
+// invalid - ошибка разбора запроса. Уже типизированные ошибки и ошибки echo проходят как есть,
+// всё остальное (uuid.Parse, strconv и т.п.) становится invalid_request с исходным текстом в detail
+func invalid(err error) error {
+	var typed *errors.Error
+	var he *echo.HTTPError
+	var validation validator.ValidationErrors
+	if errors.As(err, &typed) || errors.As(err, &he) || errors.As(err, &validation) {
+		return err
+	}
+	return errors.Wrap(errors.ErrInvalidRequest, err)
+}
@@ -0,0 +1,148 @@
+package http
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	stdhttp "net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"testovoe_again/internal/errors"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+func TestStatusFor(t *testing.T) {
+	cases := []struct {
+		kind errors.Kind
+		want int
+	}{
+		{errors.KindInvalid, 400},
+		{errors.KindUnauthorized, 401},
+		{errors.KindForbidden, 403},
+		{errors.KindNotFound, 404},
+		{errors.KindConflict, 409},
+		{errors.KindPrecondition, 412},
+		{errors.KindUnprocessable, 422},
+		{errors.KindRateLimited, 429},
+		{errors.KindInternal, 500},
+		{errors.Kind("unknown"), 500},
+	}
+	for _, c := range cases {
+		if got := StatusFor(c.kind); got != c.want {
+			t.Errorf("StatusFor(%s) = %d, ожидалось %d", c.kind, got, c.want)
+		}
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	type body struct {
+		Price int    `json:"price" validate:"gt=0"`
+		Name  string `json:"service_name" validate:"required"`
+	}
+	validation := NewValidator().Validate(body{})
+
+	cases := []struct {
+		name       string
+		err        error
+		method     string
+		status     int
+		code       string
+		field      string
+		detail     string
+		fieldCodes int
+	}{
+		{name: "сентинел", err: errors.ErrSubscriptionNotFound, status: 404, code: "subscription_not_found",
+			detail: errors.ErrSubscriptionNotFound.Message},
+		{name: "сентинел с полем и причиной", err: errors.Wrap(errors.ErrInvalidUUID.WithField("user_id"), fmt.Errorf("invalid UUID length: 3")),
+			status: 400, code: "invalid_uuid", field: "user_id", detail: "невалидный uuid: invalid UUID length: 3"},
+		{name: "обёрнутый через %w", err: fmt.Errorf("сервис: %w", errors.ErrVersionConflict), status: 412, code: "version_conflict",
+			detail: "сервис: " + errors.ErrVersionConflict.Message},
+		{name: "внутренняя ошибка не отдаёт причину", err: errors.Wrap(errors.ErrInternal, stderrors.New("pq: password authentication failed")),
+			status: 500, code: "internal", detail: errors.ErrInternal.Message},
+		{name: "валидация тела", err: validation, status: 400, code: "validation_failed", detail: "тело запроса не прошло валидацию", fieldCodes: 2},
+		{name: "ошибка echo", err: echo.ErrNotFound, status: 404, code: "not_found", detail: "Not Found"},
+		{name: "не тот метод", err: echo.ErrMethodNotAllowed, status: 405, code: "method_not_allowed", detail: "Method Not Allowed"},
+		{name: "ошибка echo с типизированной причиной", err: echo.NewHTTPError(413).SetInternal(errors.ErrInvalidImport),
+			status: 413, code: "invalid_import", detail: errors.ErrInvalidImport.Message},
+		{name: "500 от echo без текста", err: echo.NewHTTPError(500, "dial tcp 10.0.0.1:5432"), status: 500, code: "internal_server_error",
+			detail: errors.ErrInternal.Message},
+		{name: "нетипизированная ошибка", err: stderrors.New("sql: no rows"), status: 500, code: "internal", detail: errors.ErrInternal.Message},
+		{name: "HEAD без тела", err: errors.ErrForbidden, method: stdhttp.MethodHead, status: 403},
+	}
+
+	h := NewHandler(zap.NewNop(), nil, nil, nil, nil)
+	for _, c := range cases {
+		method := c.method
+		if method == "" {
+			method = stdhttp.MethodGet
+		}
+		req := httptest.NewRequest(method, "/api/v1/subscriptions/7", nil)
+		rec := httptest.NewRecorder()
+		h.ErrorHandler(c.err, echo.New().NewContext(req, rec))
+
+		if rec.Code != c.status {
+			t.Errorf("%s: статус %d, ожидался %d", c.name, rec.Code, c.status)
+		}
+		if method == stdhttp.MethodHead {
+			if rec.Body.Len() != 0 {
+				t.Errorf("%s: у HEAD есть тело %q", c.name, rec.Body.String())
+			}
+			continue
+		}
+		if got := rec.Header().Get(echo.HeaderContentType); got != MIMEProblemJSON {
+			t.Errorf("%s: Content-Type %q", c.name, got)
+		}
+		var p Problem
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Errorf("%s: тело %q: %v", c.name, rec.Body.String(), err)
+			continue
+		}
+		if p.Status != c.status || p.Code != c.code || p.Field != c.field || p.Detail != c.detail {
+			t.Errorf("%s: %d %q %q %q, ожидалось %d %q %q %q", c.name, p.Status, p.Code, p.Field, p.Detail, c.status, c.code, c.field, c.detail)
+		}
+		if p.Type != problemTypePrefix+c.code || p.Title != stdhttp.StatusText(c.status) || p.Instance != "/api/v1/subscriptions/7" {
+			t.Errorf("%s: type %q, title %q, instance %q", c.name, p.Type, p.Title, p.Instance)
+		}
+		if len(p.Errors) != c.fieldCodes {
+			t.Errorf("%s: ошибки полей %v, ожидалось %d", c.name, p.Errors, c.fieldCodes)
+		}
+	}
+}
+
+func TestErrorHandlerCommitted(t *testing.T) {
+	h := NewHandler(zap.NewNop(), nil, nil, nil, nil)
+	rec := httptest.NewRecorder()
+	ctx := echo.New().NewContext(httptest.NewRequest(stdhttp.MethodGet, "/api/v1/subscriptions/export", nil), rec)
+	_ = ctx.String(200, "id,service_name\n")
+
+	h.ErrorHandler(errors.ErrInternal, ctx)
+	if rec.Body.String() != "id,service_name\n" {
+		t.Errorf("ошибка дописана в уже отданный ответ: %q", rec.Body.String())
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	_, parseErr := strconv.Atoi("x")
+	cases := []struct {
+		name string
+		err  error
+		want *errors.Error
+	}{
+		{name: "разбор числа", err: parseErr, want: errors.ErrInvalidRequest},
+		{name: "типизированная проходит как есть", err: errors.ErrInvalidCursor, want: errors.ErrInvalidCursor},
+	}
+	for _, c := range cases {
+		if got := invalid(c.err); !errors.Is(got, c.want) {
+			t.Errorf("%s: %v, ожидалось %v", c.name, got, c.want)
+		}
+	}
+
+	he := echo.NewHTTPError(415)
+	var got *echo.HTTPError
+	if !errors.As(invalid(he), &got) || got.Code != 415 {
+		t.Errorf("ошибка echo переписана: %v", invalid(he))
+	}
+}
@@ -4,23 +4,93 @@ import (
 	"github.com/labstack/echo/v4"
 	echoSwagger "github.com/swaggo/echo-swagger"
 	_ "testovoe_again/docs"
+	"testovoe_again/internal/delivery/http/middleware"
 )
 
-func (h *Handler) Routing(e *echo.Echo) {
+// RouteMiddleware - middleware, которые роутинг навешивает на отдельные группы и маршруты.
+// Auth - на всё, кроме healthcheck и swagger. Limit отдаёт лимит запросов для группы маршрутов,
+// он и Idempotency идут после Auth: лимит считается по клиенту, ключи идемпотентности - в пределах клиента
+type RouteMiddleware struct {
+	Auth        echo.MiddlewareFunc
+	Limit       func(group string) echo.MiddlewareFunc
+	Idempotency echo.MiddlewareFunc
+}
+
+func (h *Handler) Routing(e *echo.Echo, mw RouteMiddleware) {
+	auth, limit := mw.Auth, mw.Limit
+
 	group := e.Group("/api/v1")
+	group.Use(middleware.Actor())
 
 	// роутинг эндпоинтов
-	subs := group.Group("/subscriptions")
+	subs := group.Group("/subscriptions", auth)
+	{
+		crud := limit("subscriptions")
+		subs.POST("", h.Create, crud, mw.Idempotency)
+		subs.GET("", h.ListSubscriptions, crud)
+		subs.GET("/trash", h.ListTrash, crud)
+		subs.GET("/conflicts", h.Conflicts, crud)
+		subs.POST("/import", h.Import, limit("import"))
+		subs.GET("/export", h.Export, limit("export"))
+		subs.POST("/:id/restore", h.Restore, crud)
+		subs.POST("/:id/pause", h.Pause, crud)
+		subs.POST("/:id/resume", h.Resume, crud)
+		subs.POST("/:id/cancel", h.Cancel, crud)
+		subs.POST("/:id/renew", h.Renew, crud)
+		subs.GET("/:id/history", h.History, crud)
+		subs.GET("/:id", h.GetByID, crud)
+		subs.GET("/list/:user_id", h.List, crud)
+		subs.PUT("/:id", h.Update, crud)
+		subs.PATCH("/:id", h.Patch, crud)
+		subs.DELETE("/:id", h.Delete, crud)
+	}
+
+	// каталог сервисов: читать может любой, менять - по политике доступа
+	services := group.Group("/services", auth, limit("catalog"))
+	{
+		services.GET("", h.ListServices)
+		services.POST("", h.CreateService)
+		services.GET("/:id", h.GetService)
+		services.PUT("/:id", h.UpdateService)
+		services.DELETE("/:id", h.DeleteService)
+	}
+
+	// справочник пользователей: листинг - для доступа ко всем пользователям, свою запись видит каждый,
+	// менять - по политике доступа
+	users := group.Group("/users", auth, limit("users"))
+	{
+		users.GET("", h.ListUsers)
+		users.POST("", h.CreateUser)
+		users.GET("/:id", h.GetUser)
+		users.PUT("/:id", h.UpdateUser)
+		users.DELETE("/:id", h.DeleteUser)
+	}
+
+	// вебхуки на события подписок и их dead-letter список, всё - по политике доступа
+	webhooks := group.Group("/webhooks", auth, limit("webhooks"))
 	{
-		subs.POST("", h.Create)
-		subs.GET("/:id", h.GetByID)
-		subs.GET("/list/:user_id", h.List)
-		subs.PUT("/:id", h.Update)
-		subs.DELETE("/:id", h.Delete)
+		webhooks.GET("", h.ListWebhooks)
+		webhooks.POST("", h.CreateWebhook)
+		webhooks.GET("/dead-letters", h.DeadLetters)
+		webhooks.POST("/deliveries/:id/replay", h.ReplayDelivery)
+		webhooks.GET("/:id", h.GetWebhook)
+		webhooks.PUT("/:id", h.UpdateWebhook)
+		webhooks.DELETE("/:id", h.DeleteWebhook)
 	}
 
-	// статистика из второго пункта
-	group.POST("/stats", h.GetSum)
+	// пакетные операции, двоеточие экранировано, чтобы echo не принял :batch за параметр
+	group.POST("/subscriptions\\:batch", h.Batch, auth, limit("batch"))
+
+	// статистика из второго пункта, тяжёлая для базы - у неё свой лимит
+	group.POST("/stats", h.GetSum, auth, limit("stats"))
+
+	// аналитика поверх той же месячной модели подсчёта
+	stats := group.Group("/stats", auth, limit("stats"))
+	{
+		stats.GET("/monthly", h.MonthlySpend)
+		stats.GET("/services", h.SpendByService)
+		stats.GET("/top", h.TopServices)
+	}
 
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
 
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"strconv"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// Pause godoc
+// @Summary      поставить подписку на паузу
+// @Description  active -> paused с сегодняшнего дня. Дни на паузе не входят в траты подписки
+// @Tags         subscriptions
+// @Produce      json
+// @Param        id        path    int     true   "ID подписки"
+// @Param        If-Match  header  string  false  "ETag версии, которую видел клиент"
+// @Success      200  {object}  CreateSubscriptionResponse
+// @Header       200  {string}  ETag  "новая версия подписки"
+// @Failure      400  {object}  Problem "невалидный id"
+// @Failure      401  {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403  {object}  Problem "операция запрещена роли"
+// @Failure      404  {object}  Problem "подписка не найдена"
+// @Failure      409  {object}  Problem "действие недоступно в текущем статусе"
+// @Failure      412  {object}  Problem "подписка изменилась с момента чтения"
+// @Failure      429  {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500  {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/subscriptions/{id}/pause [post]
+func (h *Handler) Pause(c echo.Context) error {
+	return h.changeStatus(c, domain.ActionPause)
+}
+
+// Resume godoc
+// @Summary      снять подписку с паузы
+// @Description  paused -> active с сегодняшнего дня
+// @Tags         subscriptions
+// @Produce      json
+// @Param        id        path    int     true   "ID подписки"
+// @Param        If-Match  header  string  false  "ETag версии, которую видел клиент"
+// @Success      200  {object}  CreateSubscriptionResponse
+// @Header       200  {string}  ETag  "новая версия подписки"
+// @Failure      400  {object}  Problem "невалидный id"
+// @Failure      401  {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403  {object}  Problem "операция запрещена роли"
+// @Failure      404  {object}  Problem "подписка не найдена"
+// @Failure      409  {object}  Problem "действие недоступно в текущем статусе"
+// @Failure      412  {object}  Problem "подписка изменилась с момента чтения"
+// @Failure      429  {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500  {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/subscriptions/{id}/resume [post]
+func (h *Handler) Resume(c echo.Context) error {
+	return h.changeStatus(c, domain.ActionResume)
+}
+
+// Cancel godoc
+// @Summary      отменить подписку
+// @Description  trial, active или paused -> cancelled. Текущий расчётный период остаётся оплаченным: end_date становится сегодняшним днём, если была позже или не была задана, и auto_renew выключается. Ещё не начавшуюся подписку отменить нельзя (409) - её удаляют
+// @Tags         subscriptions
+// @Produce      json
+// @Param        id        path    int     true   "ID подписки"
+// @Param        If-Match  header  string  false  "ETag версии, которую видел клиент"
+// @Success      200  {object}  CreateSubscriptionResponse
+// @Header       200  {string}  ETag  "новая версия подписки"
+// @Failure      400  {object}  Problem "невалидный id"
+// @Failure      401  {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403  {object}  Problem "операция запрещена роли"
+// @Failure      404  {object}  Problem "подписка не найдена"
+// @Failure      409  {object}  Problem "действие недоступно в текущем статусе или подписка ещё не началась"
+// @Failure      412  {object}  Problem "подписка изменилась с момента чтения"
+// @Failure      429  {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500  {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/subscriptions/{id}/cancel [post]
+func (h *Handler) Cancel(c echo.Context) error {
+	return h.changeStatus(c, domain.ActionCancel)
+}
+
+// Renew godoc
+// @Summary      продлить подписку
+// @Description  trial, active или cancelled -> active, end_date сдвигается на один расчётный период. trial без end_date просто становится платной, закончившуюся (expired) подписку не продлить
+// @Tags         subscriptions
+// @Produce      json
+// @Param        id        path    int     true   "ID подписки"
+// @Param        If-Match  header  string  false  "ETag версии, которую видел клиент"
+// @Success      200  {object}  CreateSubscriptionResponse
+// @Header       200  {string}  ETag  "новая версия подписки"
+// @Failure      400  {object}  Problem "невалидный id"
+// @Failure      401  {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403  {object}  Problem "операция запрещена роли"
+// @Failure      404  {object}  Problem "подписка не найдена"
+// @Failure      409  {object}  Problem "действие недоступно в текущем статусе или продление пересекается с другой подпиской сервиса"
+// @Failure      412  {object}  Problem "подписка изменилась с момента чтения"
+// @Failure      429  {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500  {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/subscriptions/{id}/renew [post]
+func (h *Handler) Renew(c echo.Context) error {
+	return h.changeStatus(c, domain.ActionRenew)
+}
+
+func (h *Handler) changeStatus(c echo.Context, action string) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Warn("невалидный id", zap.String("id", c.Param("id")))
+		return errors.ErrInvalidID
+	}
+
+	version, err := ParseIfMatch(c.Request().Header.Get(HeaderIfMatch))
+	if err != nil {
+		return err
+	}
+
+	if err := h.authorizeSubscription(c, id); err != nil {
+		return err
+	}
+
+	sub, err := h.service.ChangeStatus(c.Request().Context(), id, action, version)
+	if err != nil {
+		return err
+	}
+
+	c.Response().Header().Set(HeaderETag, ETag(sub.Version))
+	return c.JSON(200, ToResponse(sub))
+}
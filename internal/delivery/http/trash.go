@@ -0,0 +1,97 @@
+package http
+
+import (
+	"strconv"
+	"testovoe_again/internal/errors"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// ListTrash godoc
+// @Summary      корзина подписок
+// @Description  возвращает страницу мягко удалённых подписок; фильтры, сортировка и пагинация те же, что у листинга
+// @Tags         subscriptions
+// @Produce      json
+// @Param        user_id       query     string  false  "UUID пользователя"
+// @Param        service_name  query     string  false  "название сервиса"
+// @Param        currency      query     string  false  "валюта подписки (RUB, USD, EUR)"
+// @Param        sort          query     string  false  "id | service_name | price | start_date, префикс - для убывания"
+// @Param        limit         query     int     false  "размер страницы (по умолчанию 50, максимум 500)"
+// @Param        cursor        query     string  false  "next_cursor из предыдущего ответа"
+// @Success      200           {object}  ListSubscriptionsResponse
+// @Failure      400           {object}  Problem "невалидные параметры запроса"
+// @Failure      401           {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403           {object}  Problem "подписки другого пользователя"
+// @Failure      429           {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500           {object}  Problem "не удалось получить корзину"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/subscriptions/trash [get]
+func (h *Handler) ListTrash(c echo.Context) error {
+	var request ListSubscriptionsRequest
+	if err := c.Bind(&request); err != nil {
+		h.logger.Warn("не удалось обработать параметры корзины", zap.Error(err))
+		return err
+	}
+
+	filter, err := h.ToListFilter(request)
+	if err != nil {
+		return err
+	}
+	filter.Deleted = true
+	if err := scopeUserFilter(c, &filter.UserID); err != nil {
+		return err
+	}
+
+	page, err := h.service.List(c.Request().Context(), filter)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(200, ToListResponse(page))
+}
+
+// Restore godoc
+// @Summary      восстановить подписку из корзины
+// @Description  возвращает мягко удалённую подписку обратно, пока корзина не очищена
+// @Tags         subscriptions
+// @Produce      json
+// @Param        id   path      int  true  "ID подписки"
+// @Success      200  {object}  CreateSubscriptionResponse
+// @Header       200  {string}  ETag  "новая версия подписки"
+// @Failure      400  {object}  Problem "невалидный id"
+// @Failure      401  {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403  {object}  Problem "операция запрещена роли"
+// @Failure      404  {object}  Problem "подписки нет в корзине"
+// @Failure      409  {object}  Problem "период подписки уже занят другой подпиской сервиса"
+// @Failure      429  {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500  {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/subscriptions/{id}/restore [post]
+func (h *Handler) Restore(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Warn("невалидный id", zap.String("id", c.Param("id")))
+		return errors.ErrInvalidID
+	}
+
+	// подписка уже в корзине и обычным чтением её не достать, владельца смотрим по журналу
+	entries, err := h.service.History(c.Request().Context(), id)
+	if err != nil && !errors.Is(err, errors.ErrSubscriptionNotFound) {
+		return err
+	}
+	if err := authorizeHistory(c, entries); err != nil {
+		return err
+	}
+
+	sub, err := h.service.Restore(c.Request().Context(), id)
+	if err != nil {
+		h.logger.Warn("ошибка восстановления подписки", zap.Error(err))
+		return err
+	}
+
+	c.Response().Header().Set(HeaderETag, ETag(sub.Version))
+	return c.JSON(200, ToResponse(sub))
+}
@@ -0,0 +1,202 @@
+package http
+
+import (
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// ListUsers godoc
+// @Summary      справочник пользователей
+// @Description  страница пользователей по возрастанию id, следующая страница запрашивается по next_cursor. Доступно только принципалам с доступом ко всем пользователям
+// @Tags         users
+// @Produce      json
+// @Param        limit   query     int     false  "размер страницы (по умолчанию 50, максимум 500)"
+// @Param        cursor  query     string  false  "next_cursor из предыдущего ответа"
+// @Success      200     {object}  ListUsersResponse
+// @Failure      400     {object}  Problem "невалидные параметры запроса"
+// @Failure      401     {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403     {object}  Problem "нет доступа ко всем пользователям"
+// @Failure      429     {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500     {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/users [get]
+func (h *Handler) ListUsers(c echo.Context) error {
+	if err := requireAnyUser(c); err != nil {
+		return err
+	}
+
+	var request ListUsersRequest
+	if err := c.Bind(&request); err != nil {
+		h.logger.Warn("не удалось обработать параметры листинга", zap.Error(err))
+		return err
+	}
+	if err := c.Validate(request); err != nil {
+		return err
+	}
+
+	var after *uuid.UUID
+	if request.Cursor != "" {
+		cursor, err := uuid.Parse(request.Cursor)
+		if err != nil {
+			return errors.Wrap(errors.ErrInvalidCursor, err)
+		}
+		after = &cursor
+	}
+
+	page, err := h.users.List(c.Request().Context(), after, request.Limit)
+	if err != nil {
+		return err
+	}
+	return c.JSON(200, ListUsersResponse{Items: page.Items, NextCursor: page.NextCursor})
+}
+
+// GetUser godoc
+// @Summary      пользователь справочника
+// @Tags         users
+// @Produce      json
+// @Param        id   path      string  true  "UUID пользователя"
+// @Success      200  {object}  domain.User
+// @Failure      400  {object}  Problem "невалидный uuid"
+// @Failure      401  {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403  {object}  Problem "другой пользователь"
+// @Failure      404  {object}  Problem "пользователь не найден"
+// @Failure      429  {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500  {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/users/{id} [get]
+func (h *Handler) GetUser(c echo.Context) error {
+	id, err := h.userID(c)
+	if err != nil {
+		return err
+	}
+	if err := authorizeUser(c, id); err != nil {
+		return err
+	}
+
+	user, err := h.users.Get(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(200, user)
+}
+
+// CreateUser godoc
+// @Summary      добавить пользователя
+// @Description  заводит пользователя в справочник, только после этого на него можно создавать подписки. id задаёт клиент - это тот же UUID, что в токене и user_id подписок
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        input body      CreateUserRequest  true  "пользователь"
+// @Success      201   {object}  domain.User
+// @Failure      400   {object}  Problem "невалидный пользователь"
+// @Failure      401   {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403   {object}  Problem "операция запрещена роли"
+// @Failure      409   {object}  Problem "пользователь с таким id или email уже есть"
+// @Failure      429   {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500   {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/users [post]
+func (h *Handler) CreateUser(c echo.Context) error {
+	var request CreateUserRequest
+	if err := c.Bind(&request); err != nil {
+		h.logger.Warn("не удалось обработать запрос", zap.Error(err))
+		return err
+	}
+	if err := c.Validate(request); err != nil {
+		return err
+	}
+
+	id, err := uuid.Parse(request.ID)
+	if err != nil {
+		return errors.Wrap(errors.ErrInvalidUUID.WithField("id"), err)
+	}
+	user, err := h.users.Create(c.Request().Context(), domain.User{ID: id, Email: request.Email, Name: request.Name})
+	if err != nil {
+		return err
+	}
+	return c.JSON(201, user)
+}
+
+// UpdateUser godoc
+// @Summary      обновить пользователя
+// @Description  перезаписывает email и имя пользователя, email без значения стирается
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param        id    path      string             true  "UUID пользователя"
+// @Param        input body      UpdateUserRequest  true  "пользователь"
+// @Success      200   {object}  domain.User
+// @Failure      400   {object}  Problem "невалидный uuid или пользователь"
+// @Failure      401   {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403   {object}  Problem "операция запрещена роли"
+// @Failure      404   {object}  Problem "пользователь не найден"
+// @Failure      409   {object}  Problem "email занят другим пользователем"
+// @Failure      429   {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500   {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/users/{id} [put]
+func (h *Handler) UpdateUser(c echo.Context) error {
+	id, err := h.userID(c)
+	if err != nil {
+		return err
+	}
+
+	var request UpdateUserRequest
+	if err := c.Bind(&request); err != nil {
+		h.logger.Warn("не удалось обработать запрос", zap.Error(err))
+		return err
+	}
+	if err := c.Validate(request); err != nil {
+		return err
+	}
+
+	user, err := h.users.Update(c.Request().Context(), domain.User{ID: id, Email: request.Email, Name: request.Name})
+	if err != nil {
+		return err
+	}
+	return c.JSON(200, user)
+}
+
+// DeleteUser godoc
+// @Summary      удалить пользователя
+// @Description  удаляет пользователя без подписок. Подписки в корзине тоже считаются: сначала их нужно очистить
+// @Tags         users
+// @Param        id   path      string  true  "UUID пользователя"
+// @Success      204  "No Content"
+// @Failure      400  {object}  Problem "невалидный uuid"
+// @Failure      401  {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403  {object}  Problem "операция запрещена роли"
+// @Failure      404  {object}  Problem "пользователь не найден"
+// @Failure      409  {object}  Problem "у пользователя есть подписки"
+// @Failure      429  {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500  {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/users/{id} [delete]
+func (h *Handler) DeleteUser(c echo.Context) error {
+	id, err := h.userID(c)
+	if err != nil {
+		return err
+	}
+	if err := h.users.Delete(c.Request().Context(), id); err != nil {
+		return err
+	}
+	return c.NoContent(204)
+}
+
+func (h *Handler) userID(c echo.Context) (uuid.UUID, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.logger.Warn("невалидный uuid", zap.String("id", c.Param("id")))
+		return uuid.Nil, errors.Wrap(errors.ErrInvalidUUID.WithField("id"), err)
+	}
+	return id, nil
+}
@@ -1,13 +1,28 @@
 package http
 
-import "github.com/go-playground/validator/v10"
+import (
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
 
 type Validator struct {
 	Validater *validator.Validate
 }
 
-func (v *Validator) NewValidator() *Validator {
-	return &Validator{Validater: validator.New()}
+// NewValidator - валидатор, который в ошибках называет поля так же, как они называются в JSON,
+// а не именами полей Go-структуры: клиенту "service_name" понятнее, чем "ServiceName"
+func NewValidator() *Validator {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			return f.Name
+		}
+		return name
+	})
+	return &Validator{Validater: v}
 }
 
 func (v *Validator) Validate(i interface{}) error {
@@ -0,0 +1,225 @@
+package http
+
+import (
+	"strconv"
+	"testovoe_again/internal/errors"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// ListWebhooks godoc
+// @Summary      вебхуки
+// @Description  все вебхуки на события подписок, без секретов
+// @Tags         webhooks
+// @Produce      json
+// @Success      200  {object}  WebhooksResponse
+// @Failure      401  {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403  {object}  Problem "операция запрещена роли"
+// @Failure      429  {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500  {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/webhooks [get]
+func (h *Handler) ListWebhooks(c echo.Context) error {
+	hooks, err := h.webhooks.List(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	return c.JSON(200, WebhooksResponse{Items: hooks})
+}
+
+// GetWebhook godoc
+// @Summary      вебхук
+// @Tags         webhooks
+// @Produce      json
+// @Param        id   path      int  true  "ID вебхука"
+// @Success      200  {object}  domain.Webhook
+// @Failure      400  {object}  Problem "невалидный id"
+// @Failure      401  {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403  {object}  Problem "операция запрещена роли"
+// @Failure      404  {object}  Problem "вебхук не найден"
+// @Failure      429  {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500  {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/webhooks/{id} [get]
+func (h *Handler) GetWebhook(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Warn("невалидный id", zap.String("id", c.Param("id")))
+		return errors.ErrInvalidID
+	}
+	hook, err := h.webhooks.Get(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(200, hook)
+}
+
+// CreateWebhook godoc
+// @Summary      добавить вебхук
+// @Description  после создания, обновления и удаления подписки на url POST-ом уходит событие {id, type, occurred_at, actor, subscription}. Тело подписано: X-Webhook-Signature = "sha256=" + hex(HMAC-SHA256(secret, X-Webhook-Timestamp + "." + тело)). id события в X-Webhook-Id не меняется между повторами. Ответ не 2xx или таймаут - повтор с экспоненциальной задержкой, после последней попытки доставка уходит в dead-letter. Секрет возвращается только в ответе на создание
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        input body      WebhookRequest  true  "вебхук"
+// @Success      201   {object}  domain.Webhook
+// @Failure      400   {object}  Problem "невалидный вебхук"
+// @Failure      401   {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403   {object}  Problem "операция запрещена роли"
+// @Failure      429   {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500   {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/webhooks [post]
+func (h *Handler) CreateWebhook(c echo.Context) error {
+	var request WebhookRequest
+	if err := c.Bind(&request); err != nil {
+		h.logger.Warn("не удалось обработать запрос", zap.Error(err))
+		return err
+	}
+	if err := c.Validate(request); err != nil {
+		return err
+	}
+
+	hook, err := h.webhooks.Create(c.Request().Context(), request.ToDomain())
+	if err != nil {
+		return err
+	}
+	return c.JSON(201, hook)
+}
+
+// UpdateWebhook godoc
+// @Summary      обновить вебхук
+// @Description  перезаписывает адрес, события и активность вебхука, без secret секрет остаётся прежним. Доставки выключенного вебхука ждут в очереди, пока его не включат
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        id    path      int             true  "ID вебхука"
+// @Param        input body      WebhookRequest  true  "вебхук"
+// @Success      200   {object}  domain.Webhook
+// @Failure      400   {object}  Problem "невалидный id или вебхук"
+// @Failure      401   {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403   {object}  Problem "операция запрещена роли"
+// @Failure      404   {object}  Problem "вебхук не найден"
+// @Failure      429   {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500   {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/webhooks/{id} [put]
+func (h *Handler) UpdateWebhook(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Warn("невалидный id", zap.String("id", c.Param("id")))
+		return errors.ErrInvalidID
+	}
+
+	var request WebhookRequest
+	if err := c.Bind(&request); err != nil {
+		h.logger.Warn("не удалось обработать запрос", zap.Error(err))
+		return err
+	}
+	if err := c.Validate(request); err != nil {
+		return err
+	}
+
+	hook := request.ToDomain()
+	hook.ID = id
+	hook, err = h.webhooks.Update(c.Request().Context(), hook)
+	if err != nil {
+		return err
+	}
+	return c.JSON(200, hook)
+}
+
+// DeleteWebhook godoc
+// @Summary      удалить вебхук
+// @Description  удаляет вебхук вместе с очередью и dead-letter списком его доставок
+// @Tags         webhooks
+// @Param        id   path      int  true  "ID вебхука"
+// @Success      204  "No Content"
+// @Failure      400  {object}  Problem "невалидный id"
+// @Failure      401  {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403  {object}  Problem "операция запрещена роли"
+// @Failure      404  {object}  Problem "вебхук не найден"
+// @Failure      429  {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500  {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/webhooks/{id} [delete]
+func (h *Handler) DeleteWebhook(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Warn("невалидный id", zap.String("id", c.Param("id")))
+		return errors.ErrInvalidID
+	}
+	if err := h.webhooks.Delete(c.Request().Context(), id); err != nil {
+		return err
+	}
+	return c.NoContent(204)
+}
+
+// DeadLetters godoc
+// @Summary      dead-letter список
+// @Description  доставки, исчерпавшие попытки, от новых к старым. Следующая страница запрашивается по next_cursor
+// @Tags         webhooks
+// @Produce      json
+// @Param        webhook_id  query     int  false  "только доставки этого вебхука"
+// @Param        limit       query     int  false  "размер страницы (по умолчанию 50, максимум 500)"
+// @Param        cursor      query     int  false  "next_cursor из предыдущего ответа"
+// @Success      200         {object}  DeadLettersResponse
+// @Failure      400         {object}  Problem "невалидные параметры запроса"
+// @Failure      401         {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403         {object}  Problem "операция запрещена роли"
+// @Failure      429         {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500         {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/webhooks/dead-letters [get]
+func (h *Handler) DeadLetters(c echo.Context) error {
+	var request DeadLettersRequest
+	if err := c.Bind(&request); err != nil {
+		h.logger.Warn("не удалось обработать параметры листинга", zap.Error(err))
+		return err
+	}
+	if err := c.Validate(request); err != nil {
+		return err
+	}
+
+	page, err := h.webhooks.DeadLetters(c.Request().Context(), request.WebhookID, request.Cursor, request.Limit)
+	if err != nil {
+		return err
+	}
+	return c.JSON(200, DeadLettersResponse{Items: page.Items, NextCursor: page.NextCursor})
+}
+
+// ReplayDelivery godoc
+// @Summary      повторить доставку
+// @Description  возвращает доставку из dead-letter списка в очередь с обнулённым счётчиком попыток, воркер отправит её на ближайшем проходе с тем же id события
+// @Tags         webhooks
+// @Produce      json
+// @Param        id   path      int  true  "ID доставки"
+// @Success      202  {object}  domain.WebhookDelivery
+// @Failure      400  {object}  Problem "невалидный id"
+// @Failure      401  {object}  Problem "нет или невалидные учётные данные"
+// @Failure      403  {object}  Problem "операция запрещена роли"
+// @Failure      404  {object}  Problem "доставка не найдена"
+// @Failure      409  {object}  Problem "доставка не в dead-letter списке"
+// @Failure      429  {object}  Problem "превышен лимит запросов, см. Retry-After"
+// @Failure      500  {object}  Problem "ошибка сервера"
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Router       /api/v1/webhooks/deliveries/{id}/replay [post]
+func (h *Handler) ReplayDelivery(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.logger.Warn("невалидный id", zap.String("id", c.Param("id")))
+		return errors.ErrInvalidID
+	}
+	delivery, err := h.webhooks.Replay(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(202, delivery)
+}
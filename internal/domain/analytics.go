@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AnalyticsFilter - общий фильтр для аналитических выборок.
+// Окно отчёта - полуинтервал [From, To), включительные даты из запроса переводятся в него через ParseDateRange
+type AnalyticsFilter struct {
+	UserID      *uuid.UUID
+	ServiceName *string
+	From        time.Time
+	To          time.Time
+
+	// Limit используется только в топе сервисов
+	Limit int
+
+	// Currency - валюта, в которую пересчитываются суммы отчёта
+	Currency string
+}
+
+// SpendRow - строка аналитического отчёта.
+// Period - месяц в формате MM-YYYY либо всё окно отчёта (см. FormatPeriod),
+// ServiceName пустой, если строка агрегирует все сервисы.
+// Total в минорных единицах валюты Currency
+type SpendRow struct {
+	Period      string `json:"period" example:"07-2025"`
+	ServiceName string `json:"service_name" example:"Yandex Plus"`
+	Currency    string `json:"currency" example:"RUB"`
+	Total       int64  `json:"total" example:"120000"`
+}
+
+// FormatPeriod - подпись окна [from, to) для строк отчёта: MM-YYYY/MM-YYYY для окон по целым месяцам,
+// иначе полные даты с включительной правой границей
+func FormatPeriod(from, to time.Time) string {
+	if from.Day() == 1 && to.Day() == 1 {
+		return from.Format(MonthLayout) + "/" + to.AddDate(0, -1, 0).Format(MonthLayout)
+	}
+	return from.Format(DayLayout) + "/" + to.AddDate(0, 0, -1).Format(DayLayout)
+}
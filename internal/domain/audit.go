@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Операции, которые пишутся в журнал изменений подписок
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditPatch   = "patch"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+)
+
+// AuditEntry - одна запись журнала subscription_audit.
+// Before и After - снимки подписки в JSON на момент изменения: у create нет Before, у purge нет After.
+// Снимки храним как есть, а не как Subscription, чтобы старые записи читались и после изменения структуры
+type AuditEntry struct {
+	ID             int64           `json:"id"`
+	SubscriptionID int             `json:"subscription_id"`
+	Actor          string          `json:"actor"`
+	Operation      string          `json:"operation"`
+	ChangedAt      time.Time       `json:"changed_at"`
+	Before         json.RawMessage `json:"before,omitempty"`
+	After          json.RawMessage `json:"after,omitempty"`
+}
+
+// AnonymousActor пишется в журнал, если автор изменения неизвестен
+const AnonymousActor = "anonymous"
+
+// SystemActor - автор изменений, сделанных фоновыми задачами
+const SystemActor = "system"
+
+type actorKey struct{}
+
+// WithActor кладёт в контекст того, кто делает изменение - его увидит журнал изменений
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext достаёт автора изменения, AnonymousActor если его не положили
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"context"
+	"testing"
+)
+
+func TestActorFromContext(t *testing.T) {
+	cases := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"без автора", context.Background(), AnonymousActor},
+		{"пустой автор", WithActor(context.Background(), ""), AnonymousActor},
+		{"автор из запроса", WithActor(context.Background(), "support@example.com"), "support@example.com"},
+		{"фоновая задача", WithActor(context.Background(), SystemActor), SystemActor},
+		{"последний перезаписывает", WithActor(WithActor(context.Background(), "header"), "jwt-subject"), "jwt-subject"},
+	}
+	for _, c := range cases {
+		if got := ActorFromContext(c.ctx); got != c.want {
+			t.Errorf("%s: %q, ожидалось %q", c.name, got, c.want)
+		}
+	}
+}
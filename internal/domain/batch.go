@@ -0,0 +1,35 @@
+package domain
+
+// Операции пакетного эндпоинта
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// Режимы пакета: atomic - всё или ничего, best_effort - каждая операция сама по себе
+const (
+	BatchAtomic     = "atomic"
+	BatchBestEffort = "best_effort"
+)
+
+// BatchOp - одна операция пакета. Для create и update подписка лежит в Subscription,
+// для update и delete нужен ID, Version - ожидаемая версия (0 - любая).
+// Err заполняется, если операция не прошла разбор или валидацию, такая операция не выполняется
+type BatchOp struct {
+	Op           string
+	ID           int
+	Version      int
+	Subscription Subscription
+	Err          error
+}
+
+// BatchResult - итог одной операции, Index - её позиция в пакете.
+// ID и Version - id подписки и её версия после операции, Err - почему операция не выполнена
+type BatchResult struct {
+	Index   int
+	Op      string
+	ID      int
+	Version int
+	Err     error
+}
@@ -0,0 +1,20 @@
+package domain
+
+// Периоды списания подписки. Для custom длина периода в днях лежит в BillingPeriodDays
+const (
+	BillingWeekly    = "weekly"
+	BillingMonthly   = "monthly"
+	BillingQuarterly = "quarterly"
+	BillingYearly    = "yearly"
+	BillingCustom    = "custom"
+
+	DefaultBillingPeriod = BillingMonthly
+)
+
+func IsBillingPeriod(period string) bool {
+	switch period {
+	case BillingWeekly, BillingMonthly, BillingQuarterly, BillingYearly, BillingCustom:
+		return true
+	}
+	return false
+}
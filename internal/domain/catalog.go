@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// CatalogEntry - сервис из каталога. Подписки на любое из написаний (Name или алиас из Aliases)
+// сохраняются под каноническим Name и ссылаются на сервис по ID.
+// Цены в минорных единицах Currency, MinPrice и MaxPrice - необязательные границы цены подписки
+type CatalogEntry struct {
+	ID           int       `json:"id" example:"1"`
+	Name         string    `json:"name" example:"Yandex Plus"`
+	Aliases      []string  `json:"aliases" example:"yandex plus,Яндекс Плюс"`
+	DefaultPrice int64     `json:"default_price" example:"39900"`
+	Currency     string    `json:"currency" example:"RUB"`
+	MinPrice     *int64    `json:"min_price,omitempty" example:"19900"`
+	MaxPrice     *int64    `json:"max_price,omitempty" example:"99900"`
+	CreatedAt    time.Time `json:"created_at" example:"2025-08-01T12:00:00Z"`
+	UpdatedAt    time.Time `json:"updated_at" example:"2025-08-01T12:00:00Z"`
+}
+
+// ServiceKey - написание названия сервиса, по которому ищется совпадение в каталоге:
+// без учёта регистра, крайних и повторных пробелов. "Yandex  Plus " и "yandex plus" - одно и то же
+func ServiceKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// Names - все написания сервиса, каноническое первым
+func (e CatalogEntry) Names() []string {
+	return append([]string{e.Name}, e.Aliases...)
+}
+
+// PriceInRange - укладывается ли цена в минорных единицах Currency в границы сервиса
+func (e CatalogEntry) PriceInRange(price int64) bool {
+	if e.MinPrice != nil && price < *e.MinPrice {
+		return false
+	}
+	if e.MaxPrice != nil && price > *e.MaxPrice {
+		return false
+	}
+	return true
+}
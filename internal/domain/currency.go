@@ -0,0 +1,37 @@
+package domain
+
+import "math"
+
+// Валюты, в которых мы выставляем подписки. Цены везде хранятся в минорных единицах (копейки, центы),
+// у всех трёх валют две цифры после запятой, поэтому конвертация идёт из минорных в минорные без доп. множителей
+const (
+	CurrencyRUB = "RUB"
+	CurrencyUSD = "USD"
+	CurrencyEUR = "EUR"
+
+	DefaultCurrency = CurrencyRUB
+)
+
+func IsSupportedCurrency(currency string) bool {
+	switch currency {
+	case CurrencyRUB, CurrencyUSD, CurrencyEUR:
+		return true
+	}
+	return false
+}
+
+// MinorPerUnit - минорных единиц в одной целой у каждой из поддерживаемых валют
+const MinorPerUnit = 100
+
+// WholeUnits - сумма minor в целых единицах валюты, дробная часть отбрасывается
+func WholeUnits(minor int64) int64 {
+	return minor / MinorPerUnit
+}
+
+// MinorUnits - сумма whole целых единиц в минорных, ok == false при переполнении int64
+func MinorUnits(whole int64) (minor int64, ok bool) {
+	if whole > math.MaxInt64/MinorPerUnit || whole < math.MinInt64/MinorPerUnit {
+		return 0, false
+	}
+	return whole * MinorPerUnit, true
+}
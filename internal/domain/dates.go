@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"time"
+)
+
+// Форматы дат в API. Исторически всё было в MM-YYYY (по примеру из ТЗ), полная дата нужна
+// для недельных и кастомных периодов, которые начинаются не с первого числа
+const (
+	MonthLayout = "01-2006"
+	DayLayout   = "2006-01-02"
+)
+
+// ParseDate принимает дату в любом из двух форматов, MM-YYYY превращается в первое число месяца
+func ParseDate(date string) (time.Time, error) {
+	if t, err := time.Parse(MonthLayout, date); err == nil {
+		return t, nil
+	}
+	return time.Parse(DayLayout, date)
+}
+
+// ParseDateRange - та же дата, но как полуинтервал [start, end): для MM-YYYY это весь месяц,
+// для полной даты - один день. Нужен там, где дата - включительная граница периода отчёта
+func ParseDateRange(date string) (time.Time, time.Time, error) {
+	if t, err := time.Parse(MonthLayout, date); err == nil {
+		return t, t.AddDate(0, 1, 0), nil
+	}
+	t, err := time.Parse(DayLayout, date)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return t, t.AddDate(0, 0, 1), nil
+}
+
+// FormatDate - обратная операция: первое число месяца отдаём как MM-YYYY, чтобы не ломать клиентов,
+// которые работают с месяцами, остальные даты - полной датой
+func FormatDate(t time.Time) string {
+	if t.Day() == 1 {
+		return t.Format(MonthLayout)
+	}
+	return t.Format(DayLayout)
+}
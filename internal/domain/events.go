@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// События подписок: их пишет outbox, на них подписываются вебхуки
+const (
+	EventSubscriptionCreated = "subscription.created"
+	EventSubscriptionUpdated = "subscription.updated"
+	EventSubscriptionDeleted = "subscription.deleted"
+	// EventSubscriptionPurged - подписка удалена из корзины насовсем
+	EventSubscriptionPurged = "subscription.purged"
+)
+
+func IsEvent(event string) bool {
+	switch event {
+	case EventSubscriptionCreated, EventSubscriptionUpdated, EventSubscriptionDeleted, EventSubscriptionPurged:
+		return true
+	}
+	return false
+}
+
+// EventForOperation - событие по операции журнала изменений. Всё, кроме создания и удаления,
+// включая восстановление из корзины и смену статуса, - это изменение подписки
+func EventForOperation(operation string) string {
+	switch operation {
+	case AuditCreate:
+		return EventSubscriptionCreated
+	case AuditDelete:
+		return EventSubscriptionDeleted
+	case AuditPurge:
+		return EventSubscriptionPurged
+	}
+	return EventSubscriptionUpdated
+}
+
+// Event - тело события, его же получает вебхук. ID не меняется между повторными публикациями,
+// по нему получатель отбрасывает дубликаты. Subscription - подписка после изменения, у purged - до него
+type Event struct {
+	ID           uuid.UUID     `json:"id"`
+	Type         string        `json:"type"`
+	OccurredAt   time.Time     `json:"occurred_at"`
+	Actor        string        `json:"actor"`
+	Subscription *Subscription `json:"subscription"`
+}
+
+// OutboxEvent - строка outbox, которую релей отдаёт публикатору. Payload - Event в JSON
+type OutboxEvent struct {
+	ID             int64           `json:"-"`
+	EventID        uuid.UUID       `json:"event_id"`
+	Type           string          `json:"type"`
+	SubscriptionID int             `json:"subscription_id"`
+	Payload        json.RawMessage `json:"payload"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
@@ -0,0 +1,16 @@
+package domain
+
+import "time"
+
+// IdempotencyRecord - запрос с заголовком Idempotency-Key и ответ на него.
+// Ключ уникален в пределах Owner (актора запроса), чтобы клиенты не могли пересечься ключами.
+// Status == 0 - запрос ещё выполняется, ответа пока нет
+type IdempotencyRecord struct {
+	Owner       string
+	Key         string
+	RequestHash string
+	Status      int
+	Headers     map[string]string
+	Body        []byte
+	ExpiresAt   time.Time
+}
@@ -0,0 +1,42 @@
+package domain
+
+import "testovoe_again/internal/errors"
+
+// ImportRowError - почему строка файла не импортирована, Row - номер строки с учётом заголовка (первая строка данных - 2)
+type ImportRowError struct {
+	Row   int    `json:"row" example:"3"`
+	Code  string `json:"code" example:"invalid_price"`
+	Field string `json:"field,omitempty" example:"price"`
+	Error string `json:"error" example:"указана невалидная цена"`
+}
+
+// ImportReport - итог импорта. В dry_run режиме Created всегда 0, а Valid показывает, сколько строк было бы создано.
+// Ошибок в отчёте не больше MaxImportErrors, остальные только считаются в Invalid
+type ImportReport struct {
+	DryRun    bool             `json:"dry_run" example:"false"`
+	Total     int              `json:"total" example:"120"`
+	Valid     int              `json:"valid" example:"118"`
+	Invalid   int              `json:"invalid" example:"2"`
+	Created   int              `json:"created" example:"118"`
+	Errors    []ImportRowError `json:"errors"`
+	Truncated bool             `json:"errors_truncated,omitempty" example:"false"`
+}
+
+// MaxImportErrors - сколько ошибок по строкам попадает в отчёт
+const MaxImportErrors = 1000
+
+// AddError записывает строку в упавшие, код и поле берутся из типизированной ошибки
+func (r *ImportReport) AddError(row int, err error) {
+	r.Invalid++
+	if len(r.Errors) >= MaxImportErrors {
+		r.Truncated = true
+		return
+	}
+
+	rowErr := ImportRowError{Row: row, Code: errors.ErrInternal.Code, Error: errors.ErrInternal.Message}
+	var typed *errors.Error
+	if errors.As(err, &typed) && typed.Kind != errors.KindInternal {
+		rowErr.Code, rowErr.Field, rowErr.Error = typed.Code, typed.Field, err.Error()
+	}
+	r.Errors = append(r.Errors, rowErr)
+}
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Ключи сортировки, которые можно передавать в листинг.
+// end_date сюда осознанно не попал - он nullable, а keyset-пагинация по nullable колонке
+// превращается в отдельное приключение
+const (
+	SortByID          = "id"
+	SortByServiceName = "service_name"
+	SortByPrice       = "price"
+	SortByStartDate   = "start_date"
+)
+
+// ListFilter описывает фильтры, сортировку и позицию курсора для листинга подписок.
+// nil в полях-указателях значит, что фильтр не применяется
+type ListFilter struct {
+	UserID      *uuid.UUID
+	ServiceName *string
+	Currency    *string
+
+	// границы цены в минорных единицах
+	MinPrice *int64
+	MaxPrice *int64
+
+	// ActiveAt - подписка активна на эту дату (start_date <= ActiveAt <= end_date или end_date не указан)
+	ActiveAt *time.Time
+
+	StartFrom *time.Time
+	StartTo   *time.Time
+	EndFrom   *time.Time
+	EndTo     *time.Time
+
+	// Deleted - листинг корзины вместо живых подписок
+	Deleted bool
+
+	SortBy   string
+	SortDesc bool
+
+	// Limit - размер страницы, Cursor - позиция последней записи предыдущей страницы
+	Limit  int
+	Cursor *Cursor
+}
+
+// Cursor - раскодированное значение next_cursor: значение ключа сортировки и id последней записи страницы.
+// id нужен как тай-брейкер, иначе записи с одинаковой ценой/датой будут теряться между страницами
+type Cursor struct {
+	SortBy    string `json:"s"`
+	SortDesc  bool   `json:"d"`
+	SortValue string `json:"v"`
+	ID        int    `json:"id"`
+}
+
+// SubscriptionPage - одна страница листинга, NextCursor пустой, если страница последняя
+type SubscriptionPage struct {
+	Items      []Subscription `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// Политика пересечений: что делать, если подписка пересекается по датам с другой подпиской
+// того же пользователя на тот же сервис
+const (
+	// OverlapReject - отказать (409)
+	OverlapReject = "reject"
+	// OverlapMerge - слить пересекающиеся подписки в одну с объединённым периодом
+	OverlapMerge = "merge"
+	// OverlapWarn - сохранить как есть и вернуть id пересечений в ответе
+	OverlapWarn = "warn"
+)
+
+func IsOverlapPolicy(policy string) bool {
+	switch policy {
+	case OverlapReject, OverlapMerge, OverlapWarn:
+		return true
+	}
+	return false
+}
+
+// OverlapKey - тот же сервис в смысле пересечений: сервис каталога, если подписка к нему привязана, иначе название.
+// То же, что считает subscription_service_key в базе
+func OverlapKey(sub Subscription) string {
+	if sub.ServiceID != nil {
+		return "id:" + strconv.Itoa(*sub.ServiceID)
+	}
+	return "name:" + sub.ServiceName
+}
+
+// Conflict - пара живых подписок одного пользователя на один сервис с пересекающимися периодами.
+// From и To - границы пересечения, To пустой, если обе подписки бессрочные
+type Conflict struct {
+	UserID      uuid.UUID `json:"user_id"`
+	ServiceName string    `json:"service_name"`
+	FirstID     int       `json:"first_id"`
+	SecondID    int       `json:"second_id"`
+	From        string    `json:"from"`
+	To          *string   `json:"to,omitempty"`
+}
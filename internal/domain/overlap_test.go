@@ -0,0 +1,25 @@
+package domain
+
+import "testing"
+
+func TestOverlapKey(t *testing.T) {
+	one, two := 1, 2
+	cases := []struct {
+		name string
+		a, b Subscription
+		same bool
+	}{
+		{"одно название без каталога", Subscription{ServiceName: "Netflix"}, Subscription{ServiceName: "Netflix"}, true},
+		{"разные названия без каталога", Subscription{ServiceName: "Netflix"}, Subscription{ServiceName: "netflix"}, false},
+		{"разные написания одного сервиса каталога", Subscription{ServiceName: "Yandex Plus", ServiceID: &one}, Subscription{ServiceName: "Плюс", ServiceID: &one}, true},
+		{"одно название, разные сервисы каталога", Subscription{ServiceName: "Plus", ServiceID: &one}, Subscription{ServiceName: "Plus", ServiceID: &two}, false},
+		{"привязанная и непривязанная с одним названием", Subscription{ServiceName: "Plus", ServiceID: &one}, Subscription{ServiceName: "Plus"}, false},
+		// название вида "id:1" не должно совпасть с сервисом каталога 1
+		{"название под видом id", Subscription{ServiceName: "id:1"}, Subscription{ServiceName: "Plus", ServiceID: &one}, false},
+	}
+	for _, c := range cases {
+		if same := OverlapKey(c.a) == OverlapKey(c.b); same != c.same {
+			t.Errorf("%s: одинаковые ключи = %v, ожидалось %v", c.name, same, c.same)
+		}
+	}
+}
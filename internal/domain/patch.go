@@ -0,0 +1,63 @@
+package domain
+
+// SubscriptionPatch - частичное обновление по RFC 7396 (JSON Merge Patch).
+// nil в поле значит "ключа в документе не было, значение не трогаем".
+// Для nullable-колонок явный null отличается от отсутствующего ключа отдельным флагом Clear*
+type SubscriptionPatch struct {
+	ServiceName *string
+	Price       *int64
+	Currency    *string
+	StartDate   *string
+
+	EndDate      *string
+	ClearEndDate bool
+
+	BillingPeriod          *string
+	BillingPeriodDays      *int
+	ClearBillingPeriodDays bool
+
+	AutoRenew *bool
+}
+
+// IsEmpty - в документе не было ни одного известного ключа
+func (p SubscriptionPatch) IsEmpty() bool {
+	return p.ServiceName == nil && p.Price == nil && p.Currency == nil && p.StartDate == nil &&
+		p.EndDate == nil && !p.ClearEndDate &&
+		p.BillingPeriod == nil && p.BillingPeriodDays == nil && !p.ClearBillingPeriodDays &&
+		p.AutoRenew == nil
+}
+
+// Apply накладывает патч на подписку и возвращает результат, исходная подписка не меняется
+func (p SubscriptionPatch) Apply(sub Subscription) Subscription {
+	if p.ServiceName != nil {
+		sub.ServiceName = *p.ServiceName
+	}
+	if p.Price != nil {
+		sub.Price = *p.Price
+	}
+	if p.Currency != nil {
+		sub.Currency = *p.Currency
+	}
+	if p.StartDate != nil {
+		sub.StartDate = *p.StartDate
+	}
+	if p.EndDate != nil {
+		sub.EndDate = p.EndDate
+	}
+	if p.ClearEndDate {
+		sub.EndDate = nil
+	}
+	if p.BillingPeriod != nil {
+		sub.BillingPeriod = *p.BillingPeriod
+	}
+	if p.BillingPeriodDays != nil {
+		sub.BillingPeriodDays = p.BillingPeriodDays
+	}
+	if p.ClearBillingPeriodDays {
+		sub.BillingPeriodDays = nil
+	}
+	if p.AutoRenew != nil {
+		sub.AutoRenew = *p.AutoRenew
+	}
+	return sub
+}
@@ -0,0 +1,76 @@
+package domain
+
+import "testing"
+
+func TestSubscriptionPatchApply(t *testing.T) {
+	str := func(s string) *string { return &s }
+	num := func(n int) *int { return &n }
+	price := func(n int64) *int64 { return &n }
+	yes := true
+
+	base := func() Subscription {
+		return Subscription{ID: 1, ServiceName: "Yandex Plus", Price: 39900, Currency: CurrencyRUB, StartDate: "07-2025",
+			EndDate: str("12-2025"), BillingPeriod: BillingCustom, BillingPeriodDays: num(30), Version: 3}
+	}
+	cases := []struct {
+		name  string
+		patch SubscriptionPatch
+		check func(Subscription) bool
+	}{
+		{"пустой патч ничего не меняет", SubscriptionPatch{}, func(s Subscription) bool {
+			b := base()
+			return s.ServiceName == b.ServiceName && s.Price == b.Price && *s.EndDate == *b.EndDate && *s.BillingPeriodDays == 30
+		}},
+		{"цена и валюта", SubscriptionPatch{Price: price(1000), Currency: str(CurrencyUSD)}, func(s Subscription) bool {
+			return s.Price == 1000 && s.Currency == CurrencyUSD && s.ServiceName == "Yandex Plus"
+		}},
+		{"новая end_date", SubscriptionPatch{EndDate: str("01-2026")}, func(s Subscription) bool {
+			return s.EndDate != nil && *s.EndDate == "01-2026"
+		}},
+		{"null стирает end_date", SubscriptionPatch{ClearEndDate: true}, func(s Subscription) bool {
+			return s.EndDate == nil
+		}},
+		{"смена периода с очисткой длины", SubscriptionPatch{BillingPeriod: str(BillingMonthly), ClearBillingPeriodDays: true}, func(s Subscription) bool {
+			return s.BillingPeriod == BillingMonthly && s.BillingPeriodDays == nil
+		}},
+		{"auto_renew", SubscriptionPatch{AutoRenew: &yes}, func(s Subscription) bool {
+			return s.AutoRenew
+		}},
+		{"id и версия не меняются", SubscriptionPatch{ServiceName: str("Kinopoisk"), StartDate: str("2025-08-01")}, func(s Subscription) bool {
+			return s.ID == 1 && s.Version == 3 && s.ServiceName == "Kinopoisk" && s.StartDate == "2025-08-01"
+		}},
+	}
+
+	for _, c := range cases {
+		sub := base()
+		got := c.patch.Apply(sub)
+		if !c.check(got) {
+			t.Errorf("%s: %+v", c.name, got)
+		}
+		// исходная подписка остаётся как была
+		if sub.Price != 39900 || sub.EndDate == nil || sub.BillingPeriodDays == nil || sub.AutoRenew {
+			t.Errorf("%s: Apply изменил исходную подписку: %+v", c.name, sub)
+		}
+	}
+}
+
+func TestSubscriptionPatchIsEmpty(t *testing.T) {
+	s := "x"
+	no := false
+	cases := []struct {
+		name  string
+		patch SubscriptionPatch
+		empty bool
+	}{
+		{"ничего", SubscriptionPatch{}, true},
+		{"название", SubscriptionPatch{ServiceName: &s}, false},
+		{"только null end_date", SubscriptionPatch{ClearEndDate: true}, false},
+		{"только null billing_period_days", SubscriptionPatch{ClearBillingPeriodDays: true}, false},
+		{"auto_renew false", SubscriptionPatch{AutoRenew: &no}, false},
+	}
+	for _, c := range cases {
+		if got := c.patch.IsEmpty(); got != c.empty {
+			t.Errorf("%s: IsEmpty = %v, ожидалось %v", c.name, got, c.empty)
+		}
+	}
+}
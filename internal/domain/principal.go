@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// Способы аутентификации
+const (
+	AuthJWT    = "jwt"
+	AuthAPIKey = "api_key"
+)
+
+// ScopeAdmin - скоуп, с которым можно работать с подписками любого пользователя
+const ScopeAdmin = "admin"
+
+// RoleAdmin - роль, которую получает принципал с админским скоупом, даже если в токене ролей нет
+const RoleAdmin = "admin"
+
+// Операции, на которые политика доступа раздаёт права ролям
+const (
+	OpCreate = "create"
+	OpRead   = "read"
+	OpUpdate = "update"
+	OpDelete = "delete"
+	OpList   = "list"
+	OpStats  = "stats"
+
+	// OpCatalog - изменение каталога сервисов, читать каталог может любой
+	OpCatalog = "catalog"
+	// OpUsers - ведение справочника пользователей
+	OpUsers = "users"
+	// OpWebhooks - ведение вебхуков и их доставок
+	OpWebhooks = "webhooks"
+)
+
+// Principal - тот, кто сделал запрос. Для JWT Subject - это sub токена, и если он uuid,
+// то UserID - пользователь, чьи подписки ему доступны. Для API-ключа Subject - имя ключа, UserID пустой.
+// Roles - роли из токена, какие операции им разрешены, решает политика доступа.
+// AllUsers выставляется по политике при аутентификации: роль работает с подписками любых пользователей
+type Principal struct {
+	Subject  string
+	UserID   *uuid.UUID
+	Scopes   []string
+	Roles    []string
+	Method   string
+	AllUsers bool
+}
+
+func (p Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Principal) IsAdmin() bool {
+	return p.HasScope(ScopeAdmin)
+}
+
+// AnyUser - принципалу доступны подписки всех пользователей, а не только свои
+func (p Principal) AnyUser() bool {
+	return p.IsAdmin() || p.AllUsers
+}
+
+// Owns - может ли принципал работать с подписками пользователя userID
+func (p Principal) Owns(userID uuid.UUID) bool {
+	return p.AnyUser() || (p.UserID != nil && *p.UserID == userID)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext - false, если запрос прошёл без аутентификации (она выключена в конфиге)
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
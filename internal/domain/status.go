@@ -0,0 +1,112 @@
+package domain
+
+import "time"
+
+// Статусы подписки. Новая подписка - trial или active, дальше статус меняется только действиями:
+// trial -> active -> paused -> active -> cancelled -> expired
+const (
+	StatusTrial     = "trial"
+	StatusActive    = "active"
+	StatusPaused    = "paused"
+	StatusCancelled = "cancelled"
+	StatusExpired   = "expired"
+
+	DefaultStatus = StatusActive
+)
+
+// Действия над подпиской, они же операции в журнале изменений. ActionExpire выполняет только воркер
+// по прошествии end_date, остальные доступны и через API
+const (
+	ActionPause  = "pause"
+	ActionResume = "resume"
+	ActionCancel = "cancel"
+	ActionRenew  = "renew"
+	ActionExpire = "expire"
+)
+
+// transitions - в какой статус действие переводит подписку из каждого статуса, где оно доступно.
+// expired - конечный статус: закончившуюся подписку не продлить, нужна новая
+var transitions = map[string]map[string]string{
+	ActionPause:  {StatusActive: StatusPaused},
+	ActionResume: {StatusPaused: StatusActive},
+	ActionCancel: {StatusTrial: StatusCancelled, StatusActive: StatusCancelled, StatusPaused: StatusCancelled},
+	ActionRenew:  {StatusTrial: StatusActive, StatusActive: StatusActive, StatusCancelled: StatusActive},
+	ActionExpire: {StatusTrial: StatusExpired, StatusActive: StatusExpired, StatusPaused: StatusExpired, StatusCancelled: StatusExpired},
+}
+
+func IsStatus(status string) bool {
+	switch status {
+	case StatusTrial, StatusActive, StatusPaused, StatusCancelled, StatusExpired:
+		return true
+	}
+	return false
+}
+
+func IsAction(action string) bool {
+	_, ok := transitions[action]
+	return ok
+}
+
+// NextStatus - статус после действия, false если из status действие недоступно
+func NextStatus(status, action string) (string, bool) {
+	next, ok := transitions[action][status]
+	return next, ok
+}
+
+// StatusChange - действие над подпиской, как его применяет репозиторий. From - статус, из которого
+// действие проверялось: если подписку успели перевести в другой, изменение не применяется.
+// On - день действия, с него начинается или им заканчивается пауза. EndDate - новая end_date, nil - без изменений,
+// AutoRenew - новый auto_renew, nil - без изменений
+type StatusChange struct {
+	Action    string
+	From      string
+	To        string
+	On        time.Time
+	EndDate   *string
+	AutoRenew *bool
+}
+
+// ExpiryStats - итог прохода воркера по закончившимся подпискам.
+// У одного пакета ещё Scanned - сколько подписок он выбрал, и Next - курсор после последней из них
+type ExpiryStats struct {
+	Expired int
+	Renewed int
+	Scanned int
+	Next    ExpiryCursor
+}
+
+// ExpiryCursor - место в очереди воркера истечения, упорядоченной по (end_date, id): следующий пакет прохода
+// начинается после него. Так подписки, которые воркер тронуть не смог (продление упёрлось в пересечение,
+// а end_date ещё не прошла), не стоят в голове очереди и не закрывают собой остальные
+type ExpiryCursor struct {
+	EndDate time.Time
+	ID      int
+}
+
+// AddBillingPeriod сдвигает дату на один расчётный период. Месяцы прибавляются как в postgres:
+// 31 января + месяц = 28 (29) февраля, а не 3 марта, чтобы совпадать с разбивкой на периоды в аналитике
+func AddBillingPeriod(t time.Time, period string, days *int) time.Time {
+	switch period {
+	case BillingWeekly:
+		return t.AddDate(0, 0, 7)
+	case BillingQuarterly:
+		return addMonths(t, 3)
+	case BillingYearly:
+		return addMonths(t, 12)
+	case BillingCustom:
+		if days != nil {
+			return t.AddDate(0, 0, *days)
+		}
+	}
+	return addMonths(t, 1)
+}
+
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()).AddDate(0, n, 0)
+	last := first.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextStatus(t *testing.T) {
+	cases := []struct {
+		status, action string
+		want           string
+		ok             bool
+	}{
+		{StatusActive, ActionPause, StatusPaused, true},
+		{StatusTrial, ActionPause, "", false},
+		{StatusPaused, ActionPause, "", false},
+		{StatusPaused, ActionResume, StatusActive, true},
+		{StatusActive, ActionResume, "", false},
+		{StatusTrial, ActionCancel, StatusCancelled, true},
+		{StatusPaused, ActionCancel, StatusCancelled, true},
+		{StatusCancelled, ActionCancel, "", false},
+		{StatusTrial, ActionRenew, StatusActive, true},
+		{StatusCancelled, ActionRenew, StatusActive, true},
+		{StatusPaused, ActionRenew, "", false},
+		{StatusCancelled, ActionExpire, StatusExpired, true},
+		// expired - конечный статус
+		{StatusExpired, ActionRenew, "", false},
+		{StatusExpired, ActionResume, "", false},
+		{StatusExpired, ActionExpire, "", false},
+		{StatusActive, "delete", "", false},
+		{"frozen", ActionResume, "", false},
+	}
+	for _, c := range cases {
+		got, ok := NextStatus(c.status, c.action)
+		if got != c.want || ok != c.ok {
+			t.Errorf("NextStatus(%s, %s) = %q, %v, ожидалось %q, %v", c.status, c.action, got, ok, c.want, c.ok)
+		}
+	}
+
+	for _, action := range []string{ActionPause, ActionResume, ActionCancel, ActionRenew, ActionExpire} {
+		if !IsAction(action) {
+			t.Errorf("IsAction(%s) = false", action)
+		}
+	}
+	if IsAction("delete") || IsStatus("frozen") || !IsStatus(StatusExpired) {
+		t.Errorf("IsAction/IsStatus пропускают неизвестные значения")
+	}
+}
+
+func TestAddBillingPeriod(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse(DayLayout, s)
+		if err != nil {
+			t.Fatalf("дата %s: %v", s, err)
+		}
+		return d
+	}
+	days := func(n int) *int { return &n }
+
+	cases := []struct {
+		name   string
+		from   string
+		period string
+		days   *int
+		want   string
+	}{
+		{name: "месяц", from: "2025-03-15", period: BillingMonthly, want: "2025-04-15"},
+		{name: "без периода - месяц", from: "2025-03-15", want: "2025-04-15"},
+		{name: "31 января + месяц", from: "2025-01-31", period: BillingMonthly, want: "2025-02-28"},
+		{name: "31 января високосного", from: "2024-01-31", period: BillingMonthly, want: "2024-02-29"},
+		{name: "через год", from: "2025-12-31", period: BillingMonthly, want: "2026-01-31"},
+		{name: "неделя", from: "2025-12-29", period: BillingWeekly, want: "2026-01-05"},
+		{name: "квартал с конца месяца", from: "2025-11-30", period: BillingQuarterly, want: "2026-02-28"},
+		{name: "год с 29 февраля", from: "2024-02-29", period: BillingYearly, want: "2025-02-28"},
+		{name: "custom", from: "2025-03-15", period: BillingCustom, days: days(45), want: "2025-04-29"},
+		{name: "custom без длины - месяц", from: "2025-03-15", period: BillingCustom, want: "2025-04-15"},
+	}
+	for _, c := range cases {
+		got := AddBillingPeriod(date(c.from), c.period, c.days)
+		if got.Format(DayLayout) != c.want {
+			t.Errorf("%s: %s + %s = %s, ожидалось %s", c.name, c.from, c.period, got.Format(DayLayout), c.want)
+		}
+	}
+}
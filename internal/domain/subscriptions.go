@@ -3,18 +3,51 @@
 package domain
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
 type Subscription struct {
 	ID          int       `json:"id" db:"id"`                     // Идентификатор подписки
 	ServiceName string    `json:"service_name" db:"service_name"` // Название покупаемого сервиса
-	Price       int       `json:"price" db:"price"`               // Цена подписки
+	Price       int64     `json:"price" db:"price"`               // Цена подписки в минорных единицах валюты (копейки, центы)
+	Currency    string    `json:"currency" db:"currency"`         // Код валюты ISO 4217: RUB, USD, EUR
 	UserID      uuid.UUID `json:"user_id" db:"user_id"`           // UUID пользователя
 
 	// Дату реализовал через строку по примеру из ТЗ, планирую строку валидировать и преобразовывать с помощью time.Parse
 	// в сервисе, в базу буду сохранять в TIMESTAMP.
+	// Принимается MM-YYYY или полная дата YYYY-MM-DD, см. ParseDate
 	StartDate string  `json:"start_date" db:"start_date"`       // Дата активации подписки
 	EndDate   *string `json:"end_date,omitempty" db:"end_date"` // Дата окончания подписки, EndDate реализовал через указатель на строку для проверки на nil,
 
+	// Период списания: weekly, monthly, quarterly, yearly или custom с длиной в днях.
+	// Списание за период, начавшийся не позже EndDate, считается полностью
+	BillingPeriod     string `json:"billing_period" db:"billing_period"`                     // Период списания
+	BillingPeriodDays *int   `json:"billing_period_days,omitempty" db:"billing_period_days"` // Длина периода в днях, только для custom
+
+	// Статус: при создании trial или active, дальше меняется только действиями pause/resume/cancel/renew
+	Status string `json:"status" db:"status"`
+	// AutoRenew - по прошествии end_date подписка продлевается на расчётный период, а не заканчивается
+	AutoRenew bool `json:"auto_renew" db:"auto_renew"`
+
+	// Версия растёт на каждое изменение, по ней работает оптимистичная блокировка (ETag / If-Match)
+	Version int `json:"version" db:"version"`
+
+	// Сервис каталога, с которым сопоставлено название, nil для сервисов вне каталога
+	ServiceID *int `json:"service_id,omitempty" db:"service_id"`
+
+	// Момент мягкого удаления, nil у живых подписок
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+
+	// Пересечение с другими подписками этого сервиса сохранено осознанно (политика warn),
+	// на такие подписки не действует ограничение на пересечения в базе
+	OverlapAllowed bool `json:"overlap_allowed" db:"overlap_allowed"`
+
+	// Не хранятся, заполняются при записи: Overlaps - id пересекающихся подписок (политика warn),
+	// MergedIDs - id подписок, поглощённых при слиянии и ушедших в корзину (политика merge),
+	// Merged - новая подписка легла в уже существующую, а не создана (MergedIDs при этом может быть пустым)
+	Overlaps  []int `json:"overlaps,omitempty" db:"-"`
+	MergedIDs []int `json:"merged_ids,omitempty" db:"-"`
+	Merged    bool  `json:"-" db:"-"`
 }
@@ -0,0 +1,23 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// User - пользователь из справочника. ID совпадает с sub в токене и user_id в подписках,
+// поэтому задаётся при создании, а не генерируется
+type User struct {
+	ID        uuid.UUID `json:"id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	Email     *string   `json:"email,omitempty" example:"user@example.com"`
+	Name      string    `json:"name" example:"Иван Иванов"`
+	CreatedAt time.Time `json:"created_at" example:"2025-08-01T12:00:00Z"`
+	UpdatedAt time.Time `json:"updated_at" example:"2025-08-01T12:00:00Z"`
+}
+
+// UserPage - страница справочника пользователей, NextCursor пустой на последней странице
+type UserPage struct {
+	Items      []User
+	NextCursor *uuid.UUID
+}
@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook - адрес, на который POST-ом уходят события. Тело подписывается HMAC-SHA256 на Secret,
+// пустой Events - вебхук получает все события. Secret отдаётся клиенту только при создании
+type Webhook struct {
+	ID        int       `json:"id" example:"1"`
+	URL       string    `json:"url" example:"https://billing.example.com/hooks/subscriptions"`
+	Secret    string    `json:"secret,omitempty" example:"whsec_3f9a..."`
+	Events    []string  `json:"events" example:"subscription.created,subscription.deleted"`
+	Active    bool      `json:"active" example:"true"`
+	CreatedAt time.Time `json:"created_at" example:"2025-08-01T12:00:00Z"`
+	UpdatedAt time.Time `json:"updated_at" example:"2025-08-01T12:00:00Z"`
+}
+
+// Accepts - подписан ли вебхук на событие
+func (w Webhook) Accepts(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Статусы доставки
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// WebhookDelivery - одна доставка события одному вебхуку. LastStatus - HTTP-статус последнего ответа,
+// LastError - почему последняя попытка не удалась
+type WebhookDelivery struct {
+	ID            int64           `json:"id" example:"42"`
+	WebhookID     int             `json:"webhook_id" example:"1"`
+	EventID       uuid.UUID       `json:"event_id"`
+	Event         string          `json:"event" example:"subscription.created"`
+	Payload       json.RawMessage `json:"payload" swaggertype:"object"`
+	Status        string          `json:"status" example:"dead"`
+	Attempts      int             `json:"attempts" example:"8"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     *string         `json:"last_error,omitempty" example:"unexpected status 503"`
+	LastStatus    *int            `json:"last_status,omitempty" example:"503"`
+	CreatedAt     time.Time       `json:"created_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+
+	// куда и с каким ключом слать, заполняется только для доставок, взятых воркером
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// DeliveryStats - итог одного прохода воркера доставки
+type DeliveryStats struct {
+	Delivered int
+	Retried   int
+	Dead      int
+}
+
+// DeliveryPage - страница dead-letter списка, NextCursor - before для следующей страницы
+type DeliveryPage struct {
+	Items      []WebhookDelivery
+	NextCursor *int64
+}
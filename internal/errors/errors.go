@@ -2,12 +2,135 @@ package errors
 
 import "errors"
 
+// Kind - класс ошибки, по нему HTTP-слой выбирает статус ответа.
+// Сам пакет про HTTP ничего не знает, маппинг живёт в delivery/http
+type Kind string
+
+const (
+	KindInvalid       Kind = "invalid"       // клиент прислал что-то не то - 400
+	KindUnauthorized  Kind = "unauthorized"  // нет или невалидные учётные данные - 401
+	KindForbidden     Kind = "forbidden"     // учётные данные есть, но прав не хватает - 403
+	KindNotFound      Kind = "not_found"     // 404
+	KindConflict      Kind = "conflict"      // состояние не позволяет операцию - 409
+	KindPrecondition  Kind = "precondition"  // If-Match не совпал - 412
+	KindUnprocessable Kind = "unprocessable" // запрос корректный, но выполнить его нельзя - 422
+	KindRateLimited   Kind = "rate_limited"  // клиент исчерпал лимит запросов - 429
+	KindInternal      Kind = "internal"      // 500
+)
+
+// Error - типизированная ошибка: стабильный машиночитаемый Code, класс Kind и поле запроса Field, к которому она относится.
+// Message - человекочитаемый текст, клиентам на него опираться не нужно, для этого есть Code.
+// Две ошибки с одинаковым Code считаются одной и той же для errors.Is, так что WithField и Wrap не ломают сравнение с сентинелом
+type Error struct {
+	Code    string
+	Kind    Kind
+	Field   string
+	Message string
+	Err     error
+}
+
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithField - та же ошибка, но привязанная к конкретному полю запроса
+func (e *Error) WithField(field string) *Error {
+	c := *e
+	c.Field = field
+	return &c
+}
+
+// Wrap - ошибка base с причиной cause, текст причины уходит клиенту в detail
+func Wrap(base *Error, cause error) *Error {
+	c := *base
+	c.Err = cause
+	return &c
+}
+
 // здесь я буду реализовывать кастомные ошибки для общего развития
 var (
-	ErrSubscriptionNotFound = errors.New("подписка не найдена")
-	ErrInvalidDateFormat    = errors.New("указан невалидный формат даты")
-	ErrInvalidPrice         = errors.New("указана невалидная цена")
-	ErrInvalidUserID        = errors.New("пользователя не существует")
+	ErrSubscriptionNotFound = New(KindNotFound, "subscription_not_found", "подписка не найдена")
+	ErrInvalidDateFormat    = New(KindInvalid, "invalid_date", "указан невалидный формат даты")
+	ErrInvalidPrice         = New(KindInvalid, "invalid_price", "указана невалидная цена").WithField("price")
+	ErrInvalidUserID        = New(KindInvalid, "invalid_user_id", "пользователя не существует").WithField("user_id")
+	ErrInvalidCursor        = New(KindInvalid, "invalid_cursor", "невалидный курсор пагинации").WithField("cursor")
+	ErrInvalidSortKey       = New(KindInvalid, "invalid_sort", "невалидный ключ сортировки").WithField("sort")
+	ErrInvalidFilter        = New(KindInvalid, "invalid_filter", "невалидный фильтр")
+	ErrInvalidPeriod        = New(KindInvalid, "invalid_period", "начало периода позже его окончания")
+	ErrInvalidCurrency      = New(KindInvalid, "invalid_currency", "неподдерживаемая валюта").WithField("currency")
+	ErrUnknownRate          = New(KindUnprocessable, "unknown_rate", "нет курса для пересчёта валюты")
+	ErrInvalidBilling       = New(KindInvalid, "invalid_billing_period", "невалидный период списания").WithField("billing_period")
+	ErrInvalidPatch         = New(KindInvalid, "invalid_patch", "невалидный merge-patch документ")
+	ErrVersionConflict      = New(KindPrecondition, "version_conflict", "подписка была изменена другим запросом")
+	ErrSubscriptionOverlap  = New(KindConflict, "subscription_overlap", "подписка пересекается по датам с другой подпиской этого сервиса").WithField("start_date")
+	ErrInvalidPrecondition  = New(KindInvalid, "invalid_if_match", "невалидный заголовок If-Match").WithField("If-Match")
+	ErrInvalidBatch         = New(KindInvalid, "invalid_batch", "невалидная операция пакета")
+	ErrBatchAborted         = New(KindUnprocessable, "batch_aborted", "пакет откатился целиком из-за ошибки в одной из операций")
+	ErrBatchRolledBack      = New(KindUnprocessable, "batch_rolled_back", "операция отменена вместе со всем пакетом")
+	ErrInvalidImport        = New(KindInvalid, "invalid_import", "невалидный файл импорта")
+
+	// каталог сервисов
+	ErrServiceNotFound  = New(KindNotFound, "service_not_found", "сервис не найден в каталоге")
+	ErrUnknownService   = New(KindUnprocessable, "unknown_service", "сервиса нет в каталоге").WithField("service_name")
+	ErrServiceNameTaken = New(KindConflict, "service_name_taken", "название или алиас уже заняты другим сервисом каталога")
+	ErrInvalidService   = New(KindInvalid, "invalid_service", "невалидный сервис каталога")
+	ErrPriceOutOfRange  = New(KindInvalid, "price_out_of_range", "цена вне допустимого для сервиса диапазона").WithField("price")
+
+	// статусы подписки
+	ErrInvalidStatus     = New(KindInvalid, "invalid_status", "новая подписка может быть только trial или active").WithField("status")
+	ErrInvalidTransition = New(KindConflict, "invalid_transition", "действие недоступно в текущем статусе подписки").WithField("status")
+
+	// справочник пользователей
+	ErrUserNotFound         = New(KindNotFound, "user_not_found", "пользователь не найден")
+	ErrUserExists           = New(KindConflict, "user_exists", "пользователь с таким id или email уже есть")
+	ErrUserHasSubscriptions = New(KindConflict, "user_has_subscriptions", "у пользователя есть подписки, в том числе в корзине")
+	ErrInvalidUser          = New(KindInvalid, "invalid_user", "невалидный пользователь")
+
+	// вебхуки
+	ErrWebhookNotFound  = New(KindNotFound, "webhook_not_found", "вебхук не найден")
+	ErrInvalidWebhook   = New(KindInvalid, "invalid_webhook", "невалидный вебхук")
+	ErrDeliveryNotFound = New(KindNotFound, "delivery_not_found", "доставка не найдена")
+	ErrDeliveryNotDead  = New(KindConflict, "delivery_not_dead", "повторить можно только доставку из dead-letter списка")
 
-	// можно было бы расписать еще кучу ошибок, если бы у меня была условная база юзеров и сервисов, но есть что есть
+	// общие ошибки запроса, не привязанные к подпискам
+	ErrInvalidRequest = New(KindInvalid, "invalid_request", "невалидный запрос")
+	ErrInvalidID      = New(KindInvalid, "invalid_id", "невалидный id").WithField("id")
+	ErrInvalidUUID    = New(KindInvalid, "invalid_uuid", "невалидный uuid")
+	ErrInternal       = New(KindInternal, "internal", "внутренняя ошибка сервера")
+
+	// аутентификация и права
+	ErrUnauthorized = New(KindUnauthorized, "unauthorized", "требуется аутентификация")
+	ErrForbidden    = New(KindForbidden, "forbidden", "недостаточно прав")
+	ErrRateLimited  = New(KindRateLimited, "rate_limited", "слишком много запросов")
+
+	// Idempotency-Key
+	ErrInvalidIdempotencyKey    = New(KindInvalid, "invalid_idempotency_key", "невалидный Idempotency-Key").WithField("Idempotency-Key")
+	ErrIdempotencyKeyReused     = New(KindUnprocessable, "idempotency_key_reused", "Idempotency-Key уже использован с другим телом запроса").WithField("Idempotency-Key")
+	ErrIdempotencyKeyInProgress = New(KindConflict, "idempotency_key_in_progress", "запрос с этим Idempotency-Key ещё выполняется").WithField("Idempotency-Key")
 )
+
+// Is - прокся на стандартный errors.Is, чтобы в слоях выше не импортировать два пакета errors под алиасами
+func Is(err, target error) bool {
+	return errors.Is(err, target)
+}
+
+// As - прокся на стандартный errors.As, по той же причине
+func As(err error, target any) bool {
+	return errors.As(err, target)
+}
@@ -0,0 +1,233 @@
+// Разбор табличных файлов с подписками (CSV, XLSX) для импорта.
+// Файлы читаются построчно, целиком в памяти держится только таблица строк XLSX (sharedStrings)
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reader отдаёт файл по строке за раз, первая строка - заголовок. В конце файла - io.EOF.
+// Ему удовлетворяет *csv.Reader
+type Reader interface {
+	Read() ([]string, error)
+}
+
+// Поля подписки, которые можно сопоставить колонкам файла
+const (
+	FieldServiceName       = "service_name"
+	FieldPrice             = "price"
+	FieldPriceMinor        = "price_minor"
+	FieldCurrency          = "currency"
+	FieldUserID            = "user_id"
+	FieldStartDate         = "start_date"
+	FieldEndDate           = "end_date"
+	FieldBillingPeriod     = "billing_period"
+	FieldBillingPeriodDays = "billing_period_days"
+)
+
+var fields = []string{
+	FieldServiceName, FieldPrice, FieldPriceMinor, FieldCurrency, FieldUserID,
+	FieldStartDate, FieldEndDate, FieldBillingPeriod, FieldBillingPeriodDays,
+}
+
+// цена обязательна, но может прийти любой из колонок price и price_minor, это проверяется отдельно
+var requiredFields = []string{FieldServiceName, FieldUserID, FieldStartDate}
+
+// Mapping - поле подписки -> название колонки в файле. Поля без маппинга ищутся в заголовке по своему имени
+type Mapping map[string]string
+
+// Columns - номера колонок каждого поля в файле, -1 если колонки нет
+type Columns map[string]int
+
+// ResolveColumns находит колонки полей в заголовке, регистр и пробелы по краям не важны.
+// Обязательные поля должны найтись, лишние колонки файла игнорируются
+func ResolveColumns(header []string, mapping Mapping) (Columns, error) {
+	for field := range mapping {
+		if !isField(field) {
+			return nil, errors.Wrap(errors.ErrInvalidImport.WithField("mapping"), fmt.Errorf("неизвестное поле в маппинге %q", field))
+		}
+	}
+
+	index := make(map[string]int, len(header))
+	for i, h := range header {
+		// Excel любит класть BOM в начало CSV
+		h = strings.TrimPrefix(h, "\ufeff")
+		index[normalize(h)] = i
+	}
+
+	cols := make(Columns, len(fields))
+	for _, field := range fields {
+		name := field
+		if mapped, ok := mapping[field]; ok {
+			name = mapped
+		}
+		i, ok := index[normalize(name)]
+		if !ok {
+			i = -1
+		}
+		cols[field] = i
+	}
+
+	for _, field := range requiredFields {
+		if cols[field] < 0 {
+			return nil, errors.Wrap(errors.ErrInvalidImport.WithField(field), fmt.Errorf("нет колонки для поля %s", field))
+		}
+	}
+	if cols[FieldPrice] < 0 && cols[FieldPriceMinor] < 0 {
+		return nil, errors.Wrap(errors.ErrInvalidImport.WithField(FieldPrice), fmt.Errorf("нет колонки для поля %s или %s", FieldPrice, FieldPriceMinor))
+	}
+	return cols, nil
+}
+
+// IsBlank - строка без единого непустого значения, такие строки импорт пропускает
+func IsBlank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Subscription собирает подписку из строки файла. Проверяется только формат значений,
+// бизнес-правила - дело сервиса. Цена, как и в API, - price в целых единицах валюты или price_minor в минорных,
+// если в строке заполнены обе, они должны совпадать
+func (c Columns) Subscription(record []string) (domain.Subscription, error) {
+	var (
+		sub domain.Subscription
+		err error
+	)
+
+	sub.ServiceName = c.value(record, FieldServiceName)
+	if sub.ServiceName == "" {
+		return domain.Subscription{}, fieldError(FieldServiceName, "пустое значение")
+	}
+
+	sub.Price, err = c.price(record)
+	if err != nil {
+		return domain.Subscription{}, err
+	}
+
+	sub.UserID, err = uuid.Parse(c.value(record, FieldUserID))
+	if err != nil {
+		return domain.Subscription{}, fieldError(FieldUserID, "невалидный uuid")
+	}
+
+	sub.StartDate = dateCell(c.value(record, FieldStartDate))
+	if end := dateCell(c.value(record, FieldEndDate)); end != "" {
+		sub.EndDate = &end
+	}
+
+	sub.Currency = strings.ToUpper(c.value(record, FieldCurrency))
+	sub.BillingPeriod = strings.ToLower(c.value(record, FieldBillingPeriod))
+	if raw := c.value(record, FieldBillingPeriodDays); raw != "" {
+		days, err := strconv.Atoi(raw)
+		if err != nil {
+			return domain.Subscription{}, fieldError(FieldBillingPeriodDays, "ожидается целое число")
+		}
+		sub.BillingPeriodDays = &days
+	}
+	return sub, nil
+}
+
+func (c Columns) price(record []string) (int64, error) {
+	rawWhole, rawMinor := c.value(record, FieldPrice), c.value(record, FieldPriceMinor)
+	if rawWhole == "" && rawMinor == "" {
+		return 0, fieldError(FieldPrice, "пустое значение")
+	}
+
+	var whole int64
+	if rawWhole != "" {
+		var err error
+		whole, err = strconv.ParseInt(rawWhole, 10, 64)
+		if err != nil {
+			return 0, fieldError(FieldPrice, "ожидается целое число в целых единицах валюты")
+		}
+	}
+	if rawMinor == "" {
+		minor, ok := domain.MinorUnits(whole)
+		if !ok {
+			return 0, fieldError(FieldPrice, "слишком большое число")
+		}
+		return minor, nil
+	}
+
+	minor, err := strconv.ParseInt(rawMinor, 10, 64)
+	if err != nil {
+		return 0, fieldError(FieldPriceMinor, "ожидается целое число в минорных единицах")
+	}
+	if rawWhole != "" && whole != domain.WholeUnits(minor) {
+		return 0, fieldError(FieldPrice, "не совпадает с price_minor")
+	}
+	return minor, nil
+}
+
+func (c Columns) value(record []string, field string) string {
+	i, ok := c[field]
+	if !ok || i < 0 || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// excelEpoch - нулевой день дат Excel (с учётом его ошибки про 29 февраля 1900)
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// dateCell - если ячейку с датой в XLSX отформатировали как дату, в файле лежит её порядковый номер.
+// Такое значение переводим в YYYY-MM-DD, всё остальное отдаём как есть на разбор ParseDate
+func dateCell(v string) string {
+	if v == "" || strings.ContainsAny(v, "-./") {
+		return v
+	}
+	serial, err := strconv.ParseFloat(v, 64)
+	if err != nil || serial <= 0 {
+		return v
+	}
+	return excelEpoch.AddDate(0, 0, int(serial)).Format(domain.DayLayout)
+}
+
+func fieldError(field, reason string) error {
+	return errors.Wrap(errors.ErrInvalidImport.WithField(field), fmt.Errorf("%s: %s", field, reason))
+}
+
+func isField(name string) bool {
+	for _, f := range fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+func normalize(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// NewCSVReader читает CSV потоком. Разделитель определяется по заголовку: выгрузки из русского Excel
+// идут через точку с запятой, всё остальное - через запятую
+func NewCSVReader(r io.Reader) *csv.Reader {
+	br := bufio.NewReader(r)
+	header, _ := br.Peek(4096)
+	if i := bytes.IndexByte(header, '\n'); i >= 0 {
+		header = header[:i]
+	}
+
+	reader := csv.NewReader(br)
+	if bytes.Count(header, []byte{';'}) > bytes.Count(header, []byte{','}) {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	return reader
+}
@@ -0,0 +1,59 @@
+package importer
+
+import (
+	"testing"
+	"testovoe_again/internal/errors"
+)
+
+func TestSubscriptionPrice(t *testing.T) {
+	const userID = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+	cases := []struct {
+		name    string
+		header  []string
+		record  []string
+		want    int64
+		wantErr string
+	}{
+		{name: "price в целых единицах", header: []string{"service_name", "price", "user_id", "start_date"},
+			record: []string{"Yandex Plus", "399", userID, "07-2025"}, want: 39900},
+		{name: "price_minor", header: []string{"service_name", "price_minor", "user_id", "start_date"},
+			record: []string{"Yandex Plus", "39950", userID, "07-2025"}, want: 39950},
+		{name: "обе колонки совпадают", header: []string{"service_name", "price", "price_minor", "user_id", "start_date"},
+			record: []string{"Yandex Plus", "399", "39950", userID, "07-2025"}, want: 39950},
+		{name: "пустая price рядом с price_minor", header: []string{"service_name", "price", "price_minor", "user_id", "start_date"},
+			record: []string{"Yandex Plus", "", "39950", userID, "07-2025"}, want: 39950},
+		{name: "обе колонки расходятся", header: []string{"service_name", "price", "price_minor", "user_id", "start_date"},
+			record: []string{"Yandex Plus", "400", "39950", userID, "07-2025"}, wantErr: FieldPrice},
+		{name: "обе пустые", header: []string{"service_name", "price", "price_minor", "user_id", "start_date"},
+			record: []string{"Yandex Plus", "", "", userID, "07-2025"}, wantErr: FieldPrice},
+		{name: "не число", header: []string{"service_name", "price_minor", "user_id", "start_date"},
+			record: []string{"Yandex Plus", "399.50", userID, "07-2025"}, wantErr: FieldPriceMinor},
+	}
+
+	for _, c := range cases {
+		cols, err := ResolveColumns(c.header, nil)
+		if err != nil {
+			t.Fatalf("%s: ResolveColumns: %v", c.name, err)
+		}
+		sub, err := cols.Subscription(c.record)
+		if c.wantErr != "" {
+			var e *errors.Error
+			if !errors.As(err, &e) || e.Field != c.wantErr {
+				t.Errorf("%s: ошибка %v, ожидалась ошибка поля %s", c.name, err, c.wantErr)
+			}
+			continue
+		}
+		if err != nil || sub.Price != c.want {
+			t.Errorf("%s: %d, %v, ожидалось %d", c.name, sub.Price, err, c.want)
+		}
+	}
+}
+
+func TestResolveColumnsPrice(t *testing.T) {
+	if _, err := ResolveColumns([]string{"service_name", "user_id", "start_date"}, nil); !errors.Is(err, errors.ErrInvalidImport) {
+		t.Errorf("без колонок цены: %v, ожидалась ErrInvalidImport", err)
+	}
+	if _, err := ResolveColumns([]string{"service_name", "Цена, коп.", "user_id", "start_date"}, Mapping{FieldPriceMinor: "цена, коп."}); err != nil {
+		t.Errorf("price_minor по маппингу: %v", err)
+	}
+}
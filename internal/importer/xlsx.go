@@ -0,0 +1,334 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"testovoe_again/internal/errors"
+)
+
+// XLSX - это zip с XML внутри. Тянуть ради одного импорта целую библиотеку не стали:
+// нам нужен только первый лист и только значения ячеек, без стилей и формул.
+// Лист разбирается потоком по токенам, в памяти целиком лежит только таблица строк sharedStrings
+
+// Пределы книги. Zip сжимает XML в десятки раз, поэтому распакованные части ограничены отдельно от
+// размера файла, а номер колонки - пределом самого формата: иначе адрес вида XFDZZZZZ1 раздул бы строку
+const (
+	MaxXLSXColumns       = 16384 // XFD
+	maxSharedStringsSize = 64 << 20
+	maxSheetSize         = 256 << 20
+)
+
+// XLSXReader читает первый лист книги построчно
+type XLSXReader struct {
+	sheet   io.ReadCloser
+	decoder *xml.Decoder
+	strings []string
+}
+
+// NewXLSXReader открывает книгу. zip читается с произвольным доступом, поэтому нужен io.ReaderAt и размер
+func NewXLSXReader(r io.ReaderAt, size int64) (*XLSXReader, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: файл не похож на xlsx", errors.ErrInvalidImport)
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	shared, err := readSharedStrings(files["xl/sharedStrings.xml"])
+	if err != nil {
+		return nil, err
+	}
+
+	sheetFile := files[firstSheetPath(files)]
+	if sheetFile == nil {
+		return nil, fmt.Errorf("%w: в книге нет листов", errors.ErrInvalidImport)
+	}
+	sheet, err := sheetFile.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := xml.NewDecoder(newLimitedReader(sheet, maxSheetSize, "лист"))
+	return &XLSXReader{sheet: sheet, decoder: decoder, strings: shared}, nil
+}
+
+func (x *XLSXReader) Close() error {
+	return x.sheet.Close()
+}
+
+// Read отдаёт следующую строку листа. Пропущенные ячейки (в XLSX пустые ячейки часто не пишутся вовсе)
+// заполняются пустыми строками по их адресу
+func (x *XLSXReader) Read() ([]string, error) {
+	for {
+		tok, err := x.decoder.Token()
+		if err != nil {
+			return nil, brokenSheet(err)
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "row" {
+			return x.readRow()
+		}
+	}
+}
+
+func (x *XLSXReader) readRow() ([]string, error) {
+	var record []string
+	for {
+		tok, err := x.decoder.Token()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "c" {
+				continue
+			}
+			col, value, err := x.readCell(t)
+			if err != nil {
+				return nil, err
+			}
+			if col < 0 {
+				col = len(record)
+			}
+			if col >= MaxXLSXColumns {
+				return nil, fmt.Errorf("%w: в строке больше %d колонок", errors.ErrInvalidImport, MaxXLSXColumns)
+			}
+			for len(record) < col {
+				record = append(record, "")
+			}
+			record = append(record, value)
+		case xml.EndElement:
+			if t.Name.Local == "row" {
+				return record, nil
+			}
+		}
+	}
+}
+
+// readCell разбирает <c r="B2" t="s"><v>3</v></c> и отдаёт номер колонки и значение
+func (x *XLSXReader) readCell(start xml.StartElement) (int, string, error) {
+	var ref, typ string
+	for _, a := range start.Attr {
+		switch a.Name.Local {
+		case "r":
+			ref = a.Value
+		case "t":
+			typ = a.Value
+		}
+	}
+
+	// значение лежит в <v>, у inlineStr - в <is><t>; текст формулы <f> в значение не берём
+	var (
+		raw     strings.Builder
+		inValue bool
+	)
+	for {
+		tok, err := x.decoder.Token()
+		if err != nil {
+			return 0, "", unexpectedEOF(err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "v" || t.Name.Local == "t" {
+				inValue = true
+			}
+		case xml.CharData:
+			if inValue {
+				raw.Write(t)
+			}
+		case xml.EndElement:
+			if t.Name.Local == "v" || t.Name.Local == "t" {
+				inValue = false
+				continue
+			}
+			if t.Name.Local != "c" {
+				continue
+			}
+			value := raw.String()
+			if typ == "s" {
+				i, err := strconv.Atoi(strings.TrimSpace(value))
+				if err != nil || i < 0 || i >= len(x.strings) {
+					return 0, "", fmt.Errorf("%w: битая ссылка на строку в ячейке %s", errors.ErrInvalidImport, ref)
+				}
+				value = x.strings[i]
+			}
+			col, err := columnIndex(ref)
+			if err != nil {
+				return 0, "", err
+			}
+			return col, value, nil
+		}
+	}
+}
+
+// columnIndex переводит буквы адреса ячейки в номер колонки с нуля: A1 -> 0, AB7 -> 27.
+// Адрес без букв - -1, колонка за пределом XLSX - ошибка импорта
+func columnIndex(ref string) (int, error) {
+	col := 0
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		if col > MaxXLSXColumns {
+			return 0, fmt.Errorf("%w: адрес ячейки %q за пределами листа", errors.ErrInvalidImport, ref)
+		}
+		n++
+	}
+	if n == 0 {
+		return -1, nil
+	}
+	return col - 1, nil
+}
+
+// readSharedStrings читает таблицу строк книги, в <si> текст может быть разбит на несколько <r><t>.
+// Фонетические подсказки <rPh> в значение не входят
+func readSharedStrings(f *zip.File) ([]string, error) {
+	if f == nil {
+		return nil, nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var (
+		result  []string
+		current strings.Builder
+		inText  bool
+		inPhon  bool
+	)
+	decoder := xml.NewDecoder(newLimitedReader(rc, maxSharedStringsSize, "таблица строк"))
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			return result, nil
+		}
+		if errors.Is(err, errors.ErrInvalidImport) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: битая таблица строк", errors.ErrInvalidImport)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				current.Reset()
+			case "t":
+				inText = true
+			case "rPh":
+				inPhon = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				result = append(result, current.String())
+			case "t":
+				inText = false
+			case "rPh":
+				inPhon = false
+			}
+		case xml.CharData:
+			if inText && !inPhon {
+				current.Write(t)
+			}
+		}
+	}
+}
+
+// firstSheetPath ищет файл первого листа через workbook.xml и его rels,
+// если что-то не нашлось - берём стандартный путь, с которым пишет большинство редакторов
+func firstSheetPath(files map[string]*zip.File) string {
+	const fallback = "xl/worksheets/sheet1.xml"
+
+	var workbook struct {
+		Sheets []struct {
+			RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := decodeZipXML(files["xl/workbook.xml"], &workbook); err != nil || len(workbook.Sheets) == 0 {
+		return fallback
+	}
+
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodeZipXML(files["xl/_rels/workbook.xml.rels"], &rels); err != nil {
+		return fallback
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID != workbook.Sheets[0].RID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/")
+		}
+		return path.Join("xl", rel.Target)
+	}
+	return fallback
+}
+
+func decodeZipXML(f *zip.File, v any) error {
+	if f == nil {
+		return io.ErrUnexpectedEOF
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+// limitedReader - io.LimitReader, который за пределом отдаёт ошибку импорта, а не тихий конец данных:
+// обрезанная по лимиту часть книги иначе разобралась бы как целая
+type limitedReader struct {
+	r     io.Reader
+	read  int64
+	limit int64
+	what  string
+}
+
+func newLimitedReader(r io.Reader, limit int64, what string) *limitedReader {
+	return &limitedReader{r: io.LimitReader(r, limit+1), limit: limit, what: what}
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.limit {
+		return 0, fmt.Errorf("%w: %s больше %d МБ после распаковки", errors.ErrInvalidImport, l.what, l.limit>>20)
+	}
+	return n, err
+}
+
+// конец файла посреди строки - это битый лист, а не конец данных
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return fmt.Errorf("%w: лист оборван посреди строки", errors.ErrInvalidImport)
+	}
+	return brokenSheet(err)
+}
+
+// brokenSheet - синтаксическая ошибка XML листа (в том числе оборванный посреди тега файл) - это ошибка
+// импорта, а не сбой сервера
+func brokenSheet(err error) error {
+	var syntax *xml.SyntaxError
+	if errors.As(err, &syntax) {
+		return fmt.Errorf("%w: битый XML листа: %s", errors.ErrInvalidImport, syntax.Msg)
+	}
+	return err
+}
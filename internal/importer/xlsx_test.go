@@ -0,0 +1,225 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testovoe_again/internal/errors"
+)
+
+func TestColumnIndex(t *testing.T) {
+	cases := []struct {
+		ref     string
+		want    int
+		wantErr bool
+	}{
+		{"A1", 0, false},
+		{"Z9", 25, false},
+		{"AA1", 26, false},
+		{"AB7", 27, false},
+		{"XFD1", MaxXLSXColumns - 1, false},
+		{"XFE1", 0, true},
+		{"XFDZZZZZ1", 0, true},
+		{"ZZZZZZZZZZZZZZZ1", 0, true},
+		{"1", -1, false},
+		{"", -1, false},
+		{"a1", -1, false},
+	}
+	for _, c := range cases {
+		got, err := columnIndex(c.ref)
+		if c.wantErr {
+			if !errors.Is(err, errors.ErrInvalidImport) {
+				t.Errorf("columnIndex(%q): ошибка %v, ожидалась ErrInvalidImport", c.ref, err)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("columnIndex(%q) = %d, %v, ожидалось %d", c.ref, got, err, c.want)
+		}
+	}
+}
+
+// buildXLSX собирает минимальную книгу: части по путям внутри zip
+func buildXLSX(t *testing.T, parts map[string]string) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip: %v", err)
+		}
+		if _, err := io.WriteString(w, body); err != nil {
+			t.Fatalf("zip: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip: %v", err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func sheet(rows string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?><worksheet><sheetData>` + rows + `</sheetData></worksheet>`
+}
+
+const sharedStrings = `<sst><si><t>service_name</t></si><si><r><t>Yandex </t></r><r><t>Plus</t></r></si>` +
+	`<si><t>Кино</t><rPh><t>кино</t></rPh></si></sst>`
+
+func readAll(t *testing.T, parts map[string]string) ([][]string, error) {
+	t.Helper()
+	file := buildXLSX(t, parts)
+	x, err := NewXLSXReader(file, file.Size())
+	if err != nil {
+		return nil, err
+	}
+	defer x.Close()
+
+	var rows [][]string
+	for {
+		row, err := x.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestXLSXReader(t *testing.T) {
+	cases := []struct {
+		name    string
+		parts   map[string]string
+		want    [][]string
+		wantErr bool
+	}{
+		{
+			name: "строки из таблицы, числа и inlineStr",
+			parts: map[string]string{
+				"xl/sharedStrings.xml":     sharedStrings,
+				"xl/worksheets/sheet1.xml": sheet(`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="inlineStr"><is><t>price</t></is></c></row>` + `<row r="2"><c r="A2" t="s"><v>1</v></c><c r="B2"><v>399</v></c></row>`),
+			},
+			want: [][]string{{"service_name", "price"}, {"Yandex Plus", "399"}},
+		},
+		{
+			name: "пропущенные ячейки и формула",
+			parts: map[string]string{
+				"xl/sharedStrings.xml":     sharedStrings,
+				"xl/worksheets/sheet1.xml": sheet(`<row><c r="C1" t="s"><v>2</v></c><c r="E1"><f>SUM(A1:B1)</f><v>42</v></c></row>`),
+			},
+			want: [][]string{{"", "", "Кино", "", "42"}},
+		},
+		{
+			name: "первый лист по workbook.xml",
+			parts: map[string]string{
+				"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+					`<sheets><sheet name="Данные" r:id="rId7"/></sheets></workbook>`,
+				"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId7" Target="worksheets/data.xml"/></Relationships>`,
+				"xl/worksheets/data.xml":     sheet(`<row><c r="A1" t="inlineStr"><is><t>ok</t></is></c></row>`),
+				"xl/worksheets/sheet1.xml":   sheet(`<row><c r="A1" t="inlineStr"><is><t>не тот лист</t></is></c></row>`),
+			},
+			want: [][]string{{"ok"}},
+		},
+		{
+			name:    "нет листов",
+			parts:   map[string]string{"xl/sharedStrings.xml": sharedStrings},
+			wantErr: true,
+		},
+		{
+			name:    "ссылка за пределы таблицы строк",
+			parts:   map[string]string{"xl/sharedStrings.xml": sharedStrings, "xl/worksheets/sheet1.xml": sheet(`<row><c r="A1" t="s"><v>3</v></c></row>`)},
+			wantErr: true,
+		},
+		{
+			name:    "колонка за пределом формата",
+			parts:   map[string]string{"xl/worksheets/sheet1.xml": sheet(`<row><c r="XFE1"><v>1</v></c></row>`)},
+			wantErr: true,
+		},
+		{
+			name:    "лист оборван посреди строки",
+			parts:   map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row><c r="A1"><v>1</v></c>`},
+			wantErr: true,
+		},
+		{
+			name:    "лист оборван между строками",
+			parts:   map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row><c r="A1"><v>1</v></c></row><ro`},
+			wantErr: true,
+		},
+		{
+			name:    "битая таблица строк",
+			parts:   map[string]string{"xl/sharedStrings.xml": `<sst><si><t>`, "xl/worksheets/sheet1.xml": sheet(``)},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		rows, err := readAll(t, c.parts)
+		if c.wantErr {
+			if !errors.Is(err, errors.ErrInvalidImport) {
+				t.Errorf("%s: ошибка %v, ожидалась ErrInvalidImport", c.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if len(rows) != len(c.want) {
+			t.Errorf("%s: %q, ожидалось %q", c.name, rows, c.want)
+			continue
+		}
+		for i := range c.want {
+			if strings.Join(rows[i], "|") != strings.Join(c.want[i], "|") {
+				t.Errorf("%s: строка %d %q, ожидалось %q", c.name, i, rows[i], c.want[i])
+			}
+		}
+	}
+}
+
+func TestXLSXReaderNotZip(t *testing.T) {
+	file := strings.NewReader("service_name,price\nNetflix,399\n")
+	if _, err := NewXLSXReader(file, file.Size()); !errors.Is(err, errors.ErrInvalidImport) {
+		t.Errorf("csv вместо xlsx: ошибка %v, ожидалась ErrInvalidImport", err)
+	}
+}
+
+func TestXLSXReaderColumnLimit(t *testing.T) {
+	// ячейки без адреса встают друг за другом, строка длиннее листа - ошибка, а не гигантский срез
+	row := "<row>" + strings.Repeat("<c><v>1</v></c>", MaxXLSXColumns+1) + "</row>"
+	_, err := readAll(t, map[string]string{"xl/worksheets/sheet1.xml": sheet(row)})
+	if !errors.Is(err, errors.ErrInvalidImport) {
+		t.Errorf("строка из %d ячеек: ошибка %v, ожидалась ErrInvalidImport", MaxXLSXColumns+1, err)
+	}
+}
+
+func TestLimitedReader(t *testing.T) {
+	cases := []struct {
+		size, limit int
+		wantErr     bool
+	}{
+		{size: 10, limit: 100},
+		{size: 100, limit: 100},
+		{size: 101, limit: 100, wantErr: true},
+		{size: 5 << 20, limit: 1 << 20, wantErr: true},
+	}
+	for _, c := range cases {
+		r := newLimitedReader(strings.NewReader(strings.Repeat("x", c.size)), int64(c.limit), "лист")
+		n, err := io.Copy(io.Discard, r)
+		if c.wantErr {
+			if !errors.Is(err, errors.ErrInvalidImport) {
+				t.Errorf("%d байт при лимите %d: ошибка %v, ожидалась ErrInvalidImport", c.size, c.limit, err)
+			}
+			if n > int64(c.limit) {
+				t.Errorf("%d байт при лимите %d: прочитано %d", c.size, c.limit, n)
+			}
+			continue
+		}
+		if err != nil || n != int64(c.size) {
+			t.Errorf("%d байт при лимите %d: прочитано %d, %v", c.size, c.limit, n, err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Пакет outbox - куда релей outbox отдаёт события: интерфейс публикатора и реализации в лог, в память и в файл.
+// Сам outbox и его чтение лежат в repository, т.к. ходят в базу
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"testovoe_again/internal/domain"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// EventPublisher публикует одно событие. Ошибка - событие не опубликовано, релей повторит его позже.
+// Доставка at-least-once: одно событие может прийти повторно, дубликаты отбрасываются по EventID
+type EventPublisher interface {
+	Publish(ctx context.Context, event domain.OutboxEvent) error
+}
+
+// LogPublisher пишет события в лог приложения
+type LogPublisher struct {
+	logger *zap.Logger
+}
+
+func NewLogPublisher(logger *zap.Logger) *LogPublisher {
+	return &LogPublisher{logger: logger}
+}
+
+func (p *LogPublisher) Publish(ctx context.Context, event domain.OutboxEvent) error {
+	p.logger.Info("событие подписки", zap.String("event_id", event.EventID.String()), zap.String("type", event.Type),
+		zap.Int("subscription_id", event.SubscriptionID), zap.ByteString("payload", event.Payload))
+	return nil
+}
+
+// MemoryPublisher держит последние capacity событий в памяти и сам отбрасывает повторы по EventID.
+// Для локального запуска и проверок: после рестарта события пропадают
+type MemoryPublisher struct {
+	capacity int
+
+	mu     sync.Mutex
+	events []domain.OutboxEvent
+	seen   map[uuid.UUID]struct{}
+}
+
+func NewMemoryPublisher(capacity int) *MemoryPublisher {
+	return &MemoryPublisher{capacity: capacity, seen: make(map[uuid.UUID]struct{})}
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, event domain.OutboxEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.seen[event.EventID]; ok {
+		return nil
+	}
+	p.events = append(p.events, event)
+	p.seen[event.EventID] = struct{}{}
+	if p.capacity > 0 && len(p.events) > p.capacity {
+		delete(p.seen, p.events[0].EventID)
+		p.events = p.events[1:]
+	}
+	return nil
+}
+
+// Events - копия накопленных событий от старых к новым
+func (p *MemoryPublisher) Events() []domain.OutboxEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]domain.OutboxEvent(nil), p.events...)
+}
+
+// FilePublisher дописывает события в файл по одному JSON на строку. Повторы не отбрасываются:
+// читатель файла дедуплицирует по event_id
+type FilePublisher struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFilePublisher(path string) (*FilePublisher, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть файл событий: %w", err)
+	}
+	return &FilePublisher{file: file}, nil
+}
+
+// Publish возвращает управление только после fsync: событие, помеченное в outbox опубликованным, уже на диске
+func (p *FilePublisher) Publish(ctx context.Context, event domain.OutboxEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w := bufio.NewWriter(p.file)
+	w.Write(line)
+	w.WriteByte('\n')
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return p.file.Sync()
+}
+
+func (p *FilePublisher) Close() error {
+	return p.file.Close()
+}
+
+// Fanout отдаёт событие всем публикаторам по очереди. Ошибка любого - событие повторится целиком,
+// поэтому публикаторы, которые уже его приняли, получат дубликат
+type Fanout []EventPublisher
+
+func (f Fanout) Publish(ctx context.Context, event domain.OutboxEvent) error {
+	for _, p := range f {
+		if err := p.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testovoe_again/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+func event(id uuid.UUID) domain.OutboxEvent {
+	return domain.OutboxEvent{EventID: id, Type: domain.EventSubscriptionCreated, SubscriptionID: 7, Payload: json.RawMessage(`{"id":7}`)}
+}
+
+func TestMemoryPublisher(t *testing.T) {
+	ids := make([]uuid.UUID, 5)
+	for i := range ids {
+		ids[i] = uuid.New()
+	}
+
+	cases := []struct {
+		name     string
+		capacity int
+		publish  []int // номера событий из ids по порядку публикации
+		want     []int
+	}{
+		{name: "по порядку", capacity: 10, publish: []int{0, 1, 2}, want: []int{0, 1, 2}},
+		{name: "повтор отбрасывается", capacity: 10, publish: []int{0, 1, 0, 2, 1, 1}, want: []int{0, 1, 2}},
+		{name: "остаются последние capacity", capacity: 3, publish: []int{0, 1, 2, 3, 4}, want: []int{2, 3, 4}},
+		{name: "повтор из окна при переполнении", capacity: 2, publish: []int{0, 1, 1, 2, 2}, want: []int{1, 2}},
+		{name: "без ограничения", capacity: 0, publish: []int{0, 1, 2, 3, 4, 4}, want: []int{0, 1, 2, 3, 4}},
+	}
+	for _, c := range cases {
+		p := NewMemoryPublisher(c.capacity)
+		for _, i := range c.publish {
+			if err := p.Publish(context.Background(), event(ids[i])); err != nil {
+				t.Fatalf("%s: %v", c.name, err)
+			}
+		}
+		got := p.Events()
+		if len(got) != len(c.want) {
+			t.Errorf("%s: %d событий, ожидалось %d", c.name, len(got), len(c.want))
+			continue
+		}
+		for i, want := range c.want {
+			if got[i].EventID != ids[want] {
+				t.Errorf("%s: событие %d - %s, ожидалось %s", c.name, i, got[i].EventID, ids[want])
+			}
+		}
+	}
+
+	// Events отдаёт копию
+	p := NewMemoryPublisher(10)
+	_ = p.Publish(context.Background(), event(ids[0]))
+	p.Events()[0].Type = "changed"
+	if p.Events()[0].Type != domain.EventSubscriptionCreated {
+		t.Errorf("Events отдаёт внутренний срез")
+	}
+}
+
+func TestFilePublisher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	p, err := NewFilePublisher(path)
+	if err != nil {
+		t.Fatalf("NewFilePublisher: %v", err)
+	}
+	first, second := uuid.New(), uuid.New()
+	// повторы файл не отбрасывает, это дело читателя
+	for _, id := range []uuid.UUID{first, second, first} {
+		if err := p.Publish(context.Background(), event(id)); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// файл дописывается, а не перезаписывается
+	p, err = NewFilePublisher(path)
+	if err != nil {
+		t.Fatalf("NewFilePublisher: %v", err)
+	}
+	_ = p.Publish(context.Background(), event(second))
+	_ = p.Close()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer file.Close()
+	var got []uuid.UUID
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var e domain.OutboxEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("строка %q: %v", scanner.Text(), err)
+		}
+		got = append(got, e.EventID)
+	}
+	want := []uuid.UUID{first, second, first, second}
+	if len(got) != len(want) {
+		t.Fatalf("%d строк, ожидалось %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("строка %d: %s, ожидалось %s", i, got[i], want[i])
+		}
+	}
+}
+
+type failingPublisher struct{ err error }
+
+func (p failingPublisher) Publish(context.Context, domain.OutboxEvent) error { return p.err }
+
+func TestFanout(t *testing.T) {
+	outage := stderrors.New("broker недоступен")
+	first, last := NewMemoryPublisher(10), NewMemoryPublisher(10)
+
+	cases := []struct {
+		name    string
+		fanout  Fanout
+		wantErr error
+		first   int
+		last    int
+	}{
+		{name: "все приняли", fanout: Fanout{first, last}, first: 1, last: 1},
+		{name: "ошибка останавливает раздачу", fanout: Fanout{first, failingPublisher{outage}, last}, wantErr: outage, first: 2, last: 1},
+	}
+	for _, c := range cases {
+		err := c.fanout.Publish(context.Background(), event(uuid.New()))
+		if !stderrors.Is(err, c.wantErr) {
+			t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, c.wantErr)
+		}
+		if len(first.Events()) != c.first || len(last.Events()) != c.last {
+			t.Errorf("%s: %d и %d событий, ожидалось %d и %d", c.name, len(first.Events()), len(last.Events()), c.first, c.last)
+		}
+	}
+}
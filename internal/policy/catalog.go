@@ -0,0 +1,48 @@
+package policy
+
+import (
+	"context"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/service"
+)
+
+// CatalogService - каталог сервисов под политикой доступа: читать может любой, менять - роли с операцией catalog
+type CatalogService struct {
+	next   service.CatalogService
+	policy *Store
+}
+
+var _ service.CatalogService = (*CatalogService)(nil)
+
+func NewCatalogService(next service.CatalogService, policy *Store) *CatalogService {
+	return &CatalogService{next: next, policy: policy}
+}
+
+func (s *CatalogService) Create(ctx context.Context, entry domain.CatalogEntry) (domain.CatalogEntry, error) {
+	if err := s.policy.Allow(ctx, domain.OpCatalog); err != nil {
+		return domain.CatalogEntry{}, err
+	}
+	return s.next.Create(ctx, entry)
+}
+
+func (s *CatalogService) Get(ctx context.Context, id int) (domain.CatalogEntry, error) {
+	return s.next.Get(ctx, id)
+}
+
+func (s *CatalogService) List(ctx context.Context) ([]domain.CatalogEntry, error) {
+	return s.next.List(ctx)
+}
+
+func (s *CatalogService) Update(ctx context.Context, entry domain.CatalogEntry) (domain.CatalogEntry, error) {
+	if err := s.policy.Allow(ctx, domain.OpCatalog); err != nil {
+		return domain.CatalogEntry{}, err
+	}
+	return s.next.Update(ctx, entry)
+}
+
+func (s *CatalogService) Delete(ctx context.Context, id int) error {
+	if err := s.policy.Allow(ctx, domain.OpCatalog); err != nil {
+		return err
+	}
+	return s.next.Delete(ctx, id)
+}
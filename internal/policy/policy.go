@@ -0,0 +1,172 @@
+// Пакет policy - RBAC поверх аутентификации: какие операции над подписками разрешены какой роли.
+// Политика читается из json-файла и перечитывается по SIGHUP без рестарта сервиса
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"go.uber.org/zap"
+)
+
+// Role - права одной роли. AllUsers - роль работает с подписками любых пользователей,
+// без него принципал с этой ролью видит только свои подписки
+type Role struct {
+	Operations []string `json:"operations"`
+	AllUsers   bool     `json:"all_users"`
+}
+
+// Policy - содержимое файла политики вида
+//
+//	{"default_role": "user", "roles": {"support": {"operations": ["read", "list"], "all_users": true}}}
+//
+// default_role достаётся принципалам, у которых в токене нет ролей
+type Policy struct {
+	DefaultRole string          `json:"default_role"`
+	Roles       map[string]Role `json:"roles"`
+}
+
+func (p *Policy) validate() error {
+	for name, role := range p.Roles {
+		for _, op := range role.Operations {
+			if !isOperation(op) {
+				return fmt.Errorf("у роли %s неизвестная операция %q", name, op)
+			}
+		}
+	}
+	if p.DefaultRole != "" {
+		if _, ok := p.Roles[p.DefaultRole]; !ok {
+			return fmt.Errorf("роль по умолчанию %s не описана в политике", p.DefaultRole)
+		}
+	}
+	return nil
+}
+
+func isOperation(op string) bool {
+	switch op {
+	case domain.OpCreate, domain.OpRead, domain.OpUpdate, domain.OpDelete, domain.OpList, domain.OpStats, domain.OpCatalog, domain.OpUsers, domain.OpWebhooks:
+		return true
+	}
+	return false
+}
+
+func Load(path string) (*Policy, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла политики: %w", err)
+	}
+
+	var p Policy
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга файла политики: %w", err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Store - текущая политика, которую можно подменить на лету
+type Store struct {
+	logger *zap.Logger
+	path   string
+
+	mu     sync.RWMutex
+	policy *Policy
+}
+
+func NewStore(logger *zap.Logger, path string) (*Store, error) {
+	p, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{logger: logger, path: path, policy: p}, nil
+}
+
+// Reload перечитывает файл. Если новый файл битый, остаётся старая политика - лучше так, чем открыть или закрыть всё разом
+func (s *Store) Reload() error {
+	p, err := Load(s.path)
+	if err != nil {
+		s.logger.Error("не удалось перечитать политику доступа, остаётся старая", zap.Error(err))
+		return err
+	}
+
+	s.mu.Lock()
+	s.policy = p
+	s.mu.Unlock()
+
+	s.logger.Info("политика доступа перечитана", zap.String("path", s.path), zap.Int("roles", len(p.Roles)))
+	return nil
+}
+
+// ReloadOn перечитывает политику на каждый сигнал из signals, пока жив ctx
+func (s *Store) ReloadOn(ctx context.Context, signals <-chan os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			_ = s.Reload()
+		}
+	}
+}
+
+func (s *Store) current() *Policy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.policy
+}
+
+// rolesOf - роли принципала: из токена, либо роль по умолчанию. Админский скоуп всегда даёт роль admin
+func (s *Store) rolesOf(p *Policy, principal domain.Principal) []string {
+	roles := principal.Roles
+	if len(roles) == 0 && p.DefaultRole != "" {
+		roles = []string{p.DefaultRole}
+	}
+	if principal.IsAdmin() {
+		roles = append([]string{domain.RoleAdmin}, roles...)
+	}
+	return roles
+}
+
+// AllUsers - есть ли среди ролей принципала роль с доступом к подпискам всех пользователей
+func (s *Store) AllUsers(principal domain.Principal) bool {
+	p := s.current()
+	for _, name := range s.rolesOf(p, principal) {
+		if p.Roles[name].AllUsers {
+			return true
+		}
+	}
+	return false
+}
+
+// Allow - разрешена ли операция op принципалу из ctx. Без принципала (аутентификация выключена,
+// фоновые задачи) проверять нечего. Отказ логируется с актором, чтобы попытки было видно
+func (s *Store) Allow(ctx context.Context, op string) error {
+	principal, ok := domain.PrincipalFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	p := s.current()
+	roles := s.rolesOf(p, principal)
+	for _, name := range roles {
+		for _, allowed := range p.Roles[name].Operations {
+			if allowed == op {
+				return nil
+			}
+		}
+	}
+
+	s.logger.Warn("операция запрещена политикой доступа",
+		zap.String("actor", domain.ActorFromContext(ctx)),
+		zap.Strings("roles", roles),
+		zap.String("operation", op),
+	)
+	return errors.Wrap(errors.ErrForbidden, fmt.Errorf("операция %s не разрешена", op))
+}
@@ -0,0 +1,171 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"testovoe_again/internal/service"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const testPolicy = `{
+  "default_role": "user",
+  "roles": {
+    "admin":     {"operations": ["create", "read", "update", "delete", "list", "stats", "catalog", "users", "webhooks"], "all_users": true},
+    "support":   {"operations": ["read", "list"], "all_users": true},
+    "read-only": {"operations": ["read", "list", "stats"]},
+    "user":      {"operations": ["create", "read", "update", "delete", "list", "stats"]}
+  }
+}`
+
+func writePolicy(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "policy.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("запись политики: %v", err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "конфиг из репозитория", content: testPolicy},
+		{name: "без роли по умолчанию", content: `{"roles": {"support": {"operations": ["read"]}}}`},
+		{name: "неизвестная операция", content: `{"roles": {"support": {"operations": ["read", "drop"]}}}`, wantErr: true},
+		{name: "роль по умолчанию не описана", content: `{"default_role": "guest", "roles": {}}`, wantErr: true},
+		{name: "не JSON", content: `roles: [support]`, wantErr: true},
+	}
+	for _, c := range cases {
+		_, err := Load(writePolicy(t, t.TempDir(), c.content))
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, c.wantErr)
+		}
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("несуществующий файл прочитан без ошибки")
+	}
+}
+
+func TestAllow(t *testing.T) {
+	store, err := NewStore(zap.NewNop(), writePolicy(t, t.TempDir(), testPolicy))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	cases := []struct {
+		name      string
+		principal *domain.Principal
+		op        string
+		allowed   bool
+		allUsers  bool
+	}{
+		{name: "без аутентификации", op: domain.OpDelete, allowed: true},
+		{name: "роль по умолчанию", principal: &domain.Principal{}, op: domain.OpCreate, allowed: true},
+		{name: "роль по умолчанию без каталога", principal: &domain.Principal{}, op: domain.OpCatalog},
+		{name: "support читает всех", principal: &domain.Principal{Roles: []string{"support"}}, op: domain.OpRead, allowed: true, allUsers: true},
+		{name: "support не меняет", principal: &domain.Principal{Roles: []string{"support"}}, op: domain.OpUpdate, allUsers: true},
+		{name: "роли складываются", principal: &domain.Principal{Roles: []string{"support", "read-only"}}, op: domain.OpStats, allowed: true, allUsers: true},
+		{name: "роль из токена заменяет роль по умолчанию", principal: &domain.Principal{Roles: []string{"read-only"}}, op: domain.OpCreate},
+		{name: "неизвестная роль", principal: &domain.Principal{Roles: []string{"intern"}}, op: domain.OpRead},
+		{name: "админский скоуп", principal: &domain.Principal{Scopes: []string{domain.ScopeAdmin}, Roles: []string{"read-only"}},
+			op: domain.OpWebhooks, allowed: true, allUsers: true},
+	}
+	for _, c := range cases {
+		ctx := context.Background()
+		if c.principal != nil {
+			ctx = domain.WithPrincipal(ctx, *c.principal)
+		}
+		err := store.Allow(ctx, c.op)
+		if c.allowed && err != nil {
+			t.Errorf("%s: %s запрещена: %v", c.name, c.op, err)
+		}
+		if !c.allowed && !errors.Is(err, errors.ErrForbidden) {
+			t.Errorf("%s: %s - ошибка %v, ожидалась ErrForbidden", c.name, c.op, err)
+		}
+		if c.principal != nil && store.AllUsers(*c.principal) != c.allUsers {
+			t.Errorf("%s: доступ ко всем пользователям %v, ожидался %v", c.name, !c.allUsers, c.allUsers)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	path := writePolicy(t, dir, testPolicy)
+	store, err := NewStore(zap.NewNop(), path)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	support := domain.WithPrincipal(context.Background(), domain.Principal{Roles: []string{"support"}})
+
+	writePolicy(t, dir, `{"roles": {"support": {"operations": ["read", "list", "update"]}}}`)
+	if err := store.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if err := store.Allow(support, domain.OpUpdate); err != nil {
+		t.Errorf("новая политика не применилась: %v", err)
+	}
+
+	// битый файл не сбрасывает политику
+	writePolicy(t, dir, `{"roles": {"support": {"operations": ["everything"]}}}`)
+	if err := store.Reload(); err == nil {
+		t.Errorf("битая политика перечитана без ошибки")
+	}
+	if err := store.Allow(support, domain.OpUpdate); err != nil {
+		t.Errorf("после битого файла осталась не прежняя политика: %v", err)
+	}
+}
+
+// countingService считает вызовы, которые прошли политику
+type countingService struct {
+	service.SubService
+	calls int
+}
+
+func (s *countingService) Delete(ctx context.Context, id int, version int) error {
+	s.calls++
+	return nil
+}
+
+func (s *countingService) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	s.calls++
+	return 0, nil
+}
+
+func TestServiceChecksPolicyBeforeCall(t *testing.T) {
+	store, err := NewStore(zap.NewNop(), writePolicy(t, t.TempDir(), testPolicy))
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+
+	cases := []struct {
+		name      string
+		principal domain.Principal
+		calls     int
+	}{
+		{name: "пользователь", principal: domain.Principal{}, calls: 2},
+		{name: "support", principal: domain.Principal{Roles: []string{"support"}}, calls: 0},
+	}
+	for _, c := range cases {
+		next := &countingService{}
+		svc := NewService(next, store)
+		ctx := domain.WithPrincipal(context.Background(), c.principal)
+		errDelete := svc.Delete(ctx, 1, 0)
+		_, errPurge := svc.PurgeDeleted(ctx, time.Hour)
+
+		if next.calls != c.calls {
+			t.Errorf("%s: до сервиса дошло %d вызовов, ожидалось %d", c.name, next.calls, c.calls)
+		}
+		if c.calls == 0 && (!errors.Is(errDelete, errors.ErrForbidden) || !errors.Is(errPurge, errors.ErrForbidden)) {
+			t.Errorf("%s: ошибки %v, %v, ожидалась ErrForbidden", c.name, errDelete, errPurge)
+		}
+	}
+}
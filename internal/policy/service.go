@@ -0,0 +1,177 @@
+package policy
+
+import (
+	"context"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/importer"
+	"testovoe_again/internal/service"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Service - SubService, который перед каждым вызовом проверяет, разрешена ли операция ролям принципала.
+// Чьи именно подписки трогает запрос, проверяют хендлеры, здесь только что с ними можно делать
+type Service struct {
+	next   service.SubService
+	policy *Store
+}
+
+var _ service.SubService = (*Service)(nil)
+
+func NewService(next service.SubService, policy *Store) *Service {
+	return &Service{next: next, policy: policy}
+}
+
+func (s *Service) Create(ctx context.Context, sub domain.Subscription) (domain.Subscription, error) {
+	if err := s.policy.Allow(ctx, domain.OpCreate); err != nil {
+		return domain.Subscription{}, err
+	}
+	return s.next.Create(ctx, sub)
+}
+
+func (s *Service) Read(ctx context.Context, id int) (domain.Subscription, error) {
+	if err := s.policy.Allow(ctx, domain.OpRead); err != nil {
+		return domain.Subscription{}, err
+	}
+	return s.next.Read(ctx, id)
+}
+
+func (s *Service) Update(ctx context.Context, sub domain.Subscription) (domain.Subscription, error) {
+	if err := s.policy.Allow(ctx, domain.OpUpdate); err != nil {
+		return domain.Subscription{}, err
+	}
+	return s.next.Update(ctx, sub)
+}
+
+func (s *Service) Patch(ctx context.Context, id int, patch domain.SubscriptionPatch, version int) (domain.Subscription, error) {
+	if err := s.policy.Allow(ctx, domain.OpUpdate); err != nil {
+		return domain.Subscription{}, err
+	}
+	return s.next.Patch(ctx, id, patch, version)
+}
+
+func (s *Service) Delete(ctx context.Context, id int, version int) error {
+	if err := s.policy.Allow(ctx, domain.OpDelete); err != nil {
+		return err
+	}
+	return s.next.Delete(ctx, id, version)
+}
+
+// Restore отменяет удаление, поэтому требует того же права, что и Delete
+func (s *Service) Restore(ctx context.Context, id int) (domain.Subscription, error) {
+	if err := s.policy.Allow(ctx, domain.OpDelete); err != nil {
+		return domain.Subscription{}, err
+	}
+	return s.next.Restore(ctx, id)
+}
+
+// ChangeStatus - pause, resume, cancel и renew меняют подписку, поэтому под правом Update
+func (s *Service) ChangeStatus(ctx context.Context, id int, action string, version int) (domain.Subscription, error) {
+	if err := s.policy.Allow(ctx, domain.OpUpdate); err != nil {
+		return domain.Subscription{}, err
+	}
+	return s.next.ChangeStatus(ctx, id, action, version)
+}
+
+func (s *Service) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	if err := s.policy.Allow(ctx, domain.OpDelete); err != nil {
+		return 0, err
+	}
+	return s.next.PurgeDeleted(ctx, retention)
+}
+
+func (s *Service) History(ctx context.Context, id int) ([]domain.AuditEntry, error) {
+	if err := s.policy.Allow(ctx, domain.OpRead); err != nil {
+		return nil, err
+	}
+	return s.next.History(ctx, id)
+}
+
+// Batch проверяет каждый вид операции в пакете, пакет с одним запрещённым delete целиком отклоняется
+func (s *Service) Batch(ctx context.Context, ops []domain.BatchOp, atomic bool) ([]domain.BatchResult, error) {
+	checked := make(map[string]bool)
+	for _, op := range ops {
+		if checked[op.Op] {
+			continue
+		}
+		checked[op.Op] = true
+		if err := s.policy.Allow(ctx, batchOperation(op.Op)); err != nil {
+			return nil, err
+		}
+	}
+	return s.next.Batch(ctx, ops, atomic)
+}
+
+func batchOperation(op string) string {
+	switch op {
+	case domain.BatchUpdate:
+		return domain.OpUpdate
+	case domain.BatchDelete:
+		return domain.OpDelete
+	}
+	return domain.OpCreate
+}
+
+func (s *Service) Import(ctx context.Context, rows importer.Reader, mapping importer.Mapping, dryRun bool) (domain.ImportReport, error) {
+	if err := s.policy.Allow(ctx, domain.OpCreate); err != nil {
+		return domain.ImportReport{}, err
+	}
+	return s.next.Import(ctx, rows, mapping, dryRun)
+}
+
+func (s *Service) GetListByUserID(ctx context.Context, userID uuid.UUID) ([]domain.Subscription, error) {
+	if err := s.policy.Allow(ctx, domain.OpList); err != nil {
+		return nil, err
+	}
+	return s.next.GetListByUserID(ctx, userID)
+}
+
+func (s *Service) List(ctx context.Context, filter domain.ListFilter) (domain.SubscriptionPage, error) {
+	if err := s.policy.Allow(ctx, domain.OpList); err != nil {
+		return domain.SubscriptionPage{}, err
+	}
+	return s.next.List(ctx, filter)
+}
+
+func (s *Service) Export(ctx context.Context, filter domain.ListFilter, fn func(domain.Subscription) error) error {
+	if err := s.policy.Allow(ctx, domain.OpList); err != nil {
+		return err
+	}
+	return s.next.Export(ctx, filter, fn)
+}
+
+func (s *Service) Conflicts(ctx context.Context, userID *uuid.UUID) ([]domain.Conflict, error) {
+	if err := s.policy.Allow(ctx, domain.OpList); err != nil {
+		return nil, err
+	}
+	return s.next.Conflicts(ctx, userID)
+}
+
+func (s *Service) CalculateTotal(ctx context.Context, userID uuid.UUID, serviceName string, firstDate, lastDate string, currency string) (int64, error) {
+	if err := s.policy.Allow(ctx, domain.OpStats); err != nil {
+		return 0, err
+	}
+	return s.next.CalculateTotal(ctx, userID, serviceName, firstDate, lastDate, currency)
+}
+
+func (s *Service) MonthlySpend(ctx context.Context, filter domain.AnalyticsFilter) ([]domain.SpendRow, error) {
+	if err := s.policy.Allow(ctx, domain.OpStats); err != nil {
+		return nil, err
+	}
+	return s.next.MonthlySpend(ctx, filter)
+}
+
+func (s *Service) SpendByService(ctx context.Context, filter domain.AnalyticsFilter) ([]domain.SpendRow, error) {
+	if err := s.policy.Allow(ctx, domain.OpStats); err != nil {
+		return nil, err
+	}
+	return s.next.SpendByService(ctx, filter)
+}
+
+func (s *Service) TopServices(ctx context.Context, filter domain.AnalyticsFilter) ([]domain.SpendRow, error) {
+	if err := s.policy.Allow(ctx, domain.OpStats); err != nil {
+		return nil, err
+	}
+	return s.next.TopServices(ctx, filter)
+}
@@ -0,0 +1,51 @@
+package policy
+
+import (
+	"context"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// UserService - справочник пользователей под политикой доступа. Чтение ограничивает хендлер по владельцу,
+// как и для подписок, а заводить, менять и удалять пользователей могут только роли с операцией users
+type UserService struct {
+	next   service.UserService
+	policy *Store
+}
+
+var _ service.UserService = (*UserService)(nil)
+
+func NewUserService(next service.UserService, policy *Store) *UserService {
+	return &UserService{next: next, policy: policy}
+}
+
+func (s *UserService) Create(ctx context.Context, user domain.User) (domain.User, error) {
+	if err := s.policy.Allow(ctx, domain.OpUsers); err != nil {
+		return domain.User{}, err
+	}
+	return s.next.Create(ctx, user)
+}
+
+func (s *UserService) Get(ctx context.Context, id uuid.UUID) (domain.User, error) {
+	return s.next.Get(ctx, id)
+}
+
+func (s *UserService) List(ctx context.Context, after *uuid.UUID, limit int) (domain.UserPage, error) {
+	return s.next.List(ctx, after, limit)
+}
+
+func (s *UserService) Update(ctx context.Context, user domain.User) (domain.User, error) {
+	if err := s.policy.Allow(ctx, domain.OpUsers); err != nil {
+		return domain.User{}, err
+	}
+	return s.next.Update(ctx, user)
+}
+
+func (s *UserService) Delete(ctx context.Context, id uuid.UUID) error {
+	if err := s.policy.Allow(ctx, domain.OpUsers); err != nil {
+		return err
+	}
+	return s.next.Delete(ctx, id)
+}
@@ -0,0 +1,68 @@
+package policy
+
+import (
+	"context"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/service"
+)
+
+// WebhookService - вебхуки под политикой доступа: адреса и доставки видны только ролям с операцией webhooks
+type WebhookService struct {
+	next   service.WebhookService
+	policy *Store
+}
+
+var _ service.WebhookService = (*WebhookService)(nil)
+
+func NewWebhookService(next service.WebhookService, policy *Store) *WebhookService {
+	return &WebhookService{next: next, policy: policy}
+}
+
+func (s *WebhookService) Create(ctx context.Context, hook domain.Webhook) (domain.Webhook, error) {
+	if err := s.policy.Allow(ctx, domain.OpWebhooks); err != nil {
+		return domain.Webhook{}, err
+	}
+	return s.next.Create(ctx, hook)
+}
+
+func (s *WebhookService) Get(ctx context.Context, id int) (domain.Webhook, error) {
+	if err := s.policy.Allow(ctx, domain.OpWebhooks); err != nil {
+		return domain.Webhook{}, err
+	}
+	return s.next.Get(ctx, id)
+}
+
+func (s *WebhookService) List(ctx context.Context) ([]domain.Webhook, error) {
+	if err := s.policy.Allow(ctx, domain.OpWebhooks); err != nil {
+		return nil, err
+	}
+	return s.next.List(ctx)
+}
+
+func (s *WebhookService) Update(ctx context.Context, hook domain.Webhook) (domain.Webhook, error) {
+	if err := s.policy.Allow(ctx, domain.OpWebhooks); err != nil {
+		return domain.Webhook{}, err
+	}
+	return s.next.Update(ctx, hook)
+}
+
+func (s *WebhookService) Delete(ctx context.Context, id int) error {
+	if err := s.policy.Allow(ctx, domain.OpWebhooks); err != nil {
+		return err
+	}
+	return s.next.Delete(ctx, id)
+}
+
+func (s *WebhookService) DeadLetters(ctx context.Context, webhookID *int, before *int64, limit int) (domain.DeliveryPage, error) {
+	if err := s.policy.Allow(ctx, domain.OpWebhooks); err != nil {
+		return domain.DeliveryPage{}, err
+	}
+	return s.next.DeadLetters(ctx, webhookID, before, limit)
+}
+
+func (s *WebhookService) Replay(ctx context.Context, id int64) (domain.WebhookDelivery, error) {
+	if err := s.policy.Allow(ctx, domain.OpWebhooks); err != nil {
+		return domain.WebhookDelivery{}, err
+	}
+	return s.next.Replay(ctx, id)
+}
@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore - бакеты в памяти процесса. Лимит считается на каждый инстанс отдельно,
+// при нескольких репликах нужен postgres
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+
+	level := refill(b.tokens, now.Sub(b.updated), limit)
+	allowed := level >= 1
+	// при отказе бакет не трогаем, как и в postgres - пополнение дальше считается от прошлого изменения
+	if allowed {
+		b.tokens = level - 1
+		b.updated = now
+	}
+	return NewResult(level, allowed, limit), nil
+}
+
+func (s *MemoryStore) Sweep(_ context.Context, idle time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed int64
+	threshold := s.now().Add(-idle)
+	for key, b := range s.buckets {
+		if b.updated.Before(threshold) {
+			delete(s.buckets, key)
+			removed++
+		}
+	}
+	return removed, nil
+}
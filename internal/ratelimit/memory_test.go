@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	start := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	limit := Limit{Rate: 1, Burst: 3}
+
+	// шаги идут по одному бакету, at - смещение от start
+	cases := []struct {
+		at        time.Duration
+		key       string
+		allowed   bool
+		remaining int
+	}{
+		{at: 0, key: "a", allowed: true, remaining: 2},
+		{at: 0, key: "a", allowed: true, remaining: 1},
+		{at: 0, key: "a", allowed: true, remaining: 0},
+		{at: 0, key: "a", allowed: false, remaining: 0},
+		// у другого клиента свой бакет
+		{at: 0, key: "b", allowed: true, remaining: 2},
+		// отказ не сдвигает пополнение: через полсекунды после последнего списания токена ещё нет
+		{at: 500 * time.Millisecond, key: "a", allowed: false, remaining: 0},
+		{at: time.Second, key: "a", allowed: true, remaining: 0},
+		// за простой бакет наполняется только до Burst
+		{at: time.Hour, key: "a", allowed: true, remaining: 2},
+	}
+
+	store := NewMemoryStore()
+	for i, c := range cases {
+		store.now = func() time.Time { return start.Add(c.at) }
+		res, err := store.Take(context.Background(), c.key, limit)
+		if err != nil {
+			t.Fatalf("шаг %d: %v", i, err)
+		}
+		if res.Allowed != c.allowed || res.Remaining != c.remaining {
+			t.Errorf("шаг %d (%s через %v): allowed %v remaining %d, ожидалось %v %d", i, c.key, c.at, res.Allowed, res.Remaining, c.allowed, c.remaining)
+		}
+		if !res.Allowed && res.RetryAfter <= 0 {
+			t.Errorf("шаг %d: отказ без Retry-After", i)
+		}
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	start := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	limit := Limit{Rate: 1, Burst: 3}
+
+	store.now = func() time.Time { return start }
+	_, _ = store.Take(context.Background(), "old", limit)
+	store.now = func() time.Time { return start.Add(time.Minute) }
+	_, _ = store.Take(context.Background(), "fresh", limit)
+
+	store.now = func() time.Time { return start.Add(90 * time.Second) }
+	removed, err := store.Sweep(context.Background(), time.Minute)
+	if err != nil || removed != 1 {
+		t.Fatalf("Sweep = %d, %v, ожидался 1 удалённый бакет", removed, err)
+	}
+	if _, ok := store.buckets["fresh"]; !ok {
+		t.Errorf("удалён бакет, который трогали меньше idle назад")
+	}
+}
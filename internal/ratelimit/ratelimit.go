@@ -0,0 +1,114 @@
+// Пакет ratelimit - token bucket для ограничения частоты запросов. Хранилище бакетов подключаемое:
+// в памяти процесса для одного инстанса и в postgres (repository.RateLimitRepo), чтобы лимит держался на все реплики
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit - Rate токенов в секунду, Burst - ёмкость бакета, столько запросов можно сделать разом после простоя
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// FillTime - за сколько пустой бакет наполняется целиком
+func (l Limit) FillTime() time.Duration {
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+// Result - итог одной попытки взять токен. Remaining - сколько запросов ещё можно сделать прямо сейчас,
+// RetryAfter - через сколько появится следующий токен (только при отказе), Reset - через сколько бакет снова полон
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+// Store - хранилище бакетов. Take атомарно пополняет бакет key за прошедшее время и пытается забрать токен.
+// Sweep удаляет бакеты, которых не трогали дольше idle - за это время они всё равно наполнились бы целиком
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	Sweep(ctx context.Context, idle time.Duration) (int64, error)
+}
+
+// NewResult собирает Result по уровню бакета level (после пополнения, до списания) - общая арифметика для всех хранилищ
+func NewResult(level float64, allowed bool, limit Limit) Result {
+	r := Result{Allowed: allowed, Limit: limit.Burst}
+
+	after := level
+	if allowed {
+		after--
+	}
+	r.Remaining = int(math.Max(0, math.Floor(after)))
+	r.Reset = seconds((float64(limit.Burst) - after) / limit.Rate)
+	if !allowed {
+		r.RetryAfter = seconds((1 - level) / limit.Rate)
+	}
+	return r
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Max(0, s) * float64(time.Second))
+}
+
+// refill - уровень бакета через elapsed после последнего изменения
+func refill(tokens float64, elapsed time.Duration, limit Limit) float64 {
+	return math.Min(float64(limit.Burst), tokens+elapsed.Seconds()*limit.Rate)
+}
+
+// ParseLimit разбирает лимит вида 30/m или 10/s:20 - число запросов за секунду, минуту или час
+// и необязательная ёмкость бакета, по умолчанию равная числу запросов
+func ParseLimit(raw string) (Limit, error) {
+	spec, burstRaw, hasBurst := strings.Cut(strings.TrimSpace(raw), ":")
+	countRaw, unit, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("лимит %q должен быть в формате N/s, N/m или N/h", raw)
+	}
+
+	count, err := strconv.Atoi(countRaw)
+	if err != nil || count <= 0 {
+		return Limit{}, fmt.Errorf("лимит %q: число запросов должно быть положительным", raw)
+	}
+
+	var per time.Duration
+	switch unit {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("лимит %q: неизвестная единица %q", raw, unit)
+	}
+
+	limit := Limit{Rate: float64(count) / per.Seconds(), Burst: count}
+	if hasBurst {
+		limit.Burst, err = strconv.Atoi(burstRaw)
+		if err != nil || limit.Burst <= 0 {
+			return Limit{}, fmt.Errorf("лимит %q: ёмкость должна быть положительной", raw)
+		}
+	}
+	return limit, nil
+}
+
+// ParseLimits разбирает лимиты по группам маршрутов
+func ParseLimits(raw map[string]string) (map[string]Limit, error) {
+	limits := make(map[string]Limit, len(raw))
+	for group, spec := range raw {
+		limit, err := ParseLimit(spec)
+		if err != nil {
+			return nil, fmt.Errorf("группа %s: %w", group, err)
+		}
+		limits[strings.TrimSpace(group)] = limit
+	}
+	return limits, nil
+}
@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	cases := []struct {
+		raw     string
+		want    Limit
+		wantErr bool
+	}{
+		{raw: "10/s", want: Limit{Rate: 10, Burst: 10}},
+		{raw: "30/m", want: Limit{Rate: 0.5, Burst: 30}},
+		{raw: " 3600/h ", want: Limit{Rate: 1, Burst: 3600}},
+		{raw: "10/s:20", want: Limit{Rate: 10, Burst: 20}},
+		{raw: "60/m:1", want: Limit{Rate: 1, Burst: 1}},
+		{raw: "10", wantErr: true},
+		{raw: "", wantErr: true},
+		{raw: "0/s", wantErr: true},
+		{raw: "-5/m", wantErr: true},
+		{raw: "ten/s", wantErr: true},
+		{raw: "10/d", wantErr: true},
+		{raw: "10/s:0", wantErr: true},
+		{raw: "10/s:x", wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := ParseLimit(c.raw)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("ParseLimit(%q) = %+v, ожидалась ошибка", c.raw, got)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("ParseLimit(%q) = %+v, %v, ожидалось %+v", c.raw, got, err, c.want)
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits(map[string]string{"default": "60/m", " export ": "2/m:1"})
+	if err != nil {
+		t.Fatalf("ParseLimits: %v", err)
+	}
+	if limits["export"] != (Limit{Rate: 2.0 / 60, Burst: 1}) || limits["default"].Burst != 60 {
+		t.Errorf("лимиты %+v", limits)
+	}
+	if _, err := ParseLimits(map[string]string{"default": "60/m", "import": "often"}); err == nil {
+		t.Errorf("битый лимит группы разобран без ошибки")
+	}
+}
+
+func TestNewResult(t *testing.T) {
+	limit := Limit{Rate: 2, Burst: 10}
+	cases := []struct {
+		name    string
+		level   float64
+		allowed bool
+		want    Result
+	}{
+		{name: "полный бакет", level: 10, allowed: true,
+			want: Result{Allowed: true, Limit: 10, Remaining: 9, Reset: 500 * time.Millisecond}},
+		{name: "последний токен", level: 1, allowed: true,
+			want: Result{Allowed: true, Limit: 10, Remaining: 0, Reset: 5 * time.Second}},
+		{name: "дробный остаток округляется вниз", level: 3.75, allowed: true,
+			want: Result{Allowed: true, Limit: 10, Remaining: 2, Reset: 3625 * time.Millisecond}},
+		{name: "отказ", level: 0.5, allowed: false,
+			want: Result{Limit: 10, Remaining: 0, RetryAfter: 250 * time.Millisecond, Reset: 4750 * time.Millisecond}},
+		{name: "отказ на пустом бакете", level: 0, allowed: false,
+			want: Result{Limit: 10, Remaining: 0, RetryAfter: 500 * time.Millisecond, Reset: 5 * time.Second}},
+	}
+	for _, c := range cases {
+		if got := NewResult(c.level, c.allowed, limit); got != c.want {
+			t.Errorf("%s: %+v, ожидалось %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestFillTime(t *testing.T) {
+	if got := (Limit{Rate: 0.5, Burst: 30}).FillTime(); got != time.Minute {
+		t.Errorf("FillTime = %v, ожидалась минута", got)
+	}
+}
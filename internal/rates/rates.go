@@ -0,0 +1,83 @@
+// Пакет rates отвечает за курсы валют: интерфейс провайдера курсов и реализация поверх json-файла.
+// Реализация поверх таблицы exchange_rates лежит в repository, т.к. ходит в базу
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"testovoe_again/internal/errors"
+)
+
+// Provider отдаёт курс пересчёта: сколько единиц валюты to стоит одна единица валюты from
+type Provider interface {
+	Rate(ctx context.Context, from, to string) (float64, error)
+}
+
+// Convert пересчитывает сумму в минорных единицах из одной валюты в другую с банковским округлением до копейки
+func Convert(ctx context.Context, p Provider, amount int64, from, to string) (int64, error) {
+	if from == to || amount == 0 {
+		return amount, nil
+	}
+	rate, err := p.Rate(ctx, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return int64(math.RoundToEven(float64(amount) * rate)), nil
+}
+
+// FileProvider - курсы из json-файла вида
+//
+//	{"base": "RUB", "rates": {"RUB": 1, "USD": 81.5, "EUR": 92.3}}
+//
+// где rates[X] - стоимость одной единицы X в базовой валюте
+type FileProvider struct {
+	base  string
+	rates map[string]float64
+}
+
+type ratesFile struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+func NewFileProvider(path string) (*FileProvider, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла курсов: %w", err)
+	}
+
+	var f ratesFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга файла курсов: %w", err)
+	}
+	if f.Base == "" {
+		return nil, fmt.Errorf("в файле курсов не указана базовая валюта")
+	}
+	if f.Rates == nil {
+		f.Rates = map[string]float64{}
+	}
+	f.Rates[f.Base] = 1
+
+	for currency, rate := range f.Rates {
+		if rate <= 0 {
+			return nil, fmt.Errorf("невалидный курс %s: %v", currency, rate)
+		}
+	}
+
+	return &FileProvider{base: f.Base, rates: f.Rates}, nil
+}
+
+func (p *FileProvider) Rate(_ context.Context, from, to string) (float64, error) {
+	fromRate, ok := p.rates[from]
+	if !ok {
+		return 0, errors.ErrUnknownRate
+	}
+	toRate, ok := p.rates[to]
+	if !ok {
+		return 0, errors.ErrUnknownRate
+	}
+	return fromRate / toRate, nil
+}
@@ -0,0 +1,83 @@
+package rates
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testovoe_again/internal/errors"
+)
+
+func writeRates(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatalf("запись файла курсов: %v", err)
+	}
+	return path
+}
+
+func TestConvert(t *testing.T) {
+	p, err := NewFileProvider(writeRates(t, `{"base": "RUB", "rates": {"USD": 80, "EUR": 100}}`))
+	if err != nil {
+		t.Fatalf("NewFileProvider: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		amount   int64
+		from, to string
+		want     int64
+		wantErr  error
+	}{
+		{name: "та же валюта", amount: 39900, from: "RUB", to: "RUB", want: 39900},
+		{name: "ноль не пересчитывается", amount: 0, from: "GBP", to: "RUB", want: 0},
+		{name: "доллары в рубли", amount: 1000, from: "USD", to: "RUB", want: 80000},
+		{name: "рубли в евро", amount: 39900, from: "RUB", to: "EUR", want: 399},
+		{name: "кросс-курс", amount: 100, from: "EUR", to: "USD", want: 125},
+		// 50 копеек это 0.625 цента, 40 - ровно 0.5, 120 - ровно 1.5: банковское округление
+		{name: "округление до ближайшего", amount: 50, from: "RUB", to: "USD", want: 1},
+		{name: "половина к чётному вниз", amount: 40, from: "RUB", to: "USD", want: 0},
+		{name: "половина к чётному вверх", amount: 120, from: "RUB", to: "USD", want: 2},
+		{name: "неизвестная валюта", amount: 100, from: "GBP", to: "RUB", wantErr: errors.ErrUnknownRate},
+		{name: "неизвестная целевая валюта", amount: 100, from: "RUB", to: "GBP", wantErr: errors.ErrUnknownRate},
+	}
+
+	for _, c := range cases {
+		got, err := Convert(context.Background(), p, c.amount, c.from, c.to)
+		if c.wantErr != nil {
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, c.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("%s: %d, %v, ожидалось %d", c.name, got, err, c.want)
+		}
+	}
+}
+
+func TestNewFileProvider(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		ok   bool
+	}{
+		{"базовая валюта без курса в rates", `{"base": "RUB", "rates": {"USD": 80}}`, true},
+		{"без rates", `{"base": "RUB"}`, true},
+		{"без базовой валюты", `{"rates": {"USD": 80}}`, false},
+		{"нулевой курс", `{"base": "RUB", "rates": {"USD": 0}}`, false},
+		{"отрицательный курс", `{"base": "RUB", "rates": {"USD": -1}}`, false},
+		{"не json", `base: RUB`, false},
+	}
+	for _, c := range cases {
+		_, err := NewFileProvider(writeRates(t, c.body))
+		if c.ok != (err == nil) {
+			t.Errorf("%s: ошибка %v", c.name, err)
+		}
+	}
+
+	if _, err := NewFileProvider(filepath.Join(t.TempDir(), "нет.json")); err == nil {
+		t.Errorf("нет файла курсов - ожидалась ошибка")
+	}
+}
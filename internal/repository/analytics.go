@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testovoe_again/internal/domain"
+
+	"go.uber.org/zap"
+)
+
+// Вся аналитика и CalculateTotal считаются по одной модели. Каждая подписка разворачивается в расчётные периоды
+// длиной billingStep, начиная со start_date. Период, начавшийся не позже end_date, оплачен целиком.
+// В отчёт идёт доля цены периода, пропорциональная числу дней пересечения периода с окном отчёта
+// [$1, $2) - так годовой план в полугодовом отчёте даёт половину цены, а не ноль или целый год.
+// Для помесячных подписок с датами по первым числам это ровно price * число месяцев пересечения.
+// Дни, когда подписка стояла на паузе (subscription_pauses), из пересечения вычитаются.
+
+// billingStep - длина расчётного периода подписки s
+const billingStep = `CASE s.billing_period
+			WHEN 'weekly' THEN interval '7 days'
+			WHEN 'quarterly' THEN interval '3 months'
+			WHEN 'yearly' THEN interval '1 year'
+			WHEN 'custom' THEN make_interval(days => s.billing_period_days)
+			ELSE interval '1 month'
+		END`
+
+// serviceNameExpr - название сервиса подписки в отчётах: каноническое из каталога, если подписка к нему привязана,
+// так что подписки на "Yandex Plus" и "yandex plus" считаются одним сервисом
+const serviceNameExpr = `COALESCE(cat.name, s.service_name)`
+
+// billingSteps - сколько целых расчётных периодов подписки s укладывается между start_date и датой to, 0 до start_date.
+// Месяцы считаются через age: граница периода - start_date плюс целое число шагов, так что на концах месяцев
+// результат может оказаться на один шаг меньше, но не больше
+func billingSteps(to string) string {
+	return `CASE WHEN ` + to + ` <= s.start_date THEN 0 ELSE CASE s.billing_period
+			WHEN 'weekly' THEN (` + to + ` - s.start_date) / 7
+			WHEN 'custom' THEN (` + to + ` - s.start_date) / s.billing_period_days
+			ELSE (EXTRACT(YEAR FROM age(` + to + `, s.start_date)) * 12 + EXTRACT(MONTH FROM age(` + to + `, s.start_date)))::int
+				/ CASE s.billing_period WHEN 'quarterly' THEN 3 WHEN 'yearly' THEN 12 ELSE 1 END
+		END END`
+}
+
+// chargesCTE - CTE charges: строка на каждый расчётный период каждой подписки, задевающий окно [$1, $2).
+// Периоды не перебираются от start_date: n-й период начинается в start_date + n шагов, и ряд n идёт
+// с последней границы не позже $1 до последней границы не позже конца подписки в окне - иначе
+// давняя еженедельная подписка разворачивалась бы в тысячи строк ради одного месяца отчёта.
+// cond - дополнительные условия на s, собранные analyticsConditions
+func chargesCTE(cond string) string {
+	// последний день, в который ещё может начаться оплачиваемый период
+	last := `LEAST(COALESCE(s.end_date, $2::date - 1), $2::date - 1)`
+	return `charges AS (
+		SELECT s.id AS subscription_id, ` + serviceNameExpr + ` AS service_name, s.currency, s.price,
+		       p.period_start, p.period_end
+		FROM subscriptions s
+		LEFT JOIN services cat ON cat.id = s.service_id
+		CROSS JOIN LATERAL generate_series(` + billingSteps("$1::date") + `, ` + billingSteps(last) + ` + 1) AS steps(n)
+		CROSS JOIN LATERAL (
+			SELECT (s.start_date + n * ` + billingStep + `)::date AS period_start,
+			       (s.start_date + (n + 1) * ` + billingStep + `)::date AS period_end
+		) AS p
+		WHERE s.deleted_at IS NULL
+		  AND s.start_date < $2::date
+		  AND p.period_start <= ` + last + `
+		  AND p.period_end > $1::date` + cond + `
+	)`
+}
+
+// chargeAmount - часть цены периода c, приходящаяся на окно [lo, hi), без дней на паузе
+func chargeAmount(lo, hi string) string {
+	return fmt.Sprintf(`c.price::numeric
+			* GREATEST(0, LEAST(c.period_end, %s) - GREATEST(c.period_start, %s) - %s)
+			/ (c.period_end - c.period_start)`, hi, lo, pausedDays(lo, hi))
+}
+
+// pausedDays - сколько дней периода c внутри окна [lo, hi) подписка стояла на паузе.
+// Паузы одной подписки не пересекаются, поэтому их можно просто сложить
+func pausedDays(lo, hi string) string {
+	return fmt.Sprintf(`COALESCE((
+				SELECT SUM(GREATEST(0,
+					LEAST(COALESCE(ps.resumed_on, 'infinity'::date), c.period_end, %s)
+					- GREATEST(ps.paused_on, c.period_start, %s)))
+				FROM subscription_pauses ps
+				WHERE ps.subscription_id = c.subscription_id), 0)`, hi, lo)
+}
+
+// SpendByCurrency - общая сумма за окно в разрезе валют, на ней построен GetStatsByServiceName
+func (r *PostgresRepo) SpendByCurrency(ctx context.Context, filter domain.AnalyticsFilter) (map[string]int64, error) {
+	args := []any{filter.From, filter.To}
+	cond := analyticsConditions(filter, &args)
+
+	query := fmt.Sprintf(`
+		WITH %s
+		SELECT c.currency, ROUND(SUM(%s))::bigint
+		FROM charges c
+		GROUP BY c.currency`, chargesCTE(cond), chargeAmount("$1::date", "$2::date"))
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.Error("ошибка получения статистики", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]int64)
+	for rows.Next() {
+		var (
+			currency string
+			total    int64
+		)
+		if err := rows.Scan(&currency, &total); err != nil {
+			r.logger.Error("ошибка скана строки статистики", zap.Error(err))
+			return nil, err
+		}
+		result[currency] = total
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("ошибка итерации по строке", zap.Error(err))
+		return nil, err
+	}
+	return result, nil
+}
+
+// SpendByMonth отдаёт траты по каждому календарному месяцу окна в разрезе валют подписок,
+// месяцы без подписок тоже попадают в выдачу с нулём и пустой валютой
+func (r *PostgresRepo) SpendByMonth(ctx context.Context, filter domain.AnalyticsFilter) ([]domain.SpendRow, error) {
+	args := []any{filter.From, filter.To}
+	cond := analyticsConditions(filter, &args)
+
+	// окно месяца, обрезанное по окну отчёта - первый и последний месяц могут быть неполными
+	monthLo := "GREATEST(m.month, $1::date)"
+	monthHi := "LEAST((m.month + interval '1 month')::date, $2::date)"
+
+	query := fmt.Sprintf(`
+		WITH %s,
+		months AS (
+			SELECT generate_series(date_trunc('month', $1::date), $2::date - 1, interval '1 month')::date AS month
+		)
+		SELECT to_char(m.month, 'MM-YYYY'), COALESCE(c.currency, ''), COALESCE(ROUND(SUM(%s)), 0)::bigint
+		FROM months m
+		LEFT JOIN charges c ON c.period_start < %s AND c.period_end > %s
+		GROUP BY m.month, c.currency
+		ORDER BY m.month, c.currency`, chargesCTE(cond), chargeAmount(monthLo, monthHi), monthHi, monthLo)
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.Error("ошибка получения помесячной статистики", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var serviceName string
+	if filter.ServiceName != nil {
+		serviceName = *filter.ServiceName
+	}
+
+	result := make([]domain.SpendRow, 0)
+	for rows.Next() {
+		row := domain.SpendRow{ServiceName: serviceName}
+		if err := rows.Scan(&row.Period, &row.Currency, &row.Total); err != nil {
+			r.logger.Error("ошибка скана строки статистики", zap.Error(err))
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("ошибка итерации по строке", zap.Error(err))
+		return nil, err
+	}
+	return result, nil
+}
+
+// SpendByService отдаёт траты за всё окно в разрезе сервисов и валют.
+// Сортировки и лимита тут нет: сравнивать суммы в разных валютах можно только после пересчёта в сервисе
+func (r *PostgresRepo) SpendByService(ctx context.Context, filter domain.AnalyticsFilter) ([]domain.SpendRow, error) {
+	args := []any{filter.From, filter.To}
+	cond := analyticsConditions(filter, &args)
+
+	query := fmt.Sprintf(`
+		WITH %s
+		SELECT c.service_name, c.currency, ROUND(SUM(%s))::bigint
+		FROM charges c
+		GROUP BY c.service_name, c.currency`, chargesCTE(cond), chargeAmount("$1::date", "$2::date"))
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.Error("ошибка получения статистики по сервисам", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	period := domain.FormatPeriod(filter.From, filter.To)
+
+	result := make([]domain.SpendRow, 0)
+	for rows.Next() {
+		row := domain.SpendRow{Period: period}
+		if err := rows.Scan(&row.ServiceName, &row.Currency, &row.Total); err != nil {
+			r.logger.Error("ошибка скана строки статистики", zap.Error(err))
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("ошибка итерации по строке", zap.Error(err))
+		return nil, err
+	}
+	return result, nil
+}
+
+// analyticsConditions дописывает в args значения фильтров и возвращает условия на подписку s для chargesCTE
+func analyticsConditions(filter domain.AnalyticsFilter, args *[]any) string {
+	cond := ""
+	if filter.UserID != nil {
+		*args = append(*args, *filter.UserID)
+		cond += fmt.Sprintf(" AND s.user_id = $%d", len(*args))
+	}
+	if filter.ServiceName != nil {
+		*args = append(*args, *filter.ServiceName)
+		cond += fmt.Sprintf(" AND "+serviceNameExpr+" = $%d", len(*args))
+	}
+	return cond
+}
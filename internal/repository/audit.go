@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"go.uber.org/zap"
+)
+
+// Журнал изменений пишется в той же транзакции, что и само изменение: либо есть и то, и другое, либо ничего.
+// Таблица append-only, UPDATE и DELETE по ней запрещены триггером в миграции. Вместе с записью журнала
+// в outbox ложится событие об изменении, так что ни одно изменение подписки не проходит мимо него
+
+// withTx выполняет fn в транзакции, коммитит при nil и откатывает при ошибке
+func (r *PostgresRepo) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return runTx(ctx, r.db, r.logger, fn)
+}
+
+// lockSubscription читает подписку под FOR UPDATE: живую, или из корзины если deleted.
+// Заодно сверяет версию - version == 0 значит "любая версия"
+func (r *PostgresRepo) lockSubscription(ctx context.Context, tx *sql.Tx, id int, version int, deleted bool) (domain.Subscription, error) {
+	cond := "deleted_at IS NULL"
+	if deleted {
+		cond = "deleted_at IS NOT NULL"
+	}
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE id = $1 AND ` + cond + ` FOR UPDATE`
+
+	sub, err := scanSubscription(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			r.logger.Warn("подписка не найдена", zap.Int("id", id))
+			return domain.Subscription{}, errors.ErrSubscriptionNotFound
+		}
+		r.logger.Error("ошибка чтения подписки", zap.Error(err))
+		return domain.Subscription{}, err
+	}
+	if version != 0 && sub.Version != version {
+		r.logger.Warn("конфликт версий подписки", zap.Int("id", id), zap.Int("expected_version", version))
+		return domain.Subscription{}, errors.ErrVersionConflict
+	}
+	return sub, nil
+}
+
+// getInTx - GetByID внутри транзакции и без фильтра по корзине, нужен для снимка "после"
+func getInTx(ctx context.Context, tx *sql.Tx, id int) (domain.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE id = $1`
+	return scanSubscription(tx.QueryRowContext(ctx, query, id))
+}
+
+// writeAudit дописывает запись в журнал и событие в outbox, автор изменения берётся из контекста
+func (r *PostgresRepo) writeAudit(ctx context.Context, tx *sql.Tx, id int, operation string, before, after *domain.Subscription) error {
+	beforeJSON, err := snapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := snapshot(after)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO subscription_audit (subscription_id, actor, operation, before, after)
+			  VALUES ($1, $2, $3, $4, $5)`
+	_, err = tx.ExecContext(ctx, query, id, domain.ActorFromContext(ctx), operation, beforeJSON, afterJSON)
+	if err != nil {
+		r.logger.Error("ошибка записи в журнал изменений", zap.Error(err), zap.Int("id", id))
+		return err
+	}
+	return r.writeOutbox(ctx, tx, id, operation, before, after)
+}
+
+// snapshot - JSON подписки для колонок before/after, nil превращается в NULL
+func snapshot(sub *domain.Subscription) ([]byte, error) {
+	if sub == nil {
+		return nil, nil
+	}
+	return json.Marshal(sub)
+}
+
+// History отдаёт журнал изменений подписки от старых записей к новым.
+// Журнал переживает и мягкое, и окончательное удаление подписки
+func (r *PostgresRepo) History(ctx context.Context, id int) ([]domain.AuditEntry, error) {
+	query := `SELECT id, subscription_id, actor, operation, changed_at, before, after
+			  FROM subscription_audit
+			  WHERE subscription_id = $1
+			  ORDER BY id`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, id)
+	if err != nil {
+		r.logger.Error("ошибка получения журнала изменений", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]domain.AuditEntry, 0)
+	for rows.Next() {
+		var (
+			entry         domain.AuditEntry
+			before, after []byte
+		)
+		if err := rows.Scan(&entry.ID, &entry.SubscriptionID, &entry.Actor, &entry.Operation, &entry.ChangedAt, &before, &after); err != nil {
+			r.logger.Error("ошибка скана строки журнала", zap.Error(err))
+			return nil, err
+		}
+		entry.Before = before
+		entry.After = after
+		result = append(result, entry)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("ошибка итерации по строке", zap.Error(err))
+		return nil, err
+	}
+	return result, nil
+}
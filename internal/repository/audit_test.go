@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"encoding/json"
+	"testing"
+	"testovoe_again/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+func TestSnapshot(t *testing.T) {
+	raw, err := snapshot(nil)
+	if err != nil || raw != nil {
+		t.Errorf("снимок nil должен стать NULL, получено %q, %v", raw, err)
+	}
+
+	end := "12-2025"
+	sub := domain.Subscription{ID: 7, ServiceName: "Yandex Plus", Price: 39900, Currency: domain.CurrencyRUB,
+		UserID: uuid.New(), StartDate: "07-2025", EndDate: &end, Version: 2}
+	raw, err = snapshot(&sub)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	// снимок читается обратно в подписку - так его разбирают проверка доступа к журналу и получатели событий
+	var back domain.Subscription
+	if err := json.Unmarshal(raw, &back); err != nil {
+		t.Fatalf("снимок не разбирается: %v", err)
+	}
+	if back.ID != sub.ID || back.UserID != sub.UserID || back.Price != sub.Price || back.EndDate == nil || *back.EndDate != end {
+		t.Errorf("снимок %s не совпадает с подпиской", raw)
+	}
+}
@@ -0,0 +1,681 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Пакет пишется общими запросами, а не операция за операцией: блокировка всех затронутых строк - один запрос,
+// поиск пересечений для всех создаваемых и обновляемых подписок - один запрос, дальше по одному запросу
+// на удаления, обновления и вставки, и по одному на их журнал с outbox. Строки пакета уходят в postgres
+// одним jsonb-параметром и разворачиваются jsonb_to_recordset.
+//
+// Операции выполняются как бы по порядку: пересечения считаются в SQL парами "операция - строка базы" и
+// "операция - предыдущая операция пакета", а порядок разбирается в Go. Общими запросами не выполняются
+// слияния по политике merge и вторая операция над той же подпиской - они уходят в fallback и выполняются
+// по одной после остальных, как и всё, что зависит от них. Упади общая запись (например, два обновления
+// пакета меняются периодами и на полпути задевают ограничение), она откатывается к savepoint,
+// и все операции идут через fallback
+
+// BatchFallback выполняет одну операцию пакета отдельно от общих запросов и дописывает в result id и версию после неё.
+// ctx несёт транзакцию пакета
+type BatchFallback func(ctx context.Context, op domain.BatchOp, result *domain.BatchResult) error
+
+// Batch выполняет проверенные операции пакета в одной транзакции с политикой пересечений policy.
+// Операции с Err не выполняются. В atomic режиме любая упавшая операция откатывает весь пакет,
+// и вместе с результатами возвращается ErrBatchAborted
+func (r *PostgresRepo) Batch(ctx context.Context, ops []domain.BatchOp, atomic bool, policy string, fallback BatchFallback) ([]domain.BatchResult, error) {
+	results := make([]domain.BatchResult, len(ops))
+	for i, op := range ops {
+		results[i] = domain.BatchResult{Index: i, Op: op.Op, ID: op.ID, Err: op.Err}
+		if op.Err == nil && op.Op != domain.BatchCreate && op.Op != domain.BatchUpdate && op.Op != domain.BatchDelete {
+			results[i].Err = fmt.Errorf("неизвестная операция пакета: %s", op.Op)
+		}
+	}
+
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		ctx := context.WithValue(ctx, txKey{}, tx)
+		b := &batchPlan{ops: ops, results: results, deferred: make([]bool, len(ops)), allowed: make([]bool, len(ops))}
+
+		if err := r.lockBatch(ctx, tx, b); err != nil {
+			return err
+		}
+		if err := r.planBatch(ctx, tx, b, policy); err != nil {
+			return err
+		}
+		if atomic && b.failed() {
+			return errors.ErrBatchAborted
+		}
+
+		err := runNested(ctx, tx, r.logger, func(tx *sql.Tx) error {
+			return r.writeBatch(ctx, tx, b)
+		})
+		if err != nil {
+			r.logger.Warn("пакет не записался общими запросами, операции выполняются по одной", zap.Error(err))
+			b.deferAll()
+		}
+
+		for i, op := range ops {
+			if !b.deferred[i] {
+				continue
+			}
+			err := runNested(ctx, tx, r.logger, func(*sql.Tx) error {
+				return fallback(ctx, op, &results[i])
+			})
+			if err != nil {
+				results[i].Err = err
+				if atomic {
+					return errors.ErrBatchAborted
+				}
+			}
+		}
+		return nil
+	})
+	return results, err
+}
+
+// batchPlan - операции пакета и что с ними решено: упавшие получают Err в results,
+// отложенные выполняются через fallback, остальные пишутся общими запросами
+type batchPlan struct {
+	ops      []domain.BatchOp
+	results  []domain.BatchResult
+	deferred []bool
+	// allowed - подписка пересекается с другими и пишется с overlap_allowed по политике warn
+	allowed []bool
+	// before - заблокированные строки обновляемых и удаляемых подписок
+	before map[int]domain.Subscription
+}
+
+// fast - операция пишется общими запросами
+func (b *batchPlan) fast(i int) bool {
+	return b.results[i].Err == nil && !b.deferred[i]
+}
+
+func (b *batchPlan) failed() bool {
+	for _, result := range b.results {
+		if result.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// deferAll отдаёт в fallback все операции, которые должны были записаться общими запросами
+func (b *batchPlan) deferAll() {
+	for i := range b.ops {
+		if b.fast(i) {
+			b.deferred[i] = true
+			b.results[i].ID, b.results[i].Version = b.ops[i].ID, 0
+		}
+	}
+}
+
+// lockBatch одним запросом блокирует подписки, которые пакет обновляет и удаляет, и сверяет их версии.
+// Вторая операция над той же подпиской откладывается: её версия и проверки зависят от первой
+func (r *PostgresRepo) lockBatch(ctx context.Context, tx *sql.Tx, b *batchPlan) error {
+	var ids []int
+	seen := make(map[int]bool)
+	for i, op := range b.ops {
+		if b.results[i].Err != nil || op.Op == domain.BatchCreate {
+			continue
+		}
+		if seen[op.ID] {
+			b.deferred[i] = true
+			continue
+		}
+		seen[op.ID] = true
+		ids = append(ids, op.ID)
+	}
+
+	b.before = make(map[int]domain.Subscription, len(ids))
+	if len(ids) == 0 {
+		return nil
+	}
+	// строки блокируются по возрастанию id, чтобы два пакета не взяли их в разном порядке
+	query := `SELECT ` + subscriptionColumns + `
+			  FROM subscriptions
+			  WHERE id = ANY($1) AND deleted_at IS NULL
+			  ORDER BY id
+			  FOR UPDATE`
+	rows, err := tx.QueryContext(ctx, query, ids)
+	if err != nil {
+		r.logger.Error("ошибка блокировки подписок пакета", zap.Error(err))
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			r.logger.Error("ошибка скана строки подписки", zap.Error(err))
+			return err
+		}
+		b.before[sub.ID] = sub
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("ошибка итерации по строке", zap.Error(err))
+		return err
+	}
+
+	for i, op := range b.ops {
+		if !b.fast(i) || op.Op == domain.BatchCreate {
+			continue
+		}
+		current, ok := b.before[op.ID]
+		switch {
+		case !ok:
+			r.logger.Warn("подписка не найдена", zap.Int("id", op.ID))
+			b.results[i].Err = errors.ErrSubscriptionNotFound
+		case op.Version != 0 && op.Version != current.Version:
+			r.logger.Warn("конфликт версий подписки", zap.Int("id", op.ID), zap.Int("expected_version", op.Version))
+			b.results[i].Err = errors.ErrVersionConflict
+		}
+	}
+	return nil
+}
+
+// batchCandidate - период создаваемой или обновляемой подписки для поиска пересечений, Idx - позиция в пакете
+type batchCandidate struct {
+	Idx         int     `json:"idx"`
+	ID          int     `json:"id"`
+	UserID      string  `json:"user_id"`
+	ServiceID   *int    `json:"service_id"`
+	ServiceName string  `json:"service_name"`
+	StartDate   string  `json:"start_date"`
+	EndDate     *string `json:"end_date"`
+}
+
+// planBatch находит пересечения всех создаваемых и обновляемых подписок одним запросом и применяет политику
+// в порядке операций: пересечение со строкой базы считается, только если её ещё не обновила или удалила
+// одна из предыдущих операций, с операцией пакета - только если та выполнилась. reject отказывает,
+// warn помечает подписку как осознанное пересечение, merge откладывает операцию в fallback.
+// Всё, что пересекается с отложенной операцией или затронутой ею строкой, тоже откладывается
+func (r *PostgresRepo) planBatch(ctx context.Context, tx *sql.Tx, b *batchPlan, policy string) error {
+	var candidates []batchCandidate
+	for i, op := range b.ops {
+		if !b.fast(i) || op.Op == domain.BatchDelete {
+			continue
+		}
+		start, end, err := parseDates(op.Subscription)
+		if err != nil {
+			b.results[i].Err = err
+			continue
+		}
+		c := batchCandidate{Idx: i, UserID: op.Subscription.UserID.String(), ServiceID: op.Subscription.ServiceID,
+			ServiceName: op.Subscription.ServiceName, StartDate: domain.FormatDate(start)}
+		if op.Op == domain.BatchUpdate {
+			// обновление не меняет пользователя подписки
+			c.ID, c.UserID = op.ID, b.before[op.ID].UserID.String()
+		}
+		if end != nil {
+			date := domain.FormatDate(*end)
+			c.EndDate = &date
+		}
+		candidates = append(candidates, c)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	// пары "операция - пересекающаяся живая строка базы" и "операция - предыдущая пересекающаяся операция пакета"
+	query := `WITH c AS (
+			      SELECT * FROM jsonb_to_recordset($1::jsonb)
+			          AS c(idx INT, id INT, user_id UUID, service_id INT, service_name VARCHAR, start_date DATE, end_date DATE)
+			  )
+			  SELECT c.idx, s.id, NULL::INT
+			  FROM c
+			  JOIN subscriptions s
+			    ON s.user_id = c.user_id AND s.id <> c.id AND s.deleted_at IS NULL
+			   AND subscription_service_key(s.service_id, s.service_name) = subscription_service_key(c.service_id, c.service_name)
+			   AND subscription_period(s.start_date, s.end_date) && subscription_period(c.start_date, c.end_date)
+			  UNION ALL
+			  SELECT c.idx, NULL, o.idx
+			  FROM c
+			  JOIN c o
+			    ON o.idx < c.idx AND o.user_id = c.user_id
+			   AND subscription_service_key(o.service_id, o.service_name) = subscription_service_key(c.service_id, c.service_name)
+			   AND subscription_period(o.start_date, o.end_date) && subscription_period(c.start_date, c.end_date)
+			  ORDER BY 1, 2, 3`
+
+	param, err := jsonRows(candidates)
+	if err != nil {
+		return err
+	}
+	rows, err := tx.QueryContext(ctx, query, param)
+	if err != nil {
+		r.logger.Error("ошибка поиска пересечений пакета", zap.Error(err))
+		return err
+	}
+	defer rows.Close()
+
+	overlaps := make(map[int]batchOverlaps)
+	for rows.Next() {
+		var (
+			idx       int
+			id, other sql.NullInt64
+		)
+		if err := rows.Scan(&idx, &id, &other); err != nil {
+			r.logger.Error("ошибка скана строки пересечения", zap.Error(err))
+			return err
+		}
+		o := overlaps[idx]
+		if id.Valid {
+			o.rows = append(o.rows, int(id.Int64))
+		} else {
+			o.ops = append(o.ops, int(other.Int64))
+		}
+		overlaps[idx] = o
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("ошибка итерации по строке", zap.Error(err))
+		return err
+	}
+
+	b.applyPolicy(overlaps, policy, r.logger)
+	return nil
+}
+
+// batchOverlaps - с чем пересекается операция пакета: id строк базы и позиции предыдущих операций
+type batchOverlaps struct {
+	rows []int
+	ops  []int
+}
+
+// applyPolicy проходит операции по порядку и решает по найденным пересечениям, что с каждой делать
+func (b *batchPlan) applyPolicy(overlaps map[int]batchOverlaps, policy string, logger *zap.Logger) {
+	var (
+		// changed - строки, которые уже обновила или удалила выполненная операция: их старый период больше не в счёт
+		changed = make(map[int]bool)
+		// unknown и unknownOps - строки и операции, состояние которых зависит от отложенных операций
+		unknown    = make(map[int]bool)
+		unknownOps = make([]bool, len(b.ops))
+		first      = make(map[int]int)
+	)
+	deferOp := func(i int) {
+		b.deferred[i] = true
+		unknownOps[i] = true
+		if op := b.ops[i]; op.Op != domain.BatchCreate {
+			unknown[op.ID] = true
+			if j, ok := first[op.ID]; ok {
+				unknownOps[j] = true
+			}
+		}
+	}
+
+	for i, op := range b.ops {
+		if b.deferred[i] {
+			deferOp(i)
+			continue
+		}
+		if b.results[i].Err != nil {
+			continue
+		}
+		if op.Op != domain.BatchCreate {
+			first[op.ID] = i
+		}
+		if op.Op == domain.BatchDelete {
+			changed[op.ID] = true
+			continue
+		}
+
+		var ids, prev []int
+		wait := false
+		for _, id := range overlaps[i].rows {
+			switch {
+			case unknown[id]:
+				wait = true
+			case !changed[id]:
+				ids = append(ids, id)
+			}
+		}
+		for _, j := range overlaps[i].ops {
+			switch {
+			case unknownOps[j]:
+				wait = true
+			case b.fast(j):
+				prev = append(prev, j)
+			}
+		}
+		if wait {
+			deferOp(i)
+			continue
+		}
+
+		if len(ids) > 0 || len(prev) > 0 {
+			switch policy {
+			case domain.OverlapWarn:
+				logger.Warn("подписка из пакета пересекается с другими подписками сервиса", zap.Int("index", i),
+					zap.Ints("overlaps", ids), zap.Ints("batch_overlaps", prev))
+				b.allowed[i] = true
+			case domain.OverlapMerge:
+				deferOp(i)
+				continue
+			default:
+				logger.Warn("отказ: подписка из пакета пересекается с другими подписками сервиса", zap.Int("index", i),
+					zap.Ints("overlaps", ids), zap.Ints("batch_overlaps", prev))
+				b.results[i].Err = errors.Wrap(errors.ErrSubscriptionOverlap, overlapReason(ids, prev))
+				continue
+			}
+		}
+		if op.Op == domain.BatchUpdate {
+			changed[op.ID] = true
+		}
+	}
+}
+
+// overlapReason - с чем пересеклась операция пакета, для текста ошибки
+func overlapReason(ids, prev []int) error {
+	switch {
+	case len(prev) == 0:
+		return fmt.Errorf("пересекается с подписками %v", ids)
+	case len(ids) == 0:
+		return fmt.Errorf("пересекается с операциями пакета %v", prev)
+	default:
+		return fmt.Errorf("пересекается с подписками %v и операциями пакета %v", ids, prev)
+	}
+}
+
+// writeBatch пишет все неотложенные операции: сначала удаления, чтобы освободившиеся периоды
+// не задели ограничение, потом обновления и вставки, и к каждой группе - журнал и outbox
+func (r *PostgresRepo) writeBatch(ctx context.Context, tx *sql.Tx, b *batchPlan) error {
+	var deletes, updates, creates []int
+	for i, op := range b.ops {
+		if !b.fast(i) {
+			continue
+		}
+		switch op.Op {
+		case domain.BatchDelete:
+			deletes = append(deletes, i)
+		case domain.BatchUpdate:
+			updates = append(updates, i)
+		case domain.BatchCreate:
+			creates = append(creates, i)
+		}
+	}
+
+	if err := r.batchDelete(ctx, tx, b, deletes); err != nil {
+		return err
+	}
+	if err := r.batchUpdate(ctx, tx, b, updates); err != nil {
+		return err
+	}
+	return r.batchCreate(ctx, tx, b, creates)
+}
+
+func (r *PostgresRepo) batchDelete(ctx context.Context, tx *sql.Tx, b *batchPlan, idx []int) error {
+	if len(idx) == 0 {
+		return nil
+	}
+	ids := make([]int, len(idx))
+	for n, i := range idx {
+		ids[n] = b.ops[i].ID
+	}
+
+	query := `UPDATE subscriptions
+			  SET deleted_at = now(), version = version + 1
+			  WHERE id = ANY($1)
+			  RETURNING ` + subscriptionColumns
+	after, err := r.returnedRows(ctx, tx, "ошибка удаления подписок пакета", query, ids)
+	if err != nil {
+		return err
+	}
+	return r.writeAuditBatch(ctx, tx, domain.AuditDelete, b.changes(idx, after))
+}
+
+func (r *PostgresRepo) batchUpdate(ctx context.Context, tx *sql.Tx, b *batchPlan, idx []int) error {
+	if len(idx) == 0 {
+		return nil
+	}
+	values := make([]batchRow, len(idx))
+	for n, i := range idx {
+		op := b.ops[i]
+		// как Update: пользователь, статус и версия остаются от текущей строки, остальное приходит из операции
+		sub := b.before[op.ID]
+		sub.Price, sub.ServiceName, sub.ServiceID = op.Subscription.Price, op.Subscription.ServiceName, op.Subscription.ServiceID
+		sub.StartDate, sub.EndDate, sub.Currency = op.Subscription.StartDate, op.Subscription.EndDate, op.Subscription.Currency
+		sub.BillingPeriod, sub.BillingPeriodDays = op.Subscription.BillingPeriod, op.Subscription.BillingPeriodDays
+		sub.AutoRenew, sub.OverlapAllowed = op.Subscription.AutoRenew, b.allowed[i]
+		row, err := newBatchRow(sub)
+		if err != nil {
+			return err
+		}
+		values[n] = row
+	}
+	param, err := jsonRows(values)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE subscriptions s
+			  SET price = v.price, service_name = v.service_name, start_date = v.start_date, end_date = v.end_date,
+			      currency = v.currency, billing_period = v.billing_period, billing_period_days = v.billing_period_days,
+			      overlap_allowed = v.overlap_allowed, service_id = v.service_id, auto_renew = v.auto_renew, version = s.version + 1
+			  FROM jsonb_to_recordset($1::jsonb) AS v(` + batchRowColumns + `)
+			  WHERE s.id = v.id
+			  RETURNING ` + qualifiedColumns("s")
+	after, err := r.returnedRows(ctx, tx, "ошибка обновления подписок пакета", query, param)
+	if err != nil {
+		return err
+	}
+	for _, i := range idx {
+		b.results[i].Version = after[b.ops[i].ID].Version
+	}
+	return r.writeAuditBatch(ctx, tx, domain.AuditUpdate, b.changes(idx, after))
+}
+
+// batchCreate берёт id из последовательности заранее: так строки вставки однозначно сопоставляются операциям,
+// порядок RETURNING у INSERT ... SELECT postgres не обещает
+func (r *PostgresRepo) batchCreate(ctx context.Context, tx *sql.Tx, b *batchPlan, idx []int) error {
+	if len(idx) == 0 {
+		return nil
+	}
+	ids, err := r.nextIDs(ctx, tx, len(idx))
+	if err != nil {
+		return err
+	}
+
+	values := make([]batchRow, len(idx))
+	for n, i := range idx {
+		sub := b.ops[i].Subscription
+		sub.ID, sub.OverlapAllowed = ids[n], b.allowed[i]
+		row, err := newBatchRow(sub)
+		if err != nil {
+			return err
+		}
+		values[n] = row
+		b.results[i].ID = ids[n]
+	}
+	param, err := jsonRows(values)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO subscriptions (id, service_name, price, currency, user_id, start_date, end_date, billing_period,
+			      billing_period_days, overlap_allowed, service_id, status, auto_renew)
+			  SELECT id, service_name, price, currency, user_id, start_date, end_date, billing_period,
+			      billing_period_days, overlap_allowed, service_id, status, auto_renew
+			  FROM jsonb_to_recordset($1::jsonb) AS v(` + batchRowColumns + `)
+			  RETURNING ` + subscriptionColumns
+	after, err := r.returnedRows(ctx, tx, "ошибка создания подписок пакета", query, param)
+	if err != nil {
+		return err
+	}
+	for _, i := range idx {
+		b.results[i].Version = after[b.results[i].ID].Version
+	}
+
+	changes := make([]auditChange, len(idx))
+	for n, i := range idx {
+		sub := after[b.results[i].ID]
+		changes[n] = auditChange{id: sub.ID, after: &sub}
+	}
+	return r.writeAuditBatch(ctx, tx, domain.AuditCreate, changes)
+}
+
+func (r *PostgresRepo) nextIDs(ctx context.Context, tx *sql.Tx, n int) ([]int, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT nextval(pg_get_serial_sequence('subscriptions', 'id')) FROM generate_series(1, $1::int)`, n)
+	if err != nil {
+		r.logger.Error("ошибка выделения id подписок пакета", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+	ids := make([]int, 0, n)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.Error("ошибка итерации по строке", zap.Error(err))
+		return nil, err
+	}
+	return ids, nil
+}
+
+// returnedRows выполняет запрос с RETURNING subscriptionColumns и раскладывает строки по id
+func (r *PostgresRepo) returnedRows(ctx context.Context, tx *sql.Tx, msg string, query string, args ...any) (map[int]domain.Subscription, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, r.writeError(msg, err)
+	}
+	defer rows.Close()
+	result := make(map[int]domain.Subscription)
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			r.logger.Error("ошибка скана строки подписки", zap.Error(err))
+			return nil, err
+		}
+		result[sub.ID] = sub
+	}
+	// ошибка ограничения при многострочной записи приходит при чтении результата, а не при отправке запроса
+	if err := rows.Err(); err != nil {
+		return nil, r.writeError(msg, err)
+	}
+	return result, nil
+}
+
+// batchRow - подписка строкой jsonb_to_recordset, колонки в batchRowColumns
+type batchRow struct {
+	ID                int     `json:"id"`
+	ServiceName       string  `json:"service_name"`
+	Price             int64   `json:"price"`
+	Currency          string  `json:"currency"`
+	UserID            string  `json:"user_id"`
+	StartDate         string  `json:"start_date"`
+	EndDate           *string `json:"end_date"`
+	BillingPeriod     string  `json:"billing_period"`
+	BillingPeriodDays *int    `json:"billing_period_days"`
+	OverlapAllowed    bool    `json:"overlap_allowed"`
+	ServiceID         *int    `json:"service_id"`
+	Status            string  `json:"status"`
+	AutoRenew         bool    `json:"auto_renew"`
+}
+
+const batchRowColumns = `id INT, service_name VARCHAR, price BIGINT, currency CHAR(3), user_id UUID, start_date DATE, end_date DATE,
+	billing_period VARCHAR, billing_period_days INT, overlap_allowed BOOLEAN, service_id INT, status VARCHAR, auto_renew BOOLEAN`
+
+// newBatchRow - даты приводятся к YYYY-MM-DD, подписка может прийти и с MM-YYYY
+func newBatchRow(sub domain.Subscription) (batchRow, error) {
+	start, end, err := parseDates(sub)
+	if err != nil {
+		return batchRow{}, err
+	}
+	row := batchRow{
+		ID: sub.ID, ServiceName: sub.ServiceName, Price: sub.Price, Currency: sub.Currency, UserID: sub.UserID.String(),
+		StartDate: domain.FormatDate(start), BillingPeriod: sub.BillingPeriod, BillingPeriodDays: sub.BillingPeriodDays,
+		OverlapAllowed: sub.OverlapAllowed, ServiceID: sub.ServiceID, Status: sub.Status, AutoRenew: sub.AutoRenew,
+	}
+	if end != nil {
+		date := domain.FormatDate(*end)
+		row.EndDate = &date
+	}
+	return row, nil
+}
+
+// auditChange - снимки подписки до и после изменения для журнала, nil - подписки не было
+type auditChange struct {
+	id            int
+	before, after *domain.Subscription
+}
+
+// changes - снимки до и после для обновлённых или удалённых операциями idx подписок
+func (b *batchPlan) changes(idx []int, after map[int]domain.Subscription) []auditChange {
+	changes := make([]auditChange, len(idx))
+	for n, i := range idx {
+		id := b.ops[i].ID
+		before, now := b.before[id], after[id]
+		changes[n] = auditChange{id: id, before: &before, after: &now}
+	}
+	return changes
+}
+
+// writeAuditBatch - writeAudit для группы изменений одной операции: журнал и outbox одним запросом
+func (r *PostgresRepo) writeAuditBatch(ctx context.Context, tx *sql.Tx, operation string, changes []auditChange) error {
+	type auditRow struct {
+		SubscriptionID int             `json:"subscription_id"`
+		Before         json.RawMessage `json:"before"`
+		After          json.RawMessage `json:"after"`
+		EventID        uuid.UUID       `json:"event_id"`
+		Payload        json.RawMessage `json:"payload"`
+	}
+	values := make([]auditRow, len(changes))
+	for n, c := range changes {
+		before, err := snapshot(c.before)
+		if err != nil {
+			return err
+		}
+		after, err := snapshot(c.after)
+		if err != nil {
+			return err
+		}
+		event, payload, err := newEvent(ctx, operation, c.before, c.after)
+		if err != nil {
+			return err
+		}
+		values[n] = auditRow{SubscriptionID: c.id, Before: before, After: after, EventID: event.ID, Payload: payload}
+	}
+	param, err := jsonRows(values)
+	if err != nil {
+		return err
+	}
+
+	query := `WITH v AS (
+			      SELECT * FROM jsonb_to_recordset($1::jsonb)
+			          AS v(subscription_id INT, before JSONB, after JSONB, event_id UUID, payload JSONB)
+			  ), audit AS (
+			      INSERT INTO subscription_audit (subscription_id, actor, operation, before, after)
+			      SELECT subscription_id, $2::text, $3::text, before, after FROM v
+			  )
+			  INSERT INTO outbox (event_id, event, subscription_id, payload)
+			  SELECT event_id, $4::varchar, subscription_id, payload FROM v`
+	_, err = tx.ExecContext(ctx, query, param, domain.ActorFromContext(ctx), operation, domain.EventForOperation(operation))
+	if err != nil {
+		r.logger.Error("ошибка записи пакета в журнал изменений", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// jsonRows - строки пакета одним jsonb-параметром для jsonb_to_recordset
+func jsonRows(rows any) (string, error) {
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// qualifiedColumns - subscriptionColumns с именем таблицы, для RETURNING в UPDATE ... FROM
+func qualifiedColumns(table string) string {
+	return table + "." + strings.ReplaceAll(subscriptionColumns, ", ", ", "+table+".")
+}
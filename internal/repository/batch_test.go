@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"go.uber.org/zap"
+)
+
+func TestBatchApplyPolicy(t *testing.T) {
+	create := domain.BatchOp{Op: domain.BatchCreate}
+	update := func(id int) domain.BatchOp { return domain.BatchOp{Op: domain.BatchUpdate, ID: id} }
+	remove := func(id int) domain.BatchOp { return domain.BatchOp{Op: domain.BatchDelete, ID: id} }
+
+	// итог операции: fast - общими запросами, deferred - через fallback, overlap - отказ, allowed - warn
+	const (
+		fast     = "fast"
+		deferred = "deferred"
+		overlap  = "overlap"
+		allowed  = "allowed"
+		failed   = "failed"
+	)
+	cases := []struct {
+		name     string
+		policy   string
+		ops      []domain.BatchOp
+		failed   map[int]error
+		deferred []int
+		overlaps map[int]batchOverlaps
+		want     []string
+	}{
+		{
+			name:     "reject: пересечение со строкой базы",
+			policy:   domain.OverlapReject,
+			ops:      []domain.BatchOp{create, create},
+			overlaps: map[int]batchOverlaps{0: {rows: []int{5}}},
+			want:     []string{overlap, fast},
+		},
+		{
+			name:     "reject: строку уже сдвинула предыдущая операция",
+			policy:   domain.OverlapReject,
+			ops:      []domain.BatchOp{update(5), create},
+			overlaps: map[int]batchOverlaps{1: {rows: []int{5}}},
+			want:     []string{fast, fast},
+		},
+		{
+			name:     "reject: строку сдвинет только следующая операция",
+			policy:   domain.OverlapReject,
+			ops:      []domain.BatchOp{create, update(5)},
+			overlaps: map[int]batchOverlaps{0: {rows: []int{5}}},
+			want:     []string{overlap, fast},
+		},
+		{
+			name:     "reject: удалённая раньше строка не мешает",
+			policy:   domain.OverlapReject,
+			ops:      []domain.BatchOp{remove(5), create},
+			overlaps: map[int]batchOverlaps{1: {rows: []int{5}}},
+			want:     []string{fast, fast},
+		},
+		{
+			name:     "reject: пересечение с предыдущей операцией пакета",
+			policy:   domain.OverlapReject,
+			ops:      []domain.BatchOp{create, create},
+			overlaps: map[int]batchOverlaps{1: {ops: []int{0}}},
+			want:     []string{fast, overlap},
+		},
+		{
+			name:     "reject: упавшая операция ни с чем не пересекается",
+			policy:   domain.OverlapReject,
+			ops:      []domain.BatchOp{create, create},
+			failed:   map[int]error{0: errors.ErrVersionConflict},
+			overlaps: map[int]batchOverlaps{1: {ops: []int{0}}},
+			want:     []string{failed, fast},
+		},
+		{
+			name:     "warn: пересечение пишется с overlap_allowed",
+			policy:   domain.OverlapWarn,
+			ops:      []domain.BatchOp{create, create},
+			overlaps: map[int]batchOverlaps{0: {rows: []int{5}}, 1: {ops: []int{0}}},
+			want:     []string{allowed, allowed},
+		},
+		{
+			name:     "merge: слияние и всё, что с ним пересекается, уходит в fallback",
+			policy:   domain.OverlapMerge,
+			ops:      []domain.BatchOp{create, create, create},
+			overlaps: map[int]batchOverlaps{0: {rows: []int{5}}, 1: {ops: []int{0}}},
+			want:     []string{deferred, deferred, fast},
+		},
+		{
+			name:     "merge: отложенное обновление держит старый период строки",
+			policy:   domain.OverlapMerge,
+			ops:      []domain.BatchOp{update(5), create},
+			overlaps: map[int]batchOverlaps{0: {rows: []int{7}}, 1: {rows: []int{5}}},
+			want:     []string{deferred, deferred},
+		},
+		{
+			name:     "вторая операция над подпиской делает неизвестной и первую",
+			policy:   domain.OverlapReject,
+			ops:      []domain.BatchOp{update(5), remove(5), create},
+			deferred: []int{1},
+			overlaps: map[int]batchOverlaps{2: {ops: []int{0}}},
+			want:     []string{fast, deferred, deferred},
+		},
+	}
+
+	for _, c := range cases {
+		b := &batchPlan{
+			ops:      c.ops,
+			results:  make([]domain.BatchResult, len(c.ops)),
+			deferred: make([]bool, len(c.ops)),
+			allowed:  make([]bool, len(c.ops)),
+		}
+		for i, err := range c.failed {
+			b.results[i].Err = err
+		}
+		for _, i := range c.deferred {
+			b.deferred[i] = true
+		}
+
+		b.applyPolicy(c.overlaps, c.policy, zap.NewNop())
+
+		for i, want := range c.want {
+			var got string
+			switch {
+			case b.deferred[i]:
+				got = deferred
+			case errors.Is(b.results[i].Err, errors.ErrSubscriptionOverlap):
+				got = overlap
+			case b.results[i].Err != nil:
+				got = failed
+			case b.allowed[i]:
+				got = allowed
+			default:
+				got = fast
+			}
+			if got != want {
+				t.Errorf("%s: операция %d - %s, ожидалось %s", c.name, i, got, want)
+			}
+		}
+	}
+}
+
+func TestQualifiedColumns(t *testing.T) {
+	got := qualifiedColumns("s")
+	if !strings.HasPrefix(got, "s.id, s.service_name,") {
+		t.Errorf("qualifiedColumns = %q", got)
+	}
+	for _, column := range []string{"s.service_name", "s.auto_renew", "s.deleted_at"} {
+		if !strings.Contains(got, column) {
+			t.Errorf("в %q нет %s", got, column)
+		}
+	}
+}
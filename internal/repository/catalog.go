@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+)
+
+// CatalogRepository - каталог сервисов в таблицах services и service_names
+type CatalogRepository interface {
+	Create(ctx context.Context, entry domain.CatalogEntry) (domain.CatalogEntry, error)
+	Get(ctx context.Context, id int) (domain.CatalogEntry, error)
+	List(ctx context.Context) ([]domain.CatalogEntry, error)
+	// Update перезаписывает сервис целиком вместе с набором алиасов
+	Update(ctx context.Context, entry domain.CatalogEntry) (domain.CatalogEntry, error)
+	Delete(ctx context.Context, id int) error
+
+	// Resolve ищет сервис по любому написанию, ErrServiceNotFound если его нет
+	Resolve(ctx context.Context, name string) (domain.CatalogEntry, error)
+}
+
+// uniqueViolation - SQLSTATE нарушения уникальности
+const uniqueViolation = "23505"
+
+type CatalogRepo struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewCatalogRepo(db *sql.DB, logger *zap.Logger) *CatalogRepo {
+	return &CatalogRepo{db: db, logger: logger}
+}
+
+// catalogSelect - сервис вместе с алиасами. Алиасы собираются в json: database/sql не умеет сканировать массивы
+const catalogSelect = `SELECT s.id, s.name, s.default_price, s.currency, s.min_price, s.max_price, s.created_at, s.updated_at,
+			  COALESCE((SELECT json_agg(n.name ORDER BY n.name) FROM service_names n
+			            WHERE n.service_id = s.id AND NOT n.canonical), '[]')
+			  FROM services s`
+
+func scanCatalogEntry(row rowScanner) (domain.CatalogEntry, error) {
+	var (
+		entry              domain.CatalogEntry
+		minPrice, maxPrice sql.NullInt64
+		aliases            []byte
+	)
+	err := row.Scan(&entry.ID, &entry.Name, &entry.DefaultPrice, &entry.Currency, &minPrice, &maxPrice,
+		&entry.CreatedAt, &entry.UpdatedAt, &aliases)
+	if err != nil {
+		return domain.CatalogEntry{}, err
+	}
+	if minPrice.Valid {
+		entry.MinPrice = &minPrice.Int64
+	}
+	if maxPrice.Valid {
+		entry.MaxPrice = &maxPrice.Int64
+	}
+	if err := json.Unmarshal(aliases, &entry.Aliases); err != nil {
+		return domain.CatalogEntry{}, err
+	}
+	return entry, nil
+}
+
+func (r *CatalogRepo) Create(ctx context.Context, entry domain.CatalogEntry) (domain.CatalogEntry, error) {
+	err := runTx(ctx, r.db, r.logger, func(tx *sql.Tx) error {
+		query := `INSERT INTO services (name, default_price, currency, min_price, max_price)
+				  VALUES ($1, $2, $3, $4, $5)
+				  RETURNING id`
+		err := tx.QueryRowContext(ctx, query, entry.Name, entry.DefaultPrice, entry.Currency, entry.MinPrice, entry.MaxPrice).
+			Scan(&entry.ID)
+		if err != nil {
+			r.logger.Error("ошибка создания сервиса каталога", zap.Error(err))
+			return err
+		}
+		return r.saveNames(ctx, tx, entry)
+	})
+	if err != nil {
+		return domain.CatalogEntry{}, err
+	}
+	return r.Get(ctx, entry.ID)
+}
+
+func (r *CatalogRepo) Get(ctx context.Context, id int) (domain.CatalogEntry, error) {
+	entry, err := scanCatalogEntry(conn(ctx, r.db).QueryRowContext(ctx, catalogSelect+` WHERE s.id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.CatalogEntry{}, errors.ErrServiceNotFound
+		}
+		r.logger.Error("ошибка чтения сервиса каталога", zap.Error(err), zap.Int("id", id))
+		return domain.CatalogEntry{}, err
+	}
+	return entry, nil
+}
+
+func (r *CatalogRepo) List(ctx context.Context) ([]domain.CatalogEntry, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, catalogSelect+` ORDER BY s.name`)
+	if err != nil {
+		r.logger.Error("ошибка получения каталога сервисов", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]domain.CatalogEntry, 0)
+	for rows.Next() {
+		entry, err := scanCatalogEntry(rows)
+		if err != nil {
+			r.logger.Error("ошибка скана строки каталога", zap.Error(err))
+			return nil, err
+		}
+		result = append(result, entry)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("ошибка итерации по строке", zap.Error(err))
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *CatalogRepo) Update(ctx context.Context, entry domain.CatalogEntry) (domain.CatalogEntry, error) {
+	err := runTx(ctx, r.db, r.logger, func(tx *sql.Tx) error {
+		query := `UPDATE services
+				  SET name = $1, default_price = $2, currency = $3, min_price = $4, max_price = $5, updated_at = now()
+				  WHERE id = $6`
+		res, err := tx.ExecContext(ctx, query, entry.Name, entry.DefaultPrice, entry.Currency, entry.MinPrice, entry.MaxPrice, entry.ID)
+		if err != nil {
+			r.logger.Error("ошибка обновления сервиса каталога", zap.Error(err))
+			return err
+		}
+		if affected, err := res.RowsAffected(); err != nil {
+			return err
+		} else if affected == 0 {
+			return errors.ErrServiceNotFound
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM service_names WHERE service_id = $1`, entry.ID); err != nil {
+			r.logger.Error("ошибка удаления написаний сервиса", zap.Error(err))
+			return err
+		}
+		// подписки, уже привязанные к сервису, не переименовываются: аналитика берёт имя из каталога по service_id
+		return r.saveNames(ctx, tx, entry)
+	})
+	if err != nil {
+		return domain.CatalogEntry{}, err
+	}
+	return r.Get(ctx, entry.ID)
+}
+
+// Delete удаляет сервис из каталога. Подписки на него остаются со своим названием, но без привязки
+func (r *CatalogRepo) Delete(ctx context.Context, id int) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM services WHERE id = $1`, id)
+	if err != nil {
+		r.logger.Error("ошибка удаления сервиса каталога", zap.Error(err), zap.Int("id", id))
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.ErrServiceNotFound
+	}
+	return nil
+}
+
+func (r *CatalogRepo) Resolve(ctx context.Context, name string) (domain.CatalogEntry, error) {
+	query := catalogSelect + ` JOIN service_names sn ON sn.service_id = s.id WHERE sn.key = $1`
+	entry, err := scanCatalogEntry(conn(ctx, r.db).QueryRowContext(ctx, query, domain.ServiceKey(name)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.CatalogEntry{}, errors.ErrServiceNotFound
+		}
+		r.logger.Error("ошибка поиска сервиса в каталоге", zap.Error(err), zap.String("name", name))
+		return domain.CatalogEntry{}, err
+	}
+	return entry, nil
+}
+
+// saveNames записывает все написания сервиса и привязывает к нему подписки, которые заведены под одним из них
+// до появления сервиса в каталоге. Название у таких подписок остаётся как есть, меняется только service_id.
+// Сопоставление по ключу идёт в Go: из непривязанных названий выбираются те, что дают тот же ServiceKey
+func (r *CatalogRepo) saveNames(ctx context.Context, tx *sql.Tx, entry domain.CatalogEntry) error {
+	keys := make(map[string]bool)
+	for i, name := range entry.Names() {
+		key := domain.ServiceKey(name)
+		keys[key] = true
+		_, err := tx.ExecContext(ctx, `INSERT INTO service_names (key, service_id, name, canonical) VALUES ($1, $2, $3, $4)`,
+			key, entry.ID, name, i == 0)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+				r.logger.Warn("написание уже занято другим сервисом", zap.String("name", name))
+				field := "aliases"
+				if i == 0 {
+					field = "name"
+				}
+				return errors.Wrap(errors.ErrServiceNameTaken.WithField(field), fmt.Errorf("%q", name))
+			}
+			r.logger.Error("ошибка записи написания сервиса", zap.Error(err))
+			return err
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT DISTINCT service_name FROM subscriptions WHERE service_id IS NULL`)
+	if err != nil {
+		r.logger.Error("ошибка поиска непривязанных подписок", zap.Error(err))
+		return err
+	}
+	var matched []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		if keys[domain.ServiceKey(name)] {
+			matched = append(matched, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// привязанная подписка сравнивается на пересечения по сервису каталога и может задеть подписку под другим
+	// написанием: такие пересечения помечаются разрешёнными, как в миграции 000017, иначе привязку не пустит ограничение
+	query := `UPDATE subscriptions s
+			  SET service_id = $1,
+			      overlap_allowed = s.overlap_allowed OR (s.deleted_at IS NULL AND EXISTS (
+			          SELECT 1 FROM subscriptions o
+			          WHERE o.id <> s.id AND o.user_id = s.user_id AND o.service_id = $1 AND o.deleted_at IS NULL
+			            AND subscription_period(o.start_date, o.end_date) && subscription_period(s.start_date, s.end_date)))
+			  WHERE s.service_id IS NULL AND s.service_name = $2`
+	for _, name := range matched {
+		_, err := tx.ExecContext(ctx, query, entry.ID, name)
+		if err != nil {
+			r.logger.Error("ошибка привязки подписок к сервису", zap.Error(err))
+			return err
+		}
+	}
+	if len(matched) > 0 {
+		r.logger.Info("подписки привязаны к сервису каталога", zap.Int("service_id", entry.ID), zap.Strings("names", matched))
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testovoe_again/internal/domain"
+
+	"go.uber.org/zap"
+)
+
+// Export проходит по всем подпискам под фильтром прямо по курсору sql.Rows и отдаёт каждую в fn,
+// ничего не копя в памяти - в отличие от GetByUserID, который собирает слайс целиком.
+// Limit и Cursor фильтра здесь не используются. Ошибка из fn прерывает выгрузку и возвращается как есть
+func (r *PostgresRepo) Export(ctx context.Context, filter domain.ListFilter, fn func(domain.Subscription) error) error {
+	if _, ok := sortColumns[filter.SortBy]; !ok {
+		return fmt.Errorf("неизвестная колонка сортировки %q", filter.SortBy)
+	}
+
+	where, args := buildListWhere(filter)
+
+	direction := "ASC"
+	if filter.SortDesc {
+		direction = "DESC"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM subscriptions
+		%s
+		ORDER BY %s %s, id %s`, subscriptionColumns, whereClause(where), filter.SortBy, direction, direction)
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.Error("ошибка выгрузки подписок", zap.Error(err))
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			r.logger.Error("ошибка скана строки подписки", zap.Error(err))
+			return err
+		}
+		if err := fn(sub); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		r.logger.Error("ошибка итерации по строке", zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testovoe_again/internal/domain"
+
+	"go.uber.org/zap"
+)
+
+// IdempotencyRepo - хранилище ключей идемпотентности в таблице idempotency_keys
+type IdempotencyRepo struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewIdempotencyRepo(db *sql.DB, logger *zap.Logger) *IdempotencyRepo {
+	return &IdempotencyRepo{db: db, logger: logger}
+}
+
+// Reserve занимает ключ под новый запрос. Если ключ уже занят и не протух, возвращается существующая запись
+// и false. Протухшая запись перезаписывается тем же upsert'ом, так что гонка двух первых запросов невозможна
+func (r *IdempotencyRepo) Reserve(ctx context.Context, rec domain.IdempotencyRecord) (domain.IdempotencyRecord, bool, error) {
+	query := `INSERT INTO idempotency_keys AS k (owner, key, request_hash, expires_at)
+			  VALUES ($1, $2, $3, $4)
+			  ON CONFLICT (owner, key) DO UPDATE SET
+				request_hash = EXCLUDED.request_hash,
+				status = NULL,
+				response_headers = NULL,
+				response_body = NULL,
+				created_at = now(),
+				expires_at = EXCLUDED.expires_at
+			  WHERE k.expires_at < now()`
+
+	res, err := r.db.ExecContext(ctx, query, rec.Owner, rec.Key, rec.RequestHash, rec.ExpiresAt)
+	if err != nil {
+		r.logger.Error("ошибка резервирования ключа идемпотентности", zap.Error(err))
+		return domain.IdempotencyRecord{}, false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return domain.IdempotencyRecord{}, false, err
+	}
+	if affected == 1 {
+		return rec, true, nil
+	}
+
+	existing := domain.IdempotencyRecord{Owner: rec.Owner, Key: rec.Key}
+	var (
+		status  sql.NullInt64
+		headers []byte
+	)
+	query = `SELECT request_hash, status, response_headers, response_body, expires_at
+			 FROM idempotency_keys
+			 WHERE owner = $1 AND key = $2`
+	err = r.db.QueryRowContext(ctx, query, rec.Owner, rec.Key).
+		Scan(&existing.RequestHash, &status, &headers, &existing.Body, &existing.ExpiresAt)
+	if err != nil {
+		r.logger.Error("ошибка чтения ключа идемпотентности", zap.Error(err))
+		return domain.IdempotencyRecord{}, false, err
+	}
+
+	existing.Status = int(status.Int64)
+	if len(headers) > 0 {
+		if err := json.Unmarshal(headers, &existing.Headers); err != nil {
+			return domain.IdempotencyRecord{}, false, err
+		}
+	}
+	return existing, false, nil
+}
+
+// Complete сохраняет ответ на запрос, занявший ключ
+func (r *IdempotencyRepo) Complete(ctx context.Context, rec domain.IdempotencyRecord) error {
+	headers, err := json.Marshal(rec.Headers)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE idempotency_keys
+			  SET status = $3, response_headers = $4, response_body = $5
+			  WHERE owner = $1 AND key = $2`
+	if _, err := r.db.ExecContext(ctx, query, rec.Owner, rec.Key, rec.Status, headers, rec.Body); err != nil {
+		r.logger.Error("ошибка сохранения ответа по ключу идемпотентности", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// Release освобождает ключ, если запрос не удался - клиент сможет повторить его с тем же ключом
+func (r *IdempotencyRepo) Release(ctx context.Context, owner, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE owner = $1 AND key = $2 AND status IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, owner, key); err != nil {
+		r.logger.Error("ошибка освобождения ключа идемпотентности", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// PurgeExpired удаляет протухшие ключи
+func (r *IdempotencyRepo) PurgeExpired(ctx context.Context) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < now()`)
+	if err != nil {
+		r.logger.Error("ошибка очистки ключей идемпотентности", zap.Error(err))
+		return 0, err
+	}
+	return res.RowsAffected()
+}
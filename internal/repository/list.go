@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testovoe_again/internal/domain"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// колонки, по которым разрешена сортировка, и тип, к которому приводим значение из курсора
+var sortColumns = map[string]string{
+	domain.SortByID:          "integer",
+	domain.SortByServiceName: "text",
+	domain.SortByPrice:       "bigint",
+	domain.SortByStartDate:   "date",
+}
+
+// List отдаёт страницу подписок по фильтру с keyset-пагинацией.
+// Возвращается до Limit+1 записей - лишняя запись нужна сервису, чтобы понять, есть ли следующая страница
+func (r *PostgresRepo) List(ctx context.Context, filter domain.ListFilter) ([]domain.Subscription, error) {
+	sortType, ok := sortColumns[filter.SortBy]
+	if !ok {
+		return nil, fmt.Errorf("неизвестная колонка сортировки %q", filter.SortBy)
+	}
+
+	where, args := buildListWhere(filter)
+
+	// условие keyset: (колонка, id) строго после последней записи прошлой страницы
+	if filter.Cursor != nil {
+		op := ">"
+		if filter.SortDesc {
+			op = "<"
+		}
+		args = append(args, filter.Cursor.SortValue, filter.Cursor.ID)
+		where = append(where, fmt.Sprintf("(%s, id) %s ($%d::%s, $%d)",
+			filter.SortBy, op, len(args)-1, sortType, len(args)))
+	}
+
+	direction := "ASC"
+	if filter.SortDesc {
+		direction = "DESC"
+	}
+
+	args = append(args, filter.Limit+1)
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM subscriptions
+		%s
+		ORDER BY %s %s, id %s
+		LIMIT $%d`, subscriptionColumns, whereClause(where), filter.SortBy, direction, direction, len(args))
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		r.logger.Error("ошибка получения списка подписок", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := make([]domain.Subscription, 0, filter.Limit+1)
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			r.logger.Error("ошибка скана строки подписки", zap.Error(err))
+			return nil, err
+		}
+		subscriptions = append(subscriptions, sub)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.logger.Error("ошибка итерации по строке", zap.Error(err))
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+// buildListWhere собирает условия WHERE и аргументы под них, нумерация плейсхолдеров идёт по длине args
+func buildListWhere(filter domain.ListFilter) ([]string, []any) {
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	// корзина и основной листинг не пересекаются
+	if filter.Deleted {
+		where = append(where, "deleted_at IS NOT NULL")
+	} else {
+		where = append(where, "deleted_at IS NULL")
+	}
+
+	if filter.UserID != nil {
+		add("user_id = $%d", *filter.UserID)
+	}
+	if filter.ServiceName != nil {
+		add("service_name = $%d", *filter.ServiceName)
+	}
+	if filter.Currency != nil {
+		add("currency = $%d", *filter.Currency)
+	}
+	if filter.MinPrice != nil {
+		add("price >= $%d", *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		add("price <= $%d", *filter.MaxPrice)
+	}
+	if filter.ActiveAt != nil {
+		args = append(args, *filter.ActiveAt)
+		n := len(args)
+		where = append(where, fmt.Sprintf("start_date <= $%d AND (end_date IS NULL OR end_date >= $%d)", n, n))
+	}
+	if filter.StartFrom != nil {
+		add("start_date >= $%d", *filter.StartFrom)
+	}
+	if filter.StartTo != nil {
+		add("start_date <= $%d", *filter.StartTo)
+	}
+	if filter.EndFrom != nil {
+		add("end_date >= $%d", *filter.EndFrom)
+	}
+	if filter.EndTo != nil {
+		add("end_date <= $%d", *filter.EndTo)
+	}
+
+	return where, args
+}
+
+func whereClause(where []string) string {
+	if len(where) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(where, " AND ")
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// subscriptionColumns - порядок колонок, который ожидает scanSubscription
+const subscriptionColumns = "id, service_name, price, currency, user_id, start_date, end_date, billing_period, billing_period_days, version, deleted_at, overlap_allowed, service_id, status, auto_renew"
+
+// scanSubscription сканирует строку, выбранную с колонками subscriptionColumns
+func scanSubscription(row rowScanner) (domain.Subscription, error) {
+	var (
+		sub          domain.Subscription
+		startT, endT sql.NullTime
+		periodDays   sql.NullInt32
+		deletedAt    sql.NullTime
+		serviceID    sql.NullInt32
+	)
+
+	err := row.Scan(&sub.ID, &sub.ServiceName, &sub.Price, &sub.Currency, &sub.UserID, &startT, &endT,
+		&sub.BillingPeriod, &periodDays, &sub.Version, &deletedAt, &sub.OverlapAllowed, &serviceID, &sub.Status, &sub.AutoRenew)
+	if err != nil {
+		return domain.Subscription{}, err
+	}
+
+	sub.StartDate = domain.FormatDate(startT.Time)
+	if endT.Valid {
+		strEnd := domain.FormatDate(endT.Time)
+		sub.EndDate = &strEnd
+	}
+	if periodDays.Valid {
+		days := int(periodDays.Int32)
+		sub.BillingPeriodDays = &days
+	}
+	if deletedAt.Valid {
+		sub.DeletedAt = &deletedAt.Time
+	}
+	if serviceID.Valid {
+		id := int(serviceID.Int32)
+		sub.ServiceID = &id
+	}
+	return sub, nil
+}
+
+// parseDates переводит строковые даты подписки в то, что кладём в колонки start_date и end_date
+func parseDates(sub domain.Subscription) (time.Time, *time.Time, error) {
+	tStart, err := domain.ParseDate(sub.StartDate)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+
+	var tEnd *time.Time
+	if sub.EndDate != nil {
+		te, err := domain.ParseDate(*sub.EndDate)
+		if err != nil {
+			return time.Time{}, nil, err
+		}
+		tEnd = &te
+	}
+	return tStart, tEnd, nil
+}
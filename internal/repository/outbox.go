@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testovoe_again/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Событие в outbox пишется рядом с записью журнала изменений, в той же транзакции:
+// откатилось изменение - откатилось и событие, закоммитилось - релей его рано или поздно опубликует
+
+// writeOutbox ставит событие об изменении подписки в outbox. after == nil - подписки больше нет, в событие идёт before
+func (r *PostgresRepo) writeOutbox(ctx context.Context, tx *sql.Tx, id int, operation string, before, after *domain.Subscription) error {
+	event, payload, err := newEvent(ctx, operation, before, after)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO outbox (event_id, event, subscription_id, payload) VALUES ($1, $2, $3, $4::jsonb)`
+	if _, err := tx.ExecContext(ctx, query, event.ID, event.Type, id, string(payload)); err != nil {
+		r.logger.Error("ошибка записи события в outbox", zap.Error(err), zap.Int("id", id))
+		return err
+	}
+	return nil
+}
+
+// newEvent - событие об изменении подписки и его JSON для колонки payload
+func newEvent(ctx context.Context, operation string, before, after *domain.Subscription) (domain.Event, []byte, error) {
+	sub := after
+	if sub == nil {
+		sub = before
+	}
+	event := domain.Event{
+		ID:           uuid.New(),
+		Type:         domain.EventForOperation(operation),
+		OccurredAt:   time.Now().UTC(),
+		Actor:        domain.ActorFromContext(ctx),
+		Subscription: sub,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return domain.Event{}, nil, err
+	}
+	return event, payload, nil
+}
+
+// OutboxRepository - чтение outbox для релея
+type OutboxRepository interface {
+	// Relay берёт до limit неопубликованных событий по порядку и отдаёт их в publish. Опубликованные помечаются
+	// в той же транзакции, на первой ошибке проход останавливается, чтобы не обгонять упавшее событие.
+	// Упади процесс между publish и коммитом - событие уйдёт ещё раз, отсюда at-least-once.
+	// Ошибка публикации возвращается вместе с числом опубликованных до неё событий
+	Relay(ctx context.Context, limit int, publish func(ctx context.Context, event domain.OutboxEvent) error) (int, error)
+	// PurgePublished удаляет события, опубликованные раньше before
+	PurgePublished(ctx context.Context, before time.Time) (int64, error)
+}
+
+type OutboxRepo struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewOutboxRepo(db *sql.DB, logger *zap.Logger) *OutboxRepo {
+	return &OutboxRepo{db: db, logger: logger}
+}
+
+// Relay - строки под FOR UPDATE SKIP LOCKED, так что релеи на разных репликах не публикуют одно событие одновременно
+func (r *OutboxRepo) Relay(ctx context.Context, limit int, publish func(ctx context.Context, event domain.OutboxEvent) error) (int, error) {
+	query := `SELECT id, event_id, event, subscription_id, payload, created_at
+			  FROM outbox
+			  WHERE published_at IS NULL
+			  ORDER BY id
+			  LIMIT $1
+			  FOR UPDATE SKIP LOCKED`
+
+	var (
+		published  int
+		publishErr error
+	)
+	err := runTx(ctx, r.db, r.logger, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, limit)
+		if err != nil {
+			r.logger.Error("ошибка чтения outbox", zap.Error(err))
+			return err
+		}
+		var events []domain.OutboxEvent
+		for rows.Next() {
+			var (
+				event   domain.OutboxEvent
+				payload []byte
+			)
+			if err := rows.Scan(&event.ID, &event.EventID, &event.Type, &event.SubscriptionID, &payload, &event.CreatedAt); err != nil {
+				rows.Close()
+				r.logger.Error("ошибка скана строки outbox", zap.Error(err))
+				return err
+			}
+			event.Payload = payload
+			events = append(events, event)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			r.logger.Error("ошибка итерации по строке", zap.Error(err))
+			return err
+		}
+
+		for _, event := range events {
+			if err := publish(ctx, event); err != nil {
+				r.logger.Warn("событие не опубликовано", zap.Error(err), zap.String("event_id", event.EventID.String()))
+				_, markErr := tx.ExecContext(ctx, `UPDATE outbox SET attempts = attempts + 1, last_error = $1 WHERE id = $2`,
+					err.Error(), event.ID)
+				if markErr != nil {
+					r.logger.Error("ошибка записи неудачной публикации", zap.Error(markErr), zap.Int64("id", event.ID))
+					return markErr
+				}
+				// уже опубликованные помечаются коммитом, упавшее событие повторится на следующем проходе
+				publishErr = err
+				return nil
+			}
+			_, err := tx.ExecContext(ctx, `UPDATE outbox SET published_at = now(), attempts = attempts + 1, last_error = NULL WHERE id = $1`,
+				event.ID)
+			if err != nil {
+				r.logger.Error("ошибка пометки события опубликованным", zap.Error(err), zap.Int64("id", event.ID))
+				return err
+			}
+			published++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return published, publishErr
+}
+
+func (r *OutboxRepo) PurgePublished(ctx context.Context, before time.Time) (int64, error) {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM outbox WHERE published_at < $1`, before)
+	if err != nil {
+		r.logger.Error("ошибка очистки outbox", zap.Error(err))
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+)
+
+// Пересечения периодов считаются функцией subscription_period из миграции, а "тот же сервис" - функцией
+// subscription_service_key, теми же, что стоят в ограничении subscriptions_no_overlap, чтобы сервис и база
+// не расходились в том, что такое пересечение
+
+// exclusionViolation - SQLSTATE нарушения EXCLUDE-ограничения
+const exclusionViolation = "23P01"
+
+// writeError - ошибка записи подписки. Нарушение ограничения на пересечения или внешнего ключа на пользователя - ожидаемая ошибка клиента,
+// а не сбой базы: отдаём типизированную ошибку без текста postgres, в нём ключи чужих строк
+func (r *PostgresRepo) writeError(msg string, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == exclusionViolation {
+		r.logger.Warn("подписка пересекается с другой подпиской сервиса", zap.String("constraint", pgErr.ConstraintName))
+		return errors.ErrSubscriptionOverlap
+	}
+	// пользователя нет в таблице users: сервис проверяет его заранее, но между проверкой и записью его могли удалить
+	if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation && pgErr.ConstraintName == "subscriptions_user_id_fkey" {
+		r.logger.Warn("подписка на неизвестного пользователя")
+		return errors.ErrInvalidUserID
+	}
+	r.logger.Error(msg, zap.Error(err))
+	return err
+}
+
+// Overlaps - живые подписки того же пользователя на тот же сервис, период которых пересекается с sub.
+// Тот же сервис - тот же сервис каталога, если подписка к нему привязана, иначе то же название.
+// excludeID - id самой sub при обновлении, 0 при создании
+func (r *PostgresRepo) Overlaps(ctx context.Context, sub domain.Subscription, excludeID int) ([]domain.Subscription, error) {
+	tStart, tEnd, err := parseDates(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + subscriptionColumns + `
+			  FROM subscriptions
+			  WHERE user_id = $1 AND subscription_service_key(service_id, service_name) = subscription_service_key($6, $2)
+			    AND deleted_at IS NULL AND id <> $3
+			    AND subscription_period(start_date, end_date) && subscription_period($4::date, $5::date)
+			  ORDER BY id`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, sub.UserID, sub.ServiceName, excludeID, tStart, tEnd, sub.ServiceID)
+	if err != nil {
+		r.logger.Error("ошибка поиска пересекающихся подписок", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []domain.Subscription
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			r.logger.Error("ошибка скана строки подписки", zap.Error(err))
+			return nil, err
+		}
+		result = append(result, s)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("ошибка итерации по строке", zap.Error(err))
+		return nil, err
+	}
+	return result, nil
+}
+
+// Merge в одной транзакции отправляет в корзину поглощённые подписки absorbed и записывает в id
+// подписку с объединённым периодом. Удаление идёт первым, иначе расширенный период упрётся в ограничение
+func (r *PostgresRepo) Merge(ctx context.Context, id int, merged domain.Subscription, version int, absorbed []int) (int, error) {
+	var newVersion int
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		for _, absorbedID := range absorbed {
+			if err := r.deleteInTx(ctx, tx, absorbedID, 0); err != nil {
+				return err
+			}
+		}
+		var err error
+		newVersion, err = r.updateInTx(ctx, tx, id, merged, version)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return newVersion, nil
+}
+
+// Conflicts - все пары пересекающихся живых подписок, по одному пользователю, если userID задан
+func (r *PostgresRepo) Conflicts(ctx context.Context, userID *uuid.UUID) ([]domain.Conflict, error) {
+	query := `SELECT a.user_id, a.service_name, a.id, b.id, lower(p.overlap), upper(p.overlap) - 1
+			  FROM subscriptions a
+			  JOIN subscriptions b
+			    ON b.user_id = a.user_id AND b.id > a.id
+			   AND subscription_service_key(b.service_id, b.service_name) = subscription_service_key(a.service_id, a.service_name)
+			   AND b.deleted_at IS NULL
+			  CROSS JOIN LATERAL (
+			      SELECT subscription_period(a.start_date, a.end_date) * subscription_period(b.start_date, b.end_date) AS overlap
+			  ) p
+			  WHERE a.deleted_at IS NULL
+			    AND NOT isempty(p.overlap)
+			    AND ($1::uuid IS NULL OR a.user_id = $1)
+			  ORDER BY a.user_id, a.service_name, a.id, b.id`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, userID)
+	if err != nil {
+		r.logger.Error("ошибка поиска пересечений подписок", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]domain.Conflict, 0)
+	for rows.Next() {
+		var (
+			c        domain.Conflict
+			from, to sql.NullTime
+		)
+		if err := rows.Scan(&c.UserID, &c.ServiceName, &c.FirstID, &c.SecondID, &from, &to); err != nil {
+			r.logger.Error("ошибка скана строки пересечения", zap.Error(err))
+			return nil, err
+		}
+		c.From = domain.FormatDate(from.Time)
+		if to.Valid {
+			end := domain.FormatDate(to.Time)
+			c.To = &end
+		}
+		result = append(result, c)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("ошибка итерации по строке", zap.Error(err))
+		return nil, err
+	}
+	return result, nil
+}
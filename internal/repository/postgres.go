@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 	"testovoe_again/internal/domain"
 	"testovoe_again/internal/errors"
 	"time"
@@ -15,10 +17,46 @@ import (
 type SubscriptionRepository interface {
 	Create(ctx context.Context, sub domain.Subscription) (int, error)
 	GetByID(ctx context.Context, id int) (domain.Subscription, error)
-	Update(ctx context.Context, id int, sub domain.Subscription) error
-	Delete(ctx context.Context, id int) error
-	GetStatsByServiceName(ctx context.Context, userID uuid.UUID, serviceName string, time1, time2 time.Time) (int, error)
+	// GetForUpdate - GetByID под FOR UPDATE: внутри TxManager.WithinTx подписку до конца транзакции никто не изменит
+	GetForUpdate(ctx context.Context, id int) (domain.Subscription, error)
+	// Update, Patch и Delete условные по версии: version == 0 значит "любая версия",
+	// иначе при несовпадении возвращается ErrVersionConflict. Update и Patch отдают новую версию
+	Update(ctx context.Context, id int, sub domain.Subscription, version int) (int, error)
+	Patch(ctx context.Context, id int, patch domain.SubscriptionPatch, version int) (int, error)
+	Delete(ctx context.Context, id int, version int) error
+
+	// корзина: Delete мягкий, Restore возвращает подписку из корзины и отдаёт новую версию,
+	// PurgeDeleted насовсем удаляет подписки, лежащие в корзине дольше before
+	Restore(ctx context.Context, id int) (int, error)
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+
+	// ChangeStatus - действие pause/resume/cancel/renew, отдаёт новую версию
+	ChangeStatus(ctx context.Context, id int, change domain.StatusChange, version int) (int, error)
+	// ProcessExpiring - пакет воркера истечения после курсора after, что делать с каждой подпиской решает plan
+	ProcessExpiring(ctx context.Context, expireBefore, renewBefore time.Time, after domain.ExpiryCursor, limit int, plan ExpiryPlan) (domain.ExpiryStats, error)
+
+	// History - журнал изменений подписки, пишется в одной транзакции с каждым изменением
+	History(ctx context.Context, id int) ([]domain.AuditEntry, error)
+
+	// Batch выполняет пакет операций в одной транзакции общими запросами с политикой пересечений policy:
+	// atomic - всё или ничего, иначе падение одной операции не откатывает остальные.
+	// Операции с заполненным Err пропускаются, то, что нельзя записать общими запросами, выполняет fallback
+	Batch(ctx context.Context, ops []domain.BatchOp, atomic bool, policy string, fallback BatchFallback) ([]domain.BatchResult, error)
+	// окно [from, to), to - не включительно
+	GetStatsByServiceName(ctx context.Context, userID uuid.UUID, serviceName string, from, to time.Time) (map[string]int64, error)
 	GetByUserID(ctx context.Context, userID uuid.UUID) ([]domain.Subscription, error)
+	List(ctx context.Context, filter domain.ListFilter) ([]domain.Subscription, error)
+	// Export - потоковая выгрузка под фильтром листинга, без лимита
+	Export(ctx context.Context, filter domain.ListFilter, fn func(domain.Subscription) error) error
+
+	// пересечения периодов подписок одного пользователя на один сервис
+	Overlaps(ctx context.Context, sub domain.Subscription, excludeID int) ([]domain.Subscription, error)
+	Merge(ctx context.Context, id int, merged domain.Subscription, version int, absorbed []int) (int, error)
+	Conflicts(ctx context.Context, userID *uuid.UUID) ([]domain.Conflict, error)
+
+	// аналитика
+	SpendByMonth(ctx context.Context, filter domain.AnalyticsFilter) ([]domain.SpendRow, error)
+	SpendByService(ctx context.Context, filter domain.AnalyticsFilter) ([]domain.SpendRow, error)
 }
 type PostgresRepo struct {
 	db     *sql.DB
@@ -30,20 +68,37 @@ func NewPostgresRepo(db *sql.DB, logger *zap.Logger) *PostgresRepo {
 }
 
 func (p *PostgresRepo) Create(ctx context.Context, sub domain.Subscription) (int, error) {
-	query := `INSERT INTO subscriptions (service_name, price, user_id, start_date) 
-			  VALUES ($1, $2, $3, $4) RETURNING id`
+	var id int
+	err := p.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		id, err = p.createInTx(ctx, tx, sub)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// createInTx - Create внутри уже открытой транзакции
+func (p *PostgresRepo) createInTx(ctx context.Context, tx *sql.Tx, sub domain.Subscription) (int, error) {
+	query := `INSERT INTO subscriptions (service_name, price, currency, user_id, start_date, end_date, billing_period, billing_period_days, overlap_allowed, service_id, status, auto_renew) 
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`
 
 	var id int
 
-	tStart, err := time.Parse("01-2006", sub.StartDate)
+	tStart, tEnd, err := parseDates(sub)
 	if err != nil {
-		p.logger.Error("невалидное поле start_date", zap.Error(err))
+		p.logger.Error("невалидные даты подписки", zap.Error(err))
 		return 0, err
 	}
 
-	err = p.db.QueryRowContext(ctx, query, sub.ServiceName, sub.Price, sub.UserID, tStart).Scan(&id)
+	err = tx.QueryRowContext(ctx, query, sub.ServiceName, sub.Price, sub.Currency, sub.UserID, tStart, tEnd,
+		sub.BillingPeriod, sub.BillingPeriodDays, sub.OverlapAllowed, sub.ServiceID, sub.Status, sub.AutoRenew).Scan(&id)
 	if err != nil {
-		p.logger.Error("ошибка при создании подписки", zap.Error(err))
+		return 0, p.writeError("ошибка при создании подписки", err)
+	}
+	if err := p.auditAfter(ctx, tx, id, domain.AuditCreate, nil); err != nil {
 		return 0, err
 	}
 	return id, nil
@@ -51,11 +106,11 @@ func (p *PostgresRepo) Create(ctx context.Context, sub domain.Subscription) (int
 
 func (r *PostgresRepo) GetByUserID(ctx context.Context, userID uuid.UUID) ([]domain.Subscription, error) {
 	query := `
-        SELECT id, service_name, price, user_id, start_date, end_date 
+        SELECT ` + subscriptionColumns + ` 
         FROM subscriptions 
-        WHERE user_id = $1`
+        WHERE user_id = $1 AND deleted_at IS NULL`
 
-	rows, err := r.db.QueryContext(ctx, query, userID)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, userID)
 	if err != nil {
 		r.logger.Error("ошибка получения пользователя", zap.Error(err))
 		return nil, err
@@ -66,28 +121,12 @@ func (r *PostgresRepo) GetByUserID(ctx context.Context, userID uuid.UUID) ([]dom
 	var subscriptions []domain.Subscription
 
 	for rows.Next() {
-		var sub domain.Subscription
-		var startT, endT sql.NullTime
-
-		err := rows.Scan(
-			&sub.ID,
-			&sub.ServiceName,
-			&sub.Price,
-			&sub.UserID,
-			&startT,
-			&endT,
-		)
+		sub, err := scanSubscription(rows)
 		if err != nil {
 			r.logger.Error("ошибка скана строки подписки", zap.Error(err))
 			return nil, err
 		}
 
-		sub.StartDate = startT.Time.Format("01-2006")
-		if endT.Valid {
-			strEnd := endT.Time.Format("01-2006")
-			sub.EndDate = &strEnd
-		}
-
 		subscriptions = append(subscriptions, sub)
 	}
 
@@ -103,84 +142,258 @@ func (r *PostgresRepo) GetByUserID(ctx context.Context, userID uuid.UUID) ([]dom
 // в ТЗ сказано "... ручки для операций над записями о подписках", т.е. Я подразумеваю что этим методом будет пользоваться
 // не пользователь сервиса подписок, а метод будет систематически вызываться условно для продления подписки, возможно
 // в случае обновлении цены, обновлении end_date.
-func (r *PostgresRepo) Update(ctx context.Context, id int, sub domain.Subscription) error {
+//
+// Обновление условное: если version != 0, строка обновится только если её версия не поменялась с момента чтения,
+// иначе два параллельных Update молча перетирали бы друг друга
+func (r *PostgresRepo) Update(ctx context.Context, id int, sub domain.Subscription, version int) (int, error) {
+	var newVersion int
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		newVersion, err = r.updateInTx(ctx, tx, id, sub, version)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return newVersion, nil
+}
+
+func (r *PostgresRepo) updateInTx(ctx context.Context, tx *sql.Tx, id int, sub domain.Subscription, version int) (int, error) {
 	query := `UPDATE subscriptions 
-			  SET price = $1, service_name = $2, start_date = $3, end_date = $4
-			  WHERE id = $5`
+			  SET price = $1, service_name = $2, start_date = $3, end_date = $4, currency = $5,
+			      billing_period = $6, billing_period_days = $7, overlap_allowed = $8, service_id = $9, auto_renew = $10, version = version + 1
+			  WHERE id = $11
+			  RETURNING version`
 
-	var tStart time.Time
-	var err error
-	if sub.StartDate != "" {
-		tStart, err = time.Parse("01-2006", sub.StartDate)
+	tStart, tEnd, err := parseDates(sub)
+	if err != nil {
+		r.logger.Error("невалидные даты подписки", zap.Error(err))
+		return 0, err
+	}
+
+	before, err := r.lockSubscription(ctx, tx, id, version, false)
+	if err != nil {
+		return 0, err
+	}
+
+	var newVersion int
+	err = tx.QueryRowContext(ctx, query, sub.Price, sub.ServiceName, tStart, tEnd, sub.Currency,
+		sub.BillingPeriod, sub.BillingPeriodDays, sub.OverlapAllowed, sub.ServiceID, sub.AutoRenew, id).Scan(&newVersion)
+	if err != nil {
+		return 0, r.writeError("ошибка обновления подписки", err)
+	}
+	if err := r.auditAfter(ctx, tx, id, domain.AuditUpdate, &before); err != nil {
+		return 0, err
+	}
+	return newVersion, nil
+}
+
+// Patch обновляет только те колонки, которые пришли в патче, остальные не попадают в SET вообще
+func (r *PostgresRepo) Patch(ctx context.Context, id int, patch domain.SubscriptionPatch, version int) (int, error) {
+	var (
+		set  []string
+		args []any
+	)
+	add := func(column string, value any) {
+		args = append(args, value)
+		set = append(set, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if patch.ServiceName != nil {
+		add("service_name", *patch.ServiceName)
+	}
+	if patch.Price != nil {
+		add("price", *patch.Price)
+	}
+	if patch.Currency != nil {
+		add("currency", *patch.Currency)
+	}
+	if patch.StartDate != nil {
+		t, err := domain.ParseDate(*patch.StartDate)
 		if err != nil {
 			r.logger.Error("невалидное поле start_date", zap.Error(err))
-			return err
+			return 0, err
 		}
+		add("start_date", t)
 	}
-
-	var tEnd *time.Time
-	if sub.EndDate != nil {
-		te, err := time.Parse("01-2006", *sub.EndDate)
+	if patch.EndDate != nil {
+		t, err := domain.ParseDate(*patch.EndDate)
 		if err != nil {
 			r.logger.Error("невалидное поле end_date", zap.Error(err))
-			return err
+			return 0, err
 		}
-		tEnd = &te
+		add("end_date", t)
+	}
+	if patch.ClearEndDate {
+		add("end_date", nil)
+	}
+	if patch.BillingPeriod != nil {
+		add("billing_period", *patch.BillingPeriod)
+	}
+	if patch.BillingPeriodDays != nil {
+		add("billing_period_days", *patch.BillingPeriodDays)
+	}
+	if patch.ClearBillingPeriodDays {
+		add("billing_period_days", nil)
 	}
+	if patch.AutoRenew != nil {
+		add("auto_renew", *patch.AutoRenew)
+	}
+
+	if len(set) == 0 {
+		return version, nil
+	}
+	set = append(set, "version = version + 1")
+
+	args = append(args, id)
+	query := fmt.Sprintf(`UPDATE subscriptions SET %s WHERE id = $%d RETURNING version`,
+		strings.Join(set, ", "), len(args))
 
-	_, err = r.db.ExecContext(ctx, query, sub.Price, sub.ServiceName, tStart, tEnd, id)
+	var newVersion int
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		before, err := r.lockSubscription(ctx, tx, id, version, false)
+		if err != nil {
+			return err
+		}
+		if err := tx.QueryRowContext(ctx, query, args...).Scan(&newVersion); err != nil {
+			return r.writeError("ошибка частичного обновления подписки", err)
+		}
+		return r.auditAfter(ctx, tx, id, domain.AuditPatch, &before)
+	})
 	if err != nil {
-		r.logger.Error("ошибка обновления подписки", zap.Error(err))
-		return err
+		return 0, err
 	}
-	return nil
+	return newVersion, nil
 }
 
-func (r *PostgresRepo) Delete(ctx context.Context, id int) error {
-	query := `DELETE FROM subscriptions 
+// Delete мягкий: строка остаётся в базе с deleted_at и пропадает из всех чтений и статистики.
+// Насовсем её удаляет PurgeDeleted по истечении срока хранения корзины
+func (r *PostgresRepo) Delete(ctx context.Context, id int, version int) error {
+	return r.withTx(ctx, func(tx *sql.Tx) error {
+		return r.deleteInTx(ctx, tx, id, version)
+	})
+}
+
+func (r *PostgresRepo) deleteInTx(ctx context.Context, tx *sql.Tx, id int, version int) error {
+	query := `UPDATE subscriptions 
+              SET deleted_at = now(), version = version + 1
               WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
+
+	before, err := r.lockSubscription(ctx, tx, id, version, false)
 	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
 		r.logger.Error("ошибка удаления подписки", zap.Error(err))
 		return err
 	}
-	return nil
+	return r.auditAfter(ctx, tx, id, domain.AuditDelete, &before)
 }
 
-func (r *PostgresRepo) GetStatsByServiceName(ctx context.Context, userID uuid.UUID, serviceName string, time1, time2 time.Time) (int, error) {
-	query := `
-		SELECT COALESCE(SUM(price), 0) 
-		FROM subscriptions 
-		WHERE user_id = $1 
-		  AND service_name = $2 
-		  AND start_date BETWEEN $3 AND $4`
+func (r *PostgresRepo) Restore(ctx context.Context, id int) (int, error) {
+	query := `UPDATE subscriptions
+			  SET deleted_at = NULL, version = version + 1
+			  WHERE id = $1
+			  RETURNING version`
+
+	var version int
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		before, err := r.lockSubscription(ctx, tx, id, 0, true)
+		if err != nil {
+			return err
+		}
+		// пока подписка лежала в корзине, период могла занять другая - тогда восстановить её нельзя
+		if err := tx.QueryRowContext(ctx, query, id).Scan(&version); err != nil {
+			return r.writeError("ошибка восстановления подписки", err)
+		}
+		return r.auditAfter(ctx, tx, id, domain.AuditRestore, &before)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
 
-	var result int
+// PurgeDeleted удаляет строки насовсем, поэтому последний снимок каждой подписки остаётся только в журнале
+func (r *PostgresRepo) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM subscriptions
+			  WHERE deleted_at IS NOT NULL AND deleted_at < $1
+			  RETURNING ` + subscriptionColumns
 
-	err := r.db.QueryRowContext(ctx, query, userID, serviceName, time1, time2).Scan(&result)
+	var purged int64
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, before)
+		if err != nil {
+			r.logger.Error("ошибка очистки корзины", zap.Error(err))
+			return err
+		}
+		var subs []domain.Subscription
+		for rows.Next() {
+			sub, err := scanSubscription(rows)
+			if err != nil {
+				rows.Close()
+				r.logger.Error("ошибка скана строки подписки", zap.Error(err))
+				return err
+			}
+			subs = append(subs, sub)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			r.logger.Error("ошибка итерации по строке", zap.Error(err))
+			return err
+		}
+
+		for i := range subs {
+			if err := r.writeAudit(ctx, tx, subs[i].ID, domain.AuditPurge, &subs[i], nil); err != nil {
+				return err
+			}
+		}
+		purged = int64(len(subs))
+		return nil
+	})
 	if err != nil {
-		r.logger.Error("ошибка получения статистики", zap.Error(err))
 		return 0, err
 	}
-	return result, nil
+	return purged, nil
+}
+
+// auditAfter перечитывает подписку после изменения и пишет в журнал пару before/after
+func (r *PostgresRepo) auditAfter(ctx context.Context, tx *sql.Tx, id int, operation string, before *domain.Subscription) error {
+	after, err := getInTx(ctx, tx, id)
+	if err != nil {
+		r.logger.Error("ошибка чтения подписки после изменения", zap.Error(err))
+		return err
+	}
+	return r.writeAudit(ctx, tx, id, operation, before, &after)
+}
+
+// GetStatsByServiceName считает стоимость подписок пользователя на сервис за окно [from, to).
+// Раньше тут суммировался price подписок, начавшихся внутри периода, теперь каждая подписка разворачивается
+// в расчётные периоды и в сумму идёт доля цены каждого периода, попавшая в окно (см. chargesCTE).
+// Суммы складывать между валютами нельзя, поэтому результат - сумма в минорных единицах по каждой валюте
+func (r *PostgresRepo) GetStatsByServiceName(ctx context.Context, userID uuid.UUID, serviceName string, from, to time.Time) (map[string]int64, error) {
+	return r.SpendByCurrency(ctx, domain.AnalyticsFilter{
+		UserID:      &userID,
+		ServiceName: &serviceName,
+		From:        from,
+		To:          to,
+	})
 }
 
 func (r *PostgresRepo) GetByID(ctx context.Context, id int) (domain.Subscription, error) {
-	query := `SELECT id, service_name, price, user_id, start_date, end_date
-			  FROM subscriptions
-			  WHERE id = $1`
+	return r.getByID(ctx, id, "")
+}
 
-	var (
-		result       domain.Subscription
-		StartT, EndT sql.NullTime
-	)
+func (r *PostgresRepo) GetForUpdate(ctx context.Context, id int) (domain.Subscription, error) {
+	return r.getByID(ctx, id, " FOR UPDATE")
+}
+
+func (r *PostgresRepo) getByID(ctx context.Context, id int, lock string) (domain.Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + `
+			  FROM subscriptions
+			  WHERE id = $1 AND deleted_at IS NULL` + lock
 
-	err := r.db.QueryRowContext(ctx, query, id).Scan(&result.ID,
-		&result.ServiceName,
-		&result.Price,
-		&result.UserID,
-		&StartT,
-		&EndT)
+	result, err := scanSubscription(conn(ctx, r.db).QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			r.logger.Warn("подписка не найдена", zap.Int("id", id))
@@ -190,11 +403,5 @@ func (r *PostgresRepo) GetByID(ctx context.Context, id int) (domain.Subscription
 		return domain.Subscription{}, err
 	}
 
-	result.StartDate = StartT.Time.Format("01-2006")
-	if EndT.Valid {
-		strEnd := EndT.Time.Format("01-2006")
-		result.EndDate = &strEnd
-	}
-
 	return result, nil
 }
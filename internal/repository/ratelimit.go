@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testovoe_again/internal/ratelimit"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RateLimitRepo - хранилище бакетов rate limiter'а в таблице rate_limit_buckets, лимит общий для всех реплик
+type RateLimitRepo struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewRateLimitRepo(db *sql.DB, logger *zap.Logger) *RateLimitRepo {
+	return &RateLimitRepo{db: db, logger: logger}
+}
+
+// Take пополняет бакет и забирает токен одним upsert'ом, построчная блокировка ON CONFLICT сериализует
+// конкурентные запросы с одним ключом. При отказе строка не меняется, поэтому updated_at = now()
+// в RETURNING - это и есть признак того, что токен списан
+func (r *RateLimitRepo) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	query := `INSERT INTO rate_limit_buckets AS b (key, tokens, updated_at)
+			  VALUES ($1, $3::float8 - 1, now())
+			  ON CONFLICT (key) DO UPDATE SET
+				tokens = LEAST($3, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at) * $2) - 1,
+				updated_at = now()
+			  WHERE LEAST($3, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at) * $2) >= 1
+			  RETURNING b.tokens + 1`
+
+	var level float64
+	err := r.db.QueryRowContext(ctx, query, key, limit.Rate, limit.Burst).Scan(&level)
+	if err == nil {
+		return ratelimit.NewResult(level, true, limit), nil
+	}
+	if err != sql.ErrNoRows {
+		r.logger.Error("ошибка списания токена rate limit", zap.Error(err))
+		return ratelimit.Result{}, err
+	}
+
+	// WHERE не пропустил апдейт - токенов нет, дочитываем уровень бакета для Retry-After
+	query = `SELECT LEAST($2, tokens + EXTRACT(EPOCH FROM now() - updated_at) * $3)
+			 FROM rate_limit_buckets WHERE key = $1`
+	if err := r.db.QueryRowContext(ctx, query, key, limit.Burst, limit.Rate).Scan(&level); err != nil {
+		r.logger.Error("ошибка чтения бакета rate limit", zap.Error(err))
+		return ratelimit.Result{}, err
+	}
+	return ratelimit.NewResult(level, false, limit), nil
+}
+
+func (r *RateLimitRepo) Sweep(ctx context.Context, idle time.Duration) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM rate_limit_buckets WHERE updated_at < now() - $1 * interval '1 second'`, idle.Seconds())
+	if err != nil {
+		r.logger.Error("ошибка очистки бакетов rate limit", zap.Error(err))
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testovoe_again/internal/errors"
+
+	"go.uber.org/zap"
+)
+
+// ExchangeRateRepo - провайдер курсов поверх таблицы exchange_rates,
+// в таблице хранится стоимость одной единицы валюты в базовой валюте (у базовой rate = 1)
+type ExchangeRateRepo struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewExchangeRateRepo(db *sql.DB, logger *zap.Logger) *ExchangeRateRepo {
+	return &ExchangeRateRepo{db: db, logger: logger}
+}
+
+func (r *ExchangeRateRepo) Rate(ctx context.Context, from, to string) (float64, error) {
+	query := `SELECT 
+				(SELECT rate FROM exchange_rates WHERE currency = $1)::float8,
+				(SELECT rate FROM exchange_rates WHERE currency = $2)::float8`
+
+	var fromRate, toRate sql.NullFloat64
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, from, to).Scan(&fromRate, &toRate)
+	if err != nil {
+		r.logger.Error("ошибка получения курса валют", zap.Error(err))
+		return 0, err
+	}
+	if !fromRate.Valid || !toRate.Valid || fromRate.Float64 <= 0 || toRate.Float64 <= 0 {
+		r.logger.Warn("нет курса для пересчёта", zap.String("from", from), zap.String("to", to))
+		return 0, errors.ErrUnknownRate
+	}
+	return fromRate.Float64 / toRate.Float64, nil
+}
@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ChangeStatus применяет действие над подпиской: статус, новая end_date и интервал паузы меняются
+// в одной транзакции с записью в журнал. Условие по версии - как у Update, version == 0 - любая версия
+func (r *PostgresRepo) ChangeStatus(ctx context.Context, id int, change domain.StatusChange, version int) (int, error) {
+	var newVersion int
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		before, err := r.lockSubscription(ctx, tx, id, version, false)
+		if err != nil {
+			return err
+		}
+		// сервис проверял действие по статусу, прочитанному до блокировки
+		if before.Status != change.From {
+			r.logger.Warn("статус подписки изменился до применения действия", zap.Int("id", id),
+				zap.String("expected", change.From), zap.String("actual", before.Status))
+			return errors.ErrInvalidTransition
+		}
+		newVersion, err = r.applyStatusChange(ctx, tx, before, change)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return newVersion, nil
+}
+
+// ExpiryPlan решает, что делать с подпиской, у которой подходит end_date. renew == false - продлевать нельзя:
+// продление наехало на другую подписку сервиса. ok == false - подписку пока не трогать
+type ExpiryPlan func(sub domain.Subscription, renew bool) (change domain.StatusChange, ok bool, err error)
+
+// ProcessExpiring - один пакет воркера истечения: до limit подписок после курсора after с end_date раньше expireBefore,
+// а с auto_renew - раньше renewBefore. Строки берутся под FOR UPDATE SKIP LOCKED, так что реплики
+// разбирают разные подписки, а занятые пользователем прямо сейчас достанутся следующему проходу.
+// Каждая подписка под своим savepoint: продление, упёршееся в пересечение, не откатывает остальные
+func (r *PostgresRepo) ProcessExpiring(ctx context.Context, expireBefore, renewBefore time.Time, after domain.ExpiryCursor, limit int, plan ExpiryPlan) (domain.ExpiryStats, error) {
+	query := `SELECT ` + subscriptionColumns + `
+			  FROM subscriptions
+			  WHERE deleted_at IS NULL AND end_date IS NOT NULL AND status <> 'expired'
+			    AND (end_date < $1 OR (auto_renew AND status IN ('trial', 'active') AND end_date < $2))
+			    AND (end_date, id) > ($4::date, $5::int)
+			  ORDER BY end_date, id
+			  LIMIT $3
+			  FOR UPDATE SKIP LOCKED`
+
+	var stats domain.ExpiryStats
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, query, expireBefore, renewBefore, limit, after.EndDate, after.ID)
+		if err != nil {
+			r.logger.Error("ошибка поиска истекающих подписок", zap.Error(err))
+			return err
+		}
+		var subs []domain.Subscription
+		for rows.Next() {
+			sub, err := scanSubscription(rows)
+			if err != nil {
+				rows.Close()
+				r.logger.Error("ошибка скана строки подписки", zap.Error(err))
+				return err
+			}
+			subs = append(subs, sub)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			r.logger.Error("ошибка итерации по строке", zap.Error(err))
+			return err
+		}
+
+		stats.Scanned = len(subs)
+		if len(subs) > 0 {
+			last := subs[len(subs)-1]
+			end, err := domain.ParseDate(*last.EndDate)
+			if err != nil {
+				return err
+			}
+			stats.Next = domain.ExpiryCursor{EndDate: end, ID: last.ID}
+		}
+
+		for _, sub := range subs {
+			change, err := r.expireOne(ctx, tx, sub, plan)
+			if err != nil {
+				return err
+			}
+			switch change.Action {
+			case domain.ActionRenew:
+				stats.Renewed++
+			case domain.ActionExpire:
+				stats.Expired++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.ExpiryStats{}, err
+	}
+	return stats, nil
+}
+
+// expireOne применяет план к одной подписке, пустой Action в ответе - подписку не трогали
+func (r *PostgresRepo) expireOne(ctx context.Context, tx *sql.Tx, sub domain.Subscription, plan ExpiryPlan) (domain.StatusChange, error) {
+	for _, renew := range []bool{true, false} {
+		change, ok, err := plan(sub, renew)
+		if err != nil || !ok {
+			return domain.StatusChange{}, err
+		}
+
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT expiry_row"); err != nil {
+			r.logger.Error("не удалось поставить savepoint", zap.Error(err))
+			return domain.StatusChange{}, err
+		}
+		_, err = r.applyStatusChange(ctx, tx, sub, change)
+		if err == nil {
+			if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT expiry_row"); err != nil {
+				r.logger.Error("не удалось отпустить savepoint", zap.Error(err))
+				return domain.StatusChange{}, err
+			}
+			return change, nil
+		}
+		if !errors.Is(err, errors.ErrSubscriptionOverlap) || change.Action != domain.ActionRenew {
+			return domain.StatusChange{}, err
+		}
+		if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT expiry_row"); err != nil {
+			r.logger.Error("не удалось откатиться к savepoint", zap.Error(err))
+			return domain.StatusChange{}, err
+		}
+		r.logger.Warn("автопродление пересекается с другой подпиской сервиса", zap.Int("id", sub.ID))
+	}
+	return domain.StatusChange{}, nil
+}
+
+// applyStatusChange - само изменение по уже заблокированной подписке before
+func (r *PostgresRepo) applyStatusChange(ctx context.Context, tx *sql.Tx, before domain.Subscription, change domain.StatusChange) (int, error) {
+	query := `UPDATE subscriptions
+			  SET status = $1, end_date = COALESCE($2, end_date), auto_renew = COALESCE($3, auto_renew), version = version + 1
+			  WHERE id = $4
+			  RETURNING version`
+
+	var endDate any
+	if change.EndDate != nil {
+		t, err := domain.ParseDate(*change.EndDate)
+		if err != nil {
+			return 0, err
+		}
+		endDate = t
+	}
+
+	var autoRenew any
+	if change.AutoRenew != nil {
+		autoRenew = *change.AutoRenew
+	}
+
+	var newVersion int
+	// продление сдвигает end_date и может наехать на другую подписку сервиса
+	if err := tx.QueryRowContext(ctx, query, change.To, endDate, autoRenew, before.ID).Scan(&newVersion); err != nil {
+		return 0, r.writeError("ошибка смены статуса подписки", err)
+	}
+
+	if change.From == domain.StatusPaused {
+		_, err := tx.ExecContext(ctx, `UPDATE subscription_pauses SET resumed_on = $1 WHERE subscription_id = $2 AND resumed_on IS NULL`,
+			change.On, before.ID)
+		if err != nil {
+			r.logger.Error("ошибка закрытия паузы подписки", zap.Error(err), zap.Int("id", before.ID))
+			return 0, err
+		}
+	}
+	if change.To == domain.StatusPaused {
+		_, err := tx.ExecContext(ctx, `INSERT INTO subscription_pauses (subscription_id, paused_on) VALUES ($1, $2)`,
+			before.ID, change.On)
+		if err != nil {
+			r.logger.Error("ошибка записи паузы подписки", zap.Error(err), zap.Int("id", before.ID))
+			return 0, err
+		}
+	}
+	if err := r.auditAfter(ctx, tx, before.ID, change.Action, &before); err != nil {
+		return 0, err
+	}
+	return newVersion, nil
+}
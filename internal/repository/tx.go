@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"go.uber.org/zap"
+)
+
+// Unit of work: транзакция, открытая TxManager, едет в context.Context, и методы репозиториев поверх той же базы
+// сами в неё встают - запросы идут через conn, а runTx внутри неё открывает savepoint вместо новой транзакции.
+// Лимиты запросов и ключи идемпотентности ходят мимо: их состояние не должно откатываться вместе с запросом
+
+// TxManager выполняет fn в одной транзакции: commit при nil, rollback при ошибке.
+// Репозитории, вызванные с ctx из fn, работают внутри неё. Вложенный WithinTx встаёт во внешнюю транзакцию
+// под savepoint: его ошибка откатывает только его изменения, и внешняя транзакция остаётся рабочей
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type PgTxManager struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewTxManager(db *sql.DB, logger *zap.Logger) *PgTxManager {
+	return &PgTxManager{db: db, logger: logger}
+}
+
+func (m *PgTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if tx := txFromContext(ctx); tx != nil {
+		return runNested(ctx, tx, m.logger, func(*sql.Tx) error {
+			return fn(ctx)
+		})
+	}
+	return runTx(ctx, m.db, m.logger, func(tx *sql.Tx) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+type txKey struct{}
+
+func txFromContext(ctx context.Context) *sql.Tx {
+	tx, _ := ctx.Value(txKey{}).(*sql.Tx)
+	return tx
+}
+
+// querier - общее у *sql.DB и *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// conn - транзакция из ctx, если она есть, иначе сама база
+func conn(ctx context.Context, db *sql.DB) querier {
+	if tx := txFromContext(ctx); tx != nil {
+		return tx
+	}
+	return db
+}
+
+// runTx - fn в транзакции, откат при ошибке. Общий для репозиториев поверх одной базы.
+// Внутри транзакции из ctx fn выполняется под savepoint: его ошибка откатывает только её изменения,
+// а решение о всей транзакции остаётся за тем, кто её открыл
+func runTx(ctx context.Context, db *sql.DB, logger *zap.Logger, fn func(tx *sql.Tx) error) error {
+	if tx := txFromContext(ctx); tx != nil {
+		return runNested(ctx, tx, logger, fn)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("не удалось начать транзакцию", zap.Error(err))
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("не удалось закоммитить транзакцию", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func runNested(ctx context.Context, tx *sql.Tx, logger *zap.Logger, fn func(tx *sql.Tx) error) error {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT nested_tx"); err != nil {
+		logger.Error("не удалось поставить savepoint", zap.Error(err))
+		return err
+	}
+	if err := fn(tx); err != nil {
+		if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT nested_tx"); rbErr != nil {
+			logger.Error("не удалось откатиться к savepoint", zap.Error(rbErr))
+		}
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT nested_tx"); err != nil {
+		logger.Error("не удалось отпустить savepoint", zap.Error(err))
+		return err
+	}
+	return nil
+}
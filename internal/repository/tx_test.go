@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	stderrors "errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// logConnector - драйвер database/sql, который ничего не выполняет, а пишет в log каждый оператор,
+// BEGIN, COMMIT и ROLLBACK. fail - операторы, на которых драйвер вернёт ошибку
+type logConnector struct {
+	log  []string
+	fail map[string]bool
+}
+
+var errDriver = stderrors.New("driver: отказ")
+
+func (c *logConnector) Connect(context.Context) (driver.Conn, error) { return &logConn{c: c}, nil }
+func (c *logConnector) Driver() driver.Driver                        { return nil }
+
+func (c *logConnector) record(stmt string) error {
+	c.log = append(c.log, stmt)
+	if c.fail[stmt] {
+		return errDriver
+	}
+	return nil
+}
+
+type logConn struct{ c *logConnector }
+
+func (c *logConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *logConn) Close() error                        { return nil }
+func (c *logConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *logConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	if err := c.c.record("BEGIN"); err != nil {
+		return nil, err
+	}
+	return logTx{c: c.c}, nil
+}
+
+func (c *logConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if err := c.c.record(query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+type logTx struct{ c *logConnector }
+
+func (t logTx) Commit() error   { return t.c.record("COMMIT") }
+func (t logTx) Rollback() error { return t.c.record("ROLLBACK") }
+
+func TestWithinTx(t *testing.T) {
+	exec := func(ctx context.Context, db *sql.DB, stmt string) error {
+		_, err := conn(ctx, db).ExecContext(ctx, stmt)
+		return err
+	}
+	errFn := stderrors.New("ошибка в fn")
+
+	cases := []struct {
+		name    string
+		fail    []string
+		fn      func(ctx context.Context, m *PgTxManager, db *sql.DB) error
+		wantErr error
+		want    []string
+	}{
+		{
+			name: "commit",
+			fn: func(ctx context.Context, m *PgTxManager, db *sql.DB) error {
+				return exec(ctx, db, "INSERT a")
+			},
+			want: []string{"BEGIN", "INSERT a", "COMMIT"},
+		},
+		{
+			name: "ошибка fn откатывает транзакцию",
+			fn: func(ctx context.Context, m *PgTxManager, db *sql.DB) error {
+				_ = exec(ctx, db, "INSERT a")
+				return errFn
+			},
+			wantErr: errFn,
+			want:    []string{"BEGIN", "INSERT a", "ROLLBACK"},
+		},
+		{
+			name: "вложенный WithinTx под savepoint",
+			fn: func(ctx context.Context, m *PgTxManager, db *sql.DB) error {
+				return m.WithinTx(ctx, func(ctx context.Context) error {
+					return exec(ctx, db, "INSERT a")
+				})
+			},
+			want: []string{"BEGIN", "SAVEPOINT nested_tx", "INSERT a", "RELEASE SAVEPOINT nested_tx", "COMMIT"},
+		},
+		{
+			name: "ошибка вложенного откатывает только его",
+			fn: func(ctx context.Context, m *PgTxManager, db *sql.DB) error {
+				_ = m.WithinTx(ctx, func(ctx context.Context) error {
+					_ = exec(ctx, db, "INSERT a")
+					return errFn
+				})
+				return exec(ctx, db, "INSERT b")
+			},
+			want: []string{"BEGIN", "SAVEPOINT nested_tx", "INSERT a", "ROLLBACK TO SAVEPOINT nested_tx", "INSERT b", "COMMIT"},
+		},
+		{
+			name: "ошибка вложенного, отданная наружу, откатывает всё",
+			fn: func(ctx context.Context, m *PgTxManager, db *sql.DB) error {
+				return m.WithinTx(ctx, func(ctx context.Context) error {
+					return errFn
+				})
+			},
+			wantErr: errFn,
+			want:    []string{"BEGIN", "SAVEPOINT nested_tx", "ROLLBACK TO SAVEPOINT nested_tx", "ROLLBACK"},
+		},
+		{
+			name: "runTx репозитория встаёт в транзакцию из ctx",
+			fn: func(ctx context.Context, m *PgTxManager, db *sql.DB) error {
+				return runTx(ctx, db, zap.NewNop(), func(tx *sql.Tx) error {
+					_, err := tx.ExecContext(ctx, "INSERT a")
+					return err
+				})
+			},
+			want: []string{"BEGIN", "SAVEPOINT nested_tx", "INSERT a", "RELEASE SAVEPOINT nested_tx", "COMMIT"},
+		},
+		{
+			name: "savepoint не поставился",
+			fail: []string{"SAVEPOINT nested_tx"},
+			fn: func(ctx context.Context, m *PgTxManager, db *sql.DB) error {
+				return m.WithinTx(ctx, func(ctx context.Context) error {
+					return exec(ctx, db, "INSERT a")
+				})
+			},
+			wantErr: errDriver,
+			want:    []string{"BEGIN", "SAVEPOINT nested_tx", "ROLLBACK"},
+		},
+		{
+			name:    "транзакция не началась",
+			fail:    []string{"BEGIN"},
+			fn:      func(ctx context.Context, m *PgTxManager, db *sql.DB) error { return exec(ctx, db, "INSERT a") },
+			wantErr: errDriver,
+			want:    []string{"BEGIN"},
+		},
+		{
+			name:    "commit не прошёл",
+			fail:    []string{"COMMIT"},
+			fn:      func(ctx context.Context, m *PgTxManager, db *sql.DB) error { return exec(ctx, db, "INSERT a") },
+			wantErr: errDriver,
+			want:    []string{"BEGIN", "INSERT a", "COMMIT"},
+		},
+	}
+
+	for _, c := range cases {
+		connector := &logConnector{fail: make(map[string]bool)}
+		for _, stmt := range c.fail {
+			connector.fail[stmt] = true
+		}
+		db := sql.OpenDB(connector)
+		m := NewTxManager(db, zap.NewNop())
+
+		err := m.WithinTx(context.Background(), func(ctx context.Context) error {
+			if txFromContext(ctx) == nil {
+				t.Errorf("%s: в ctx нет транзакции", c.name)
+			}
+			return c.fn(ctx, m, db)
+		})
+		if c.wantErr == nil && err != nil || c.wantErr != nil && !stderrors.Is(err, c.wantErr) {
+			t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, c.wantErr)
+		}
+		if strings.Join(connector.log, "; ") != strings.Join(c.want, "; ") {
+			t.Errorf("%s:\n  выполнено %q\n  ожидалось %q", c.name, connector.log, c.want)
+		}
+		_ = db.Close()
+	}
+}
+
+func TestConnWithoutTx(t *testing.T) {
+	connector := &logConnector{}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+
+	ctx := context.Background()
+	if _, err := conn(ctx, db).ExecContext(ctx, "INSERT a"); err != nil {
+		t.Fatalf("exec: %v", err)
+	}
+	if strings.Join(connector.log, "; ") != "INSERT a" {
+		t.Errorf("без транзакции в ctx выполнено %q", connector.log)
+	}
+}
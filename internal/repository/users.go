@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+)
+
+// UserRepository - справочник пользователей в таблице users, он же users.Directory по умолчанию
+type UserRepository interface {
+	Create(ctx context.Context, user domain.User) (domain.User, error)
+	Lookup(ctx context.Context, id uuid.UUID) (domain.User, error)
+	// List - пользователи по возрастанию id, начиная после after (uuid.Nil - с начала)
+	List(ctx context.Context, after uuid.UUID, limit int) ([]domain.User, error)
+	Update(ctx context.Context, user domain.User) (domain.User, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	// Upsert заводит или обновляет пользователя, пришедшего из внешнего справочника
+	Upsert(ctx context.Context, user domain.User) error
+}
+
+// foreignKeyViolation - SQLSTATE нарушения внешнего ключа
+const foreignKeyViolation = "23503"
+
+type UserRepo struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewUserRepo(db *sql.DB, logger *zap.Logger) *UserRepo {
+	return &UserRepo{db: db, logger: logger}
+}
+
+const userColumns = "id, email, name, created_at, updated_at"
+
+func scanUser(row rowScanner) (domain.User, error) {
+	var (
+		u     domain.User
+		email sql.NullString
+	)
+	if err := row.Scan(&u.ID, &email, &u.Name, &u.CreatedAt, &u.UpdatedAt); err != nil {
+		return domain.User{}, err
+	}
+	if email.Valid {
+		u.Email = &email.String
+	}
+	return u, nil
+}
+
+// writeError - занятые id или email это ошибка клиента, а не сбой базы
+func (r *UserRepo) writeError(msg string, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		r.logger.Warn("пользователь с таким id или email уже есть", zap.String("constraint", pgErr.ConstraintName))
+		if pgErr.ConstraintName == "idx_users_email" {
+			return errors.ErrUserExists.WithField("email")
+		}
+		return errors.ErrUserExists.WithField("id")
+	}
+	r.logger.Error(msg, zap.Error(err))
+	return err
+}
+
+func (r *UserRepo) Create(ctx context.Context, user domain.User) (domain.User, error) {
+	query := `INSERT INTO users (id, email, name) VALUES ($1, $2, $3)
+			  RETURNING ` + userColumns
+
+	created, err := scanUser(conn(ctx, r.db).QueryRowContext(ctx, query, user.ID, user.Email, user.Name))
+	if err != nil {
+		return domain.User{}, r.writeError("ошибка создания пользователя", err)
+	}
+	return created, nil
+}
+
+func (r *UserRepo) Lookup(ctx context.Context, id uuid.UUID) (domain.User, error) {
+	u, err := scanUser(conn(ctx, r.db).QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.User{}, errors.ErrUserNotFound
+		}
+		r.logger.Error("ошибка чтения пользователя", zap.Error(err), zap.String("id", id.String()))
+		return domain.User{}, err
+	}
+	return u, nil
+}
+
+func (r *UserRepo) List(ctx context.Context, after uuid.UUID, limit int) ([]domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id > $1 ORDER BY id LIMIT $2`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, after, limit)
+	if err != nil {
+		r.logger.Error("ошибка получения пользователей", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]domain.User, 0)
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			r.logger.Error("ошибка скана строки пользователя", zap.Error(err))
+			return nil, err
+		}
+		result = append(result, u)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("ошибка итерации по строке", zap.Error(err))
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *UserRepo) Update(ctx context.Context, user domain.User) (domain.User, error) {
+	query := `UPDATE users SET email = $1, name = $2, updated_at = now()
+			  WHERE id = $3
+			  RETURNING ` + userColumns
+
+	updated, err := scanUser(conn(ctx, r.db).QueryRowContext(ctx, query, user.Email, user.Name, user.ID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.User{}, errors.ErrUserNotFound
+		}
+		return domain.User{}, r.writeError("ошибка обновления пользователя", err)
+	}
+	return updated, nil
+}
+
+// Delete удаляет пользователя без подписок. Подписки из корзины тоже держат внешний ключ:
+// пока корзина не очищена, пользователя можно восстановить вместе с ними
+func (r *UserRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+			r.logger.Warn("у пользователя остались подписки", zap.String("id", id.String()))
+			return errors.ErrUserHasSubscriptions
+		}
+		r.logger.Error("ошибка удаления пользователя", zap.Error(err), zap.String("id", id.String()))
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *UserRepo) Upsert(ctx context.Context, user domain.User) error {
+	query := `INSERT INTO users AS u (id, email, name) VALUES ($1, $2, $3)
+			  ON CONFLICT (id) DO UPDATE SET email = EXCLUDED.email, name = EXCLUDED.name, updated_at = now()
+			  WHERE u.email IS DISTINCT FROM EXCLUDED.email OR u.name <> EXCLUDED.name`
+
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query, user.ID, user.Email, user.Name); err != nil {
+		return r.writeError("ошибка сохранения пользователя из внешнего справочника", err)
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// WebhookRepository - вебхуки в таблице webhooks и очередь их доставок в webhook_deliveries
+type WebhookRepository interface {
+	Create(ctx context.Context, hook domain.Webhook) (domain.Webhook, error)
+	Get(ctx context.Context, id int) (domain.Webhook, error)
+	List(ctx context.Context) ([]domain.Webhook, error)
+	// Update перезаписывает адрес, события и активность вебхука, пустой Secret - секрет не меняется
+	Update(ctx context.Context, hook domain.Webhook) (domain.Webhook, error)
+	Delete(ctx context.Context, id int) error
+
+	// Enqueue ставит событие в очередь каждому активному вебхуку, подписанному на него, и возвращает число новых доставок.
+	// Событие, уже стоящее в очереди вебхука, повторно не добавляется
+	Enqueue(ctx context.Context, event domain.OutboxEvent) (int64, error)
+	// ClaimDue забирает до limit доставок, чей срок подошёл, и сразу откладывает их на lease:
+	// если воркер упадёт посреди отправки, доставка вернётся в очередь после lease
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error)
+	MarkDelivered(ctx context.Context, id int64, status int) error
+	// MarkFailed записывает неудачную попытку: retryAt - когда пробовать снова, nil - доставка уходит в dead-letter
+	MarkFailed(ctx context.Context, id int64, status *int, reason string, retryAt *time.Time) error
+
+	// DeadLetters - доставки, исчерпавшие попытки, от новых к старым, начиная с id меньше before (0 - с самой новой).
+	// webhookID - только доставки одного вебхука
+	DeadLetters(ctx context.Context, webhookID *int, before int64, limit int) ([]domain.WebhookDelivery, error)
+	// Replay возвращает доставку из dead-letter в очередь с обнулёнными попытками
+	Replay(ctx context.Context, id int64) (domain.WebhookDelivery, error)
+}
+
+type WebhookRepo struct {
+	db     *sql.DB
+	logger *zap.Logger
+}
+
+func NewWebhookRepo(db *sql.DB, logger *zap.Logger) *WebhookRepo {
+	return &WebhookRepo{db: db, logger: logger}
+}
+
+const webhookColumns = "id, url, secret, events, active, created_at, updated_at"
+
+func scanWebhook(row rowScanner) (domain.Webhook, error) {
+	var (
+		hook   domain.Webhook
+		events []byte
+	)
+	if err := row.Scan(&hook.ID, &hook.URL, &hook.Secret, &events, &hook.Active, &hook.CreatedAt, &hook.UpdatedAt); err != nil {
+		return domain.Webhook{}, err
+	}
+	if err := json.Unmarshal(events, &hook.Events); err != nil {
+		return domain.Webhook{}, err
+	}
+	return hook, nil
+}
+
+// webhookEvents - события вебхука в jsonb, nil превращается в пустой список - "все события"
+func webhookEvents(hook domain.Webhook) (string, error) {
+	events := hook.Events
+	if events == nil {
+		events = []string{}
+	}
+	raw, err := json.Marshal(events)
+	return string(raw), err
+}
+
+func (r *WebhookRepo) Create(ctx context.Context, hook domain.Webhook) (domain.Webhook, error) {
+	events, err := webhookEvents(hook)
+	if err != nil {
+		return domain.Webhook{}, err
+	}
+	query := `INSERT INTO webhooks (url, secret, events, active) VALUES ($1, $2, $3::jsonb, $4)
+			  RETURNING ` + webhookColumns
+
+	created, err := scanWebhook(conn(ctx, r.db).QueryRowContext(ctx, query, hook.URL, hook.Secret, events, hook.Active))
+	if err != nil {
+		r.logger.Error("ошибка создания вебхука", zap.Error(err))
+		return domain.Webhook{}, err
+	}
+	return created, nil
+}
+
+func (r *WebhookRepo) Get(ctx context.Context, id int) (domain.Webhook, error) {
+	hook, err := scanWebhook(conn(ctx, r.db).QueryRowContext(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.Webhook{}, errors.ErrWebhookNotFound
+		}
+		r.logger.Error("ошибка чтения вебхука", zap.Error(err), zap.Int("id", id))
+		return domain.Webhook{}, err
+	}
+	return hook, nil
+}
+
+func (r *WebhookRepo) List(ctx context.Context) ([]domain.Webhook, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `SELECT `+webhookColumns+` FROM webhooks ORDER BY id`)
+	if err != nil {
+		r.logger.Error("ошибка получения вебхуков", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]domain.Webhook, 0)
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			r.logger.Error("ошибка скана строки вебхука", zap.Error(err))
+			return nil, err
+		}
+		result = append(result, hook)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("ошибка итерации по строке", zap.Error(err))
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *WebhookRepo) Update(ctx context.Context, hook domain.Webhook) (domain.Webhook, error) {
+	events, err := webhookEvents(hook)
+	if err != nil {
+		return domain.Webhook{}, err
+	}
+	query := `UPDATE webhooks
+			  SET url = $1, secret = COALESCE(NULLIF($2, ''), secret), events = $3::jsonb, active = $4, updated_at = now()
+			  WHERE id = $5
+			  RETURNING ` + webhookColumns
+
+	updated, err := scanWebhook(conn(ctx, r.db).QueryRowContext(ctx, query, hook.URL, hook.Secret, events, hook.Active, hook.ID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.Webhook{}, errors.ErrWebhookNotFound
+		}
+		r.logger.Error("ошибка обновления вебхука", zap.Error(err), zap.Int("id", hook.ID))
+		return domain.Webhook{}, err
+	}
+	return updated, nil
+}
+
+// Delete удаляет вебхук вместе с очередью его доставок
+func (r *WebhookRepo) Delete(ctx context.Context, id int) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		r.logger.Error("ошибка удаления вебхука", zap.Error(err), zap.Int("id", id))
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (r *WebhookRepo) Enqueue(ctx context.Context, event domain.OutboxEvent) (int64, error) {
+	query := `INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload)
+			  SELECT id, $1, $2, $3::jsonb FROM webhooks
+			  WHERE active AND (events = '[]'::jsonb OR events @> jsonb_build_array($2::text))
+			  ON CONFLICT (webhook_id, event_id) DO NOTHING`
+
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, event.EventID, event.Type, string(event.Payload))
+	if err != nil {
+		r.logger.Error("ошибка постановки события в очередь вебхуков", zap.Error(err), zap.String("event", event.Type))
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+const deliveryColumns = `d.id, d.webhook_id, d.event_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at,
+			  d.last_error, d.last_status, d.created_at, d.delivered_at`
+
+func scanDelivery(row rowScanner, dest ...any) (domain.WebhookDelivery, error) {
+	var (
+		d          domain.WebhookDelivery
+		payload    []byte
+		lastError  sql.NullString
+		lastStatus sql.NullInt64
+		delivered  sql.NullTime
+	)
+	fields := []any{&d.ID, &d.WebhookID, &d.EventID, &d.Event, &payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+		&lastError, &lastStatus, &d.CreatedAt, &delivered}
+	if err := row.Scan(append(fields, dest...)...); err != nil {
+		return domain.WebhookDelivery{}, err
+	}
+	d.Payload = payload
+	if lastError.Valid {
+		d.LastError = &lastError.String
+	}
+	if lastStatus.Valid {
+		status := int(lastStatus.Int64)
+		d.LastStatus = &status
+	}
+	if delivered.Valid {
+		d.DeliveredAt = &delivered.Time
+	}
+	return d, nil
+}
+
+// ClaimDue - попытка засчитывается сразу при взятии, так что и попытка упавшего посреди отправки воркера не пропадёт.
+// Доставки выключенных вебхуков ждут в очереди, пока вебхук не включат
+func (r *WebhookRepo) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	query := `UPDATE webhook_deliveries d
+			  SET attempts = d.attempts + 1, next_attempt_at = now() + make_interval(secs => $2)
+			  FROM webhooks w
+			  WHERE w.id = d.webhook_id AND d.id IN (
+			      SELECT q.id FROM webhook_deliveries q JOIN webhooks h ON h.id = q.webhook_id
+			      WHERE q.status = 'pending' AND q.next_attempt_at <= now() AND h.active
+			      ORDER BY q.next_attempt_at, q.id
+			      LIMIT $1
+			      FOR UPDATE OF q SKIP LOCKED)
+			  RETURNING ` + deliveryColumns + `, w.url, w.secret`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		r.logger.Error("ошибка выборки доставок вебхуков", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []domain.WebhookDelivery
+	for rows.Next() {
+		var url, secret string
+		d, err := scanDelivery(rows, &url, &secret)
+		if err != nil {
+			r.logger.Error("ошибка скана строки доставки", zap.Error(err))
+			return nil, err
+		}
+		d.URL, d.Secret = url, secret
+		result = append(result, d)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("ошибка итерации по строке", zap.Error(err))
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *WebhookRepo) MarkDelivered(ctx context.Context, id int64, status int) error {
+	query := `UPDATE webhook_deliveries SET status = 'delivered', last_status = $1, last_error = NULL, delivered_at = now()
+			  WHERE id = $2`
+
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query, status, id); err != nil {
+		r.logger.Error("ошибка записи успешной доставки", zap.Error(err), zap.Int64("id", id))
+		return err
+	}
+	return nil
+}
+
+func (r *WebhookRepo) MarkFailed(ctx context.Context, id int64, status *int, reason string, retryAt *time.Time) error {
+	query := `UPDATE webhook_deliveries
+			  SET status = CASE WHEN $3::timestamptz IS NULL THEN 'dead' ELSE 'pending' END,
+			      next_attempt_at = COALESCE($3, next_attempt_at), last_status = $1, last_error = $2
+			  WHERE id = $4`
+
+	if _, err := conn(ctx, r.db).ExecContext(ctx, query, status, reason, retryAt, id); err != nil {
+		r.logger.Error("ошибка записи неудачной доставки", zap.Error(err), zap.Int64("id", id))
+		return err
+	}
+	return nil
+}
+
+func (r *WebhookRepo) DeadLetters(ctx context.Context, webhookID *int, before int64, limit int) ([]domain.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries d
+			  WHERE d.status = 'dead' AND ($1::int IS NULL OR d.webhook_id = $1) AND ($2 = 0 OR d.id < $2)
+			  ORDER BY d.id DESC
+			  LIMIT $3`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, webhookID, before, limit)
+	if err != nil {
+		r.logger.Error("ошибка получения dead-letter доставок", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]domain.WebhookDelivery, 0)
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			r.logger.Error("ошибка скана строки доставки", zap.Error(err))
+			return nil, err
+		}
+		result = append(result, d)
+	}
+	if err = rows.Err(); err != nil {
+		r.logger.Error("ошибка итерации по строке", zap.Error(err))
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *WebhookRepo) Replay(ctx context.Context, id int64) (domain.WebhookDelivery, error) {
+	query := `UPDATE webhook_deliveries d SET status = 'pending', attempts = 0, next_attempt_at = now()
+			  WHERE d.id = $1 AND d.status = 'dead'
+			  RETURNING ` + deliveryColumns
+
+	d, err := scanDelivery(conn(ctx, r.db).QueryRowContext(ctx, query, id))
+	if err == nil {
+		return d, nil
+	}
+	if err != sql.ErrNoRows {
+		r.logger.Error("ошибка повтора доставки", zap.Error(err), zap.Int64("id", id))
+		return domain.WebhookDelivery{}, err
+	}
+
+	// строки нет или она не в dead-letter - клиенту это разные ответы
+	var exists bool
+	if err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM webhook_deliveries WHERE id = $1)`, id).Scan(&exists); err != nil {
+		r.logger.Error("ошибка чтения доставки", zap.Error(err), zap.Int64("id", id))
+		return domain.WebhookDelivery{}, err
+	}
+	if !exists {
+		return domain.WebhookDelivery{}, errors.ErrDeliveryNotFound
+	}
+	return domain.WebhookDelivery{}, errors.ErrDeliveryNotDead
+}
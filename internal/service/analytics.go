@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"testovoe_again/internal/rates"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// MaxAnalyticsMonths - ограничение на длину периода, чтобы generate_series не разворачивал столетия
+	MaxAnalyticsMonths = 120
+
+	DefaultTopLimit = 10
+	MaxTopLimit     = 100
+)
+
+func (s *SubscriptionService) MonthlySpend(ctx context.Context, filter domain.AnalyticsFilter) ([]domain.SpendRow, error) {
+	if err := s.validatePeriod(&filter); err != nil {
+		return nil, err
+	}
+	if err := s.canonicalFilter(ctx, &filter); err != nil {
+		return nil, err
+	}
+	rows, err := s.repo.SpendByMonth(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	// репозиторий уже отсортировал по месяцу, после пересчёта порядок сохраняется
+	return s.convertRows(ctx, rows, filter.Currency)
+}
+
+func (s *SubscriptionService) SpendByService(ctx context.Context, filter domain.AnalyticsFilter) ([]domain.SpendRow, error) {
+	if err := s.validatePeriod(&filter); err != nil {
+		return nil, err
+	}
+	if err := s.canonicalFilter(ctx, &filter); err != nil {
+		return nil, err
+	}
+	rows, err := s.repo.SpendByService(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.convertRows(ctx, rows, filter.Currency)
+	if err != nil {
+		return nil, err
+	}
+	sortByTotal(result)
+	return result, nil
+}
+
+// TopServices - топ сервисов по тратам среди всех пользователей, поэтому фильтр по юзеру тут игнорируется
+func (s *SubscriptionService) TopServices(ctx context.Context, filter domain.AnalyticsFilter) ([]domain.SpendRow, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultTopLimit
+	}
+	if filter.Limit > MaxTopLimit {
+		filter.Limit = MaxTopLimit
+	}
+	filter.UserID = nil
+
+	result, err := s.SpendByService(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) > filter.Limit {
+		result = result[:filter.Limit]
+	}
+	return result, nil
+}
+
+// convertRows пересчитывает строки в целевую валюту и схлопывает строки одного периода и сервиса,
+// которые репозиторий отдал отдельно по каждой валюте. Порядок первых вхождений сохраняется
+func (s *SubscriptionService) convertRows(ctx context.Context, rows []domain.SpendRow, currency string) ([]domain.SpendRow, error) {
+	type key struct{ period, service string }
+
+	index := make(map[key]int, len(rows))
+	result := make([]domain.SpendRow, 0, len(rows))
+	for _, row := range rows {
+		total := row.Total
+		// пустая валюта - месяц без подписок, пересчитывать нечего
+		if row.Currency != "" {
+			converted, err := rates.Convert(ctx, s.rates, row.Total, row.Currency, currency)
+			if err != nil {
+				s.logger.Error("не удалось пересчитать сумму", zap.Error(err), zap.String("from", row.Currency), zap.String("to", currency))
+				return nil, err
+			}
+			total = converted
+		}
+
+		k := key{row.Period, row.ServiceName}
+		if i, ok := index[k]; ok {
+			result[i].Total += total
+			continue
+		}
+		index[k] = len(result)
+		result = append(result, domain.SpendRow{Period: row.Period, ServiceName: row.ServiceName, Currency: currency, Total: total})
+	}
+	return result, nil
+}
+
+func sortByTotal(rows []domain.SpendRow) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Total != rows[j].Total {
+			return rows[i].Total > rows[j].Total
+		}
+		return rows[i].ServiceName < rows[j].ServiceName
+	})
+}
+
+// validatePeriod проверяет период и валюту отчёта, пустую валюту заменяет на дефолтную
+func (s *SubscriptionService) validatePeriod(filter *domain.AnalyticsFilter) error {
+	if filter.Currency == "" {
+		filter.Currency = domain.DefaultCurrency
+	}
+	if err := ValidateCurrency(filter.Currency); err != nil {
+		return err
+	}
+	if !filter.To.After(filter.From) {
+		s.logger.Warn("невалидный период", zap.Time("from", filter.From), zap.Time("to", filter.To))
+		return errors.ErrInvalidPeriod
+	}
+	if filter.To.After(filter.From.AddDate(0, MaxAnalyticsMonths, 0)) {
+		s.logger.Warn("слишком длинный период", zap.Time("from", filter.From), zap.Time("to", filter.To))
+		return errors.ErrInvalidPeriod
+	}
+	return nil
+}
+
+// canonicalFilter заменяет сервис в фильтре отчёта на каноническое название из каталога
+func (s *SubscriptionService) canonicalFilter(ctx context.Context, filter *domain.AnalyticsFilter) error {
+	if filter.ServiceName == nil {
+		return nil
+	}
+	name, err := s.canonicalService(ctx, *filter.ServiceName)
+	if err != nil {
+		return err
+	}
+	filter.ServiceName = &name
+	return nil
+}
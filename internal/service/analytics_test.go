@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"testovoe_again/internal/repository"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// memSpendRepo отдаёт заготовленные строки отчётов, как репозиторий - по строке на каждую валюту
+type memSpendRepo struct {
+	repository.SubscriptionRepository
+	byMonth   []domain.SpendRow
+	byService []domain.SpendRow
+	filter    domain.AnalyticsFilter
+}
+
+func (r *memSpendRepo) SpendByMonth(ctx context.Context, filter domain.AnalyticsFilter) ([]domain.SpendRow, error) {
+	r.filter = filter
+	return r.byMonth, nil
+}
+
+func (r *memSpendRepo) SpendByService(ctx context.Context, filter domain.AnalyticsFilter) ([]domain.SpendRow, error) {
+	r.filter = filter
+	return r.byService, nil
+}
+
+func year2025() domain.AnalyticsFilter {
+	return domain.AnalyticsFilter{From: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func TestMonthlySpendConvertsAndMerges(t *testing.T) {
+	repo := &memSpendRepo{byMonth: []domain.SpendRow{
+		{Period: "01-2025", Currency: domain.CurrencyRUB, Total: 39900},
+		{Period: "01-2025", Currency: domain.CurrencyUSD, Total: 1000},
+		// месяц без подписок приходит без валюты
+		{Period: "02-2025", Total: 0},
+		{Period: "03-2025", Currency: domain.CurrencyEUR, Total: 100},
+	}}
+	svc := NewSubscriptionService(zap.NewNop(), repo, nil, testRates, domain.OverlapReject, nil, nil)
+
+	rows, err := svc.MonthlySpend(context.Background(), year2025())
+	if err != nil {
+		t.Fatalf("MonthlySpend: %v", err)
+	}
+	want := []domain.SpendRow{
+		{Period: "01-2025", Currency: domain.CurrencyRUB, Total: 39900 + 80000},
+		{Period: "02-2025", Currency: domain.CurrencyRUB, Total: 0},
+		{Period: "03-2025", Currency: domain.CurrencyRUB, Total: 10000},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("%d строк, ожидалось %d: %+v", len(rows), len(want), rows)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("строка %d: %+v, ожидалось %+v", i, rows[i], want[i])
+		}
+	}
+}
+
+func TestTopServices(t *testing.T) {
+	repo := &memSpendRepo{byService: []domain.SpendRow{
+		{ServiceName: "Netflix", Currency: domain.CurrencyUSD, Total: 1000},
+		{ServiceName: "Kinopoisk", Currency: domain.CurrencyRUB, Total: 120000},
+		{ServiceName: "Yandex Plus", Currency: domain.CurrencyRUB, Total: 39900},
+		{ServiceName: "Yandex Plus", Currency: domain.CurrencyEUR, Total: 801},
+		{ServiceName: "Apple Music", Currency: domain.CurrencyRUB, Total: 120000},
+	}}
+	svc := NewSubscriptionService(zap.NewNop(), repo, nil, testRates, domain.OverlapReject, nil, nil)
+
+	cases := []struct {
+		name  string
+		limit int
+		want  []string
+	}{
+		{"одинаковые суммы по названию", 0, []string{"Apple Music", "Kinopoisk", "Yandex Plus", "Netflix"}},
+		{"топ-2", 2, []string{"Apple Music", "Kinopoisk"}},
+	}
+	for _, c := range cases {
+		filter := year2025()
+		filter.Limit = c.limit
+		rows, err := svc.TopServices(context.Background(), filter)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		var got []string
+		for _, row := range rows {
+			got = append(got, row.ServiceName)
+		}
+		if len(got) != len(c.want) {
+			t.Errorf("%s: %v, ожидалось %v", c.name, got, c.want)
+			continue
+		}
+		for i := range c.want {
+			if got[i] != c.want[i] {
+				t.Errorf("%s: %v, ожидалось %v", c.name, got, c.want)
+				break
+			}
+		}
+	}
+	if repo.filter.UserID != nil {
+		t.Errorf("топ считается по всем пользователям, а фильтр по пользователю дошёл до репозитория")
+	}
+}
+
+func TestAnalyticsPeriodValidation(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		filter domain.AnalyticsFilter
+		want   error
+	}{
+		{"пустой период", domain.AnalyticsFilter{From: from, To: from}, errors.ErrInvalidPeriod},
+		{"конец раньше начала", domain.AnalyticsFilter{From: from, To: from.AddDate(0, -1, 0)}, errors.ErrInvalidPeriod},
+		{"ровно 10 лет", domain.AnalyticsFilter{From: from, To: from.AddDate(0, MaxAnalyticsMonths, 0)}, nil},
+		{"больше 10 лет", domain.AnalyticsFilter{From: from, To: from.AddDate(0, MaxAnalyticsMonths, 1)}, errors.ErrInvalidPeriod},
+		{"неизвестная валюта", domain.AnalyticsFilter{From: from, To: from.AddDate(0, 1, 0), Currency: "GBP"}, errors.ErrInvalidCurrency},
+	}
+	for _, c := range cases {
+		svc := NewSubscriptionService(zap.NewNop(), &memSpendRepo{}, nil, testRates, domain.OverlapReject, nil, nil)
+		_, err := svc.SpendByService(context.Background(), c.filter)
+		if c.want == nil && err != nil || c.want != nil && !errors.Is(err, c.want) {
+			t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// MaxBatchSize - сколько операций можно прислать одним пакетом
+const MaxBatchSize = 1000
+
+// Batch - все операции в одной транзакции, операции выполняются по порядку, так что каждая видит результат
+// предыдущих, в том числе при проверке пересечений. Пишет пакет репозиторий общими запросами, а операции,
+// которые так не записать (слияние по политике merge, вторая операция над той же подпиской), выполняет
+// по одной applyBatchOp - тем же путём, что и одиночный запрос
+func (s *SubscriptionService) Batch(ctx context.Context, ops []domain.BatchOp, atomic bool) ([]domain.BatchResult, error) {
+	if len(ops) == 0 || len(ops) > MaxBatchSize {
+		return nil, errors.ErrInvalidBatch
+	}
+
+	// сначала валидируем всё, что можно проверить без базы - в atomic режиме до базы тогда можно не ходить вовсе
+	invalid := false
+	check, checkUser := s.subscriptionChecker(), s.userChecker()
+	for i := range ops {
+		if ops[i].Err == nil {
+			ops[i].Err = validateBatchOp(ctx, &ops[i], check, checkUser)
+		}
+		if ops[i].Err != nil {
+			invalid = true
+		}
+	}
+
+	if atomic && invalid {
+		s.logger.Warn("пакет отклонён на валидации", zap.Int("size", len(ops)))
+		return rollBack(validationResults(ops)), errors.ErrBatchAborted
+	}
+
+	var results []domain.BatchResult
+	_, err := inTx(ctx, s, func(ctx context.Context) (struct{}, error) {
+		var err error
+		results, err = s.repo.Batch(ctx, ops, atomic, s.overlap, s.applyBatchOp)
+		return struct{}{}, err
+	})
+	if errors.Is(err, errors.ErrBatchAborted) {
+		s.logger.Warn("пакет откатился", zap.Int("size", len(ops)))
+		return rollBack(results), errors.ErrBatchAborted
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// applyBatchOp выполняет одну проверенную операцию тем же путём, что и одиночный запрос, включая политику
+// пересечений, и дописывает в result id и версию после неё
+func (s *SubscriptionService) applyBatchOp(ctx context.Context, op domain.BatchOp, result *domain.BatchResult) error {
+	switch op.Op {
+	case domain.BatchCreate:
+		sub, err := s.insert(ctx, op.Subscription)
+		if err != nil {
+			return err
+		}
+		result.ID, result.Version = sub.ID, sub.Version
+	case domain.BatchUpdate:
+		op.Subscription.ID, op.Subscription.Version = op.ID, op.Version
+		sub, err := s.rewrite(ctx, op.Subscription)
+		if err != nil {
+			return err
+		}
+		result.Version = sub.Version
+	case domain.BatchDelete:
+		return s.repo.Delete(ctx, op.ID, op.Version)
+	default:
+		return errors.ErrInvalidBatch
+	}
+	return nil
+}
+
+// validateBatchOp - те же проверки, что в Create и Update, плюс наличие id там, где он нужен.
+// Пользователь проверяется только у создаваемых подписок: Update не меняет user_id
+func validateBatchOp(ctx context.Context, op *domain.BatchOp, check func(context.Context, *domain.Subscription) error, checkUser func(context.Context, uuid.UUID) error) error {
+	switch op.Op {
+	case domain.BatchCreate, domain.BatchUpdate:
+		if op.Op == domain.BatchUpdate && op.ID <= 0 {
+			return errors.ErrInvalidBatch
+		}
+		if err := check(ctx, &op.Subscription); err != nil {
+			return err
+		}
+		if op.Op == domain.BatchCreate {
+			return checkUser(ctx, op.Subscription.UserID)
+		}
+	case domain.BatchDelete:
+		if op.ID <= 0 {
+			return errors.ErrInvalidBatch
+		}
+	default:
+		return errors.ErrInvalidBatch
+	}
+	return nil
+}
+
+// validationResults - результаты пакета, который не дошёл до базы
+func validationResults(ops []domain.BatchOp) []domain.BatchResult {
+	results := make([]domain.BatchResult, len(ops))
+	for i, op := range ops {
+		results[i] = domain.BatchResult{Index: i, Op: op.Op, ID: op.ID, Err: op.Err}
+	}
+	return results
+}
+
+// rollBack помечает все операции без собственной ошибки как отменённые вместе с пакетом
+func rollBack(results []domain.BatchResult) []domain.BatchResult {
+	for i := range results {
+		if results[i].Err == nil {
+			results[i].Err = errors.ErrBatchRolledBack
+			results[i].Version = 0
+			if results[i].Op == domain.BatchCreate {
+				results[i].ID = 0
+			}
+		}
+	}
+	return results
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"testovoe_again/internal/repository"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// memBatchRepo выполняет пакет как есть: созданным раздаёт id по порядку, падает на операциях из fail
+type memBatchRepo struct {
+	repository.SubscriptionRepository
+	calls int
+	fail  map[int]error
+}
+
+func (r *memBatchRepo) Batch(ctx context.Context, ops []domain.BatchOp, atomic bool, policy string, fallback repository.BatchFallback) ([]domain.BatchResult, error) {
+	r.calls++
+	results := make([]domain.BatchResult, len(ops))
+	failed := false
+	for i, op := range ops {
+		results[i] = domain.BatchResult{Index: i, Op: op.Op, ID: op.ID, Err: op.Err}
+		if results[i].Err == nil {
+			results[i].Err = r.fail[i]
+		}
+		if results[i].Err != nil {
+			failed = true
+			continue
+		}
+		if op.Op == domain.BatchCreate {
+			results[i].ID = 100 + i
+		}
+		results[i].Version = 1
+	}
+	if atomic && failed {
+		return results, errors.ErrBatchAborted
+	}
+	return results, nil
+}
+
+func TestBatch(t *testing.T) {
+	valid := domain.Subscription{ServiceName: "Yandex Plus", Price: 39900, UserID: uuid.New(), StartDate: "07-2025"}
+	create := domain.BatchOp{Op: domain.BatchCreate, Subscription: valid}
+	update := domain.BatchOp{Op: domain.BatchUpdate, ID: 5, Subscription: valid}
+	remove := domain.BatchOp{Op: domain.BatchDelete, ID: 5}
+	badPrice := create
+	badPrice.Subscription.Price = -1
+
+	const (
+		ok         = "ok"
+		rolledBack = "rolled_back"
+	)
+	cases := []struct {
+		name    string
+		ops     []domain.BatchOp
+		atomic  bool
+		fail    map[int]error
+		wantErr error
+		// ожидаемый итог: ok, rolled_back или код ошибки операции
+		want     []string
+		repoCall bool
+	}{
+		{name: "atomic: всё прошло", ops: []domain.BatchOp{create, update, remove}, atomic: true,
+			want: []string{ok, ok, ok}, repoCall: true},
+		{name: "atomic: невалидная операция - до базы не доходим", ops: []domain.BatchOp{create, badPrice}, atomic: true,
+			wantErr: errors.ErrBatchAborted, want: []string{rolledBack, errors.ErrInvalidPrice.Code}},
+		{name: "atomic: update без id", ops: []domain.BatchOp{{Op: domain.BatchUpdate, Subscription: valid}}, atomic: true,
+			wantErr: errors.ErrBatchAborted, want: []string{errors.ErrInvalidBatch.Code}},
+		{name: "atomic: падение в базе откатывает остальные", ops: []domain.BatchOp{create, update}, atomic: true,
+			fail: map[int]error{1: errors.ErrVersionConflict}, wantErr: errors.ErrBatchAborted,
+			want: []string{rolledBack, errors.ErrVersionConflict.Code}, repoCall: true},
+		{name: "best_effort: невалидная не мешает остальным", ops: []domain.BatchOp{badPrice, create, {Op: "upsert"}},
+			want: []string{errors.ErrInvalidPrice.Code, ok, errors.ErrInvalidBatch.Code}, repoCall: true},
+		{name: "best_effort: падение в базе только у своей операции", ops: []domain.BatchOp{create, remove},
+			fail: map[int]error{1: errors.ErrSubscriptionNotFound}, want: []string{ok, errors.ErrSubscriptionNotFound.Code}, repoCall: true},
+	}
+
+	for _, c := range cases {
+		repo := &memBatchRepo{fail: c.fail}
+		svc := NewSubscriptionService(zap.NewNop(), repo, nil, nil, domain.OverlapReject, nil, nil)
+		ops := append([]domain.BatchOp(nil), c.ops...)
+
+		results, err := svc.Batch(context.Background(), ops, c.atomic)
+		if !errors.Is(err, c.wantErr) {
+			t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, c.wantErr)
+			continue
+		}
+		if (repo.calls > 0) != c.repoCall {
+			t.Errorf("%s: вызовов репозитория %d", c.name, repo.calls)
+		}
+		if len(results) != len(c.want) {
+			t.Errorf("%s: %d результатов, ожидалось %d", c.name, len(results), len(c.want))
+			continue
+		}
+		for i, want := range c.want {
+			r := results[i]
+			var got string
+			var typed *errors.Error
+			switch {
+			case r.Err == nil:
+				got = ok
+			case errors.Is(r.Err, errors.ErrBatchRolledBack):
+				got = rolledBack
+			case errors.As(r.Err, &typed):
+				got = typed.Code
+			default:
+				got = r.Err.Error()
+			}
+			if got != want {
+				t.Errorf("%s: операция %d - %s, ожидалось %s", c.name, i, got, want)
+			}
+			// у откаченного создания не должно остаться id, которого уже нет в базе
+			if got == rolledBack && r.Op == domain.BatchCreate && (r.ID != 0 || r.Version != 0) {
+				t.Errorf("%s: у откаченной операции %d остались id %d и версия %d", c.name, i, r.ID, r.Version)
+			}
+		}
+	}
+}
+
+func TestBatchSize(t *testing.T) {
+	svc := NewSubscriptionService(zap.NewNop(), &memBatchRepo{}, nil, nil, domain.OverlapReject, nil, nil)
+	for _, size := range []int{0, MaxBatchSize + 1} {
+		ops := make([]domain.BatchOp, size)
+		if _, err := svc.Batch(context.Background(), ops, true); !errors.Is(err, errors.ErrInvalidBatch) {
+			t.Errorf("пакет из %d операций: ошибка %v, ожидалась ErrInvalidBatch", size, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+)
+
+func TestValidateBilling(t *testing.T) {
+	days := func(n int) *int { return &n }
+	cases := []struct {
+		name   string
+		period string
+		days   *int
+		want   string
+		ok     bool
+	}{
+		{name: "пустой - помесячно", period: "", want: domain.BillingMonthly, ok: true},
+		{name: "weekly", period: domain.BillingWeekly, want: domain.BillingWeekly, ok: true},
+		{name: "quarterly", period: domain.BillingQuarterly, want: domain.BillingQuarterly, ok: true},
+		{name: "yearly", period: domain.BillingYearly, want: domain.BillingYearly, ok: true},
+		{name: "custom с длиной", period: domain.BillingCustom, days: days(30), want: domain.BillingCustom, ok: true},
+		{name: "custom без длины", period: domain.BillingCustom},
+		{name: "custom с нулевой длиной", period: domain.BillingCustom, days: days(0)},
+		{name: "длина у не-custom периода", period: domain.BillingMonthly, days: days(30)},
+		{name: "длина без периода", days: days(30)},
+		{name: "неизвестный период", period: "daily"},
+		{name: "регистр важен", period: "Monthly"},
+	}
+
+	for _, c := range cases {
+		sub := domain.Subscription{BillingPeriod: c.period, BillingPeriodDays: c.days}
+		err := ValidateBilling(&sub)
+		if !c.ok {
+			if !errors.Is(err, errors.ErrInvalidBilling) {
+				t.Errorf("%s: ошибка %v, ожидалась ErrInvalidBilling", c.name, err)
+			}
+			continue
+		}
+		if err != nil || sub.BillingPeriod != c.want {
+			t.Errorf("%s: период %q, %v, ожидался %q", c.name, sub.BillingPeriod, err, c.want)
+		}
+	}
+}
+
+func TestValidateSubscription(t *testing.T) {
+	end := func(s string) *string { return &s }
+	valid := func() domain.Subscription {
+		return domain.Subscription{ServiceName: "Yandex Plus", Price: 39900, StartDate: "07-2025"}
+	}
+	cases := []struct {
+		name   string
+		change func(*domain.Subscription)
+		want   error
+	}{
+		{name: "умолчания", change: func(*domain.Subscription) {}},
+		{name: "end_date в тот же месяц", change: func(s *domain.Subscription) { s.EndDate = end("07-2025") }},
+		{name: "полные даты", change: func(s *domain.Subscription) { s.StartDate, s.EndDate = "2025-07-15", end("2025-08-14") }},
+		{name: "цена больше потолка", change: func(s *domain.Subscription) { s.Price = MaxPrice + 1 }, want: errors.ErrInvalidPrice},
+		{name: "отрицательная цена", change: func(s *domain.Subscription) { s.Price = -1 }, want: errors.ErrInvalidPrice},
+		{name: "неизвестная валюта", change: func(s *domain.Subscription) { s.Currency = "GBP" }, want: errors.ErrInvalidCurrency},
+		{name: "кривой период", change: func(s *domain.Subscription) { s.BillingPeriod = "daily" }, want: errors.ErrInvalidBilling},
+		{name: "кривая дата", change: func(s *domain.Subscription) { s.StartDate = "2025-07" }, want: errors.ErrInvalidDateFormat},
+		{name: "конец раньше начала", change: func(s *domain.Subscription) { s.EndDate = end("06-2025") }, want: errors.ErrInvalidPeriod},
+	}
+
+	for _, c := range cases {
+		sub := valid()
+		c.change(&sub)
+		err := ValidateSubscription(&sub)
+		if c.want != nil {
+			if !errors.Is(err, c.want) {
+				t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, c.want)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if sub.Currency != domain.DefaultCurrency || sub.BillingPeriod != domain.DefaultBillingPeriod {
+			t.Errorf("%s: валюта %q, период %q - умолчания не подставлены", c.name, sub.Currency, sub.BillingPeriod)
+		}
+	}
+}
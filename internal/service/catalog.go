@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"testovoe_again/internal/rates"
+	"testovoe_again/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// CatalogService - каталог сервисов: канонические названия, алиасы и цены
+type CatalogService interface {
+	Create(ctx context.Context, entry domain.CatalogEntry) (domain.CatalogEntry, error)
+	Get(ctx context.Context, id int) (domain.CatalogEntry, error)
+	List(ctx context.Context) ([]domain.CatalogEntry, error)
+	// Update перезаписывает сервис целиком, entry.ID - какой
+	Update(ctx context.Context, entry domain.CatalogEntry) (domain.CatalogEntry, error)
+	Delete(ctx context.Context, id int) error
+}
+
+// CatalogRules - насколько строго подписки сверяются с каталогом. Required - подписку на сервис вне каталога
+// не создать, EnforcePrice - цена подписки проверяется по границам сервиса вместо общего MaxPrice
+type CatalogRules struct {
+	Required     bool
+	EnforcePrice bool
+}
+
+type Catalog struct {
+	logger *zap.Logger
+	repo   repository.CatalogRepository
+	rates  rates.Provider
+	rules  CatalogRules
+}
+
+// NewCatalog - rates нужны, чтобы сверять с границами сервиса цену подписки в другой валюте
+func NewCatalog(logger *zap.Logger, repo repository.CatalogRepository, rates rates.Provider, rules CatalogRules) *Catalog {
+	return &Catalog{logger: logger, repo: repo, rates: rates, rules: rules}
+}
+
+func (c *Catalog) Create(ctx context.Context, entry domain.CatalogEntry) (domain.CatalogEntry, error) {
+	if err := normalizeEntry(&entry); err != nil {
+		c.logger.Warn("невалидный сервис каталога", zap.Error(err), zap.String("name", entry.Name))
+		return domain.CatalogEntry{}, err
+	}
+	created, err := c.repo.Create(ctx, entry)
+	if err != nil {
+		return domain.CatalogEntry{}, err
+	}
+	c.logger.Info("сервис добавлен в каталог", zap.Int("id", created.ID), zap.String("name", created.Name))
+	return created, nil
+}
+
+func (c *Catalog) Get(ctx context.Context, id int) (domain.CatalogEntry, error) {
+	return c.repo.Get(ctx, id)
+}
+
+func (c *Catalog) List(ctx context.Context) ([]domain.CatalogEntry, error) {
+	return c.repo.List(ctx)
+}
+
+func (c *Catalog) Update(ctx context.Context, entry domain.CatalogEntry) (domain.CatalogEntry, error) {
+	if err := normalizeEntry(&entry); err != nil {
+		c.logger.Warn("невалидный сервис каталога", zap.Error(err), zap.Int("id", entry.ID))
+		return domain.CatalogEntry{}, err
+	}
+	return c.repo.Update(ctx, entry)
+}
+
+func (c *Catalog) Delete(ctx context.Context, id int) error {
+	if err := c.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	c.logger.Info("сервис удалён из каталога", zap.Int("id", id))
+	return nil
+}
+
+// Canonical - каноническое название сервиса для name, или сам name, если сервиса нет в каталоге
+func (c *Catalog) Canonical(ctx context.Context, name string) (string, error) {
+	entry, err := c.repo.Resolve(ctx, name)
+	if errors.Is(err, errors.ErrServiceNotFound) {
+		return name, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return entry.Name, nil
+}
+
+// Apply сопоставляет подписку с каталогом и проверяет её цену. Найденный сервис подставляет каноническое
+// название и service_id, сервис вне каталога остаётся со свободным названием (или отклоняется с Required)
+func (c *Catalog) Apply(ctx context.Context, sub *domain.Subscription) error {
+	return c.apply(ctx, sub, c.repo.Resolve)
+}
+
+// Applier - Apply для пакетов и импорта: найденные и ненайденные названия запоминаются на время операции,
+// чтобы не ходить в базу за одним и тем же сервисом на каждой строке
+func (c *Catalog) Applier() func(ctx context.Context, sub *domain.Subscription) error {
+	type resolved struct {
+		entry domain.CatalogEntry
+		err   error
+	}
+	seen := make(map[string]resolved)
+	resolve := func(ctx context.Context, name string) (domain.CatalogEntry, error) {
+		key := domain.ServiceKey(name)
+		if r, ok := seen[key]; ok {
+			return r.entry, r.err
+		}
+		entry, err := c.repo.Resolve(ctx, name)
+		if err == nil || errors.Is(err, errors.ErrServiceNotFound) {
+			seen[key] = resolved{entry: entry, err: err}
+		}
+		return entry, err
+	}
+	return func(ctx context.Context, sub *domain.Subscription) error {
+		return c.apply(ctx, sub, resolve)
+	}
+}
+
+func (c *Catalog) apply(ctx context.Context, sub *domain.Subscription, resolve func(context.Context, string) (domain.CatalogEntry, error)) error {
+	sub.ServiceID = nil
+	entry, err := resolve(ctx, sub.ServiceName)
+	if errors.Is(err, errors.ErrServiceNotFound) {
+		if c.rules.Required {
+			return errors.Wrap(errors.ErrUnknownService, fmt.Errorf("%q", sub.ServiceName))
+		}
+		return ValidatePrice(sub.Price)
+	}
+	if err != nil {
+		return err
+	}
+
+	sub.ServiceName = entry.Name
+	sub.ServiceID = &entry.ID
+
+	if !c.rules.EnforcePrice || (entry.MinPrice == nil && entry.MaxPrice == nil) {
+		return ValidatePrice(sub.Price)
+	}
+	if sub.Price < 0 {
+		return errors.ErrInvalidPrice
+	}
+	// границы заданы в валюте сервиса, цену подписки в другой валюте сначала пересчитываем
+	price, err := rates.Convert(ctx, c.rates, sub.Price, sub.Currency, entry.Currency)
+	if err != nil {
+		return err
+	}
+	if !entry.PriceInRange(price) {
+		return errors.Wrap(errors.ErrPriceOutOfRange, fmt.Errorf("для %s допустимо %s", entry.Name, priceRange(entry)))
+	}
+	return nil
+}
+
+func priceRange(entry domain.CatalogEntry) string {
+	switch {
+	case entry.MinPrice != nil && entry.MaxPrice != nil:
+		return fmt.Sprintf("от %d до %d %s", *entry.MinPrice, *entry.MaxPrice, entry.Currency)
+	case entry.MinPrice != nil:
+		return fmt.Sprintf("от %d %s", *entry.MinPrice, entry.Currency)
+	default:
+		return fmt.Sprintf("до %d %s", *entry.MaxPrice, entry.Currency)
+	}
+}
+
+// normalizeEntry проверяет сервис и приводит написания к одному виду: лишние пробелы убираются,
+// пустые алиасы и алиасы, совпадающие с другими написаниями без учёта регистра, выкидываются
+func normalizeEntry(entry *domain.CatalogEntry) error {
+	entry.Name = strings.Join(strings.Fields(entry.Name), " ")
+	if entry.Name == "" {
+		return errors.Wrap(errors.ErrInvalidService.WithField("name"), fmt.Errorf("название не может быть пустым"))
+	}
+
+	seen := map[string]bool{domain.ServiceKey(entry.Name): true}
+	aliases := make([]string, 0, len(entry.Aliases))
+	for _, alias := range entry.Aliases {
+		alias = strings.Join(strings.Fields(alias), " ")
+		key := domain.ServiceKey(alias)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		aliases = append(aliases, alias)
+	}
+	entry.Aliases = aliases
+
+	if entry.Currency == "" {
+		entry.Currency = domain.DefaultCurrency
+	}
+	if err := ValidateCurrency(entry.Currency); err != nil {
+		return err
+	}
+	if entry.DefaultPrice < 0 {
+		return errors.ErrInvalidPrice.WithField("default_price")
+	}
+	if entry.MinPrice != nil && *entry.MinPrice < 0 {
+		return errors.ErrInvalidPrice.WithField("min_price")
+	}
+	if entry.MaxPrice != nil && *entry.MaxPrice < 0 {
+		return errors.ErrInvalidPrice.WithField("max_price")
+	}
+	if entry.MinPrice != nil && entry.MaxPrice != nil && *entry.MinPrice > *entry.MaxPrice {
+		return errors.Wrap(errors.ErrInvalidService.WithField("max_price"), fmt.Errorf("max_price меньше min_price"))
+	}
+	if !entry.PriceInRange(entry.DefaultPrice) {
+		return errors.Wrap(errors.ErrInvalidService.WithField("default_price"), fmt.Errorf("цена по умолчанию вне диапазона сервиса"))
+	}
+	return nil
+}
+
+// checkService - проверка названия и цены подписки по каталогу, без каталога только общий MaxPrice
+func (s *SubscriptionService) checkService(ctx context.Context, sub *domain.Subscription) error {
+	if s.catalog == nil {
+		return ValidatePrice(sub.Price)
+	}
+	return s.catalog.Apply(ctx, sub)
+}
+
+// subscriptionChecker - полная проверка подписки для пакетов и импорта, каталог запоминает уже найденные названия
+func (s *SubscriptionService) subscriptionChecker() func(ctx context.Context, sub *domain.Subscription) error {
+	check := s.checkService
+	if s.catalog != nil {
+		check = s.catalog.Applier()
+	}
+	return func(ctx context.Context, sub *domain.Subscription) error {
+		if err := validateTerms(sub); err != nil {
+			return err
+		}
+		return check(ctx, sub)
+	}
+}
+
+// canonicalService - название сервиса для фильтра отчётов: каноническое, если оно есть в каталоге
+func (s *SubscriptionService) canonicalService(ctx context.Context, name string) (string, error) {
+	if s.catalog == nil {
+		return name, nil
+	}
+	return s.catalog.Canonical(ctx, name)
+}
+
+func sameService(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"reflect"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"testovoe_again/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// memCatalogRepo находит сервис по любому написанию, как PostgresCatalogRepo, и считает обращения
+type memCatalogRepo struct {
+	repository.CatalogRepository
+	entries  []domain.CatalogEntry
+	err      error
+	resolves int
+}
+
+func (r *memCatalogRepo) Resolve(_ context.Context, name string) (domain.CatalogEntry, error) {
+	r.resolves++
+	if r.err != nil {
+		return domain.CatalogEntry{}, r.err
+	}
+	for _, entry := range r.entries {
+		for _, n := range entry.Names() {
+			if domain.ServiceKey(n) == domain.ServiceKey(name) {
+				return entry, nil
+			}
+		}
+	}
+	return domain.CatalogEntry{}, errors.ErrServiceNotFound
+}
+
+func minor(v int64) *int64 { return &v }
+
+func testCatalog() []domain.CatalogEntry {
+	return []domain.CatalogEntry{
+		{ID: 1, Name: "Yandex Plus", Aliases: []string{"Яндекс Плюс"}, DefaultPrice: 39900, Currency: domain.CurrencyRUB,
+			MinPrice: minor(19900), MaxPrice: minor(99900)},
+		{ID: 2, Name: "Netflix", DefaultPrice: 1500, Currency: domain.CurrencyUSD},
+		{ID: 3, Name: "Spotify", DefaultPrice: 1000, Currency: domain.CurrencyEUR, MaxPrice: minor(2000)},
+	}
+}
+
+func TestNormalizeEntry(t *testing.T) {
+	cases := []struct {
+		name        string
+		entry       domain.CatalogEntry
+		wantName    string
+		wantAliases []string
+		wantErr     *errors.Error
+		field       string
+	}{
+		{name: "пробелы и дубли алиасов", entry: domain.CatalogEntry{Name: "  Yandex   Plus ", Aliases: []string{"yandex plus", "Яндекс  Плюс", " ", "яндекс плюс"}},
+			wantName: "Yandex Plus", wantAliases: []string{"Яндекс Плюс"}},
+		{name: "без алиасов", entry: domain.CatalogEntry{Name: "Okko"}, wantName: "Okko", wantAliases: []string{}},
+		{name: "пустое название", entry: domain.CatalogEntry{Name: "   "}, wantErr: errors.ErrInvalidService, field: "name"},
+		{name: "неизвестная валюта", entry: domain.CatalogEntry{Name: "Okko", Currency: "JPY"}, wantErr: errors.ErrInvalidCurrency, field: "currency"},
+		{name: "отрицательная цена", entry: domain.CatalogEntry{Name: "Okko", DefaultPrice: -1}, wantErr: errors.ErrInvalidPrice, field: "default_price"},
+		{name: "отрицательная граница", entry: domain.CatalogEntry{Name: "Okko", MinPrice: minor(-1)}, wantErr: errors.ErrInvalidPrice, field: "min_price"},
+		{name: "границы наоборот", entry: domain.CatalogEntry{Name: "Okko", MinPrice: minor(500), MaxPrice: minor(100), DefaultPrice: 300},
+			wantErr: errors.ErrInvalidService, field: "max_price"},
+		{name: "цена по умолчанию вне границ", entry: domain.CatalogEntry{Name: "Okko", MinPrice: minor(500), DefaultPrice: 100},
+			wantErr: errors.ErrInvalidService, field: "default_price"},
+	}
+	for _, c := range cases {
+		entry := c.entry
+		err := normalizeEntry(&entry)
+		if c.wantErr != nil {
+			var typed *errors.Error
+			if !errors.Is(err, c.wantErr) || !errors.As(err, &typed) || typed.Field != c.field {
+				t.Errorf("%s: ошибка %v, ожидалась %v в поле %s", c.name, err, c.wantErr, c.field)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if entry.Name != c.wantName || !reflect.DeepEqual(entry.Aliases, c.wantAliases) || entry.Currency != domain.DefaultCurrency {
+			t.Errorf("%s: %q %q %s, ожидалось %q %q", c.name, entry.Name, entry.Aliases, entry.Currency, c.wantName, c.wantAliases)
+		}
+	}
+}
+
+func TestCatalogApply(t *testing.T) {
+	strict := CatalogRules{Required: true, EnforcePrice: true}
+	cases := []struct {
+		name     string
+		rules    CatalogRules
+		service  string
+		price    int64
+		currency string
+		wantName string
+		wantID   *int
+		wantErr  *errors.Error
+	}{
+		{name: "алиас становится каноническим названием", rules: strict, service: "яндекс  плюс", price: 39900, currency: domain.CurrencyRUB,
+			wantName: "Yandex Plus", wantID: intPtr(1)},
+		{name: "вне каталога - свободное название", service: "Okko", price: 39900, currency: domain.CurrencyRUB, wantName: "Okko"},
+		{name: "вне каталога при Required", rules: strict, service: "Okko", price: 39900, currency: domain.CurrencyRUB,
+			wantErr: errors.ErrUnknownService},
+		{name: "вне каталога - общий MaxPrice", service: "Okko", price: MaxPrice + 1, currency: domain.CurrencyRUB,
+			wantErr: errors.ErrInvalidPrice},
+		{name: "ниже границы сервиса", rules: strict, service: "Yandex Plus", price: 9900, currency: domain.CurrencyRUB,
+			wantErr: errors.ErrPriceOutOfRange},
+		{name: "границы без EnforcePrice не действуют", service: "Yandex Plus", price: 9900, currency: domain.CurrencyRUB,
+			wantName: "Yandex Plus", wantID: intPtr(1)},
+		{name: "цена в другой валюте пересчитывается", rules: strict, service: "Yandex Plus", price: 1300, currency: domain.CurrencyUSD,
+			wantErr: errors.ErrPriceOutOfRange},
+		{name: "пересчитанная цена в границах", rules: strict, service: "Yandex Plus", price: 500, currency: domain.CurrencyUSD,
+			wantName: "Yandex Plus", wantID: intPtr(1)},
+		{name: "верхняя граница включительно", rules: strict, service: "spotify", price: 2000, currency: domain.CurrencyEUR,
+			wantName: "Spotify", wantID: intPtr(3)},
+		{name: "без границ - общий MaxPrice", rules: strict, service: "Netflix", price: MaxPrice + 1, currency: domain.CurrencyUSD,
+			wantErr: errors.ErrInvalidPrice},
+		{name: "отрицательная цена", rules: strict, service: "Spotify", price: -1, currency: domain.CurrencyEUR,
+			wantErr: errors.ErrInvalidPrice},
+	}
+
+	for _, c := range cases {
+		catalog := NewCatalog(zap.NewNop(), &memCatalogRepo{entries: testCatalog()}, testRates, c.rules)
+		// service_id от прошлой версии подписки не должен пережить сверку
+		sub := domain.Subscription{ServiceName: c.service, Price: c.price, Currency: c.currency, ServiceID: intPtr(99)}
+		err := catalog.Apply(context.Background(), &sub)
+		if c.wantErr != nil {
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, c.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if sub.ServiceName != c.wantName || !sameService(sub.ServiceID, c.wantID) {
+			t.Errorf("%s: %q service_id %v, ожидалось %q %v", c.name, sub.ServiceName, sub.ServiceID, c.wantName, c.wantID)
+		}
+	}
+}
+
+func intPtr(v int) *int { return &v }
+
+func TestCatalogApplier(t *testing.T) {
+	repo := &memCatalogRepo{entries: testCatalog()}
+	apply := NewCatalog(zap.NewNop(), repo, testRates, CatalogRules{}).Applier()
+
+	// одно и то же название в разных написаниях и один и тот же неизвестный сервис ищутся по разу
+	for _, name := range []string{"Yandex Plus", "yandex plus", "Okko", "OKKO", "Netflix", "Yandex  Plus"} {
+		sub := domain.Subscription{ServiceName: name, Price: 100, Currency: domain.CurrencyRUB}
+		if err := apply(context.Background(), &sub); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	if repo.resolves != 3 {
+		t.Errorf("%d обращений к каталогу на 3 разных сервиса", repo.resolves)
+	}
+
+	// сбой базы не запоминается, следующая строка спросит снова
+	repo.err, repo.resolves = stderrors.New("conn reset"), 0
+	for i := 0; i < 2; i++ {
+		sub := domain.Subscription{ServiceName: "Spotify", Price: 100, Currency: domain.CurrencyRUB}
+		if err := apply(context.Background(), &sub); err == nil {
+			t.Errorf("сбой каталога проглочен")
+		}
+	}
+	if repo.resolves != 2 {
+		t.Errorf("сбой каталога запомнился: %d обращений вместо 2", repo.resolves)
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	cases := []struct {
+		name    string
+		want    string
+		repoErr error
+	}{
+		{name: "Яндекс Плюс", want: "Yandex Plus"},
+		{name: "Okko", want: "Okko"},
+		{name: "Netflix", repoErr: stderrors.New("conn reset")},
+	}
+	for _, c := range cases {
+		catalog := NewCatalog(zap.NewNop(), &memCatalogRepo{entries: testCatalog(), err: c.repoErr}, testRates, CatalogRules{})
+		got, err := catalog.Canonical(context.Background(), c.name)
+		if c.repoErr != nil {
+			if !stderrors.Is(err, c.repoErr) {
+				t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, c.repoErr)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("Canonical(%q) = %q, %v, ожидалось %q", c.name, got, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"testovoe_again/internal/domain"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ProcessExpiring переводит в expired подписки, у которых прошла end_date, а подписки с auto_renew
+// продлевает на расчётный период, как только до end_date остаётся меньше lead. Подписки разбираются
+// пакетами по batchSize, пока очередной пакет не окажется неполным, каждый следующий пакет начинается после
+// последней подписки предыдущего. Продление, которое пересекается с другой подпиской сервиса,
+// не выполняется: подписка закончится в свой срок, а до тех пор проход её просто минует
+func (s *SubscriptionService) ProcessExpiring(ctx context.Context, lead time.Duration, batchSize int) (domain.ExpiryStats, error) {
+	on := today()
+	renewBefore := on.Add(lead)
+
+	plan := func(sub domain.Subscription, renew bool) (domain.StatusChange, bool, error) {
+		end, err := domain.ParseDate(*sub.EndDate)
+		if err != nil {
+			return domain.StatusChange{}, false, err
+		}
+		// продлеваются только идущие подписки: отменённую или приостановленную пользователь продлит сам
+		if renew && sub.AutoRenew && (sub.Status == domain.StatusTrial || sub.Status == domain.StatusActive) {
+			change, err := planRenewal(sub, end, on, renewBefore)
+			return change, err == nil, err
+		}
+		if !end.Before(on) {
+			return domain.StatusChange{}, false, nil
+		}
+		change, err := planTransition(sub, domain.ActionExpire, on)
+		return change, err == nil, err
+	}
+
+	var (
+		total domain.ExpiryStats
+		after domain.ExpiryCursor
+	)
+	for {
+		stats, err := s.repo.ProcessExpiring(ctx, on, renewBefore, after, batchSize, plan)
+		if err != nil {
+			return total, err
+		}
+		total.Expired += stats.Expired
+		total.Renewed += stats.Renewed
+		// неполный пакет - дальше в очереди ничего не подошло. Нетронутые подписки пакета остаются позади курсора
+		// и выберутся снова только в следующем проходе, так что пакет целиком из них не зациклит воркер
+		if stats.Scanned < batchSize {
+			break
+		}
+		after = stats.Next
+	}
+	if total.Expired > 0 || total.Renewed > 0 {
+		s.logger.Info("обработаны истекающие подписки", zap.Int("expired", total.Expired), zap.Int("renewed", total.Renewed))
+	}
+	return total, nil
+}
+
+// planRenewal - автопродление: end_date сдвигается на расчётные периоды, пока не окажется не раньше renewBefore.
+// Если воркер долго не работал, за пропущенные периоды подписка тоже продлевается - auto_renew их и подразумевал
+func planRenewal(sub domain.Subscription, end, on, renewBefore time.Time) (domain.StatusChange, error) {
+	change, err := planTransition(sub, domain.ActionRenew, on)
+	if err != nil {
+		return domain.StatusChange{}, err
+	}
+	next := domain.AddBillingPeriod(end, sub.BillingPeriod, sub.BillingPeriodDays)
+	for next.Before(renewBefore) {
+		next = domain.AddBillingPeriod(next, sub.BillingPeriod, sub.BillingPeriodDays)
+	}
+	date := domain.FormatDate(next)
+	change.EndDate = &date
+	return change, nil
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/repository"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// memExpiryRepo - очередь воркера истечения в памяти с той же выборкой и порядком, что у PostgresRepo.
+// blocked - подписки, продление которых упирается в пересечение
+type memExpiryRepo struct {
+	repository.SubscriptionRepository
+	subs    map[int]*domain.Subscription
+	blocked map[int]bool
+	calls   int
+}
+
+func (r *memExpiryRepo) ProcessExpiring(ctx context.Context, expireBefore, renewBefore time.Time, after domain.ExpiryCursor, limit int, plan repository.ExpiryPlan) (domain.ExpiryStats, error) {
+	r.calls++
+	type row struct {
+		end time.Time
+		sub *domain.Subscription
+	}
+	var queue []row
+	for _, sub := range r.subs {
+		end, _ := domain.ParseDate(*sub.EndDate)
+		due := end.Before(expireBefore) || (sub.AutoRenew && (sub.Status == domain.StatusTrial || sub.Status == domain.StatusActive) && end.Before(renewBefore))
+		past := end.After(after.EndDate) || (end.Equal(after.EndDate) && sub.ID > after.ID)
+		if sub.Status != domain.StatusExpired && due && past {
+			queue = append(queue, row{end, sub})
+		}
+	}
+	sort.Slice(queue, func(i, j int) bool {
+		if !queue[i].end.Equal(queue[j].end) {
+			return queue[i].end.Before(queue[j].end)
+		}
+		return queue[i].sub.ID < queue[j].sub.ID
+	})
+	if len(queue) > limit {
+		queue = queue[:limit]
+	}
+
+	var stats domain.ExpiryStats
+	stats.Scanned = len(queue)
+	if len(queue) > 0 {
+		last := queue[len(queue)-1]
+		stats.Next = domain.ExpiryCursor{EndDate: last.end, ID: last.sub.ID}
+	}
+	for _, q := range queue {
+		for _, renew := range []bool{true, false} {
+			change, ok, err := plan(*q.sub, renew)
+			if err != nil {
+				return stats, err
+			}
+			if !ok {
+				break
+			}
+			if change.Action == domain.ActionRenew && r.blocked[q.sub.ID] {
+				continue
+			}
+			q.sub.Status = change.To
+			if change.EndDate != nil {
+				q.sub.EndDate = change.EndDate
+			}
+			if change.Action == domain.ActionRenew {
+				stats.Renewed++
+			} else {
+				stats.Expired++
+			}
+			break
+		}
+	}
+	return stats, nil
+}
+
+func TestProcessExpiringSkipsBlockedRenewals(t *testing.T) {
+	on := today()
+	date := func(days int) *string {
+		d := domain.FormatDate(on.AddDate(0, 0, days))
+		return &d
+	}
+	repo := &memExpiryRepo{subs: make(map[int]*domain.Subscription), blocked: make(map[int]bool)}
+	// голова очереди - автопродления, упёршиеся в пересечения: end_date сегодня, так что и закрывать их ещё рано.
+	// Пакетов из них хватит на несколько полных, и проход без курсора выбирал бы их снова и снова
+	for id := 1; id <= 6; id++ {
+		repo.subs[id] = &domain.Subscription{ID: id, StartDate: domain.FormatDate(on.AddDate(0, -1, 0)), EndDate: date(0),
+			Status: domain.StatusActive, AutoRenew: true, BillingPeriod: domain.BillingMonthly}
+		repo.blocked[id] = true
+	}
+	// за ними - автопродления без пересечений с end_date завтра
+	for id := 7; id <= 10; id++ {
+		repo.subs[id] = &domain.Subscription{ID: id, StartDate: domain.FormatDate(on.AddDate(0, -1, 0)), EndDate: date(1),
+			Status: domain.StatusActive, AutoRenew: true, BillingPeriod: domain.BillingMonthly}
+	}
+	// и одна уже закончившаяся - она идёт первой
+	repo.subs[11] = &domain.Subscription{ID: 11, StartDate: domain.FormatDate(on.AddDate(0, -2, 0)), EndDate: date(-3),
+		Status: domain.StatusActive, BillingPeriod: domain.BillingMonthly}
+
+	svc := NewSubscriptionService(zap.NewNop(), repo, nil, nil, domain.OverlapReject, nil, nil)
+	stats, err := svc.ProcessExpiring(context.Background(), 48*time.Hour, 3)
+	if err != nil {
+		t.Fatalf("ProcessExpiring: %v", err)
+	}
+
+	if stats.Expired != 1 {
+		t.Errorf("expired = %d, ожидалась 1 закончившаяся подписка", stats.Expired)
+	}
+	if stats.Renewed != 4 {
+		t.Errorf("renewed = %d, ожидалось продление 4 подписок за заблокированными", stats.Renewed)
+	}
+	for id := 7; id <= 10; id++ {
+		if *repo.subs[id].EndDate == *date(1) {
+			t.Errorf("подписка %d за заблокированными не обработана", id)
+		}
+	}
+	for id := 1; id <= 6; id++ {
+		if repo.subs[id].Status != domain.StatusActive || *repo.subs[id].EndDate != *date(0) {
+			t.Errorf("подписка %d с заблокированным продлением изменена", id)
+		}
+	}
+	if repo.calls > 5 {
+		t.Errorf("%d пакетов на 11 подписок по 3 - проход зациклился", repo.calls)
+	}
+}
+
+func TestPlanRenewal(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := domain.ParseDate(s)
+		if err != nil {
+			t.Fatalf("дата %s: %v", s, err)
+		}
+		return d
+	}
+	days := func(n int) *int { return &n }
+
+	cases := []struct {
+		name        string
+		end         string
+		period      string
+		days        *int
+		on          string
+		renewBefore string
+		want        string
+	}{
+		{name: "на один период", end: "2025-07-20", period: domain.BillingMonthly, on: "2025-07-15", renewBefore: "2025-07-22", want: "2025-08-20"},
+		{name: "с конца месяца", end: "2025-01-31", period: domain.BillingMonthly, on: "2025-01-30", renewBefore: "2025-02-01", want: "2025-02-28"},
+		{name: "пропущенные периоды", end: "2025-03-10", period: domain.BillingMonthly, on: "2025-07-15", renewBefore: "2025-07-17", want: "2025-08-10"},
+		{name: "новая end_date ровно на границе", end: "2025-07-01", period: domain.BillingWeekly, on: "2025-07-01", renewBefore: "2025-07-08", want: "2025-07-08"},
+		{name: "первое число - в виде MM-YYYY", end: "06-2025", period: domain.BillingMonthly, on: "2025-05-30", renewBefore: "2025-06-02", want: "07-2025"},
+		{name: "custom", end: "2025-07-16", period: domain.BillingCustom, days: days(10), on: "2025-07-15", renewBefore: "2025-07-17", want: "2025-07-26"},
+	}
+	for _, c := range cases {
+		sub := domain.Subscription{Status: domain.StatusActive, StartDate: "01-2025", EndDate: &c.end, AutoRenew: true,
+			BillingPeriod: c.period, BillingPeriodDays: c.days}
+		change, err := planRenewal(sub, date(c.end), date(c.on), date(c.renewBefore))
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if change.Action != domain.ActionRenew || change.To != domain.StatusActive || change.EndDate == nil || *change.EndDate != c.want {
+			t.Errorf("%s: %+v, end_date %s, ожидалось продление до %s", c.name, change, deref(change.EndDate), c.want)
+		}
+	}
+}
+
+func TestProcessExpiringStatuses(t *testing.T) {
+	on := today()
+	date := func(days int) *string {
+		d := domain.FormatDate(on.AddDate(0, 0, days))
+		return &d
+	}
+	cases := []struct {
+		name      string
+		status    string
+		autoRenew bool
+		end       int
+		wantTo    string
+		renewed   bool
+	}{
+		{name: "active закончилась", status: domain.StatusActive, end: -1, wantTo: domain.StatusExpired},
+		{name: "active с автопродлением", status: domain.StatusActive, autoRenew: true, end: 1, wantTo: domain.StatusActive, renewed: true},
+		{name: "trial с автопродлением становится active", status: domain.StatusTrial, autoRenew: true, end: -1, wantTo: domain.StatusActive, renewed: true},
+		{name: "paused с автопродлением не продлевается", status: domain.StatusPaused, autoRenew: true, end: -1, wantTo: domain.StatusExpired},
+		{name: "cancelled с автопродлением не продлевается", status: domain.StatusCancelled, autoRenew: true, end: -1, wantTo: domain.StatusExpired},
+		{name: "end_date сегодня - ещё идёт", status: domain.StatusActive, end: 0, wantTo: domain.StatusActive},
+	}
+	for _, c := range cases {
+		sub := &domain.Subscription{ID: 1, StartDate: domain.FormatDate(on.AddDate(0, -2, 0)), EndDate: date(c.end),
+			Status: c.status, AutoRenew: c.autoRenew, BillingPeriod: domain.BillingMonthly}
+		repo := &memExpiryRepo{subs: map[int]*domain.Subscription{1: sub}}
+		svc := NewSubscriptionService(zap.NewNop(), repo, nil, nil, domain.OverlapReject, nil, nil)
+		stats, err := svc.ProcessExpiring(context.Background(), 48*time.Hour, 10)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if sub.Status != c.wantTo || (stats.Renewed == 1) != c.renewed {
+			t.Errorf("%s: статус %s, продлено %d, ожидалось %s, продление %v", c.name, sub.Status, stats.Renewed, c.wantTo, c.renewed)
+		}
+		if c.renewed && !after(*sub.EndDate, on.Add(48*time.Hour)) {
+			t.Errorf("%s: end_date %s не дальше окна продления", c.name, *sub.EndDate)
+		}
+	}
+}
+
+func after(date string, t time.Time) bool {
+	d, _ := domain.ParseDate(date)
+	return !d.Before(t)
+}
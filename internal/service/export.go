@@ -0,0 +1,29 @@
+package service
+
+import (
+	"context"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"go.uber.org/zap"
+)
+
+// Export - выгрузка всех подписок под фильтром листинга без пагинации, подписки по одной уходят в fn
+func (s *SubscriptionService) Export(ctx context.Context, filter domain.ListFilter, fn func(domain.Subscription) error) error {
+	if filter.SortBy == "" {
+		filter.SortBy = domain.SortByID
+	}
+	if !isSortKey(filter.SortBy) {
+		s.logger.Warn("невалидный ключ сортировки", zap.String("sort", filter.SortBy))
+		return errors.ErrInvalidSortKey
+	}
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		return errors.ErrInvalidFilter
+	}
+
+	// выгрузка всегда целиком, страница и курсор тут ничего не значат
+	filter.Limit = 0
+	filter.Cursor = nil
+
+	return s.repo.Export(ctx, filter, fn)
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"testovoe_again/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+type memHistoryRepo struct {
+	repository.SubscriptionRepository
+	entries map[int][]domain.AuditEntry
+}
+
+func (r *memHistoryRepo) History(ctx context.Context, id int) ([]domain.AuditEntry, error) {
+	return r.entries[id], nil
+}
+
+func TestHistory(t *testing.T) {
+	repo := &memHistoryRepo{entries: map[int][]domain.AuditEntry{
+		1: {{ID: 1, SubscriptionID: 1, Operation: domain.AuditCreate}, {ID: 2, SubscriptionID: 1, Operation: domain.AuditDelete}},
+		// удалённая насовсем подписка: журнал остаётся
+		2: {{ID: 3, SubscriptionID: 2, Operation: domain.AuditCreate}, {ID: 4, SubscriptionID: 2, Operation: domain.AuditPurge}},
+	}}
+	svc := NewSubscriptionService(zap.NewNop(), repo, nil, nil, domain.OverlapReject, nil, nil)
+
+	cases := []struct {
+		id      int
+		entries int
+		want    error
+	}{
+		{1, 2, nil},
+		{2, 2, nil},
+		{3, 0, errors.ErrSubscriptionNotFound},
+	}
+	for _, c := range cases {
+		entries, err := svc.History(context.Background(), c.id)
+		if !errors.Is(err, c.want) || len(entries) != c.entries {
+			t.Errorf("история %d: %d записей, %v, ожидалось %d и %v", c.id, len(entries), err, c.entries, c.want)
+		}
+	}
+}
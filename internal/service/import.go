@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"testovoe_again/internal/importer"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// importChunkSize - сколько валидных строк копится перед записью одной транзакцией.
+// Файл читается потоком, поэтому в памяти одновременно не больше одного чанка
+const importChunkSize = 500
+
+// Import читает файл построчно, каждую строку прогоняет через те же правила, что и Create,
+// и пишет валидные строки чанками в best-effort режиме. dryRun - только отчёт, в базу ничего не уходит
+func (s *SubscriptionService) Import(ctx context.Context, rows importer.Reader, mapping importer.Mapping, dryRun bool) (domain.ImportReport, error) {
+	report := domain.ImportReport{DryRun: dryRun, Errors: make([]domain.ImportRowError, 0)}
+
+	header, err := rows.Read()
+	if err != nil {
+		if err == io.EOF {
+			return domain.ImportReport{}, errors.ErrInvalidImport
+		}
+		s.logger.Warn("не удалось прочитать заголовок файла", zap.Error(err))
+		return domain.ImportReport{}, errors.ErrInvalidImport
+	}
+	cols, err := importer.ResolveColumns(header, mapping)
+	if err != nil {
+		s.logger.Warn("не удалось сопоставить колонки файла", zap.Error(err))
+		return domain.ImportReport{}, err
+	}
+
+	var (
+		chunk     []domain.Subscription
+		chunkRows []int
+		check     = s.subscriptionChecker()
+		checkUser = s.userChecker()
+		overlaps  = s.importOverlaps()
+	)
+	// чанк пишется одной транзакцией, строки - по одной через insert, как в Create, каждая под своим savepoint:
+	// так к ним применяется политика пересечений, а строка видит уже записанные строки файла
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		saved := make([]bool, len(chunk))
+		_, err := inTx(ctx, s, func(ctx context.Context) (struct{}, error) {
+			for i, sub := range chunk {
+				_, err := inTx(ctx, s, func(ctx context.Context) (domain.Subscription, error) {
+					return s.insert(ctx, sub)
+				})
+				if err != nil {
+					s.logger.Warn("не удалось сохранить строку импорта", zap.Int("row", chunkRows[i]), zap.Error(err))
+					report.Valid--
+					report.AddError(chunkRows[i], err)
+					continue
+				}
+				saved[i] = true
+			}
+			return struct{}{}, nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, ok := range saved {
+			if ok {
+				report.Created++
+			}
+		}
+		chunk, chunkRows = chunk[:0], chunkRows[:0]
+		return nil
+	}
+
+	// строка 1 - заголовок
+	row := 1
+	for {
+		record, err := rows.Read()
+		if err == io.EOF {
+			break
+		}
+		row++
+		if err != nil {
+			// кривая строка CSV не мешает читать следующие, любая другая ошибка - это уже проблема с самим файлом
+			var parseErr *csv.ParseError
+			if stderrors.As(err, &parseErr) {
+				report.Total++
+				report.AddError(row, errors.Wrap(errors.ErrInvalidImport, parseErr.Err))
+				continue
+			}
+			s.logger.Warn("ошибка чтения файла импорта", zap.Int("row", row), zap.Error(err))
+			return domain.ImportReport{}, err
+		}
+		if importer.IsBlank(record) {
+			continue
+		}
+		report.Total++
+
+		sub, err := cols.Subscription(record)
+		if err == nil {
+			err = check(ctx, &sub)
+		}
+		if err == nil {
+			err = checkUser(ctx, sub.UserID)
+		}
+		// без записи пересечения проверяются отдельно, иначе dry_run пропустил бы строки, на которых упадёт импорт
+		if err == nil && dryRun {
+			err = overlaps(ctx, sub)
+		}
+		if err != nil {
+			report.AddError(row, err)
+			continue
+		}
+		report.Valid++
+
+		if dryRun {
+			continue
+		}
+		chunk = append(chunk, sub)
+		chunkRows = append(chunkRows, row)
+		if len(chunk) == importChunkSize {
+			if err := flush(); err != nil {
+				return domain.ImportReport{}, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return domain.ImportReport{}, err
+	}
+
+	s.logger.Info("импорт подписок завершён", zap.Bool("dry_run", dryRun), zap.Int("total", report.Total),
+		zap.Int("created", report.Created), zap.Int("invalid", report.Invalid))
+	return report, nil
+}
+
+// importPeriod - период строки файла, уже прошедшей проверки, end == nil - бессрочная
+type importPeriod struct {
+	start time.Time
+	end   *time.Time
+}
+
+// importKey - пользователь и сервис в смысле пересечений, см. domain.OverlapKey
+type importKey struct {
+	userID  uuid.UUID
+	service string
+}
+
+// importOverlaps - проверка пересечений для dry_run: с живыми подписками в базе и с предыдущими строками файла.
+// Отказ только при политике reject: при warn и merge такая строка была бы сохранена.
+// Периоды строк копятся в памяти, только пока политика reject, иначе они не нужны
+func (s *SubscriptionService) importOverlaps() func(ctx context.Context, sub domain.Subscription) error {
+	seen := make(map[importKey][]importPeriod)
+	return func(ctx context.Context, sub domain.Subscription) error {
+		if s.overlap != domain.OverlapReject {
+			return nil
+		}
+		existing, err := s.repo.Overlaps(ctx, sub, 0)
+		if err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			return errors.Wrap(errors.ErrSubscriptionOverlap, fmt.Errorf("пересекается с подписками %v", subscriptionIDs(existing)))
+		}
+
+		start, _ := domain.ParseDate(sub.StartDate)
+		period := importPeriod{start: start}
+		if sub.EndDate != nil {
+			end, _ := domain.ParseDate(*sub.EndDate)
+			period.end = &end
+		}
+		key := importKey{userID: sub.UserID, service: domain.OverlapKey(sub)}
+		for _, other := range seen[key] {
+			if period.overlaps(other) {
+				return errors.Wrap(errors.ErrSubscriptionOverlap, fmt.Errorf("пересекается с подпиской из предыдущей строки файла"))
+			}
+		}
+		seen[key] = append(seen[key], period)
+		return nil
+	}
+}
+
+// overlaps - пересечение с концами включительно, как subscription_period в базе
+func (p importPeriod) overlaps(other importPeriod) bool {
+	return (p.end == nil || !other.start.After(*p.end)) && (other.end == nil || !p.start.After(*other.end))
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+func (s *SubscriptionService) List(ctx context.Context, filter domain.ListFilter) (domain.SubscriptionPage, error) {
+	if filter.SortBy == "" {
+		filter.SortBy = domain.SortByID
+	}
+	if !isSortKey(filter.SortBy) {
+		s.logger.Warn("невалидный ключ сортировки", zap.String("sort", filter.SortBy))
+		return domain.SubscriptionPage{}, errors.ErrInvalidSortKey
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultPageSize
+	}
+	if filter.Limit > MaxPageSize {
+		filter.Limit = MaxPageSize
+	}
+
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		return domain.SubscriptionPage{}, errors.ErrInvalidFilter
+	}
+
+	// курсор привязан к сортировке, с которой его выдали - если клиент поменял sort посреди обхода,
+	// то значение из курсора уже ничего не значит и молча отдавать кривую страницу не стоит
+	if filter.Cursor != nil && (filter.Cursor.SortBy != filter.SortBy || filter.Cursor.SortDesc != filter.SortDesc) {
+		s.logger.Warn("курсор не соответствует сортировке", zap.String("sort", filter.SortBy))
+		return domain.SubscriptionPage{}, errors.ErrInvalidCursor
+	}
+
+	items, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return domain.SubscriptionPage{}, err
+	}
+
+	page := domain.SubscriptionPage{Items: items}
+	if len(items) > filter.Limit {
+		page.Items = items[:filter.Limit]
+		last := page.Items[len(page.Items)-1]
+		page.NextCursor, err = EncodeCursor(cursorFor(last, filter.SortBy, filter.SortDesc))
+		if err != nil {
+			return domain.SubscriptionPage{}, err
+		}
+	}
+	return page, nil
+}
+
+func isSortKey(key string) bool {
+	switch key {
+	case domain.SortByID, domain.SortByServiceName, domain.SortByPrice, domain.SortByStartDate:
+		return true
+	}
+	return false
+}
+
+// cursorFor достаёт из последней записи значение ключа сортировки в том виде, в котором его поймёт postgres
+func cursorFor(sub domain.Subscription, sortBy string, desc bool) domain.Cursor {
+	c := domain.Cursor{SortBy: sortBy, SortDesc: desc, ID: sub.ID}
+	switch sortBy {
+	case domain.SortByID:
+		c.SortValue = strconv.Itoa(sub.ID)
+	case domain.SortByServiceName:
+		c.SortValue = sub.ServiceName
+	case domain.SortByPrice:
+		c.SortValue = strconv.FormatInt(sub.Price, 10)
+	case domain.SortByStartDate:
+		t, _ := ValidateDate(sub.StartDate)
+		c.SortValue = t.Format("2006-01-02")
+	}
+	return c
+}
+
+// EncodeCursor упаковывает курсор в непрозрачную для клиента строку
+func EncodeCursor(c domain.Cursor) (string, error) {
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// DecodeCursor - обратная операция, любая ошибка разбора это ErrInvalidCursor
+func DecodeCursor(s string) (domain.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return domain.Cursor{}, errors.ErrInvalidCursor
+	}
+	var c domain.Cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return domain.Cursor{}, errors.ErrInvalidCursor
+	}
+	if !isSortKey(c.SortBy) || c.ID <= 0 || !isSortValue(c.SortBy, c.SortValue) {
+		return domain.Cursor{}, errors.ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// isSortValue - значение из курсора приводится в запросе к типу колонки сортировки, и подделанное значение
+// уронило бы запрос с 500. Поэтому разбираем его здесь так же, как его выдаёт cursorFor
+func isSortValue(sortBy, value string) bool {
+	switch sortBy {
+	case domain.SortByID:
+		_, err := strconv.ParseInt(value, 10, 32)
+		return err == nil
+	case domain.SortByPrice:
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	case domain.SortByStartDate:
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	case domain.SortByServiceName:
+		// text в postgres не принимает нулевой байт и невалидный UTF-8
+		return utf8.ValidString(value) && !strings.ContainsRune(value, 0)
+	}
+	return false
+}
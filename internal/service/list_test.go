@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"testovoe_again/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	encode := func(c domain.Cursor) string {
+		s, err := EncodeCursor(c)
+		if err != nil {
+			t.Fatalf("EncodeCursor: %v", err)
+		}
+		return s
+	}
+	raw := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	cases := []struct {
+		name   string
+		cursor string
+		ok     bool
+	}{
+		{"по id", encode(domain.Cursor{SortBy: domain.SortByID, SortValue: "50", ID: 50}), true},
+		{"по цене по убыванию", encode(domain.Cursor{SortBy: domain.SortByPrice, SortDesc: true, SortValue: "39900", ID: 7}), true},
+		{"по дате", encode(domain.Cursor{SortBy: domain.SortByStartDate, SortValue: "2025-07-01", ID: 3}), true},
+		{"по названию", encode(domain.Cursor{SortBy: domain.SortByServiceName, SortValue: "Яндекс Плюс", ID: 3}), true},
+		{"не base64", "!!!", false},
+		{"не json", raw("{"), false},
+		{"неизвестный ключ сортировки", encode(domain.Cursor{SortBy: "end_date", SortValue: "2025-07-01", ID: 1}), false},
+		{"нулевой id", encode(domain.Cursor{SortBy: domain.SortByID, SortValue: "1", ID: 0}), false},
+		{"цена не число", encode(domain.Cursor{SortBy: domain.SortByPrice, SortValue: "1; DROP TABLE", ID: 1}), false},
+		{"дата в MM-YYYY", encode(domain.Cursor{SortBy: domain.SortByStartDate, SortValue: "07-2025", ID: 1}), false},
+		{"id больше int4", encode(domain.Cursor{SortBy: domain.SortByID, SortValue: "4294967296", ID: 1}), false},
+		{"нулевой байт в названии", encode(domain.Cursor{SortBy: domain.SortByServiceName, SortValue: "a\x00b", ID: 1}), false},
+	}
+
+	for _, c := range cases {
+		_, err := DecodeCursor(c.cursor)
+		if c.ok && err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+		if !c.ok && !errors.Is(err, errors.ErrInvalidCursor) {
+			t.Errorf("%s: ошибка %v, ожидалась ErrInvalidCursor", c.name, err)
+		}
+	}
+}
+
+func TestIsSortValue(t *testing.T) {
+	cases := []struct {
+		sortBy, value string
+		want          bool
+	}{
+		{domain.SortByID, "1", true},
+		{domain.SortByID, "-1", true},
+		{domain.SortByID, "1.5", false},
+		{domain.SortByID, "", false},
+		{domain.SortByPrice, "9223372036854775807", true},
+		{domain.SortByPrice, "9223372036854775808", false},
+		{domain.SortByStartDate, "2025-02-28", true},
+		{domain.SortByStartDate, "2025-02-30", false},
+		{domain.SortByServiceName, "", true},
+		{domain.SortByServiceName, "\xff", false},
+		{"version", "1", false},
+	}
+	for _, c := range cases {
+		if got := isSortValue(c.sortBy, c.value); got != c.want {
+			t.Errorf("isSortValue(%q, %q) = %v, ожидалось %v", c.sortBy, c.value, got, c.want)
+		}
+	}
+}
+
+// memListRepo отдаёт подписки с id больше курсора, сколько попросят, - как keyset-запрос по id
+type memListRepo struct {
+	repository.SubscriptionRepository
+	subs []domain.Subscription
+	last domain.ListFilter
+}
+
+func (r *memListRepo) List(ctx context.Context, filter domain.ListFilter) ([]domain.Subscription, error) {
+	r.last = filter
+	var result []domain.Subscription
+	for _, sub := range r.subs {
+		if filter.Cursor != nil && sub.ID <= filter.Cursor.ID {
+			continue
+		}
+		// сервис просит на одну запись больше страницы, чтобы понять, есть ли следующая
+		if len(result) == filter.Limit+1 {
+			break
+		}
+		result = append(result, sub)
+	}
+	return result, nil
+}
+
+func TestListPages(t *testing.T) {
+	repo := &memListRepo{}
+	for id := 1; id <= 5; id++ {
+		repo.subs = append(repo.subs, domain.Subscription{ID: id, StartDate: "07-2025"})
+	}
+	svc := NewSubscriptionService(zap.NewNop(), repo, nil, nil, domain.OverlapReject, nil, nil)
+	ctx := context.Background()
+
+	var (
+		seen   []int
+		filter = domain.ListFilter{Limit: 2}
+	)
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("листинг не закончился за 3 страницы")
+		}
+		page, err := svc.List(ctx, filter)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		for _, sub := range page.Items {
+			seen = append(seen, sub.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor, err := DecodeCursor(page.NextCursor)
+		if err != nil {
+			t.Fatalf("выданный курсор не разбирается: %v", err)
+		}
+		filter.Cursor = &cursor
+	}
+	if len(seen) != 5 || seen[0] != 1 || seen[4] != 5 {
+		t.Errorf("обход выдал %v, ожидались id 1..5", seen)
+	}
+}
+
+func TestListValidation(t *testing.T) {
+	repo := &memListRepo{}
+	svc := NewSubscriptionService(zap.NewNop(), repo, nil, nil, domain.OverlapReject, nil, nil)
+	price := func(v int64) *int64 { return &v }
+
+	cases := []struct {
+		name   string
+		filter domain.ListFilter
+		want   error
+	}{
+		{"неизвестный ключ сортировки", domain.ListFilter{SortBy: "end_date"}, errors.ErrInvalidSortKey},
+		{"min_price больше max_price", domain.ListFilter{MinPrice: price(200), MaxPrice: price(100)}, errors.ErrInvalidFilter},
+		{"курсор от другой сортировки", domain.ListFilter{SortBy: domain.SortByPrice,
+			Cursor: &domain.Cursor{SortBy: domain.SortByID, SortValue: "1", ID: 1}}, errors.ErrInvalidCursor},
+		{"курсор от другого направления", domain.ListFilter{SortBy: domain.SortByID, SortDesc: true,
+			Cursor: &domain.Cursor{SortBy: domain.SortByID, SortValue: "1", ID: 1}}, errors.ErrInvalidCursor},
+	}
+	for _, c := range cases {
+		if _, err := svc.List(context.Background(), c.filter); !errors.Is(err, c.want) {
+			t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, c.want)
+		}
+	}
+
+	if _, err := svc.List(context.Background(), domain.ListFilter{Limit: 10000}); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if repo.last.Limit != MaxPageSize || repo.last.SortBy != domain.SortByID {
+		t.Errorf("limit %d, sort %q, ожидалось %d и id", repo.last.Limit, repo.last.SortBy, MaxPageSize)
+	}
+}
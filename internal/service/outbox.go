@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"testovoe_again/internal/outbox"
+	"testovoe_again/internal/repository"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// OutboxRelay переносит события из outbox в публикатор. Публикуются они после коммита изменения,
+// но не теряются: строка outbox закоммичена вместе с ним и ждёт, пока публикатор её примет
+type OutboxRelay struct {
+	logger    *zap.Logger
+	repo      repository.OutboxRepository
+	publisher outbox.EventPublisher
+	batchSize int
+	retention time.Duration
+}
+
+// NewOutboxRelay - retention: сколько опубликованные события хранятся в outbox перед удалением
+func NewOutboxRelay(logger *zap.Logger, repo repository.OutboxRepository, publisher outbox.EventPublisher, batchSize int, retention time.Duration) *OutboxRelay {
+	return &OutboxRelay{logger: logger, repo: repo, publisher: publisher, batchSize: batchSize, retention: retention}
+}
+
+// Relay публикует outbox пакетами по batchSize, пока пакет не окажется неполным, и чистит старые опубликованные события.
+// Ошибка публикатора прерывает проход, неопубликованные события дождутся следующего
+func (o *OutboxRelay) Relay(ctx context.Context) (int, error) {
+	var total int
+	for {
+		published, err := o.repo.Relay(ctx, o.batchSize, o.publisher.Publish)
+		total += published
+		if err != nil {
+			return total, err
+		}
+		if published < o.batchSize {
+			break
+		}
+	}
+	if total > 0 {
+		o.logger.Debug("события outbox опубликованы", zap.Int("published", total))
+	}
+
+	if _, err := o.repo.PurgePublished(ctx, time.Now().Add(-o.retention)); err != nil {
+		return total, err
+	}
+	return total, nil
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/outbox"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// memOutboxRepo - очередь outbox в памяти: Relay отдаёт до limit событий по порядку и останавливается на первой ошибке
+type memOutboxRepo struct {
+	pending []domain.OutboxEvent
+	batches int
+	purged  []time.Time
+}
+
+func (r *memOutboxRepo) Relay(ctx context.Context, limit int, publish func(ctx context.Context, event domain.OutboxEvent) error) (int, error) {
+	r.batches++
+	published := 0
+	for published < limit && len(r.pending) > 0 {
+		if err := publish(ctx, r.pending[0]); err != nil {
+			return published, err
+		}
+		r.pending = r.pending[1:]
+		published++
+	}
+	return published, nil
+}
+
+func (r *memOutboxRepo) PurgePublished(_ context.Context, before time.Time) (int64, error) {
+	r.purged = append(r.purged, before)
+	return 0, nil
+}
+
+// flakyPublisher падает на failAt-м событии (с единицы)
+type flakyPublisher struct {
+	*outbox.MemoryPublisher
+	calls  int
+	failAt int
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, event domain.OutboxEvent) error {
+	p.calls++
+	if p.calls == p.failAt {
+		return stderrors.New("broker недоступен")
+	}
+	return p.MemoryPublisher.Publish(ctx, event)
+}
+
+func TestOutboxRelay(t *testing.T) {
+	cases := []struct {
+		name      string
+		pending   int
+		batchSize int
+		failAt    int
+		want      int
+		batches   int
+		wantErr   bool
+		purged    int
+	}{
+		{name: "пусто", pending: 0, batchSize: 3, want: 0, batches: 1, purged: 1},
+		{name: "неполный пакет", pending: 2, batchSize: 3, want: 2, batches: 1, purged: 1},
+		{name: "ровно пакет - ещё один проход", pending: 3, batchSize: 3, want: 3, batches: 2, purged: 1},
+		{name: "несколько пакетов", pending: 7, batchSize: 3, want: 7, batches: 3, purged: 1},
+		{name: "ошибка прерывает проход", pending: 7, batchSize: 3, failAt: 5, want: 4, batches: 2, wantErr: true},
+	}
+	for _, c := range cases {
+		repo := &memOutboxRepo{}
+		for i := 0; i < c.pending; i++ {
+			repo.pending = append(repo.pending, domain.OutboxEvent{EventID: uuid.New(), Type: domain.EventSubscriptionCreated})
+		}
+		publisher := &flakyPublisher{MemoryPublisher: outbox.NewMemoryPublisher(0), failAt: c.failAt}
+		relay := NewOutboxRelay(zap.NewNop(), repo, publisher, c.batchSize, 24*time.Hour)
+
+		started := time.Now()
+		got, err := relay.Relay(context.Background())
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, c.wantErr)
+		}
+		if got != c.want || len(publisher.Events()) != c.want || repo.batches != c.batches {
+			t.Errorf("%s: опубликовано %d (%d у публикатора) за %d пакетов, ожидалось %d за %d", c.name, got, len(publisher.Events()), repo.batches, c.want, c.batches)
+		}
+		if len(repo.purged) != c.purged {
+			t.Errorf("%s: %d чисток, ожидалось %d", c.name, len(repo.purged), c.purged)
+			continue
+		}
+		if c.purged == 0 {
+			continue
+		}
+		if cutoff := repo.purged[0]; cutoff.Before(started.Add(-24*time.Hour)) || cutoff.After(time.Now().Add(-24*time.Hour)) {
+			t.Errorf("%s: чистятся события старше %v, ожидалось сутки назад", c.name, cutoff)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Пересечения периодов подписок одного пользователя на один сервис. Сервис проверяет их до записи,
+// чтобы применить политику, - в том числе для импорта, он пишет через те же insert и rewrite. Пакет ищет
+// пересечения одним запросом в репозитории, а слияния отдаёт обратно сюда через applyBatchOp.
+// Ограничение subscriptions_no_overlap в базе ловит гонки между проверкой и записью и запись в обход сервиса
+
+// mergePlan - куда записать подписку при слиянии: в подписку into с ожидаемой версией version,
+// отправив в корзину поглощённые absorbed
+type mergePlan struct {
+	into     int
+	version  int
+	absorbed []int
+}
+
+// resolveOverlaps ищет пересечения sub с другими живыми подписками и применяет политику: reject отказывает,
+// warn помечает sub как осознанное пересечение, merge расширяет период sub на все пересечения и возвращает план слияния.
+// version - ожидаемая версия sub при обновлении, при создании не используется
+func (s *SubscriptionService) resolveOverlaps(ctx context.Context, sub *domain.Subscription, version int) (*mergePlan, error) {
+	sub.OverlapAllowed = false
+	overlaps, err := s.repo.Overlaps(ctx, *sub, sub.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(overlaps) == 0 {
+		return nil, nil
+	}
+	ids := subscriptionIDs(overlaps)
+
+	switch s.overlap {
+	case domain.OverlapWarn:
+		s.logger.Warn("подписка пересекается с другими подписками сервиса", zap.Int("id", sub.ID),
+			zap.String("user_id", sub.UserID.String()), zap.String("service_name", sub.ServiceName), zap.Ints("overlaps", ids))
+		sub.OverlapAllowed = true
+		sub.Overlaps = ids
+		return nil, nil
+	case domain.OverlapMerge:
+		return s.planMerge(ctx, sub, overlaps, version)
+	default:
+		s.logger.Warn("отказ: подписка пересекается с другими подписками сервиса", zap.Int("id", sub.ID), zap.Ints("overlaps", ids))
+		return nil, errors.Wrap(errors.ErrSubscriptionOverlap, fmt.Errorf("пересекается с подписками %v", ids))
+	}
+}
+
+// planMerge - при создании новые данные ложатся в самую старую из пересекающихся подписок, при обновлении
+// остаётся обновляемая. Расширенный период может задеть подписки, которые с исходным не пересекались,
+// поэтому поиск повторяется, пока не перестанут находиться новые
+func (s *SubscriptionService) planMerge(ctx context.Context, sub *domain.Subscription, overlaps []domain.Subscription, version int) (*mergePlan, error) {
+	plan := &mergePlan{into: sub.ID, version: version}
+	if sub.ID == 0 {
+		// от выжившей подписки остаётся только период, остальное перезаписывается новыми данными.
+		// Версию не проверяем: клиент о ней не знал, и отвечать 412 на запрос без If-Match было бы странно
+		plan.into = overlaps[0].ID
+		plan.version = 0
+		sub.ID = overlaps[0].ID
+		widenPeriod(sub, overlaps[0])
+	}
+
+	seen := map[int]bool{sub.ID: true}
+	for len(overlaps) > 0 {
+		for _, other := range overlaps {
+			if seen[other.ID] {
+				continue
+			}
+			seen[other.ID] = true
+			plan.absorbed = append(plan.absorbed, other.ID)
+			widenPeriod(sub, other)
+		}
+
+		next, err := s.repo.Overlaps(ctx, *sub, sub.ID)
+		if err != nil {
+			return nil, err
+		}
+		overlaps = overlaps[:0]
+		for _, other := range next {
+			if !seen[other.ID] {
+				overlaps = append(overlaps, other)
+			}
+		}
+	}
+
+	s.logger.Info("пересекающиеся подписки слиты", zap.Int("id", plan.into), zap.Ints("absorbed", plan.absorbed),
+		zap.String("start_date", sub.StartDate))
+	sub.MergedIDs = plan.absorbed
+	return plan, nil
+}
+
+// widenPeriod расширяет период sub до объединения с периодом other. Бессрочная подписка остаётся бессрочной
+func widenPeriod(sub *domain.Subscription, other domain.Subscription) {
+	start, _ := domain.ParseDate(sub.StartDate)
+	if otherStart, err := domain.ParseDate(other.StartDate); err == nil && otherStart.Before(start) {
+		sub.StartDate = other.StartDate
+	}
+
+	if sub.EndDate == nil {
+		return
+	}
+	if other.EndDate == nil {
+		sub.EndDate = nil
+		return
+	}
+	end, _ := domain.ParseDate(*sub.EndDate)
+	if otherEnd, err := domain.ParseDate(*other.EndDate); err == nil && otherEnd.After(end) {
+		otherEndDate := *other.EndDate
+		sub.EndDate = &otherEndDate
+	}
+}
+
+func subscriptionIDs(subs []domain.Subscription) []int {
+	ids := make([]int, len(subs))
+	for i, sub := range subs {
+		ids[i] = sub.ID
+	}
+	return ids
+}
+
+// Conflicts - уже существующие пересечения, например сохранённые с политикой warn или оставшиеся от времён до появления проверки
+func (s *SubscriptionService) Conflicts(ctx context.Context, userID *uuid.UUID) ([]domain.Conflict, error) {
+	conflicts, err := s.repo.Conflicts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return conflicts, nil
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"testovoe_again/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// memOverlapRepo ищет пересечения так же, как PostgresRepo: тот же пользователь, тот же сервис,
+// периоды как закрытые диапазоны, бессрочная подписка тянется до бесконечности, порядок по id
+type memOverlapRepo struct {
+	repository.SubscriptionRepository
+	subs  []domain.Subscription
+	err   error
+	calls int
+}
+
+func (r *memOverlapRepo) Overlaps(_ context.Context, sub domain.Subscription, excludeID int) ([]domain.Subscription, error) {
+	r.calls++
+	if r.err != nil {
+		return nil, r.err
+	}
+	var result []domain.Subscription
+	for _, other := range r.subs {
+		if other.ID == excludeID || other.UserID != sub.UserID || !strings.EqualFold(other.ServiceName, sub.ServiceName) {
+			continue
+		}
+		if periodsOverlap(sub, other) {
+			result = append(result, other)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func periodsOverlap(a, b domain.Subscription) bool {
+	aStart, _ := domain.ParseDate(a.StartDate)
+	bStart, _ := domain.ParseDate(b.StartDate)
+	before := func(start time.Time, end *string) bool {
+		if end == nil {
+			return true
+		}
+		e, _ := domain.ParseDate(*end)
+		return !start.After(e)
+	}
+	return before(aStart, b.EndDate) && before(bStart, a.EndDate)
+}
+
+func period(start, end string) domain.Subscription {
+	sub := domain.Subscription{StartDate: start}
+	if end != "" {
+		sub.EndDate = &end
+	}
+	return sub
+}
+
+func TestWidenPeriod(t *testing.T) {
+	cases := []struct {
+		name      string
+		sub       domain.Subscription
+		other     domain.Subscription
+		wantStart string
+		wantEnd   string
+	}{
+		{name: "other внутри", sub: period("01-2025", "12-2025"), other: period("03-2025", "05-2025"), wantStart: "01-2025", wantEnd: "12-2025"},
+		{name: "other раньше", sub: period("03-2025", "05-2025"), other: period("01-2025", "04-2025"), wantStart: "01-2025", wantEnd: "05-2025"},
+		{name: "other позже", sub: period("03-2025", "05-2025"), other: period("04-2025", "09-2025"), wantStart: "03-2025", wantEnd: "09-2025"},
+		{name: "other бессрочная", sub: period("03-2025", "05-2025"), other: period("04-2025", ""), wantStart: "03-2025"},
+		{name: "sub бессрочная", sub: period("03-2025", ""), other: period("01-2025", "04-2025"), wantStart: "01-2025"},
+		{name: "даты с днём", sub: period("2025-03-15", "2025-03-31"), other: period("2025-03-01", "2025-04-10"),
+			wantStart: "2025-03-01", wantEnd: "2025-04-10"},
+	}
+	for _, c := range cases {
+		sub := c.sub
+		widenPeriod(&sub, c.other)
+		var end string
+		if sub.EndDate != nil {
+			end = *sub.EndDate
+		}
+		if sub.StartDate != c.wantStart || end != c.wantEnd {
+			t.Errorf("%s: %s - %q, ожидалось %s - %q", c.name, sub.StartDate, end, c.wantStart, c.wantEnd)
+		}
+	}
+	// период other не должен стать общим с sub
+	other := period("01-2025", "12-2025")
+	sub := period("03-2025", "05-2025")
+	widenPeriod(&sub, other)
+	*sub.EndDate = "01-2030"
+	if *other.EndDate != "12-2025" {
+		t.Errorf("widenPeriod поделил end_date с поглощённой подпиской")
+	}
+}
+
+func TestResolveOverlaps(t *testing.T) {
+	user, stranger := uuid.New(), uuid.New()
+	existing := func(id int, service, start, end string, owner uuid.UUID) domain.Subscription {
+		sub := period(start, end)
+		sub.ID, sub.ServiceName, sub.UserID = id, service, owner
+		return sub
+	}
+	// 2 пересекается с новой подпиской 01-03.2025 напрямую, 5 - только с периодом, расширенным слиянием с 2,
+	// 7 - уже с периодом после слияния с 5. Остальные не пересекаются или чужие
+	repoSubs := []domain.Subscription{
+		existing(2, "Netflix", "02-2025", "04-2025", user),
+		existing(5, "Netflix", "04-2025", "06-2025", user),
+		existing(7, "netflix", "06-2025", "", user),
+		existing(3, "Netflix", "09-2024", "12-2024", user),
+		existing(4, "Okko", "01-2025", "03-2025", user),
+		existing(6, "Netflix", "01-2025", "03-2025", stranger),
+	}
+
+	cases := []struct {
+		name      string
+		policy    string
+		sub       domain.Subscription
+		version   int
+		repoErr   error
+		wantErr   error
+		wantPlan  *mergePlan
+		wantStart string
+		wantEnd   string
+		overlaps  []int
+		allowed   bool
+	}{
+		{name: "без пересечений", policy: domain.OverlapReject, sub: existing(0, "Netflix", "01-2024", "06-2024", user),
+			wantStart: "01-2024", wantEnd: "06-2024"},
+		{name: "reject", policy: domain.OverlapReject, sub: existing(0, "Netflix", "01-2025", "03-2025", user),
+			wantErr: errors.ErrSubscriptionOverlap, wantStart: "01-2025", wantEnd: "03-2025"},
+		{name: "warn помечает пересечение", policy: domain.OverlapWarn, sub: existing(0, "Netflix", "01-2025", "03-2025", user),
+			wantStart: "01-2025", wantEnd: "03-2025", overlaps: []int{2}, allowed: true},
+		{name: "merge при создании в самую старую, цепочкой", policy: domain.OverlapMerge, sub: existing(0, "Netflix", "01-2025", "03-2025", user),
+			version: 4, wantPlan: &mergePlan{into: 2, absorbed: []int{5, 7}}, wantStart: "01-2025"},
+		{name: "merge при обновлении в обновляемую", policy: domain.OverlapMerge, sub: existing(3, "Netflix", "11-2024", "02-2025", user),
+			version: 4, wantPlan: &mergePlan{into: 3, version: 4, absorbed: []int{2, 5, 7}}, wantStart: "11-2024"},
+		{name: "ошибка поиска", policy: domain.OverlapMerge, sub: existing(0, "Netflix", "01-2025", "03-2025", user),
+			repoErr: stderrors.New("conn reset"), wantStart: "01-2025", wantEnd: "03-2025"},
+	}
+
+	for _, c := range cases {
+		repo := &memOverlapRepo{subs: repoSubs, err: c.repoErr}
+		svc := NewSubscriptionService(zap.NewNop(), repo, nil, nil, c.policy, nil, nil)
+		sub := c.sub
+		plan, err := svc.resolveOverlaps(context.Background(), &sub, c.version)
+
+		switch {
+		case c.repoErr != nil:
+			if !stderrors.Is(err, c.repoErr) {
+				t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, c.repoErr)
+			}
+		case c.wantErr != nil:
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, c.wantErr)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(plan, c.wantPlan) {
+			t.Errorf("%s: план %+v, ожидался %+v", c.name, plan, c.wantPlan)
+		}
+		var end string
+		if sub.EndDate != nil {
+			end = *sub.EndDate
+		}
+		if sub.StartDate != c.wantStart || end != c.wantEnd {
+			t.Errorf("%s: период %s - %q, ожидалось %s - %q", c.name, sub.StartDate, end, c.wantStart, c.wantEnd)
+		}
+		if !reflect.DeepEqual(sub.Overlaps, c.overlaps) || sub.OverlapAllowed != c.allowed {
+			t.Errorf("%s: overlaps %v allowed %v, ожидалось %v %v", c.name, sub.Overlaps, sub.OverlapAllowed, c.overlaps, c.allowed)
+		}
+		if c.wantPlan != nil && !reflect.DeepEqual(sub.MergedIDs, c.wantPlan.absorbed) {
+			t.Errorf("%s: merged_ids %v, ожидалось %v", c.name, sub.MergedIDs, c.wantPlan.absorbed)
+		}
+	}
+}
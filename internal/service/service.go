@@ -4,7 +4,10 @@ import (
 	"context"
 	"testovoe_again/internal/domain"
 	"testovoe_again/internal/errors"
+	"testovoe_again/internal/importer"
+	"testovoe_again/internal/rates"
 	"testovoe_again/internal/repository"
+	"testovoe_again/internal/users"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,12 +16,47 @@ import (
 
 // CRUDL Методы для сервиса
 type SubService interface {
-	Create(ctx context.Context, sub domain.Subscription) (int, error)
+	// Create, Update и Patch применяют политику пересечений: при слиянии Create возвращает
+	// уже существующую подписку, в которую легли новые данные, с заполненным MergedIDs
+	Create(ctx context.Context, sub domain.Subscription) (domain.Subscription, error)
 	Read(ctx context.Context, id int) (domain.Subscription, error)
-	Update(ctx context.Context, sub domain.Subscription) error
-	Delete(ctx context.Context, id int) error
+	// Update, Patch и Delete принимают ожидаемую версию подписки (из If-Match), 0 - без проверки.
+	// Для Update ожидаемая версия лежит в sub.Version. Update и Patch возвращают подписку после изменения
+	Update(ctx context.Context, sub domain.Subscription) (domain.Subscription, error)
+
+	// Patch - частичное обновление (JSON Merge Patch)
+	Patch(ctx context.Context, id int, patch domain.SubscriptionPatch, version int) (domain.Subscription, error)
+	Delete(ctx context.Context, id int, version int) error
+
+	// корзина: Delete мягкий, удалённые подписки листятся через List с filter.Deleted
+	Restore(ctx context.Context, id int) (domain.Subscription, error)
+	// PurgeDeleted насовсем удаляет подписки, пролежавшие в корзине дольше retention
+	PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error)
+
+	// ChangeStatus - действие pause, resume, cancel или renew по правилам переходов из domain.NextStatus,
+	// version - ожидаемая версия, как у Delete
+	ChangeStatus(ctx context.Context, id int, action string, version int) (domain.Subscription, error)
+
+	// History - журнал изменений подписки от старых записей к новым
+	History(ctx context.Context, id int) ([]domain.AuditEntry, error)
+
+	// Batch - пакет create/update/delete. В atomic режиме любая ошибка откатывает весь пакет
+	// и возвращается ErrBatchAborted вместе с результатами, по которым видно, что именно упало
+	Batch(ctx context.Context, ops []domain.BatchOp, atomic bool) ([]domain.BatchResult, error)
+
+	// Import - импорт подписок из CSV/XLSX с отчётом по строкам, dryRun ничего не пишет в базу
+	Import(ctx context.Context, rows importer.Reader, mapping importer.Mapping, dryRun bool) (domain.ImportReport, error)
 	GetListByUserID(ctx context.Context, UserID uuid.UUID) ([]domain.Subscription, error)
 
+	// List - листинг с фильтрами, сортировкой и keyset-пагинацией, в отличие от GetListByUserID не тянет всё разом
+	List(ctx context.Context, filter domain.ListFilter) (domain.SubscriptionPage, error)
+
+	// Export - те же фильтры, что у List, но без пагинации: подписки по одной отдаются в fn прямо из курсора базы
+	Export(ctx context.Context, filter domain.ListFilter, fn func(domain.Subscription) error) error
+
+	// Conflicts - пары пересекающихся по датам подписок на один сервис, по одному пользователю, если userID задан
+	Conflicts(ctx context.Context, userID *uuid.UUID) ([]domain.Conflict, error)
+
 	//Втрой пункт ТЗ
 	//ручка "для подсчета суммарной стоимости всех подписок за
 	//выбранный период с фильтрацией по id пользователя и названию подписки"
@@ -26,35 +64,89 @@ type SubService interface {
 	//дату принимаю в строке, основываясь на примере запроса в ТЗ
 	//FirstDate - начало временного отрезка, за который пользователь хочет получить статистику
 	//LastDate - конец временного отрезка
-	CalculateTotal(ctx context.Context, userID uuid.UUID, serviceName string, FirstDate, LastDate string) (int, error)
+	//currency - валюта, в которую пересчитывается итог, сумма возвращается в её минорных единицах
+	CalculateTotal(ctx context.Context, userID uuid.UUID, serviceName string, FirstDate, LastDate string, currency string) (int64, error)
+
+	// аналитика: помесячная разбивка, разбивка по сервисам и топ сервисов по всем пользователям
+	MonthlySpend(ctx context.Context, filter domain.AnalyticsFilter) ([]domain.SpendRow, error)
+	SpendByService(ctx context.Context, filter domain.AnalyticsFilter) ([]domain.SpendRow, error)
+	TopServices(ctx context.Context, filter domain.AnalyticsFilter) ([]domain.SpendRow, error)
 }
 
 type SubscriptionService struct {
-	logger *zap.Logger
-	repo   repository.SubscriptionRepository
+	logger  *zap.Logger
+	repo    repository.SubscriptionRepository
+	tx      repository.TxManager
+	rates   rates.Provider
+	overlap string
+	catalog *Catalog
+	users   users.Directory
 }
 
-func NewSubscriptionService(logger *zap.Logger, repo repository.SubscriptionRepository) *SubscriptionService {
-	return &SubscriptionService{logger: logger, repo: repo}
+// NewSubscriptionService - overlap это политика пересечений: domain.OverlapReject, OverlapMerge или OverlapWarn.
+// catalog может быть nil, тогда название сервиса - свободный текст, а цена ограничена только MaxPrice.
+// users - справочник, по которому проверяется user_id новых подписок, nil - без проверки.
+// tx - в чём выполнять операции из нескольких запросов к базе, nil - каждый запрос сам по себе
+func NewSubscriptionService(logger *zap.Logger, repo repository.SubscriptionRepository, tx repository.TxManager, rates rates.Provider, overlap string, catalog *Catalog, users users.Directory) *SubscriptionService {
+	return &SubscriptionService{logger: logger, repo: repo, tx: tx, rates: rates, overlap: overlap, catalog: catalog, users: users}
 }
 
-func (s *SubscriptionService) Create(ctx context.Context, sub domain.Subscription) (int, error) {
-	// вообще я по идее для такого сервиса должен был бы ходить в базу или кэш для того, чтобы сравнить поля
-	// ServiceName, Price, айдишники и уже на основе этой проверки давать ok || !ok, но в рамках проекта я по сути своей
-	// ничего кроме наивного решения типа проверки sub.Price < 0 && > 10000 сделать не могу
-	// в целом это бы выглядело как-то так: if sub.ServiceName != db.ServiceName { error }
-
-	//если пришла невалидная цена - кидаем Warn и делаем json ошибки для логов
-	err := ValidatePrice(sub.Price)
+// inTx выполняет fn в одной транзакции, если у сервиса есть TxManager: чтение подписки, проверки
+// и запись видят одно и то же состояние базы, а ошибка на любом шаге откатывает всё
+func inTx[T any](ctx context.Context, s *SubscriptionService, fn func(ctx context.Context) (T, error)) (T, error) {
+	if s.tx == nil {
+		return fn(ctx)
+	}
+	var result T
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = fn(ctx)
+		return err
+	})
 	if err != nil {
-		s.logger.Warn("невалидная цена", zap.Error(err), zap.Int("price", sub.Price))
-		return 0, err
+		var zero T
+		return zero, err
 	}
+	return result, nil
+}
+
+// Create - проверки по каталогу, справочнику и пересечениям идут в той же транзакции, что и запись
+func (s *SubscriptionService) Create(ctx context.Context, sub domain.Subscription) (domain.Subscription, error) {
+	return inTx(ctx, s, func(ctx context.Context) (domain.Subscription, error) {
+		return s.create(ctx, sub)
+	})
+}
 
-	_, err = ValidateDate(sub.StartDate)
+func (s *SubscriptionService) create(ctx context.Context, sub domain.Subscription) (domain.Subscription, error) {
+	// валюту не передали - считаем, что подписка в рублях, как было до появления мультивалютности
+	if sub.Currency == "" {
+		sub.Currency = domain.DefaultCurrency
+	}
+	if err := ValidateCurrency(sub.Currency); err != nil {
+		s.logger.Warn("невалидная валюта", zap.String("currency", sub.Currency))
+		return domain.Subscription{}, err
+	}
+
+	// название сервиса сверяется с каталогом, там же проверяется цена: по границам сервиса или общим MaxPrice
+	if err := s.checkService(ctx, &sub); err != nil {
+		s.logger.Warn("подписка не прошла проверку по каталогу", zap.Error(err), zap.String("service_name", sub.ServiceName), zap.Int64("price", sub.Price))
+		return domain.Subscription{}, err
+	}
+
+	if err := ValidateBilling(&sub); err != nil {
+		s.logger.Warn("невалидный период списания", zap.String("BillingPeriod", sub.BillingPeriod))
+		return domain.Subscription{}, err
+	}
+
+	if err := ValidateStatus(&sub); err != nil {
+		s.logger.Warn("невалидный статус новой подписки", zap.String("status", sub.Status))
+		return domain.Subscription{}, err
+	}
+
+	_, err := ValidateDate(sub.StartDate)
 	if err != nil {
 		s.logger.Warn("невалидная дата", zap.String("StartDate", sub.StartDate))
-		return 0, err
+		return domain.Subscription{}, err
 	}
 
 	//т.к. EndDate у нас может и не быть, я сделал проверку на nil чтобы не ловить панику в этом кейсе
@@ -62,17 +154,46 @@ func (s *SubscriptionService) Create(ctx context.Context, sub domain.Subscriptio
 		_, err := ValidateDate(*sub.EndDate)
 		if err != nil {
 			s.logger.Warn("невалидная дата", zap.String("EndDate", *sub.EndDate))
-			return 0, err
+			return domain.Subscription{}, err
+		}
+	}
+	if err := ValidatePeriod(sub); err != nil {
+		s.logger.Warn("подписка заканчивается раньше, чем начинается", zap.String("StartDate", sub.StartDate), zap.String("EndDate", *sub.EndDate))
+		return domain.Subscription{}, err
+	}
+
+	if err := s.checkUser(ctx, sub.UserID); err != nil {
+		s.logger.Warn("подписка на неизвестного пользователя", zap.Error(err), zap.String("user_id", sub.UserID.String()))
+		return domain.Subscription{}, err
+	}
+
+	return s.insert(ctx, sub)
+}
+
+// insert - запись уже проверенной новой подписки по политике пересечений: при merge она ложится в существующую.
+// Через неё же пишут пакет и импорт, чтобы политика пересечений для них была той же, что и для Create
+func (s *SubscriptionService) insert(ctx context.Context, sub domain.Subscription) (domain.Subscription, error) {
+	sub.ID = 0
+	plan, err := s.resolveOverlaps(ctx, &sub, 0)
+	if err != nil {
+		return domain.Subscription{}, err
+	}
+	if plan != nil {
+		sub.Version, err = s.repo.Merge(ctx, plan.into, sub, plan.version, plan.absorbed)
+		if err != nil {
+			return domain.Subscription{}, err
 		}
+		sub.Merged = true
+		return sub, nil
 	}
 
-	// можно было бы сюда добавить проверку на существование указанного сервиса спрашивая у редиса есть ли у нас сервис или нет
-	// и можно было бы проверить юзера по базе, но ради одного userID создавать базу смысла не особо много в рамках тестового
 	result, err := s.repo.Create(ctx, sub)
 	if err != nil {
-		return 0, err
+		return domain.Subscription{}, err
 	}
-	return result, nil
+	sub.ID = result
+	sub.Version = 1
+	return sub, nil
 }
 
 func (s *SubscriptionService) Read(ctx context.Context, id int) (domain.Subscription, error) {
@@ -88,49 +209,207 @@ func (s *SubscriptionService) Read(ctx context.Context, id int) (domain.Subscrip
 	return result, nil
 }
 
-func (s *SubscriptionService) Update(ctx context.Context, sub domain.Subscription) error {
+// Update - чтение текущей версии и запись идут в одной транзакции под блокировкой строки
+func (s *SubscriptionService) Update(ctx context.Context, sub domain.Subscription) (domain.Subscription, error) {
+	return inTx(ctx, s, func(ctx context.Context) (domain.Subscription, error) {
+		return s.update(ctx, sub)
+	})
+}
+
+func (s *SubscriptionService) update(ctx context.Context, sub domain.Subscription) (domain.Subscription, error) {
 	// логика такая - идём в базу за подпиской, которую хотим изменить
 	// затем записываем её в переменную и обновляем принимаемые поля
 	// если подписки нет - отдаём ошибку, если какое-то поле не обновили - оставляем старое
-	err := ValidatePrice(sub.Price)
-	if err != nil {
-		s.logger.Warn("невалидная цена", zap.Int("price", sub.Price))
-		return err
+	if sub.Currency == "" {
+		sub.Currency = domain.DefaultCurrency
+	}
+	if err := ValidateCurrency(sub.Currency); err != nil {
+		s.logger.Warn("невалидная валюта", zap.String("currency", sub.Currency))
+		return domain.Subscription{}, err
 	}
-	_, err = ValidateDate(sub.StartDate)
+	if err := s.checkService(ctx, &sub); err != nil {
+		s.logger.Warn("подписка не прошла проверку по каталогу", zap.Error(err), zap.String("service_name", sub.ServiceName), zap.Int64("price", sub.Price))
+		return domain.Subscription{}, err
+	}
+	if err := ValidateBilling(&sub); err != nil {
+		s.logger.Warn("невалидный период списания", zap.String("BillingPeriod", sub.BillingPeriod))
+		return domain.Subscription{}, err
+	}
+	_, err := ValidateDate(sub.StartDate)
 	if err != nil {
 		s.logger.Warn("невалидная дата", zap.String("Date", sub.StartDate))
-		return err
+		return domain.Subscription{}, err
 	}
 	if sub.EndDate != nil {
 		_, err = ValidateDate(*sub.EndDate)
 		if err != nil {
 			s.logger.Warn("невалидная дата", zap.String("Date", *sub.EndDate))
-			return err
+			return domain.Subscription{}, err
 		}
 	}
-	OldVersion, err := s.repo.GetByID(ctx, sub.ID)
+	if err := ValidatePeriod(sub); err != nil {
+		s.logger.Warn("подписка заканчивается раньше, чем начинается", zap.String("StartDate", sub.StartDate), zap.String("EndDate", *sub.EndDate))
+		return domain.Subscription{}, err
+	}
+	return s.rewrite(ctx, sub)
+}
+
+// rewrite - запись уже проверенной подписки поверх sub.ID по политике пересечений, sub.Version - ожидаемая версия.
+// Общая для Update и пакета
+func (s *SubscriptionService) rewrite(ctx context.Context, sub domain.Subscription) (domain.Subscription, error) {
+	OldVersion, err := s.repo.GetForUpdate(ctx, sub.ID)
 	if err != nil {
 		s.logger.Warn("такой подписки не существует", zap.Int("id", sub.ID))
-		return err
+		return domain.Subscription{}, err
+	}
+	// клиент редактировал уже устаревшую версию - дальше можно не идти, репозиторий всё равно не обновит строку
+	if sub.Version != 0 && sub.Version != OldVersion.Version {
+		s.logger.Warn("конфликт версий подписки", zap.Int("id", sub.ID), zap.Int("expected", sub.Version), zap.Int("actual", OldVersion.Version))
+		return domain.Subscription{}, errors.ErrVersionConflict
 	}
 	OldVersion.Price = sub.Price
 	OldVersion.StartDate = sub.StartDate
 	OldVersion.EndDate = sub.EndDate
 	OldVersion.ServiceName = sub.ServiceName
-	err = s.repo.Update(ctx, sub.ID, OldVersion)
+	OldVersion.ServiceID = sub.ServiceID
+	OldVersion.Currency = sub.Currency
+	OldVersion.BillingPeriod = sub.BillingPeriod
+	OldVersion.BillingPeriodDays = sub.BillingPeriodDays
+	OldVersion.AutoRenew = sub.AutoRenew
+
+	plan, err := s.resolveOverlaps(ctx, &OldVersion, sub.Version)
 	if err != nil {
-		return err
+		return domain.Subscription{}, err
 	}
-	return nil
+	// условие по версии всё равно уходит в репозиторий: без TxManager между чтением и Update подписку мог поменять кто-то ещё
+	if plan != nil {
+		OldVersion.Version, err = s.repo.Merge(ctx, sub.ID, OldVersion, sub.Version, plan.absorbed)
+	} else {
+		OldVersion.Version, err = s.repo.Update(ctx, sub.ID, OldVersion, sub.Version)
+	}
+	if err != nil {
+		return domain.Subscription{}, err
+	}
+	return OldVersion, nil
 }
 
-func (s *SubscriptionService) Delete(ctx context.Context, id int) error {
-	err := s.repo.Delete(ctx, id)
+func (s *SubscriptionService) Patch(ctx context.Context, id int, patch domain.SubscriptionPatch, version int) (domain.Subscription, error) {
+	return inTx(ctx, s, func(ctx context.Context) (domain.Subscription, error) {
+		return s.patch(ctx, id, patch, version)
+	})
+}
+
+func (s *SubscriptionService) patch(ctx context.Context, id int, patch domain.SubscriptionPatch, version int) (domain.Subscription, error) {
+	// валидировать патч по отдельности нельзя: billing_period без billing_period_days может быть как валидным,
+	// так и нет в зависимости от того, что уже лежит в базе. Поэтому накладываем патч на текущую версию,
+	// проверяем результат целиком, а в базу пишем только изменившиеся колонки
+	current, err := s.repo.GetForUpdate(ctx, id)
 	if err != nil {
-		return err
+		s.logger.Warn("такой подписки не существует", zap.Int("id", id))
+		return domain.Subscription{}, err
 	}
-	return nil
+
+	if version != 0 && version != current.Version {
+		s.logger.Warn("конфликт версий подписки", zap.Int("id", id), zap.Int("expected", version), zap.Int("actual", current.Version))
+		return domain.Subscription{}, errors.ErrVersionConflict
+	}
+
+	if patch.IsEmpty() {
+		return current, nil
+	}
+
+	updated := patch.Apply(current)
+
+	if err := ValidateCurrency(updated.Currency); err != nil {
+		s.logger.Warn("невалидная валюта", zap.String("currency", updated.Currency))
+		return domain.Subscription{}, err
+	}
+	if err := s.checkService(ctx, &updated); err != nil {
+		s.logger.Warn("подписка не прошла проверку по каталогу", zap.Error(err), zap.String("service_name", updated.ServiceName), zap.Int64("price", updated.Price))
+		return domain.Subscription{}, err
+	}
+	if _, err := ValidateDate(updated.StartDate); err != nil {
+		s.logger.Warn("невалидная дата", zap.String("StartDate", updated.StartDate))
+		return domain.Subscription{}, err
+	}
+	if updated.EndDate != nil {
+		if _, err := ValidateDate(*updated.EndDate); err != nil {
+			s.logger.Warn("невалидная дата", zap.String("EndDate", *updated.EndDate))
+			return domain.Subscription{}, err
+		}
+	}
+	if err := ValidateBilling(&updated); err != nil {
+		s.logger.Warn("невалидный период списания", zap.String("BillingPeriod", updated.BillingPeriod))
+		return domain.Subscription{}, err
+	}
+	if err := ValidatePeriod(updated); err != nil {
+		s.logger.Warn("подписка заканчивается раньше, чем начинается", zap.String("StartDate", updated.StartDate), zap.String("EndDate", *updated.EndDate))
+		return domain.Subscription{}, err
+	}
+
+	// пересечения проверяем, только если патч двигает период или меняет сервис
+	var plan *mergePlan
+	if patch.ServiceName != nil || patch.StartDate != nil || patch.EndDate != nil || patch.ClearEndDate {
+		plan, err = s.resolveOverlaps(ctx, &updated, version)
+		if err != nil {
+			return domain.Subscription{}, err
+		}
+	}
+	// слияние, смена отметки о пересечении или привязки к каталогу трогают не только колонки из патча - пишем подписку целиком
+	switch {
+	case plan != nil:
+		updated.Version, err = s.repo.Merge(ctx, id, updated, version, plan.absorbed)
+	case updated.OverlapAllowed != current.OverlapAllowed || updated.ServiceName != current.ServiceName ||
+		!sameService(updated.ServiceID, current.ServiceID):
+		updated.Version, err = s.repo.Update(ctx, id, updated, version)
+	default:
+		updated.Version, err = s.repo.Patch(ctx, id, patch, version)
+	}
+	if err != nil {
+		return domain.Subscription{}, err
+	}
+	return updated, nil
+}
+
+// Delete - блокировка строки, мягкое удаление, журнал и outbox идут одной транзакцией, как и остальные изменения
+func (s *SubscriptionService) Delete(ctx context.Context, id int, version int) error {
+	_, err := inTx(ctx, s, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, s.repo.Delete(ctx, id, version)
+	})
+	return err
+}
+
+func (s *SubscriptionService) Restore(ctx context.Context, id int) (domain.Subscription, error) {
+	return inTx(ctx, s, func(ctx context.Context) (domain.Subscription, error) {
+		if _, err := s.repo.Restore(ctx, id); err != nil {
+			return domain.Subscription{}, err
+		}
+		return s.repo.GetByID(ctx, id)
+	})
+}
+
+func (s *SubscriptionService) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	purged, err := s.repo.PurgeDeleted(ctx, time.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+	if purged > 0 {
+		s.logger.Info("корзина очищена", zap.Int64("purged", purged))
+	}
+	return purged, nil
+}
+
+func (s *SubscriptionService) History(ctx context.Context, id int) ([]domain.AuditEntry, error) {
+	entries, err := s.repo.History(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// пустой журнал значит, что подписки никогда не было: даже удалённая насовсем оставляет записи
+	if len(entries) == 0 {
+		s.logger.Warn("нет истории изменений подписки", zap.Int("id", id))
+		return nil, errors.ErrSubscriptionNotFound
+	}
+	return entries, nil
 }
 
 func (s *SubscriptionService) GetListByUserID(ctx context.Context, UserID uuid.UUID) ([]domain.Subscription, error) {
@@ -141,41 +420,159 @@ func (s *SubscriptionService) GetListByUserID(ctx context.Context, UserID uuid.U
 	return result, nil
 }
 
-func (s *SubscriptionService) CalculateTotal(ctx context.Context, UserID uuid.UUID, serviceName, FirstDate, LastDate string) (int, error) {
+func (s *SubscriptionService) CalculateTotal(ctx context.Context, UserID uuid.UUID, serviceName, FirstDate, LastDate, currency string) (int64, error) {
 	// т.к. по сути своей функция обязательно должна принимать какой-то временной период - вторая дата не будет передаваться
 	// через указатель, соответственно валидация у неё будет выглядеть идентично первой дате
 	// суть в том, что мы принимаем строки, валидируем и парсим, затем подставляем и возвращаем результат или ошибку
-	t1, err := ValidateDate(FirstDate)
+	// обе даты включительные: 01-2025..12-2025 это весь 2025 год, 2025-01-01..2025-01-31 - весь январь,
+	// поэтому окно это [начало первой даты, конец второй)
+	t1, _, err := ValidateDateRange(FirstDate)
 	if err != nil {
 		return 0, err
 	}
-	t2, err := ValidateDate(LastDate)
+	_, t2, err := ValidateDateRange(LastDate)
 	if err != nil {
 		return 0, err
 	}
+	// те же ограничения, что у аналитики: окно длиннее MaxAnalyticsMonths развернуло бы generate_series в столетия
+	filter := domain.AnalyticsFilter{From: t1, To: t2, Currency: currency}
+	if err := s.validatePeriod(&filter); err != nil {
+		return 0, err
+	}
+	currency = filter.Currency
 
-	result, err := s.repo.GetStatsByServiceName(ctx, UserID, serviceName, t1, t2)
+	// по каталогу любое написание сервиса даёт одну и ту же статистику
+	serviceName, err = s.canonicalService(ctx, serviceName)
 	if err != nil {
 		return 0, err
 	}
+
+	// считаем не "сколько подписок началось в периоде", а какая доля каждого расчётного периода подписки
+	// попала в окно - так подписка, начатая до окна, длящаяся несколько его месяцев или годовая, учитывается корректно
+
+	// репозиторий отдаёт суммы по каждой валюте отдельно, здесь пересчитываем их в запрошенную и складываем
+	byCurrency, err := s.repo.GetStatsByServiceName(ctx, UserID, serviceName, t1, t2)
+	if err != nil {
+		return 0, err
+	}
+
+	var result int64
+	for from, amount := range byCurrency {
+		converted, err := rates.Convert(ctx, s.rates, amount, from, currency)
+		if err != nil {
+			s.logger.Error("не удалось пересчитать сумму", zap.Error(err), zap.String("from", from), zap.String("to", currency))
+			return 0, err
+		}
+		result += converted
+	}
 	return result, nil
 }
 
-func ValidatePrice(price int) error {
-	if price < 0 || price > 10000 {
+// MaxPrice - верхняя граница цены в минорных единицах, те же 10000 в основных единицах валюты, что и раньше
+const MaxPrice = 10000 * 100
+
+func ValidatePrice(price int64) error {
+	if price < 0 || price > MaxPrice {
 		return errors.ErrInvalidPrice
 	}
 	return nil
 }
 
+func ValidateCurrency(currency string) error {
+	if !domain.IsSupportedCurrency(currency) {
+		return errors.ErrInvalidCurrency
+	}
+	return nil
+}
+
 // суть валидатора - проверить строку и вернуть time.Time
 // НО
 // если нам нужна ТОЛЬКО валидация (либо всё хорошо либо ошибка), то мы можем вызвать метод игнорируя time.Time ответ
 // и по итогу мы просто проверим, не получили ли мы случайно невалидную строку
+//
+// принимаются оба формата: MM-YYYY и полная дата YYYY-MM-DD
 func ValidateDate(date string) (time.Time, error) {
-	t, err := time.Parse("01-2006", date)
+	t, err := domain.ParseDate(date)
 	if err != nil {
 		return time.Time{}, errors.ErrInvalidDateFormat
 	}
 	return t, nil
 }
+
+// ValidateDateRange - как ValidateDate, но отдаёт дату полуинтервалом [start, end): весь месяц для MM-YYYY
+// и один день для полной даты
+func ValidateDateRange(date string) (time.Time, time.Time, error) {
+	start, end, err := domain.ParseDateRange(date)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.ErrInvalidDateFormat
+	}
+	return start, end, nil
+}
+
+// ValidatePeriod - подписка не может закончиться раньше, чем началась. Даты уже должны быть проверены ValidateDate
+func ValidatePeriod(sub domain.Subscription) error {
+	if sub.EndDate == nil {
+		return nil
+	}
+	start, _ := domain.ParseDate(sub.StartDate)
+	end, _ := domain.ParseDate(*sub.EndDate)
+	if end.Before(start) {
+		return errors.ErrInvalidPeriod.WithField("end_date")
+	}
+	return nil
+}
+
+// ValidateBilling проверяет период списания, пустой период заменяется на помесячный.
+// Длина в днях обязательна для custom и запрещена для остальных, чтобы не было двусмысленности
+func ValidateBilling(sub *domain.Subscription) error {
+	if sub.BillingPeriod == "" {
+		sub.BillingPeriod = domain.DefaultBillingPeriod
+	}
+	if !domain.IsBillingPeriod(sub.BillingPeriod) {
+		return errors.ErrInvalidBilling
+	}
+	if sub.BillingPeriod == domain.BillingCustom {
+		if sub.BillingPeriodDays == nil || *sub.BillingPeriodDays <= 0 {
+			return errors.ErrInvalidBilling
+		}
+		return nil
+	}
+	if sub.BillingPeriodDays != nil {
+		return errors.ErrInvalidBilling
+	}
+	return nil
+}
+
+// ValidateSubscription - все проверки Create разом и без логов: для пакета и импорта,
+// где ошибка уходит в отчёт по конкретной операции или строке. Пустые валюта и период заменяются на умолчания
+func ValidateSubscription(sub *domain.Subscription) error {
+	if err := ValidatePrice(sub.Price); err != nil {
+		return err
+	}
+	return validateTerms(sub)
+}
+
+// validateTerms - ValidateSubscription без цены: в сервисе её проверяет каталог
+func validateTerms(sub *domain.Subscription) error {
+	if sub.Currency == "" {
+		sub.Currency = domain.DefaultCurrency
+	}
+	if err := ValidateCurrency(sub.Currency); err != nil {
+		return err
+	}
+	if err := ValidateBilling(sub); err != nil {
+		return err
+	}
+	if err := ValidateStatus(sub); err != nil {
+		return err
+	}
+	if _, err := ValidateDate(sub.StartDate); err != nil {
+		return err
+	}
+	if sub.EndDate != nil {
+		if _, err := ValidateDate(*sub.EndDate); err != nil {
+			return err
+		}
+	}
+	return ValidatePeriod(*sub)
+}
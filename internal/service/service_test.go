@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"testovoe_again/internal/domain"
+
+	"go.uber.org/zap"
+)
+
+type txMarker struct{}
+
+// markingTx - TxManager, который помечает ctx для fn и запоминает, чем закончилась транзакция
+type markingTx struct {
+	calls int
+	err   error
+}
+
+func (m *markingTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	m.calls++
+	m.err = fn(context.WithValue(ctx, txMarker{}, true))
+	return m.err
+}
+
+func TestInTx(t *testing.T) {
+	errFn := stderrors.New("ошибка в fn")
+	cases := []struct {
+		name    string
+		tx      *markingTx
+		err     error
+		want    int
+		inTx    bool
+		wantErr error
+	}{
+		{name: "без TxManager", want: 7},
+		{name: "в транзакции", tx: &markingTx{}, want: 7, inTx: true},
+		{name: "ошибка - нулевой результат", tx: &markingTx{}, err: errFn, want: 0, inTx: true, wantErr: errFn},
+	}
+	for _, c := range cases {
+		svc := NewSubscriptionService(zap.NewNop(), nil, nil, nil, domain.OverlapReject, nil, nil)
+		if c.tx != nil {
+			svc = NewSubscriptionService(zap.NewNop(), nil, c.tx, nil, domain.OverlapReject, nil, nil)
+		}
+
+		var inTxCtx bool
+		got, err := inTx(context.Background(), svc, func(ctx context.Context) (int, error) {
+			inTxCtx = ctx.Value(txMarker{}) != nil
+			return 7, c.err
+		})
+		if !stderrors.Is(err, c.wantErr) || got != c.want || inTxCtx != c.inTx {
+			t.Errorf("%s: %d, %v, в транзакции %v, ожидалось %d, %v, %v", c.name, got, err, inTxCtx, c.want, c.wantErr, c.inTx)
+		}
+		if c.tx != nil && (c.tx.calls != 1 || !stderrors.Is(c.tx.err, c.wantErr)) {
+			t.Errorf("%s: %d транзакций, fn вернула в неё %v", c.name, c.tx.calls, c.tx.err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ChangeStatus проверяет, что действие доступно в текущем статусе подписки, и применяет его.
+// Действие считается от сегодняшнего дня: с него начинается и им заканчивается пауза, им же cancel
+// обрезает end_date и выключает auto_renew. renew продлевает подписку с end_date на один расчётный период
+func (s *SubscriptionService) ChangeStatus(ctx context.Context, id int, action string, version int) (domain.Subscription, error) {
+	return inTx(ctx, s, func(ctx context.Context) (domain.Subscription, error) {
+		return s.changeStatus(ctx, id, action, version)
+	})
+}
+
+func (s *SubscriptionService) changeStatus(ctx context.Context, id int, action string, version int) (domain.Subscription, error) {
+	current, err := s.repo.GetForUpdate(ctx, id)
+	if err != nil {
+		s.logger.Warn("такой подписки не существует", zap.Int("id", id))
+		return domain.Subscription{}, err
+	}
+	if version != 0 && version != current.Version {
+		s.logger.Warn("конфликт версий подписки", zap.Int("id", id), zap.Int("expected", version), zap.Int("actual", current.Version))
+		return domain.Subscription{}, errors.ErrVersionConflict
+	}
+
+	change, err := planTransition(current, action, today())
+	if err != nil {
+		s.logger.Warn("действие недоступно", zap.Error(err), zap.Int("id", id), zap.String("action", action), zap.String("status", current.Status))
+		return domain.Subscription{}, err
+	}
+
+	// строка заблокирована GetForUpdate до конца транзакции, так что прочитанная версия ещё актуальна
+	current.Version, err = s.repo.ChangeStatus(ctx, id, change, current.Version)
+	if err != nil {
+		return domain.Subscription{}, err
+	}
+	current.Status = change.To
+	if change.EndDate != nil {
+		current.EndDate = change.EndDate
+	}
+	if change.AutoRenew != nil {
+		current.AutoRenew = *change.AutoRenew
+	}
+	s.logger.Info("статус подписки изменён", zap.Int("id", id), zap.String("action", action),
+		zap.String("from", change.From), zap.String("to", change.To))
+	return current, nil
+}
+
+// planTransition - во что действие превращает подписку sub, если применить его в день on
+func planTransition(sub domain.Subscription, action string, on time.Time) (domain.StatusChange, error) {
+	if !domain.IsAction(action) {
+		return domain.StatusChange{}, errors.Wrap(errors.ErrInvalidTransition, fmt.Errorf("неизвестное действие %q", action))
+	}
+	next, ok := domain.NextStatus(sub.Status, action)
+	if !ok {
+		return domain.StatusChange{}, errors.Wrap(errors.ErrInvalidTransition, fmt.Errorf("%s из статуса %s", action, sub.Status))
+	}
+	change := domain.StatusChange{Action: action, From: sub.Status, To: next, On: on}
+
+	var end *time.Time
+	if sub.EndDate != nil {
+		t, err := domain.ParseDate(*sub.EndDate)
+		if err != nil {
+			return domain.StatusChange{}, err
+		}
+		end = &t
+	}
+
+	switch action {
+	case domain.ActionPause:
+		// пауза после окончания подписки ничего не изменит в тратах
+		if end != nil && end.Before(on) {
+			return domain.StatusChange{}, errors.Wrap(errors.ErrInvalidTransition, fmt.Errorf("подписка закончилась %s", *sub.EndDate))
+		}
+	case domain.ActionCancel:
+		// end_date раньше start_date сервис не допускает: не начавшуюся подписку удаляют, а не отменяют
+		start, err := domain.ParseDate(sub.StartDate)
+		if err != nil {
+			return domain.StatusChange{}, err
+		}
+		if on.Before(start) {
+			return domain.StatusChange{}, errors.Wrap(errors.ErrInvalidTransition, fmt.Errorf("подписка начнётся только %s", sub.StartDate))
+		}
+		// текущий расчётный период уже оплачен, следующие не начнутся
+		if end == nil || end.After(on) {
+			date := domain.FormatDate(on)
+			change.EndDate = &date
+		}
+		// иначе воркер истечения продлит отменённую подписку обратно
+		if sub.AutoRenew {
+			off := false
+			change.AutoRenew = &off
+		}
+	case domain.ActionRenew:
+		// trial без end_date просто становится платной, бессрочную активную подписку продлевать не нужно
+		if end == nil {
+			if sub.Status == domain.StatusActive {
+				return domain.StatusChange{}, errors.Wrap(errors.ErrInvalidTransition, fmt.Errorf("у подписки нет end_date"))
+			}
+			break
+		}
+		date := domain.FormatDate(domain.AddBillingPeriod(*end, sub.BillingPeriod, sub.BillingPeriodDays))
+		change.EndDate = &date
+	}
+	return change, nil
+}
+
+// ValidateStatus - новая подписка начинается с trial или active, без статуса - active
+func ValidateStatus(sub *domain.Subscription) error {
+	if sub.Status == "" {
+		sub.Status = domain.DefaultStatus
+	}
+	if sub.Status != domain.StatusTrial && sub.Status != domain.StatusActive {
+		return errors.ErrInvalidStatus
+	}
+	return nil
+}
+
+// today - текущий день по UTC, даты подписок хранятся без времени
+func today() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour)
+}
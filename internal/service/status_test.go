@@ -0,0 +1,117 @@
+package service
+
+import (
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"time"
+)
+
+func TestPlanTransition(t *testing.T) {
+	on := time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC)
+	end := func(s string) *string { return &s }
+	off := false
+
+	cases := []struct {
+		name      string
+		sub       domain.Subscription
+		action    string
+		wantErr   error
+		to        string
+		endDate   *string
+		autoRenew *bool
+	}{
+		{name: "пауза", sub: domain.Subscription{Status: domain.StatusActive, StartDate: "01-2025", EndDate: end("12-2025")},
+			action: domain.ActionPause, to: domain.StatusPaused},
+		{name: "пауза бессрочной", sub: domain.Subscription{Status: domain.StatusActive, StartDate: "01-2025"},
+			action: domain.ActionPause, to: domain.StatusPaused},
+		{name: "пауза после окончания", sub: domain.Subscription{Status: domain.StatusActive, StartDate: "01-2025", EndDate: end("2025-07-14")},
+			action: domain.ActionPause, wantErr: errors.ErrInvalidTransition},
+		{name: "пауза в последний день", sub: domain.Subscription{Status: domain.StatusActive, StartDate: "01-2025", EndDate: end("2025-07-15")},
+			action: domain.ActionPause, to: domain.StatusPaused},
+		{name: "возобновление", sub: domain.Subscription{Status: domain.StatusPaused, StartDate: "01-2025"},
+			action: domain.ActionResume, to: domain.StatusActive},
+		{name: "отмена обрезает end_date днём отмены", sub: domain.Subscription{Status: domain.StatusActive, StartDate: "01-2025", EndDate: end("12-2025")},
+			action: domain.ActionCancel, to: domain.StatusCancelled, endDate: end("2025-07-15")},
+		{name: "отмена бессрочной", sub: domain.Subscription{Status: domain.StatusTrial, StartDate: "01-2025"},
+			action: domain.ActionCancel, to: domain.StatusCancelled, endDate: end("2025-07-15")},
+		{name: "отмена уже закончившейся не двигает end_date", sub: domain.Subscription{Status: domain.StatusPaused, StartDate: "01-2025", EndDate: end("06-2025")},
+			action: domain.ActionCancel, to: domain.StatusCancelled},
+		{name: "отмена снимает автопродление", sub: domain.Subscription{Status: domain.StatusActive, StartDate: "01-2025", EndDate: end("12-2025"), AutoRenew: true},
+			action: domain.ActionCancel, to: domain.StatusCancelled, endDate: end("2025-07-15"), autoRenew: &off},
+		{name: "отмена в день начала", sub: domain.Subscription{Status: domain.StatusActive, StartDate: "2025-07-15"},
+			action: domain.ActionCancel, to: domain.StatusCancelled, endDate: end("2025-07-15")},
+		{name: "отмена не начавшейся", sub: domain.Subscription{Status: domain.StatusActive, StartDate: "08-2025"},
+			action: domain.ActionCancel, wantErr: errors.ErrInvalidTransition},
+		{name: "продление на период", sub: domain.Subscription{Status: domain.StatusActive, StartDate: "01-2025", EndDate: end("2025-01-31"),
+			BillingPeriod: domain.BillingMonthly}, action: domain.ActionRenew, to: domain.StatusActive, endDate: end("2025-02-28")},
+		{name: "продление на год с первого числа", sub: domain.Subscription{Status: domain.StatusCancelled, StartDate: "01-2025", EndDate: end("07-2025"),
+			BillingPeriod: domain.BillingYearly}, action: domain.ActionRenew, to: domain.StatusActive, endDate: end("07-2026")},
+		{name: "trial без end_date становится платной", sub: domain.Subscription{Status: domain.StatusTrial, StartDate: "01-2025"},
+			action: domain.ActionRenew, to: domain.StatusActive},
+		{name: "бессрочную active не продлить", sub: domain.Subscription{Status: domain.StatusActive, StartDate: "01-2025"},
+			action: domain.ActionRenew, wantErr: errors.ErrInvalidTransition},
+		{name: "недоступно из статуса", sub: domain.Subscription{Status: domain.StatusExpired, StartDate: "01-2025"},
+			action: domain.ActionResume, wantErr: errors.ErrInvalidTransition},
+		{name: "неизвестное действие", sub: domain.Subscription{Status: domain.StatusActive, StartDate: "01-2025"},
+			action: "freeze", wantErr: errors.ErrInvalidTransition},
+	}
+
+	for _, c := range cases {
+		change, err := planTransition(c.sub, c.action, on)
+		if c.wantErr != nil {
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, c.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if change.Action != c.action || change.From != c.sub.Status || change.To != c.to || !change.On.Equal(on) {
+			t.Errorf("%s: %+v, ожидался переход %s -> %s", c.name, change, c.sub.Status, c.to)
+		}
+		if (change.EndDate == nil) != (c.endDate == nil) || (change.EndDate != nil && *change.EndDate != *c.endDate) {
+			t.Errorf("%s: end_date %v, ожидалось %v", c.name, deref(change.EndDate), deref(c.endDate))
+		}
+		if (change.AutoRenew == nil) != (c.autoRenew == nil) || (change.AutoRenew != nil && *change.AutoRenew != *c.autoRenew) {
+			t.Errorf("%s: auto_renew %v, ожидалось %v", c.name, change.AutoRenew, c.autoRenew)
+		}
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return "<nil>"
+	}
+	return *s
+}
+
+func TestValidateStatus(t *testing.T) {
+	cases := []struct {
+		status string
+		want   string
+		ok     bool
+	}{
+		{status: "", want: domain.StatusActive, ok: true},
+		{status: domain.StatusTrial, want: domain.StatusTrial, ok: true},
+		{status: domain.StatusActive, want: domain.StatusActive, ok: true},
+		{status: domain.StatusPaused},
+		{status: domain.StatusExpired},
+		{status: "frozen"},
+	}
+	for _, c := range cases {
+		sub := domain.Subscription{Status: c.status}
+		err := ValidateStatus(&sub)
+		if !c.ok {
+			if !errors.Is(err, errors.ErrInvalidStatus) {
+				t.Errorf("%q: ошибка %v, ожидалась ErrInvalidStatus", c.status, err)
+			}
+			continue
+		}
+		if err != nil || sub.Status != c.want {
+			t.Errorf("%q: %q, %v, ожидалось %q", c.status, sub.Status, err, c.want)
+		}
+	}
+}
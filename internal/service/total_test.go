@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"testovoe_again/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// staticRates - курсы к рублю, как в configs/rates.json
+type staticRates map[string]float64
+
+func (r staticRates) Rate(_ context.Context, from, to string) (float64, error) {
+	fromRate, ok := r[from]
+	if !ok {
+		return 0, errors.ErrUnknownRate
+	}
+	toRate, ok := r[to]
+	if !ok {
+		return 0, errors.ErrUnknownRate
+	}
+	return fromRate / toRate, nil
+}
+
+var testRates = staticRates{domain.CurrencyRUB: 1, domain.CurrencyUSD: 80, domain.CurrencyEUR: 100}
+
+// memStatsRepo запоминает окно, за которое сервис попросил суммы, и отдаёт заготовленные суммы по валютам
+type memStatsRepo struct {
+	repository.SubscriptionRepository
+	byCurrency map[string]int64
+	from, to   time.Time
+}
+
+func (r *memStatsRepo) GetStatsByServiceName(ctx context.Context, userID uuid.UUID, serviceName string, from, to time.Time) (map[string]int64, error) {
+	r.from, r.to = from, to
+	return r.byCurrency, nil
+}
+
+func TestCalculateTotalWindow(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatalf("дата %s: %v", s, err)
+		}
+		return d
+	}
+	cases := []struct {
+		name        string
+		first, last string
+		from, to    string
+		wantErr     error
+	}{
+		{name: "год по месяцам включительно", first: "01-2025", last: "12-2025", from: "2025-01-01", to: "2026-01-01"},
+		{name: "один месяц", first: "07-2025", last: "07-2025", from: "2025-07-01", to: "2025-08-01"},
+		{name: "полные даты включительно", first: "2025-01-01", last: "2025-01-31", from: "2025-01-01", to: "2025-02-01"},
+		{name: "один день", first: "2025-01-15", last: "2025-01-15", from: "2025-01-15", to: "2025-01-16"},
+		{name: "конец раньше начала", first: "12-2025", last: "01-2025", wantErr: errors.ErrInvalidPeriod},
+		{name: "окно длиннее 10 лет", first: "01-2015", last: "01-2025", wantErr: errors.ErrInvalidPeriod},
+		{name: "невалидная дата", first: "13-2025", last: "12-2025", wantErr: errors.ErrInvalidDateFormat},
+	}
+
+	for _, c := range cases {
+		repo := &memStatsRepo{}
+		svc := NewSubscriptionService(zap.NewNop(), repo, nil, testRates, domain.OverlapReject, nil, nil)
+		_, err := svc.CalculateTotal(context.Background(), uuid.New(), "Yandex Plus", c.first, c.last, "")
+		if c.wantErr != nil {
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, c.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !repo.from.Equal(date(c.from)) || !repo.to.Equal(date(c.to)) {
+			t.Errorf("%s: окно [%s, %s), ожидалось [%s, %s)", c.name,
+				domain.FormatDate(repo.from), domain.FormatDate(repo.to), c.from, c.to)
+		}
+	}
+}
+
+func TestCalculateTotalCurrencies(t *testing.T) {
+	cases := []struct {
+		name       string
+		byCurrency map[string]int64
+		currency   string
+		want       int64
+		wantErr    error
+	}{
+		{name: "без подписок", currency: domain.CurrencyRUB, want: 0},
+		{name: "по умолчанию рубли", byCurrency: map[string]int64{domain.CurrencyRUB: 39900, domain.CurrencyUSD: 1000}, want: 39900 + 80000},
+		{name: "в долларах", byCurrency: map[string]int64{domain.CurrencyRUB: 8000, domain.CurrencyEUR: 100}, currency: domain.CurrencyUSD, want: 100 + 125},
+		{name: "неизвестная валюта отчёта", byCurrency: map[string]int64{domain.CurrencyRUB: 100}, currency: "GBP", wantErr: errors.ErrInvalidCurrency},
+	}
+
+	for _, c := range cases {
+		repo := &memStatsRepo{byCurrency: c.byCurrency}
+		svc := NewSubscriptionService(zap.NewNop(), repo, nil, testRates, domain.OverlapReject, nil, nil)
+		got, err := svc.CalculateTotal(context.Background(), uuid.New(), "Yandex Plus", "01-2025", "12-2025", c.currency)
+		if c.wantErr != nil {
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, c.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != c.want {
+			t.Errorf("%s: %d, %v, ожидалось %d", c.name, got, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"testovoe_again/internal/repository"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// memTrashRepo - корзина в памяти: Delete проставляет deleted_at, Restore снимает, PurgeDeleted удаляет насовсем
+type memTrashRepo struct {
+	repository.SubscriptionRepository
+	subs map[int]*domain.Subscription
+}
+
+func (r *memTrashRepo) GetByID(ctx context.Context, id int) (domain.Subscription, error) {
+	sub, ok := r.subs[id]
+	if !ok || sub.DeletedAt != nil {
+		return domain.Subscription{}, errors.ErrSubscriptionNotFound
+	}
+	return *sub, nil
+}
+
+func (r *memTrashRepo) Delete(ctx context.Context, id int, version int) error {
+	sub, ok := r.subs[id]
+	if !ok || sub.DeletedAt != nil {
+		return errors.ErrSubscriptionNotFound
+	}
+	if version != 0 && version != sub.Version {
+		return errors.ErrVersionConflict
+	}
+	now := time.Now()
+	sub.DeletedAt = &now
+	sub.Version++
+	return nil
+}
+
+func (r *memTrashRepo) Restore(ctx context.Context, id int) (int, error) {
+	sub, ok := r.subs[id]
+	if !ok || sub.DeletedAt == nil {
+		return 0, errors.ErrSubscriptionNotFound
+	}
+	sub.DeletedAt = nil
+	sub.Version++
+	return sub.Version, nil
+}
+
+func (r *memTrashRepo) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	for id, sub := range r.subs {
+		if sub.DeletedAt != nil && sub.DeletedAt.Before(before) {
+			delete(r.subs, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func TestDeleteAndRestore(t *testing.T) {
+	repo := &memTrashRepo{subs: map[int]*domain.Subscription{1: {ID: 1, Version: 1}}}
+	svc := NewSubscriptionService(zap.NewNop(), repo, nil, nil, domain.OverlapReject, nil, nil)
+	ctx := context.Background()
+
+	steps := []struct {
+		name string
+		run  func() error
+		want error
+	}{
+		{"восстановить живую подписку нельзя", func() error { _, err := svc.Restore(ctx, 1); return err }, errors.ErrSubscriptionNotFound},
+		{"удаление с устаревшей версией", func() error { return svc.Delete(ctx, 1, 5) }, errors.ErrVersionConflict},
+		{"удаление", func() error { return svc.Delete(ctx, 1, 1) }, nil},
+		{"удалённую не видно", func() error { _, err := svc.Read(ctx, 1); return err }, errors.ErrSubscriptionNotFound},
+		{"повторное удаление", func() error { return svc.Delete(ctx, 1, 0) }, errors.ErrSubscriptionNotFound},
+		{"восстановление", func() error { _, err := svc.Restore(ctx, 1); return err }, nil},
+		{"восстановленная снова видна", func() error { _, err := svc.Read(ctx, 1); return err }, nil},
+		{"повторное восстановление", func() error { _, err := svc.Restore(ctx, 1); return err }, errors.ErrSubscriptionNotFound},
+		{"восстановить несуществующую", func() error { _, err := svc.Restore(ctx, 2); return err }, errors.ErrSubscriptionNotFound},
+	}
+	for _, step := range steps {
+		if err := step.run(); !errors.Is(err, step.want) {
+			t.Fatalf("%s: ошибка %v, ожидалась %v", step.name, err, step.want)
+		}
+	}
+	if repo.subs[1].Version != 3 {
+		t.Errorf("версия после удаления и восстановления %d, ожидалась 3", repo.subs[1].Version)
+	}
+}
+
+func TestPurgeDeletedRetention(t *testing.T) {
+	ago := func(d time.Duration) *time.Time {
+		at := time.Now().Add(-d)
+		return &at
+	}
+	repo := &memTrashRepo{subs: map[int]*domain.Subscription{
+		1: {ID: 1, DeletedAt: ago(31 * 24 * time.Hour)},
+		2: {ID: 2, DeletedAt: ago(29 * 24 * time.Hour)},
+		3: {ID: 3},
+	}}
+	svc := NewSubscriptionService(zap.NewNop(), repo, nil, nil, domain.OverlapReject, nil, nil)
+
+	purged, err := svc.PurgeDeleted(context.Background(), 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("PurgeDeleted: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("удалено %d, ожидалась 1 подписка старше 30 дней", purged)
+	}
+	for id, want := range map[int]bool{1: false, 2: true, 3: true} {
+		if _, ok := repo.subs[id]; ok != want {
+			t.Errorf("подписка %d осталась = %v, ожидалось %v", id, ok, want)
+		}
+	}
+}
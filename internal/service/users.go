@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"testovoe_again/internal/repository"
+	"testovoe_again/internal/users"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// UserService - справочник пользователей, на которых заводятся подписки
+type UserService interface {
+	Create(ctx context.Context, user domain.User) (domain.User, error)
+	Get(ctx context.Context, id uuid.UUID) (domain.User, error)
+	// List - keyset-пагинация по id, after - NextCursor предыдущей страницы
+	List(ctx context.Context, after *uuid.UUID, limit int) (domain.UserPage, error)
+	// Update перезаписывает email и имя пользователя user.ID
+	Update(ctx context.Context, user domain.User) (domain.User, error)
+	// Delete удаляет пользователя без подписок, иначе ErrUserHasSubscriptions
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type Users struct {
+	logger *zap.Logger
+	repo   repository.UserRepository
+}
+
+func NewUsers(logger *zap.Logger, repo repository.UserRepository) *Users {
+	return &Users{logger: logger, repo: repo}
+}
+
+func (u *Users) Create(ctx context.Context, user domain.User) (domain.User, error) {
+	if err := normalizeUser(&user); err != nil {
+		u.logger.Warn("невалидный пользователь", zap.Error(err), zap.String("id", user.ID.String()))
+		return domain.User{}, err
+	}
+	created, err := u.repo.Create(ctx, user)
+	if err != nil {
+		return domain.User{}, err
+	}
+	u.logger.Info("пользователь создан", zap.String("id", created.ID.String()))
+	return created, nil
+}
+
+func (u *Users) Get(ctx context.Context, id uuid.UUID) (domain.User, error) {
+	return u.repo.Lookup(ctx, id)
+}
+
+func (u *Users) List(ctx context.Context, after *uuid.UUID, limit int) (domain.UserPage, error) {
+	// размер страницы - как у листинга подписок
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	from := uuid.Nil
+	if after != nil {
+		from = *after
+	}
+
+	// берём на одну строку больше, чтобы понять, есть ли следующая страница
+	items, err := u.repo.List(ctx, from, limit+1)
+	if err != nil {
+		return domain.UserPage{}, err
+	}
+	page := domain.UserPage{Items: items}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		last := page.Items[limit-1].ID
+		page.NextCursor = &last
+	}
+	return page, nil
+}
+
+func (u *Users) Update(ctx context.Context, user domain.User) (domain.User, error) {
+	if err := normalizeUser(&user); err != nil {
+		u.logger.Warn("невалидный пользователь", zap.Error(err), zap.String("id", user.ID.String()))
+		return domain.User{}, err
+	}
+	return u.repo.Update(ctx, user)
+}
+
+func (u *Users) Delete(ctx context.Context, id uuid.UUID) error {
+	if err := u.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	u.logger.Info("пользователь удалён", zap.String("id", id.String()))
+	return nil
+}
+
+// normalizeUser убирает лишние пробелы и проверяет email. Пустой email - то же, что его отсутствие
+func normalizeUser(user *domain.User) error {
+	if user.ID == uuid.Nil {
+		return errors.ErrInvalidUser.WithField("id")
+	}
+	user.Name = strings.Join(strings.Fields(user.Name), " ")
+	if user.Email == nil {
+		return nil
+	}
+	email := strings.TrimSpace(*user.Email)
+	if email == "" {
+		user.Email = nil
+		return nil
+	}
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return errors.Wrap(errors.ErrInvalidUser.WithField("email"), fmt.Errorf("%q не похож на email", email))
+	}
+	user.Email = &email
+	return nil
+}
+
+// checkUser - подписку можно завести только на пользователя из справочника. Без справочника проверки нет
+func (s *SubscriptionService) checkUser(ctx context.Context, id uuid.UUID) error {
+	if s.users == nil {
+		return nil
+	}
+	_, err := s.users.Lookup(ctx, id)
+	if errors.Is(err, errors.ErrUserNotFound) {
+		return errors.ErrInvalidUserID
+	}
+	return err
+}
+
+// userChecker - checkUser для пакетов и импорта: в файле обычно много строк одного пользователя,
+// поэтому уже проверенные id запоминаются на время операции
+func (s *SubscriptionService) userChecker() func(ctx context.Context, id uuid.UUID) error {
+	seen := make(map[uuid.UUID]error)
+	return func(ctx context.Context, id uuid.UUID) error {
+		if err, ok := seen[id]; ok {
+			return err
+		}
+		err := s.checkUser(ctx, id)
+		if err == nil || errors.Is(err, errors.ErrInvalidUserID) {
+			seen[id] = err
+		}
+		return err
+	}
+}
+
+// проверка типов: таблица users годится и как справочник, и как локальная копия внешнего
+var _ users.Store = (*repository.UserRepo)(nil)
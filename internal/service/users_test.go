@@ -0,0 +1,193 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"sort"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"testovoe_again/internal/repository"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func TestNormalizeUser(t *testing.T) {
+	email := func(s string) *string { return &s }
+	cases := []struct {
+		name      string
+		user      domain.User
+		wantName  string
+		wantEmail *string
+		field     string
+	}{
+		{name: "пробелы в имени", user: domain.User{ID: uuid.New(), Name: "  Иван   Иванов "}, wantName: "Иван Иванов"},
+		{name: "email без пробелов", user: domain.User{ID: uuid.New(), Email: email(" user@example.com ")}, wantEmail: email("user@example.com")},
+		{name: "пустой email - без email", user: domain.User{ID: uuid.New(), Email: email("  ")}},
+		{name: "без id", user: domain.User{Name: "Иван"}, field: "id"},
+		{name: "не email", user: domain.User{ID: uuid.New(), Email: email("user.example.com")}, field: "email"},
+		{name: "email с именем", user: domain.User{ID: uuid.New(), Email: email("Иван <user@example.com>")}, field: "email"},
+	}
+	for _, c := range cases {
+		user := c.user
+		err := normalizeUser(&user)
+		if c.field != "" {
+			var typed *errors.Error
+			if !errors.Is(err, errors.ErrInvalidUser) || !errors.As(err, &typed) || typed.Field != c.field {
+				t.Errorf("%s: ошибка %v, ожидалась ErrInvalidUser в поле %s", c.name, err, c.field)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if user.Name != c.wantName || (user.Email == nil) != (c.wantEmail == nil) || (user.Email != nil && *user.Email != *c.wantEmail) {
+			t.Errorf("%s: %q %v, ожидалось %q %v", c.name, user.Name, user.Email, c.wantName, c.wantEmail)
+		}
+	}
+}
+
+// memUserRepo - справочник по возрастанию id, как в таблице users
+type memUserRepo struct {
+	repository.UserRepository
+	users  []domain.User
+	limits []int
+}
+
+func (r *memUserRepo) List(_ context.Context, after uuid.UUID, limit int) ([]domain.User, error) {
+	r.limits = append(r.limits, limit)
+	var page []domain.User
+	for _, u := range r.users {
+		if bytes.Compare(u.ID[:], after[:]) > 0 && len(page) < limit {
+			page = append(page, u)
+		}
+	}
+	return page, nil
+}
+
+func TestUsersList(t *testing.T) {
+	repo := &memUserRepo{}
+	for i := 0; i < 5; i++ {
+		repo.users = append(repo.users, domain.User{ID: uuid.New()})
+	}
+	sort.Slice(repo.users, func(i, j int) bool { return bytes.Compare(repo.users[i].ID[:], repo.users[j].ID[:]) < 0 })
+	svc := NewUsers(zap.NewNop(), repo)
+
+	var (
+		after *uuid.UUID
+		seen  int
+		pages int
+	)
+	for {
+		page, err := svc.List(context.Background(), after, 2)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		pages++
+		for _, u := range page.Items {
+			if u.ID != repo.users[seen].ID {
+				t.Fatalf("страница %d: пользователь %s не по порядку", pages, u.ID)
+			}
+			seen++
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		after = page.NextCursor
+	}
+	if seen != 5 || pages != 3 {
+		t.Errorf("%d пользователей на %d страницах, ожидалось 5 на 3", seen, pages)
+	}
+
+	cases := []struct {
+		limit int
+		want  int
+	}{
+		{limit: 0, want: DefaultPageSize + 1},
+		{limit: -1, want: DefaultPageSize + 1},
+		{limit: MaxPageSize + 1, want: MaxPageSize + 1},
+	}
+	for _, c := range cases {
+		repo.limits = nil
+		if _, err := svc.List(context.Background(), nil, c.limit); err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if repo.limits[0] != c.want {
+			t.Errorf("limit %d: из базы запрошено %d строк, ожидалось %d", c.limit, repo.limits[0], c.want)
+		}
+	}
+}
+
+// countingDirectory - справочник с заданными пользователями, считает обращения
+type countingDirectory struct {
+	known   map[uuid.UUID]bool
+	err     error
+	lookups int
+}
+
+func (d *countingDirectory) Lookup(_ context.Context, id uuid.UUID) (domain.User, error) {
+	d.lookups++
+	if d.err != nil {
+		return domain.User{}, d.err
+	}
+	if !d.known[id] {
+		return domain.User{}, errors.ErrUserNotFound
+	}
+	return domain.User{ID: id}, nil
+}
+
+func TestCheckUser(t *testing.T) {
+	known, unknown := uuid.New(), uuid.New()
+	outage := stderrors.New("identity недоступен")
+	cases := []struct {
+		name    string
+		dir     *countingDirectory
+		id      uuid.UUID
+		wantErr error
+	}{
+		{name: "без справочника", id: unknown},
+		{name: "есть в справочнике", dir: &countingDirectory{known: map[uuid.UUID]bool{known: true}}, id: known},
+		{name: "нет в справочнике", dir: &countingDirectory{}, id: unknown, wantErr: errors.ErrInvalidUserID},
+		{name: "справочник недоступен", dir: &countingDirectory{err: outage}, id: known, wantErr: outage},
+	}
+	for _, c := range cases {
+		svc := NewSubscriptionService(zap.NewNop(), nil, nil, nil, domain.OverlapReject, nil, nil)
+		if c.dir != nil {
+			svc = NewSubscriptionService(zap.NewNop(), nil, nil, nil, domain.OverlapReject, nil, c.dir)
+		}
+		err := svc.checkUser(context.Background(), c.id)
+		if c.wantErr == nil && err != nil || c.wantErr != nil && !stderrors.Is(err, c.wantErr) {
+			t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, c.wantErr)
+		}
+	}
+}
+
+func TestUserChecker(t *testing.T) {
+	known, unknown := uuid.New(), uuid.New()
+	dir := &countingDirectory{known: map[uuid.UUID]bool{known: true}}
+	check := NewSubscriptionService(zap.NewNop(), nil, nil, nil, domain.OverlapReject, nil, dir).userChecker()
+
+	for i := 0; i < 3; i++ {
+		if err := check(context.Background(), known); err != nil {
+			t.Fatalf("известный пользователь: %v", err)
+		}
+		if err := check(context.Background(), unknown); !errors.Is(err, errors.ErrInvalidUserID) {
+			t.Fatalf("неизвестный пользователь: %v", err)
+		}
+	}
+	if dir.lookups != 2 {
+		t.Errorf("%d обращений к справочнику на 2 разных пользователя", dir.lookups)
+	}
+
+	// сбой справочника не запоминается
+	dir.err, dir.lookups = stderrors.New("identity недоступен"), 0
+	check = NewSubscriptionService(zap.NewNop(), nil, nil, nil, domain.OverlapReject, nil, dir).userChecker()
+	_ = check(context.Background(), known)
+	_ = check(context.Background(), known)
+	if dir.lookups != 2 {
+		t.Errorf("сбой справочника запомнился: %d обращений вместо 2", dir.lookups)
+	}
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"testovoe_again/internal/repository"
+	"testovoe_again/internal/webhook"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// WebhookService - вебхуки на события подписок и их dead-letter список
+type WebhookService interface {
+	// Create заводит вебхук, пустой Secret генерируется. Секрет возвращается только здесь
+	Create(ctx context.Context, hook domain.Webhook) (domain.Webhook, error)
+	Get(ctx context.Context, id int) (domain.Webhook, error)
+	List(ctx context.Context) ([]domain.Webhook, error)
+	// Update перезаписывает вебхук hook.ID, пустой Secret - секрет не меняется
+	Update(ctx context.Context, hook domain.Webhook) (domain.Webhook, error)
+	Delete(ctx context.Context, id int) error
+
+	// DeadLetters - доставки, исчерпавшие попытки, от новых к старым. before - NextCursor предыдущей страницы
+	DeadLetters(ctx context.Context, webhookID *int, before *int64, limit int) (domain.DeliveryPage, error)
+	// Replay возвращает доставку из dead-letter в очередь, ErrDeliveryNotDead для остальных
+	Replay(ctx context.Context, id int64) (domain.WebhookDelivery, error)
+}
+
+// WebhookRules - расписание доставки. Попытка, не уложившаяся в Timeout, неудачна; после MaxAttempts неудачных
+// попыток доставка уходит в dead-letter, между попытками - Backoff от BackoffBase с удвоением до BackoffMax
+type WebhookRules struct {
+	MaxAttempts int
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	Timeout     time.Duration
+	BatchSize   int
+}
+
+type Webhooks struct {
+	logger *zap.Logger
+	repo   repository.WebhookRepository
+	sender webhook.Sender
+	rules  WebhookRules
+}
+
+func NewWebhooks(logger *zap.Logger, repo repository.WebhookRepository, sender webhook.Sender, rules WebhookRules) *Webhooks {
+	return &Webhooks{logger: logger, repo: repo, sender: sender, rules: rules}
+}
+
+func (w *Webhooks) Create(ctx context.Context, hook domain.Webhook) (domain.Webhook, error) {
+	if err := validateWebhook(&hook); err != nil {
+		w.logger.Warn("невалидный вебхук", zap.Error(err), zap.String("url", hook.URL))
+		return domain.Webhook{}, err
+	}
+	if hook.Secret == "" {
+		secret, err := webhook.NewSecret()
+		if err != nil {
+			w.logger.Error("не удалось сгенерировать секрет вебхука", zap.Error(err))
+			return domain.Webhook{}, err
+		}
+		hook.Secret = secret
+	}
+	created, err := w.repo.Create(ctx, hook)
+	if err != nil {
+		return domain.Webhook{}, err
+	}
+	w.logger.Info("вебхук создан", zap.Int("id", created.ID), zap.String("url", created.URL))
+	return created, nil
+}
+
+func (w *Webhooks) Get(ctx context.Context, id int) (domain.Webhook, error) {
+	hook, err := w.repo.Get(ctx, id)
+	if err != nil {
+		return domain.Webhook{}, err
+	}
+	hook.Secret = ""
+	return hook, nil
+}
+
+func (w *Webhooks) List(ctx context.Context) ([]domain.Webhook, error) {
+	hooks, err := w.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	return hooks, nil
+}
+
+func (w *Webhooks) Update(ctx context.Context, hook domain.Webhook) (domain.Webhook, error) {
+	if err := validateWebhook(&hook); err != nil {
+		w.logger.Warn("невалидный вебхук", zap.Error(err), zap.Int("id", hook.ID))
+		return domain.Webhook{}, err
+	}
+	updated, err := w.repo.Update(ctx, hook)
+	if err != nil {
+		return domain.Webhook{}, err
+	}
+	updated.Secret = ""
+	return updated, nil
+}
+
+func (w *Webhooks) Delete(ctx context.Context, id int) error {
+	if err := w.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	w.logger.Info("вебхук удалён", zap.Int("id", id))
+	return nil
+}
+
+func (w *Webhooks) DeadLetters(ctx context.Context, webhookID *int, before *int64, limit int) (domain.DeliveryPage, error) {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	var from int64
+	if before != nil {
+		from = *before
+	}
+
+	items, err := w.repo.DeadLetters(ctx, webhookID, from, limit+1)
+	if err != nil {
+		return domain.DeliveryPage{}, err
+	}
+	page := domain.DeliveryPage{Items: items}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		last := page.Items[limit-1].ID
+		page.NextCursor = &last
+	}
+	return page, nil
+}
+
+func (w *Webhooks) Replay(ctx context.Context, id int64) (domain.WebhookDelivery, error) {
+	delivery, err := w.repo.Replay(ctx, id)
+	if err != nil {
+		return domain.WebhookDelivery{}, err
+	}
+	w.logger.Info("доставка возвращена в очередь", zap.Int64("id", id), zap.Int("webhook_id", delivery.WebhookID))
+	return delivery, nil
+}
+
+// Publish - вебхуки как публикатор outbox: событие ставится в очередь каждому подписанному вебхуку.
+// Повтор того же события ничего не добавляет, доставка на вебхук уникальна по event_id
+func (w *Webhooks) Publish(ctx context.Context, event domain.OutboxEvent) error {
+	if _, err := w.repo.Enqueue(ctx, event); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Deliver - проход воркера доставки: пакетами по BatchSize отправляет всё, чей срок подошёл, пока пакет не окажется неполным
+func (w *Webhooks) Deliver(ctx context.Context) (domain.DeliveryStats, error) {
+	var total domain.DeliveryStats
+	for {
+		// доставка откладывается на время попытки с запасом, чтобы её не взял соседний воркер
+		batch, err := w.repo.ClaimDue(ctx, w.rules.BatchSize, 2*w.rules.Timeout)
+		if err != nil {
+			return total, err
+		}
+		for _, delivery := range batch {
+			if err := w.deliverOne(ctx, delivery, &total); err != nil {
+				return total, err
+			}
+		}
+		if len(batch) < w.rules.BatchSize {
+			break
+		}
+	}
+	if total.Delivered > 0 || total.Retried > 0 || total.Dead > 0 {
+		w.logger.Info("доставлены события вебхуков", zap.Int("delivered", total.Delivered),
+			zap.Int("retried", total.Retried), zap.Int("dead", total.Dead))
+	}
+	return total, nil
+}
+
+func (w *Webhooks) deliverOne(ctx context.Context, delivery domain.WebhookDelivery, stats *domain.DeliveryStats) error {
+	status, sendErr := w.sender.Send(ctx, delivery)
+	if sendErr == nil {
+		stats.Delivered++
+		return w.repo.MarkDelivered(ctx, delivery.ID, status)
+	}
+	// воркер останавливается - попытка не считается провалом получателя, доставка вернётся после lease
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	var code *int
+	if status != 0 {
+		code = &status
+	}
+	var retryAt *time.Time
+	if delivery.Attempts < w.rules.MaxAttempts {
+		at := time.Now().Add(webhook.Backoff(delivery.Attempts, w.rules.BackoffBase, w.rules.BackoffMax))
+		retryAt = &at
+		stats.Retried++
+	} else {
+		stats.Dead++
+		w.logger.Warn("доставка ушла в dead-letter", zap.Int64("id", delivery.ID), zap.Int("webhook_id", delivery.WebhookID),
+			zap.Int("attempts", delivery.Attempts), zap.Error(sendErr))
+	}
+	return w.repo.MarkFailed(ctx, delivery.ID, code, sendErr.Error(), retryAt)
+}
+
+// validateWebhook - абсолютный http(s) адрес и известные события, повторы событий убираются
+func validateWebhook(hook *domain.Webhook) error {
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.Wrap(errors.ErrInvalidWebhook.WithField("url"), fmt.Errorf("%q не http(s) адрес", hook.URL))
+	}
+	seen := make(map[string]bool, len(hook.Events))
+	events := make([]string, 0, len(hook.Events))
+	for _, event := range hook.Events {
+		if !domain.IsEvent(event) {
+			return errors.Wrap(errors.ErrInvalidWebhook.WithField("events"), fmt.Errorf("неизвестное событие %q", event))
+		}
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+	hook.Events = events
+	return nil
+}
@@ -0,0 +1,357 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+	"testovoe_again/internal/webhook"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// memWebhookRepo - очередь доставок в памяти с той же семантикой, что у WebhookRepo
+type memWebhookRepo struct {
+	mu         sync.Mutex
+	hooks      map[int]domain.Webhook
+	deliveries map[int64]*domain.WebhookDelivery
+	nextID     int64
+}
+
+func newMemWebhookRepo(hooks ...domain.Webhook) *memWebhookRepo {
+	r := &memWebhookRepo{hooks: make(map[int]domain.Webhook), deliveries: make(map[int64]*domain.WebhookDelivery)}
+	for _, h := range hooks {
+		r.hooks[h.ID] = h
+	}
+	return r
+}
+
+func (r *memWebhookRepo) Create(ctx context.Context, hook domain.Webhook) (domain.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hook.ID = len(r.hooks) + 1
+	r.hooks[hook.ID] = hook
+	return hook, nil
+}
+
+func (r *memWebhookRepo) Get(ctx context.Context, id int) (domain.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hook, ok := r.hooks[id]
+	if !ok {
+		return domain.Webhook{}, errors.ErrWebhookNotFound
+	}
+	return hook, nil
+}
+
+func (r *memWebhookRepo) List(ctx context.Context) ([]domain.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := make([]domain.Webhook, 0, len(r.hooks))
+	for _, h := range r.hooks {
+		result = append(result, h)
+	}
+	return result, nil
+}
+
+func (r *memWebhookRepo) Update(ctx context.Context, hook domain.Webhook) (domain.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.hooks[hook.ID]
+	if !ok {
+		return domain.Webhook{}, errors.ErrWebhookNotFound
+	}
+	if hook.Secret == "" {
+		hook.Secret = current.Secret
+	}
+	r.hooks[hook.ID] = hook
+	return hook, nil
+}
+
+func (r *memWebhookRepo) Delete(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.hooks[id]; !ok {
+		return errors.ErrWebhookNotFound
+	}
+	delete(r.hooks, id)
+	return nil
+}
+
+func (r *memWebhookRepo) Enqueue(ctx context.Context, event domain.OutboxEvent) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var added int64
+	for _, h := range r.hooks {
+		if !h.Active || !h.Accepts(event.Type) || r.queued(h.ID, event.EventID) {
+			continue
+		}
+		r.nextID++
+		r.deliveries[r.nextID] = &domain.WebhookDelivery{
+			ID: r.nextID, WebhookID: h.ID, EventID: event.EventID, Event: event.Type, Payload: event.Payload,
+			Status: domain.DeliveryPending, NextAttemptAt: time.Now(), CreatedAt: time.Now(),
+		}
+		added++
+	}
+	return added, nil
+}
+
+func (r *memWebhookRepo) queued(webhookID int, eventID uuid.UUID) bool {
+	for _, d := range r.deliveries {
+		if d.WebhookID == webhookID && d.EventID == eventID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memWebhookRepo) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	var result []domain.WebhookDelivery
+	for id := int64(1); id <= r.nextID && len(result) < limit; id++ {
+		d, ok := r.deliveries[id]
+		if !ok || d.Status != domain.DeliveryPending || d.NextAttemptAt.After(now) || !r.hooks[d.WebhookID].Active {
+			continue
+		}
+		d.Attempts++
+		d.NextAttemptAt = now.Add(lease)
+		claimed := *d
+		claimed.URL, claimed.Secret = r.hooks[d.WebhookID].URL, r.hooks[d.WebhookID].Secret
+		result = append(result, claimed)
+	}
+	return result, nil
+}
+
+func (r *memWebhookRepo) MarkDelivered(ctx context.Context, id int64, status int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d := r.deliveries[id]
+	now := time.Now()
+	d.Status, d.LastStatus, d.LastError, d.DeliveredAt = domain.DeliveryDelivered, &status, nil, &now
+	return nil
+}
+
+func (r *memWebhookRepo) MarkFailed(ctx context.Context, id int64, status *int, reason string, retryAt *time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d := r.deliveries[id]
+	d.LastStatus, d.LastError = status, &reason
+	if retryAt == nil {
+		d.Status = domain.DeliveryDead
+		return nil
+	}
+	d.NextAttemptAt = *retryAt
+	return nil
+}
+
+func (r *memWebhookRepo) DeadLetters(ctx context.Context, webhookID *int, before int64, limit int) ([]domain.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := make([]domain.WebhookDelivery, 0)
+	for id := r.nextID; id > 0 && len(result) < limit; id-- {
+		d, ok := r.deliveries[id]
+		if !ok || d.Status != domain.DeliveryDead || (before != 0 && id >= before) {
+			continue
+		}
+		if webhookID != nil && d.WebhookID != *webhookID {
+			continue
+		}
+		result = append(result, *d)
+	}
+	return result, nil
+}
+
+func (r *memWebhookRepo) Replay(ctx context.Context, id int64) (domain.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.deliveries[id]
+	if !ok {
+		return domain.WebhookDelivery{}, errors.ErrDeliveryNotFound
+	}
+	if d.Status != domain.DeliveryDead {
+		return domain.WebhookDelivery{}, errors.ErrDeliveryNotDead
+	}
+	d.Status, d.Attempts, d.NextAttemptAt = domain.DeliveryPending, 0, time.Now()
+	return *d, nil
+}
+
+func (r *memWebhookRepo) delivery(id int64) domain.WebhookDelivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.deliveries[id]
+}
+
+// receiver - получатель вебхуков: первые fail запросов отвечают 503, дальше 200. Подпись каждого запроса проверяется
+type receiver struct {
+	t      *testing.T
+	secret string
+	fail   atomic.Int32
+	calls  atomic.Int32
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.calls.Add(1)
+	body, _ := io.ReadAll(r.Body)
+	ts, _ := strconv.ParseInt(r.Header.Get(webhook.HeaderTimestamp), 10, 64)
+	if !webhook.Verify(rc.secret, ts, body, r.Header.Get(webhook.HeaderSignature)) {
+		rc.t.Errorf("запрос с невалидной подписью")
+	}
+	if rc.fail.Add(-1) >= 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func newTestWebhooks(t *testing.T, failures int32, maxAttempts int) (*Webhooks, *memWebhookRepo, *receiver) {
+	rc := &receiver{t: t, secret: "whsec_test"}
+	rc.fail.Store(failures)
+	srv := httptest.NewServer(rc)
+	t.Cleanup(srv.Close)
+
+	repo := newMemWebhookRepo(domain.Webhook{ID: 1, URL: srv.URL, Secret: rc.secret, Active: true})
+	rules := WebhookRules{
+		MaxAttempts: maxAttempts,
+		BackoffBase: time.Microsecond,
+		BackoffMax:  time.Millisecond,
+		Timeout:     time.Second,
+		BatchSize:   10,
+	}
+	return NewWebhooks(zap.NewNop(), repo, webhook.NewHTTPSender(rules.Timeout, true), rules), repo, rc
+}
+
+func publishTestEvent(t *testing.T, w *Webhooks) {
+	t.Helper()
+	event := domain.OutboxEvent{EventID: uuid.New(), Type: domain.EventSubscriptionCreated, Payload: []byte(`{"subscription":{"id":1}}`)}
+	if err := w.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	// повтор события из outbox не ставит вторую доставку
+	if err := w.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+}
+
+// deliverUntil гоняет проходы доставки, пока не отработает n попыток: повтор ставится через Backoff,
+// поэтому между проходами ждём, пока срок подойдёт
+func deliverUntil(t *testing.T, w *Webhooks, rc *receiver, n int32) domain.DeliveryStats {
+	t.Helper()
+	var total domain.DeliveryStats
+	deadline := time.Now().Add(5 * time.Second)
+	for rc.calls.Load() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("за 5с отработало %d попыток из %d", rc.calls.Load(), n)
+		}
+		stats, err := w.Deliver(context.Background())
+		if err != nil {
+			t.Fatalf("Deliver: %v", err)
+		}
+		total.Delivered += stats.Delivered
+		total.Retried += stats.Retried
+		total.Dead += stats.Dead
+		time.Sleep(2 * time.Millisecond)
+	}
+	return total
+}
+
+func TestWebhooksRetryThenDeliver(t *testing.T) {
+	w, repo, rc := newTestWebhooks(t, 2, 5)
+	publishTestEvent(t, w)
+
+	stats := deliverUntil(t, w, rc, 3)
+	if stats.Retried != 2 || stats.Delivered != 1 || stats.Dead != 0 {
+		t.Errorf("stats = %+v, ожидалось 2 повтора и 1 доставка", stats)
+	}
+	d := repo.delivery(1)
+	if d.Status != domain.DeliveryDelivered || d.Attempts != 3 {
+		t.Errorf("доставка %s после %d попыток, ожидалось delivered после 3", d.Status, d.Attempts)
+	}
+	if _, ok := repo.deliveries[2]; ok {
+		t.Error("повтор события поставил вторую доставку")
+	}
+}
+
+func TestWebhooksDeadLetterAndReplay(t *testing.T) {
+	const maxAttempts = 3
+	w, repo, rc := newTestWebhooks(t, maxAttempts, maxAttempts)
+	publishTestEvent(t, w)
+
+	stats := deliverUntil(t, w, rc, maxAttempts)
+	if stats.Retried != maxAttempts-1 || stats.Dead != 1 || stats.Delivered != 0 {
+		t.Errorf("stats = %+v, ожидалось %d повтора и dead-letter", stats, maxAttempts-1)
+	}
+	d := repo.delivery(1)
+	if d.Status != domain.DeliveryDead || d.LastStatus == nil || *d.LastStatus != http.StatusServiceUnavailable {
+		t.Fatalf("доставка %s, last_status %v, ожидалось dead с 503", d.Status, d.LastStatus)
+	}
+
+	// из dead-letter доставка больше не отправляется
+	time.Sleep(2 * time.Millisecond)
+	if _, err := w.Deliver(context.Background()); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if calls := rc.calls.Load(); calls != maxAttempts {
+		t.Errorf("после dead-letter было %d запросов, ожидалось %d", calls, maxAttempts)
+	}
+
+	page, err := w.DeadLetters(context.Background(), nil, nil, 0)
+	if err != nil {
+		t.Fatalf("DeadLetters: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].ID != 1 {
+		t.Fatalf("dead-letter список %+v, ожидалась доставка 1", page.Items)
+	}
+
+	replayed, err := w.Replay(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if replayed.Status != domain.DeliveryPending || replayed.Attempts != 0 {
+		t.Errorf("после Replay доставка %s с %d попытками, ожидалось pending с 0", replayed.Status, replayed.Attempts)
+	}
+	if _, err := w.Replay(context.Background(), 1); !errors.Is(err, errors.ErrDeliveryNotDead) {
+		t.Errorf("повторный Replay: %v, ожидалось ErrDeliveryNotDead", err)
+	}
+	if _, err := w.Replay(context.Background(), 99); !errors.Is(err, errors.ErrDeliveryNotFound) {
+		t.Errorf("Replay несуществующей доставки: %v, ожидалось ErrDeliveryNotFound", err)
+	}
+
+	// получатель поднялся - возвращённая доставка уходит
+	stats = deliverUntil(t, w, rc, maxAttempts+1)
+	if stats.Delivered != 1 {
+		t.Errorf("stats после Replay = %+v, ожидалась доставка", stats)
+	}
+	if d := repo.delivery(1); d.Status != domain.DeliveryDelivered || d.Attempts != 1 {
+		t.Errorf("доставка %s после %d попыток, ожидалось delivered после 1", d.Status, d.Attempts)
+	}
+}
+
+func TestValidateWebhook(t *testing.T) {
+	hook := domain.Webhook{URL: "https://example.com/hook", Events: []string{domain.EventSubscriptionCreated, domain.EventSubscriptionCreated}}
+	if err := validateWebhook(&hook); err != nil {
+		t.Fatalf("validateWebhook: %v", err)
+	}
+	if len(hook.Events) != 1 {
+		t.Errorf("повтор события не убран: %v", hook.Events)
+	}
+
+	for _, bad := range []domain.Webhook{
+		{URL: "ftp://example.com/hook"},
+		{URL: "/hook"},
+		{URL: "https://example.com/hook", Events: []string{"subscription.unknown"}},
+	} {
+		if err := validateWebhook(&bad); !errors.Is(err, errors.ErrInvalidWebhook) {
+			t.Errorf("%+v: %v, ожидалось ErrInvalidWebhook", bad, err)
+		}
+	}
+}
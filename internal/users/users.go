@@ -0,0 +1,89 @@
+// Пакет users - справочник пользователей, по которому проверяется user_id подписок: интерфейс справочника,
+// заглушка внешнего identity-сервиса поверх json-файла и зеркалирование внешнего справочника в локальную таблицу.
+// Реализация поверх таблицы users лежит в repository, т.к. ходит в базу
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"github.com/google/uuid"
+)
+
+// Directory отвечает, существует ли пользователь. Если нет - ErrUserNotFound
+type Directory interface {
+	Lookup(ctx context.Context, id uuid.UUID) (domain.User, error)
+}
+
+// StubDirectory - локальная заглушка identity-сервиса: пользователи из json-файла вида
+//
+//	{"users": [{"id": "60601fee-2bf1-4721-ae6f-7636e79a0cba", "email": "user@example.com", "name": "Иван"}]}
+type StubDirectory struct {
+	users map[uuid.UUID]domain.User
+}
+
+type stubFile struct {
+	Users []domain.User `json:"users"`
+}
+
+func NewStubDirectory(path string) (*StubDirectory, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения файла пользователей: %w", err)
+	}
+
+	var f stubFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга файла пользователей: %w", err)
+	}
+
+	users := make(map[uuid.UUID]domain.User, len(f.Users))
+	for _, u := range f.Users {
+		if u.ID == uuid.Nil {
+			return nil, fmt.Errorf("в файле пользователей есть пользователь без id")
+		}
+		users[u.ID] = u
+	}
+	return &StubDirectory{users: users}, nil
+}
+
+func (d *StubDirectory) Lookup(_ context.Context, id uuid.UUID) (domain.User, error) {
+	u, ok := d.users[id]
+	if !ok {
+		return domain.User{}, errors.ErrUserNotFound
+	}
+	return u, nil
+}
+
+// Store - локальная таблица пользователей, куда Mirror дописывает найденных во внешнем справочнике
+type Store interface {
+	Directory
+	Upsert(ctx context.Context, user domain.User) error
+}
+
+// Mirror - внешний справочник с локальной копией. На подписки стоит внешний ключ на таблицу users,
+// поэтому пользователь, найденный во внешнем справочнике, заводится и локально. Локальная копия
+// не отвечает на Lookup сама: удалённый во внешнем справочнике пользователь новые подписки не получит
+type Mirror struct {
+	source Directory
+	store  Store
+}
+
+func NewMirror(source Directory, store Store) *Mirror {
+	return &Mirror{source: source, store: store}
+}
+
+func (m *Mirror) Lookup(ctx context.Context, id uuid.UUID) (domain.User, error) {
+	u, err := m.source.Lookup(ctx, id)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if err := m.store.Upsert(ctx, u); err != nil {
+		return domain.User{}, err
+	}
+	return u, nil
+}
@@ -0,0 +1,132 @@
+package users
+
+import (
+	"context"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testovoe_again/internal/domain"
+	"testovoe_again/internal/errors"
+
+	"github.com/google/uuid"
+)
+
+const knownUser = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+func writeUsers(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "users.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("запись файла пользователей: %v", err)
+	}
+	return path
+}
+
+func TestStubDirectory(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		lookup  string
+		wantErr bool
+		found   bool
+	}{
+		{name: "найден", content: `{"users": [{"id": "` + knownUser + `", "email": "user@example.com", "name": "Иван"}]}`,
+			lookup: knownUser, found: true},
+		{name: "не найден", content: `{"users": [{"id": "` + knownUser + `"}]}`, lookup: uuid.NewString()},
+		{name: "пустой справочник", content: `{"users": []}`, lookup: knownUser},
+		{name: "пользователь без id", content: `{"users": [{"email": "user@example.com"}]}`, wantErr: true},
+		{name: "невалидный id", content: `{"users": [{"id": "42"}]}`, wantErr: true},
+		{name: "не JSON", content: `users: []`, wantErr: true},
+	}
+	for _, c := range cases {
+		dir, err := NewStubDirectory(writeUsers(t, c.content))
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%s: справочник загружен, ожидалась ошибка", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		u, err := dir.Lookup(context.Background(), uuid.MustParse(c.lookup))
+		if c.found {
+			if err != nil || u.ID.String() != c.lookup {
+				t.Errorf("%s: %+v, %v", c.name, u, err)
+			}
+			continue
+		}
+		if !errors.Is(err, errors.ErrUserNotFound) {
+			t.Errorf("%s: ошибка %v, ожидалась ErrUserNotFound", c.name, err)
+		}
+	}
+
+	if _, err := NewStubDirectory(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("несуществующий файл прочитан без ошибки")
+	}
+}
+
+type mapDirectory map[uuid.UUID]domain.User
+
+func (d mapDirectory) Lookup(_ context.Context, id uuid.UUID) (domain.User, error) {
+	u, ok := d[id]
+	if !ok {
+		return domain.User{}, errors.ErrUserNotFound
+	}
+	return u, nil
+}
+
+// recordingStore - локальная копия, которая запоминает заведённых пользователей
+type recordingStore struct {
+	mapDirectory
+	err      error
+	upserted []uuid.UUID
+}
+
+func (s *recordingStore) Upsert(_ context.Context, user domain.User) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.upserted = append(s.upserted, user.ID)
+	return nil
+}
+
+func TestMirror(t *testing.T) {
+	known := uuid.MustParse(knownUser)
+	// пользователь есть только в локальной копии - во внешнем справочнике его уже удалили
+	removed := uuid.New()
+	source := mapDirectory{known: {ID: known, Name: "Иван"}}
+
+	cases := []struct {
+		name     string
+		id       uuid.UUID
+		storeErr error
+		wantErr  error
+		upserted int
+	}{
+		{name: "найден и заведён локально", id: known, upserted: 1},
+		{name: "удалён во внешнем справочнике", id: removed, wantErr: errors.ErrUserNotFound},
+		{name: "локальная копия недоступна", id: known, storeErr: stderrors.New("conn reset")},
+	}
+	for _, c := range cases {
+		store := &recordingStore{mapDirectory: mapDirectory{removed: {ID: removed}}, err: c.storeErr}
+		u, err := NewMirror(source, store).Lookup(context.Background(), c.id)
+
+		wantErr := c.wantErr
+		if c.storeErr != nil {
+			wantErr = c.storeErr
+		}
+		if wantErr != nil {
+			if !stderrors.Is(err, wantErr) {
+				t.Errorf("%s: ошибка %v, ожидалась %v", c.name, err, wantErr)
+			}
+		} else if err != nil || u.ID != c.id {
+			t.Errorf("%s: %+v, %v", c.name, u, err)
+		}
+		if len(store.upserted) != c.upserted {
+			t.Errorf("%s: заведено %d пользователей, ожидалось %d", c.name, len(store.upserted), c.upserted)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Пакет webhook - отправка событий на вебхуки: подпись тела HMAC-SHA256, HTTP-клиент и расписание повторов.
+// Очередь доставок лежит в repository, т.к. ходит в базу
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"testovoe_again/internal/domain"
+	"time"
+)
+
+// Заголовки запроса вебхука
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderEventID   = "X-Webhook-Id"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Sign - подпись тела: hex от HMAC-SHA256 на секрете вебхука по строке "<timestamp>.<body>".
+// Метка времени входит в подпись, чтобы перехваченный запрос нельзя было повторить позже
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify - проверка подписи на стороне получателя, сравнение за постоянное время
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// NewSecret - случайный секрет для вебхука, которому клиент не задал свой
+func NewSecret() (string, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(raw), nil
+}
+
+// Backoff - через сколько повторять доставку после attempt-й неудачной попытки: base, 2*base, 4*base... но не больше max
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
+
+// Sender отправляет одну доставку. status - HTTP-статус ответа, 0 если ответа не было.
+// Ошибка - доставка не удалась и её нужно повторить
+type Sender interface {
+	Send(ctx context.Context, delivery domain.WebhookDelivery) (status int, err error)
+}
+
+// ErrForbiddenAddress - адрес вебхука ведёт во внутреннюю сеть
+var ErrForbiddenAddress = errors.New("адрес вебхука во внутренней сети")
+
+// HTTPSender - Sender поверх net/http, успехом считается любой 2xx.
+// Адрес вебхука задаёт клиент, а запрос шлёт сервер, поэтому по умолчанию соединения с loopback, частными,
+// link-local и прочими не публичными адресами не устанавливаются: иначе вебхук стал бы окном во внутреннюю сеть
+// и к метаданным облака. Проверяется адрес после резолва, прямо в dialer, так что DNS-имя, смотрящее внутрь,
+// тоже не пройдёт. Редиректы не выполняются: 3xx - неудачная попытка
+type HTTPSender struct {
+	client *http.Client
+}
+
+// NewHTTPSender - allowPrivate снимает запрет на внутренние адреса, для локального запуска и тестов
+func NewHTTPSender(timeout time.Duration, allowPrivate bool) *HTTPSender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip, err := netip.ParseAddr(host)
+			if err != nil || !IsPublicAddr(ip) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+			}
+			return nil
+		}
+	}
+	transport := &http.Transport{
+		// прокси из окружения не используем: соединение с ним прошло бы мимо проверки адреса
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	return &HTTPSender{client: &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// IsPublicAddr - адрес из публичного интернета: не loopback, не частная сеть, не link-local
+// (169.254.0.0/16 - там метаданные облаков), не CGNAT, не multicast и не unspecified
+func IsPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// nonPublicPrefixes - не публичные сети, которых нет среди проверок netip
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+func (s *HTTPSender) Send(ctx context.Context, delivery domain.WebhookDelivery) (int, error) {
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderEventID, delivery.EventID.String())
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// тело ответа не нужно, но дочитываем его, чтобы соединение вернулось в пул
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("получатель ответил %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,217 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"testovoe_again/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"type":"subscription.created","subscription":{"id":1}}`)
+	sig := Sign("whsec_test", 1754049600, body)
+
+	if !strings.HasPrefix(sig, "sha256=") {
+		t.Fatalf("подпись без префикса sha256=: %q", sig)
+	}
+	if !Verify("whsec_test", 1754049600, body, sig) {
+		t.Fatal("подпись не проходит проверку тем же секретом")
+	}
+
+	tampered := []byte(strings.Replace(string(body), `"id":1`, `"id":2`, 1))
+	cases := map[string]bool{
+		"изменённое тело":      Verify("whsec_test", 1754049600, tampered, sig),
+		"другой секрет":        Verify("whsec_other", 1754049600, body, sig),
+		"другая метка времени": Verify("whsec_test", 1754049601, body, sig),
+		"подпись без префикса": Verify("whsec_test", 1754049600, body, strings.TrimPrefix(sig, "sha256=")),
+		"пустая подпись":       Verify("whsec_test", 1754049600, body, ""),
+	}
+	for name, ok := range cases {
+		if ok {
+			t.Errorf("%s: подпись прошла проверку", name)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base, max := time.Second, 30*time.Second
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second, 30 * time.Second}
+	for i, w := range want {
+		attempt := i + 1
+		if got := Backoff(attempt, base, max); got != w {
+			t.Errorf("Backoff(%d) = %s, ожидалось %s", attempt, got, w)
+		}
+	}
+	// на большом номере попытки удвоение не переполняется
+	if got := Backoff(1000, base, max); got != max {
+		t.Errorf("Backoff(1000) = %s, ожидалось %s", got, max)
+	}
+}
+
+func TestHTTPSender(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	delivery := testDelivery(srv.URL)
+	status, err := NewHTTPSender(time.Second, true).Send(context.Background(), delivery)
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if status != http.StatusNoContent {
+		t.Errorf("status = %d, ожидалось %d", status, http.StatusNoContent)
+	}
+
+	if got.Method != http.MethodPost {
+		t.Errorf("метод %s, ожидался POST", got.Method)
+	}
+	headers := map[string]string{
+		"Content-Type": "application/json",
+		HeaderEvent:    delivery.Event,
+		HeaderEventID:  delivery.EventID.String(),
+		HeaderDelivery: strconv.FormatInt(delivery.ID, 10),
+	}
+	for name, want := range headers {
+		if v := got.Header.Get(name); v != want {
+			t.Errorf("заголовок %s = %q, ожидалось %q", name, v, want)
+		}
+	}
+	if string(body) != string(delivery.Payload) {
+		t.Errorf("тело %s, ожидалось %s", body, delivery.Payload)
+	}
+
+	ts, err := strconv.ParseInt(got.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("невалидный %s: %v", HeaderTimestamp, err)
+	}
+	if !Verify(delivery.Secret, ts, body, got.Header.Get(HeaderSignature)) {
+		t.Error("подпись запроса не проходит проверку")
+	}
+}
+
+func TestHTTPSenderNon2xx(t *testing.T) {
+	for _, code := range []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError, http.StatusServiceUnavailable} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+		}))
+		status, err := NewHTTPSender(time.Second, true).Send(context.Background(), testDelivery(srv.URL))
+		srv.Close()
+
+		if err == nil {
+			t.Errorf("%d: ответ считается успешным", code)
+		}
+		if status != code {
+			t.Errorf("%d: status = %d", code, status)
+		}
+	}
+}
+
+func TestHTTPSenderTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	status, err := NewHTTPSender(50*time.Millisecond, true).Send(context.Background(), testDelivery(srv.URL))
+	if err == nil {
+		t.Fatal("ответ после таймаута считается успешным")
+	}
+	if status != 0 {
+		t.Errorf("status = %d, без ответа ожидался 0", status)
+	}
+}
+
+func TestHTTPSenderRefusesPrivateAddress(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	status, err := NewHTTPSender(time.Second, false).Send(context.Background(), testDelivery(srv.URL))
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("err = %v, ожидалась ErrForbiddenAddress", err)
+	}
+	if status != 0 || called {
+		t.Errorf("запрос на %s дошёл до сервера, status = %d", srv.URL, status)
+	}
+}
+
+func TestHTTPSenderNoRedirects(t *testing.T) {
+	redirected := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+	}))
+	defer target.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer srv.Close()
+
+	status, err := NewHTTPSender(time.Second, true).Send(context.Background(), testDelivery(srv.URL))
+	if err == nil {
+		t.Error("редирект считается успешной доставкой")
+	}
+	if status != http.StatusTemporaryRedirect {
+		t.Errorf("status = %d, ожидалось %d", status, http.StatusTemporaryRedirect)
+	}
+	if redirected {
+		t.Error("отправитель прошёл по редиректу")
+	}
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	cases := map[string]bool{
+		"8.8.8.8":              true,
+		"2a00:1450:4001::200e": true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"fe80::1":              false,
+		"fd00::1":              false,
+		"100.64.0.1":           false,
+		"0.0.0.0":              false,
+		"::":                   false,
+		"224.0.0.1":            false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:8.8.8.8":       true,
+	}
+	for addr, want := range cases {
+		if got := IsPublicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("IsPublicAddr(%s) = %v, ожидалось %v", addr, got, want)
+		}
+	}
+}
+
+func testDelivery(url string) domain.WebhookDelivery {
+	payload, _ := json.Marshal(map[string]any{"type": "subscription.created", "subscription": map[string]any{"id": 7}})
+	return domain.WebhookDelivery{
+		ID:        42,
+		WebhookID: 1,
+		EventID:   uuid.New(),
+		Event:     "subscription.created",
+		Payload:   payload,
+		URL:       url,
+		Secret:    "whsec_test",
+	}
+}
@@ -0,0 +1,38 @@
+package worker
+
+import (
+	"context"
+	"testovoe_again/internal/domain"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Expirer - то, что умеет заканчивать и автопродлевать подписки, реализуется service.SubscriptionService
+type Expirer interface {
+	ProcessExpiring(ctx context.Context, lead time.Duration, batchSize int) (domain.ExpiryStats, error)
+}
+
+// ExpiryWorker раз в interval переводит в expired подписки, у которых прошла end_date, и продлевает
+// подписки с auto_renew за lead до end_date. Подписки берутся под SKIP LOCKED, так что воркер
+// можно запускать на всех репликах сразу
+type ExpiryWorker struct {
+	logger    *zap.Logger
+	expirer   Expirer
+	interval  time.Duration
+	lead      time.Duration
+	batchSize int
+}
+
+func NewExpiryWorker(logger *zap.Logger, expirer Expirer, interval, lead time.Duration, batchSize int) *ExpiryWorker {
+	return &ExpiryWorker{logger: logger, expirer: expirer, interval: interval, lead: lead, batchSize: batchSize}
+}
+
+// Run блокируется до отмены ctx. В журнале изменений истечение и продление записываются от имени system
+func (w *ExpiryWorker) Run(ctx context.Context) {
+	ctx = domain.WithActor(ctx, domain.SystemActor)
+	RunPeriodic(ctx, w.logger, "expiry", w.interval, func(ctx context.Context) error {
+		_, err := w.expirer.ProcessExpiring(ctx, w.lead, w.batchSize)
+		return err
+	})
+}
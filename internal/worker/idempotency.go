@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const idempotencyPurgeInterval = time.Hour
+
+// IdempotencyCleaner - то, что умеет удалять протухшие ключи идемпотентности, реализуется repository.IdempotencyRepo
+type IdempotencyCleaner interface {
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+// IdempotencyPurger раз в час удаляет ключи идемпотентности с истёкшим TTL. Протухший ключ и без того
+// перезаписывается новым запросом, очистка нужна только чтобы таблица не росла
+type IdempotencyPurger struct {
+	logger  *zap.Logger
+	cleaner IdempotencyCleaner
+}
+
+func NewIdempotencyPurger(logger *zap.Logger, cleaner IdempotencyCleaner) *IdempotencyPurger {
+	return &IdempotencyPurger{logger: logger, cleaner: cleaner}
+}
+
+func (p *IdempotencyPurger) Run(ctx context.Context) {
+	RunPeriodic(ctx, p.logger, "idempotency-purger", idempotencyPurgeInterval, func(ctx context.Context) error {
+		_, err := p.cleaner.PurgeExpired(ctx)
+		return err
+	})
+}
@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Relayer - то, что умеет переносить outbox в публикатор, реализуется service.OutboxRelay
+type Relayer interface {
+	Relay(ctx context.Context) (int, error)
+}
+
+// OutboxWorker раз в interval публикует накопившиеся в outbox события. События берутся под SKIP LOCKED,
+// так что релей можно запускать на всех репликах сразу
+type OutboxWorker struct {
+	logger   *zap.Logger
+	relayer  Relayer
+	interval time.Duration
+}
+
+func NewOutboxWorker(logger *zap.Logger, relayer Relayer, interval time.Duration) *OutboxWorker {
+	return &OutboxWorker{logger: logger, relayer: relayer, interval: interval}
+}
+
+// Run блокируется до отмены ctx
+func (w *OutboxWorker) Run(ctx context.Context) {
+	RunPeriodic(ctx, w.logger, "outbox-relay", w.interval, func(ctx context.Context) error {
+		_, err := w.relayer.Relay(ctx)
+		return err
+	})
+}
@@ -0,0 +1,37 @@
+package worker
+
+import (
+	"context"
+	"testovoe_again/internal/ratelimit"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// rateLimitSweepInterval - бакетов немного и удаляются они одним запросом, чаще гонять незачем
+const rateLimitSweepInterval = 10 * time.Minute
+
+// RateLimitSweeper удаляет бакеты клиентов, которые не приходили дольше, чем наполняется самый медленный бакет:
+// такой бакет всё равно полон, и его пересоздание ничего не меняет
+type RateLimitSweeper struct {
+	logger *zap.Logger
+	store  ratelimit.Store
+	idle   time.Duration
+}
+
+func NewRateLimitSweeper(logger *zap.Logger, store ratelimit.Store, limits map[string]ratelimit.Limit) *RateLimitSweeper {
+	idle := time.Minute
+	for _, limit := range limits {
+		if fill := limit.FillTime(); fill > idle {
+			idle = fill
+		}
+	}
+	return &RateLimitSweeper{logger: logger, store: store, idle: idle}
+}
+
+func (s *RateLimitSweeper) Run(ctx context.Context) {
+	RunPeriodic(ctx, s.logger, "ratelimit-sweeper", rateLimitSweepInterval, func(ctx context.Context) error {
+		_, err := s.store.Sweep(ctx, s.idle)
+		return err
+	})
+}
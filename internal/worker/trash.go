@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"context"
+	"testovoe_again/internal/domain"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TrashCleaner - то, что умеет чистить корзину, реализуется service.SubscriptionService
+type TrashCleaner interface {
+	PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+// TrashPurger раз в interval насовсем удаляет подписки, пролежавшие в корзине дольше retention
+type TrashPurger struct {
+	logger    *zap.Logger
+	cleaner   TrashCleaner
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewTrashPurger(logger *zap.Logger, cleaner TrashCleaner, retention, interval time.Duration) *TrashPurger {
+	return &TrashPurger{logger: logger, cleaner: cleaner, retention: retention, interval: interval}
+}
+
+// Run блокируется до отмены ctx. В журнале изменений очистка записывается от имени system
+func (p *TrashPurger) Run(ctx context.Context) {
+	ctx = domain.WithActor(ctx, domain.SystemActor)
+	RunPeriodic(ctx, p.logger, "trash-purger", p.interval, func(ctx context.Context) error {
+		_, err := p.cleaner.PurgeDeleted(ctx, p.retention)
+		return err
+	})
+}
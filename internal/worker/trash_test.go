@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"testovoe_again/internal/domain"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type purgeCall struct {
+	retention time.Duration
+	actor     string
+}
+
+// recordingCleaner запоминает срок хранения и автора, с которыми его позвали
+type recordingCleaner struct {
+	calls chan purgeCall
+}
+
+func (c *recordingCleaner) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	c.calls <- purgeCall{retention, domain.ActorFromContext(ctx)}
+	return 0, nil
+}
+
+func TestTrashPurger(t *testing.T) {
+	cleaner := &recordingCleaner{calls: make(chan purgeCall, 10)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go NewTrashPurger(zap.NewNop(), cleaner, 72*time.Hour, time.Hour).Run(ctx)
+
+	// первый прогон идёт сразу, не дожидаясь интервала
+	select {
+	case call := <-cleaner.calls:
+		if call.retention != 72*time.Hour {
+			t.Errorf("срок хранения %v, ожидалось 72h", call.retention)
+		}
+		if call.actor != domain.SystemActor {
+			t.Errorf("очистка от имени %q, ожидалось %q", call.actor, domain.SystemActor)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("очистка не запустилась")
+	}
+}
@@ -0,0 +1,34 @@
+package worker
+
+import (
+	"context"
+	"testovoe_again/internal/domain"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// WebhookDeliverer - то, что умеет отправлять очередь вебхуков, реализуется service.Webhooks
+type WebhookDeliverer interface {
+	Deliver(ctx context.Context) (domain.DeliveryStats, error)
+}
+
+// WebhookWorker раз в interval отправляет доставки вебхуков, чей срок подошёл. Доставки берутся под SKIP LOCKED,
+// так что воркер можно запускать на всех репликах сразу
+type WebhookWorker struct {
+	logger    *zap.Logger
+	deliverer WebhookDeliverer
+	interval  time.Duration
+}
+
+func NewWebhookWorker(logger *zap.Logger, deliverer WebhookDeliverer, interval time.Duration) *WebhookWorker {
+	return &WebhookWorker{logger: logger, deliverer: deliverer, interval: interval}
+}
+
+// Run блокируется до отмены ctx
+func (w *WebhookWorker) Run(ctx context.Context) {
+	RunPeriodic(ctx, w.logger, "webhooks", w.interval, func(ctx context.Context) error {
+		_, err := w.deliverer.Deliver(ctx)
+		return err
+	})
+}
@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"testovoe_again/internal/domain"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// countingDeliverer считает проходы, первые fail из них падают
+type countingDeliverer struct {
+	calls atomic.Int32
+	fail  int32
+}
+
+func (d *countingDeliverer) Deliver(ctx context.Context) (domain.DeliveryStats, error) {
+	if d.calls.Add(1) <= d.fail {
+		return domain.DeliveryStats{}, fmt.Errorf("база недоступна")
+	}
+	return domain.DeliveryStats{Delivered: 1}, nil
+}
+
+func TestWebhookWorker(t *testing.T) {
+	deliverer := &countingDeliverer{fail: 2}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewWebhookWorker(zap.NewNop(), deliverer, time.Millisecond).Run(ctx)
+		close(done)
+	}()
+
+	// ошибка прохода не останавливает воркер, следующий тик пробует снова
+	deadline := time.Now().Add(5 * time.Second)
+	for deliverer.calls.Load() < 5 {
+		if time.Now().After(deadline) {
+			t.Fatalf("за 5с воркер сделал %d проходов", deliverer.calls.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("воркер не остановился после отмены контекста")
+	}
+}
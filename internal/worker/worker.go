@@ -0,0 +1,30 @@
+// Фоновые задачи, которые крутятся рядом с HTTP-сервером
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RunPeriodic вызывает fn сразу и затем раз в interval, пока не отменён ctx.
+// Ошибка одного прогона только логируется - следующий тик попробует снова
+func RunPeriodic(ctx context.Context, logger *zap.Logger, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	logger.Info("фоновая задача запущена", zap.String("worker", name), zap.Duration("interval", interval))
+	for {
+		if err := fn(ctx); err != nil && ctx.Err() == nil {
+			logger.Error("ошибка фоновой задачи", zap.String("worker", name), zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info("фоновая задача остановлена", zap.String("worker", name))
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_subscriptions_service_name_id;
+DROP INDEX IF EXISTS idx_subscriptions_user_price_id;
+DROP INDEX IF EXISTS idx_subscriptions_user_start_id;
@@ -0,0 +1,4 @@
+-- индексы под keyset-пагинацию листинга: сортировка всегда идёт по (колонка, id)
+CREATE INDEX IF NOT EXISTS idx_subscriptions_user_start_id ON subscriptions(user_id, start_date, id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_user_price_id ON subscriptions(user_id, price, id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_service_name_id ON subscriptions(service_name, id);
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS exchange_rates;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'subscriptions' AND column_name = 'currency') THEN
+        UPDATE subscriptions SET price = price / 100;
+        ALTER TABLE subscriptions ALTER COLUMN price TYPE INTEGER;
+        ALTER TABLE subscriptions DROP COLUMN currency;
+    END IF;
+END $$;
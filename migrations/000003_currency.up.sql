@@ -0,0 +1,23 @@
+-- валюта подписки и цены в минорных единицах.
+-- миграции накатываются при каждом старте compose, поэтому перевод цен в копейки сделан под проверкой
+-- наличия колонки currency - иначе каждый рестарт умножал бы цены на 100.
+-- API при этом цену в рублях не меняет: price в /api/v1 по-прежнему в целых единицах, минорные - в price_minor
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_name = 'subscriptions' AND column_name = 'currency') THEN
+        ALTER TABLE subscriptions ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'RUB'
+            CHECK (currency IN ('RUB', 'USD', 'EUR'));
+        ALTER TABLE subscriptions ALTER COLUMN price TYPE BIGINT;
+        UPDATE subscriptions SET price = price * 100;
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS exchange_rates(
+                                             currency CHAR(3) PRIMARY KEY,
+                                             rate NUMERIC(20, 8) NOT NULL CHECK (rate > 0),
+                                             updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- базовая валюта всегда 1, остальные курсы заливаются отдельно
+INSERT INTO exchange_rates(currency, rate) VALUES ('RUB', 1) ON CONFLICT (currency) DO NOTHING;
@@ -0,0 +1,3 @@
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_billing_period_check;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS billing_period_days;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS billing_period;
@@ -0,0 +1,13 @@
+-- период списания подписки, все существующие подписки помесячные
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS billing_period VARCHAR(16) NOT NULL DEFAULT 'monthly';
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS billing_period_days INTEGER;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'subscriptions_billing_period_check') THEN
+        ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_billing_period_check CHECK (
+            (billing_period IN ('weekly', 'monthly', 'quarterly', 'yearly') AND billing_period_days IS NULL)
+            OR (billing_period = 'custom' AND billing_period_days > 0)
+        );
+    END IF;
+END $$;
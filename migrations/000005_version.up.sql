@@ -0,0 +1,2 @@
+-- версия записи для оптимистичной блокировки, растёт на каждое изменение
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+-- мягкое удаление: строка с deleted_at лежит в корзине до очистки
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+-- частичный индекс под листинг корзины и фоновую очистку
+CREATE INDEX IF NOT EXISTS idx_subscriptions_deleted_at ON subscriptions(deleted_at) WHERE deleted_at IS NOT NULL;
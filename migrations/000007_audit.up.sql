@@ -0,0 +1,25 @@
+-- журнал изменений подписок: пишется в одной транзакции с изменением, без FK -
+-- записи должны пережить окончательное удаление подписки
+CREATE TABLE IF NOT EXISTS subscription_audit (
+    id              BIGSERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL,
+    actor           TEXT NOT NULL,
+    operation       TEXT NOT NULL,
+    changed_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    before          JSONB,
+    after           JSONB
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_audit_subscription_id ON subscription_audit(subscription_id, id);
+
+-- append-only: править и удалять записи журнала нельзя никому
+CREATE OR REPLACE FUNCTION subscription_audit_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'subscription_audit is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS subscription_audit_append_only ON subscription_audit;
+CREATE TRIGGER subscription_audit_append_only
+    BEFORE UPDATE OR DELETE ON subscription_audit
+    FOR EACH ROW EXECUTE FUNCTION subscription_audit_append_only();
@@ -0,0 +1,9 @@
+-- бакеты rate limiter'а, общие для всех реплик. UNLOGGED: после падения базы лимиты можно и потерять,
+-- зато запись на каждый запрос не идёт через WAL
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_buckets (
+    key        TEXT PRIMARY KEY,
+    tokens     DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_updated_at ON rate_limit_buckets(updated_at);
@@ -0,0 +1,15 @@
+-- ответы на запросы с Idempotency-Key: повтор с тем же ключом и телом отдаёт сохранённый ответ.
+-- status NULL - первый запрос ещё выполняется
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    owner            TEXT NOT NULL,
+    key              TEXT NOT NULL,
+    request_hash     TEXT NOT NULL,
+    status           INTEGER,
+    response_headers JSONB,
+    response_body    BYTEA,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at       TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (owner, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
@@ -0,0 +1,3 @@
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_no_overlap;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS overlap_allowed;
+DROP FUNCTION IF EXISTS subscription_period(DATE, DATE);
@@ -0,0 +1,37 @@
+-- btree_gist нужен, чтобы в одном gist-ограничении сравнивать на равенство uuid и строку
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS overlap_allowed BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- период подписки с включительными границами, бессрочная подписка - открытый справа диапазон.
+-- end_date раньше start_date сервис не пропускает, но на старых данных такое могло остаться
+CREATE OR REPLACE FUNCTION subscription_period(start_date DATE, end_date DATE) RETURNS daterange AS $$
+    SELECT daterange(start_date, CASE WHEN end_date < start_date THEN start_date ELSE end_date END, '[]')
+$$ LANGUAGE sql IMMUTABLE;
+
+-- уже существующие пересечения не трогаем, а помечаем как разрешённые - иначе ограничение не создастся.
+-- Найти их можно через /subscriptions/conflicts
+UPDATE subscriptions s
+SET overlap_allowed = TRUE
+WHERE s.deleted_at IS NULL
+  AND NOT s.overlap_allowed
+  AND EXISTS (
+      SELECT 1 FROM subscriptions o
+      WHERE o.id <> s.id
+        AND o.user_id = s.user_id
+        AND o.service_name = s.service_name
+        AND o.deleted_at IS NULL
+        AND subscription_period(o.start_date, o.end_date) && subscription_period(s.start_date, s.end_date)
+  );
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'subscriptions_no_overlap') THEN
+        ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_no_overlap
+            EXCLUDE USING gist (
+                user_id WITH =,
+                service_name WITH =,
+                subscription_period(start_date, end_date) WITH &&
+            ) WHERE (deleted_at IS NULL AND NOT overlap_allowed);
+    END IF;
+END $$;
@@ -0,0 +1,30 @@
+-- каталог сервисов: каноническое имя, цена по умолчанию и допустимый диапазон цен в валюте сервиса
+CREATE TABLE IF NOT EXISTS services (
+    id            SERIAL PRIMARY KEY,
+    name          VARCHAR NOT NULL,
+    default_price BIGINT NOT NULL DEFAULT 0 CHECK (default_price >= 0),
+    currency      CHAR(3) NOT NULL DEFAULT 'RUB' CHECK (currency IN ('RUB', 'USD', 'EUR')),
+    min_price     BIGINT CHECK (min_price >= 0),
+    max_price     BIGINT CHECK (max_price >= 0),
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT services_price_range CHECK (min_price IS NULL OR max_price IS NULL OR min_price <= max_price)
+);
+
+-- все написания сервиса: каноническое имя и алиасы. key - написание без учёта регистра и лишних пробелов,
+-- считается в Go (domain.ServiceKey), а не через lower(), который с локалью C не трогает кириллицу.
+-- Первичный ключ не даёт двум сервисам претендовать на одно написание
+CREATE TABLE IF NOT EXISTS service_names (
+    key        VARCHAR PRIMARY KEY,
+    service_id INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    name       VARCHAR NOT NULL,
+    canonical  BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS idx_service_names_service_id ON service_names(service_id);
+
+-- подписка ссылается на сервис каталога, если её название удалось сопоставить. Старые подписки
+-- привязываются при заведении сервиса в каталог, подписки на сервисы вне каталога остаются со свободным названием
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS service_id INTEGER REFERENCES services(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_service_id ON subscriptions(service_id);
@@ -0,0 +1,24 @@
+-- справочник пользователей. id - тот же uuid, что sub в токене и user_id в подписках
+CREATE TABLE IF NOT EXISTS users (
+    id         uuid PRIMARY KEY,
+    email      VARCHAR,
+    name       VARCHAR NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users(lower(email)) WHERE email IS NOT NULL;
+
+-- пользователи, у которых уже есть подписки, заводятся в справочнике без имени и почты - иначе внешний ключ не создать
+INSERT INTO users (id)
+SELECT DISTINCT user_id FROM subscriptions
+ON CONFLICT (id) DO NOTHING;
+
+-- удалить пользователя можно, только когда у него не осталось подписок, в том числе в корзине
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'subscriptions_user_id_fkey') THEN
+        ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_user_id_fkey
+            FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE RESTRICT;
+    END IF;
+END $$;
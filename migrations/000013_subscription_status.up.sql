@@ -0,0 +1,25 @@
+-- статус подписки меняется только действиями pause/resume/cancel/renew, существующие подписки считаются активными
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS status VARCHAR NOT NULL DEFAULT 'active';
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'subscriptions_status_check') THEN
+        ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_status_check
+            CHECK (status IN ('trial', 'active', 'paused', 'cancelled', 'expired'));
+    END IF;
+END $$;
+
+-- интервалы паузы [paused_on, resumed_on), у текущей паузы resumed_on пустой.
+-- Дни на паузе не входят в траты подписки
+CREATE TABLE IF NOT EXISTS subscription_pauses (
+    id              BIGSERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    paused_on       DATE NOT NULL,
+    resumed_on      DATE,
+    CONSTRAINT subscription_pauses_range CHECK (resumed_on IS NULL OR resumed_on >= paused_on)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_pauses_subscription_id ON subscription_pauses(subscription_id);
+
+-- открытая пауза у подписки может быть только одна
+CREATE UNIQUE INDEX IF NOT EXISTS idx_subscription_pauses_open ON subscription_pauses(subscription_id) WHERE resumed_on IS NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_subscriptions_expiring;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS auto_renew;
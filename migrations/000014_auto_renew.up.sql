@@ -0,0 +1,6 @@
+-- подписки с auto_renew воркер продлевает на расчётный период, остальные переводит в expired по прошествии end_date
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS auto_renew BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- воркер каждый проход ищет незакончившиеся подписки с end_date в прошлом или в ближайшие дни
+CREATE INDEX IF NOT EXISTS idx_subscriptions_expiring ON subscriptions(end_date)
+    WHERE deleted_at IS NULL AND end_date IS NOT NULL AND status <> 'expired';
@@ -0,0 +1,31 @@
+-- подписки на события: куда слать, чем подписывать тело и какие события нужны (пустой список - все)
+CREATE TABLE IF NOT EXISTS webhooks (
+    id         SERIAL PRIMARY KEY,
+    url        VARCHAR NOT NULL,
+    secret     VARCHAR NOT NULL,
+    events     JSONB NOT NULL DEFAULT '[]',
+    active     BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- очередь доставок: по строке на событие и вебхук. pending ждёт next_attempt_at, после последней
+-- неудачной попытки строка становится dead и лежит в dead-letter списке до replay
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              BIGSERIAL PRIMARY KEY,
+    webhook_id      INT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id        uuid NOT NULL,
+    event           VARCHAR NOT NULL,
+    payload         JSONB NOT NULL,
+    status          VARCHAR NOT NULL DEFAULT 'pending',
+    attempts        INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error      TEXT,
+    last_status     INT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at    TIMESTAMPTZ,
+    CONSTRAINT webhook_deliveries_status_check CHECK (status IN ('pending', 'delivered', 'dead'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_dead ON webhook_deliveries(id) WHERE status = 'dead';
@@ -0,0 +1,20 @@
+-- transactional outbox: событие пишется в той же транзакции, что и изменение подписки, а релей потом
+-- отдаёт его публикатору. event_id - ключ дедупликации для получателей: доставка at-least-once
+CREATE TABLE IF NOT EXISTS outbox (
+    id              BIGSERIAL PRIMARY KEY,
+    event_id        uuid NOT NULL,
+    event           VARCHAR NOT NULL,
+    subscription_id INT NOT NULL,
+    payload         JSONB NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    published_at    TIMESTAMPTZ,
+    attempts        INT NOT NULL DEFAULT 0,
+    last_error      TEXT
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_event_id ON outbox(event_id);
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_published ON outbox(published_at) WHERE published_at IS NOT NULL;
+
+-- релей может отдать одно событие дважды, вебхуку оно всё равно доставляется один раз
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_event ON webhook_deliveries(webhook_id, event_id);
@@ -0,0 +1,8 @@
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_no_overlap;
+ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_no_overlap
+    EXCLUDE USING gist (
+        user_id WITH =,
+        service_name WITH =,
+        subscription_period(start_date, end_date) WITH &&
+    ) WHERE (deleted_at IS NULL AND NOT overlap_allowed);
+DROP FUNCTION IF EXISTS subscription_service_key(INTEGER, VARCHAR);
@@ -0,0 +1,40 @@
+-- "тот же сервис" для пересечений: сервис каталога, если подписка к нему привязана, иначе название.
+-- Одно определение на всех: поиск пересечений, список конфликтов и ограничение subscriptions_no_overlap.
+-- Раньше поиск сравнивал ещё и service_id, а ограничение и конфликты - только название, и подписки
+-- под разными написаниями одного сервиса каталога база пропускала
+CREATE OR REPLACE FUNCTION subscription_service_key(service_id INTEGER, service_name VARCHAR) RETURNS TEXT AS $$
+    SELECT CASE WHEN service_id IS NOT NULL THEN 'id:' || service_id ELSE 'name:' || service_name END
+$$ LANGUAGE sql IMMUTABLE;
+
+-- миграции прогоняются на каждом старте, поэтому ограничение пересоздаётся, только пока оно ещё по названию
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'subscriptions_no_overlap'
+          AND pg_get_constraintdef(oid) LIKE '%subscription_service_key%'
+    ) THEN
+        -- пересечения, которые появились от смены ключа, помечаем как разрешённые, как и в 000010 -
+        -- иначе ограничение не создастся. Найти их можно через /subscriptions/conflicts
+        UPDATE subscriptions s
+        SET overlap_allowed = TRUE
+        WHERE s.deleted_at IS NULL
+          AND NOT s.overlap_allowed
+          AND EXISTS (
+              SELECT 1 FROM subscriptions o
+              WHERE o.id <> s.id
+                AND o.user_id = s.user_id
+                AND subscription_service_key(o.service_id, o.service_name) = subscription_service_key(s.service_id, s.service_name)
+                AND o.deleted_at IS NULL
+                AND subscription_period(o.start_date, o.end_date) && subscription_period(s.start_date, s.end_date)
+          );
+
+        ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_no_overlap;
+        ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_no_overlap
+            EXCLUDE USING gist (
+                user_id WITH =,
+                subscription_service_key(service_id, service_name) WITH =,
+                subscription_period(start_date, end_date) WITH &&
+            ) WHERE (deleted_at IS NULL AND NOT overlap_allowed);
+    END IF;
+END $$;